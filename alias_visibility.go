@@ -0,0 +1,89 @@
+package vfilter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// SELECT aliases are not visible to the other columns of the same
+// SELECT. The WHERE clause sees them unless disabled by
+// scope.SetWhereAliases(false). References to aliases which are not
+// visible silently resolve to a source column (or NULL) so we
+// diagnose them ahead of time.
+//
+// Returns a list of problems found in the query.
+func (self *VQL) CheckAliases(scope types.Scope) []string {
+	query := self.Query
+	if query == nil {
+		query = self.StoredQuery
+	}
+	if query == nil || query.SelectExpression == nil {
+		return nil
+	}
+
+	var result []string
+
+	aliases := make(map[string]bool)
+	for _, expr := range query.SelectExpression.Expressions {
+		if expr.As != "" {
+			aliases[expr.GetName(scope)] = true
+		}
+	}
+
+	for _, expr := range query.SelectExpression.Expressions {
+		if expr.Expression == nil {
+			continue
+		}
+
+		name := expr.GetName(scope)
+		for _, symbol := range symbolReferences(expr.Expression) {
+			// Referring to a source column of the same name
+			// is fine (e.g. SELECT X + 1 AS X).
+			if symbol == name || !aliases[symbol] {
+				continue
+			}
+			result = append(result, fmt.Sprintf(
+				"Column %v refers to alias %v which is not visible "+
+					"to other columns", name, symbol))
+		}
+	}
+
+	if query.Where != nil && !scope.WhereAliases() {
+		for _, symbol := range symbolReferences(query.Where) {
+			if aliases[symbol] {
+				result = append(result, fmt.Sprintf(
+					"WHERE clause refers to alias %v but WHERE aliases "+
+						"are disabled", symbol))
+			}
+		}
+	}
+
+	return result
+}
+
+// Return the variables referred to by an expression (in order of
+// appearance, without duplicates).
+func symbolReferences(node interface{}) []string {
+	var result []string
+	seen := make(map[string]bool)
+
+	walkAST(reflect.ValueOf(node), func(node interface{}) {
+		symbol, ok := node.(*_SymbolRef)
+		if !ok || symbol.Called {
+			return
+		}
+
+		name := utils.Unquote_ident(
+			strings.SplitN(symbol.Symbol, ".", 2)[0])
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	})
+
+	return result
+}
@@ -0,0 +1,68 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhereAliases(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	run := func(query string) []Row {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []Row
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	shadowing := "SELECT _value * 10 AS _value FROM foreach(row=[1, 2, 3]) WHERE _value = 2"
+	alias := "SELECT _value * 10 AS X FROM foreach(row=[1, 2, 3]) WHERE X = 20"
+	group_by := "SELECT _value * 10 AS X, count() AS Count FROM foreach(row=[1, 2, 3]) WHERE X = 20 GROUP BY X"
+
+	// By default WHERE sees the aliases.
+	assert.True(t, scope.WhereAliases())
+	assert.Equal(t, 0, len(run(shadowing)))
+	assert.Equal(t, 1, len(run(alias)))
+	assert.Equal(t, 1, len(run(group_by)))
+
+	// Otherwise only the source columns.
+	scope.SetWhereAliases(false)
+	rows := run(shadowing)
+	assert.Equal(t, 1, len(rows))
+	value, _ := scope.Associative(rows[0], "_value")
+	assert.Equal(t, int64(20), value)
+
+	assert.Equal(t, 0, len(run(alias)))
+	assert.Equal(t, 0, len(run(group_by)))
+}
+
+func TestCheckAliases(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	check := func(query string) []string {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+		return vql.CheckAliases(scope)
+	}
+
+	assert.Equal(t, 0, len(check("SELECT X + 1 AS X, Y FROM scope() WHERE X > 1")))
+
+	assert.Equal(t, []string{
+		"Column A refers to alias B which is not visible to other columns",
+	}, check("SELECT B.Foo + 1 AS A, 2 AS B FROM scope()"))
+
+	assert.Equal(t, 0, len(check("SELECT 2 AS B FROM scope() WHERE B = 2")))
+
+	scope.SetWhereAliases(false)
+	assert.Equal(t, []string{
+		"WHERE clause refers to alias B but WHERE aliases are disabled",
+	}, check("SELECT 2 AS B FROM scope() WHERE B = 2"))
+}
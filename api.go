@@ -2,8 +2,12 @@ package vfilter
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"time"
 
+	"github.com/Velocidex/ordereddict"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils/dict"
 )
@@ -122,4 +126,415 @@ func OutputJSON(
 	return s, err
 }
 
+// Limits applied to OutputJSONWithOptions. A zero value means no
+// limit.
+type OutputOptions struct {
+	// Maximum number of rows to serialize.
+	MaxRows int
+
+	// Maximum size of the serialized rows in bytes. The size is
+	// estimated by encoding each row separately.
+	MaxBytes int
+
+	// Append a footer record with a SHA256 hash of the rows (and a
+	// signature by the scope's ResultSigner if set). See
+	// VerifyResultHash() and VerifyEncodedResultHash().
+	Hash bool
+
+	// Compress the output of OutputJSONLWithOptions: "gzip" or a
+	// name registered with RegisterCompressor().
+	Compression string
+
+	// How often to flush compressed output so readers of long
+	// lived streams see rows promptly (default 1 second).
+	FlushInterval time.Duration
+}
+
+// Like OutputJSON but stops the query when any of the limits in
+// options is reached. The returned bool is true if the result was
+// truncated.
+func OutputJSONWithOptions(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	encoder RowEncoder,
+	options OutputOptions) ([]byte, bool, error) {
+	result, truncated, err := collectRows(vql, ctx, scope, encoder, options)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if options.Hash {
+		// Hash the rows as the encoder serializes them.
+		encoded, err := encoder(result)
+		if err != nil {
+			return nil, false, err
+		}
+
+		hasher := newResultHasher()
+		hasher.WriteEncoded(encoded, len(result))
+
+		footer, err := hasher.Footer(scope)
+		if err != nil {
+			return nil, false, err
+		}
+		result = append(result, footer)
+	}
+
+	s, err := encoder(result)
+	return s, truncated, err
+}
+
+// Like OutputJSON but stops after max_rows rows or max_bytes bytes
+// (0 means no limit). When the output is truncated a marker object
+// is appended as the last row so consumers of the raw output can
+// tell the result is incomplete.
+func OutputJSONWithLimits(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	encoder RowEncoder,
+	max_rows, max_bytes int) ([]byte, error) {
+	result, truncated, err := collectRows(vql, ctx, scope, encoder,
+		OutputOptions{MaxRows: max_rows, MaxBytes: max_bytes})
+	if err != nil {
+		return nil, err
+	}
+
+	if truncated {
+		result = append(result, ordereddict.NewDict().
+			Set("_truncated", true).
+			Set("_rows", len(result)))
+	}
+
+	return encoder(result)
+}
+
+func collectRows(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	encoder RowEncoder,
+	options OutputOptions) ([]Row, bool, error) {
+	sub_ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	output_chan := vql.Eval(sub_ctx, scope)
+	result := []Row{}
+	total_bytes := 0
+	truncated := false
+
+	for row := range output_chan {
+		if options.MaxRows > 0 && len(result) >= options.MaxRows {
+			truncated = true
+			break
+		}
+
+		value := dict.RowToDict(sub_ctx, scope, row)
+		if options.MaxBytes > 0 {
+			serialized, err := encoder([]Row{value})
+			if err != nil {
+				cancel()
+				for range output_chan {
+				}
+				return nil, false, err
+			}
+
+			total_bytes += len(serialized)
+			if total_bytes > options.MaxBytes {
+				truncated = true
+				break
+			}
+		}
+		result = append(result, value)
+
+		// Throttle if needed.
+		scope.ChargeOp()
+	}
+
+	if truncated {
+		// Abort the query and drain the channel so the query
+		// goroutines can exit.
+		cancel()
+		for range output_chan {
+		}
+	}
+
+	return result, truncated, nil
+}
+
+// A convenience function to stream JSONL output from a VQL query.
+// Unlike OutputJSON, each row is written to the writer as a separate
+// line as soon as it is produced so memory use remains flat for large
+// result sets.
+func OutputJSONL(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	w io.Writer) error {
+	// json.Encoder terminates each row with a new line.
+	encoder := json.NewEncoder(w)
+	return vql.EvalWithCallback(ctx, scope, func(row Row) error {
+		return encoder.Encode(row)
+	})
+}
+
+var errOutputLimit = errors.New("Output limit reached")
+
+// Like OutputJSONL but stops the query when any of the limits in
+// options is reached. The returned bool is true if the result was
+// truncated.
+func OutputJSONLWithOptions(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	w io.Writer,
+	options OutputOptions) (bool, error) {
+	if options.Compression == "" {
+		return outputJSONL(vql, ctx, scope, w, options)
+	}
+
+	compressed, err := newCompressedWriter(
+		w, options.Compression, options.FlushInterval)
+	if err != nil {
+		return false, err
+	}
+
+	truncated, err := outputJSONL(vql, ctx, scope, compressed, options)
+	close_err := compressed.Close()
+	if err == nil {
+		err = close_err
+	}
+	return truncated, err
+}
+
+func outputJSONL(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	w io.Writer,
+	options OutputOptions) (bool, error) {
+	hasher := newResultHasher()
+	total_bytes := 0
+
+	err := vql.EvalWithCallback(ctx, scope, func(row Row) error {
+		if options.MaxRows > 0 && hasher.rows >= options.MaxRows {
+			return errOutputLimit
+		}
+
+		line, err := jsonLine(row)
+		if err != nil {
+			return err
+		}
+
+		total_bytes += len(line)
+		if options.MaxBytes > 0 && total_bytes > options.MaxBytes {
+			return errOutputLimit
+		}
+
+		hasher.Write(line)
+		_, err = w.Write(line)
+		return err
+	})
+
+	truncated := errors.Is(err, errOutputLimit)
+	if err != nil && !truncated {
+		return false, err
+	}
+
+	if options.Hash {
+		footer, err := hasher.Footer(scope)
+		if err != nil {
+			return truncated, err
+		}
+
+		err = json.NewEncoder(w).Encode(footer)
+		if err != nil {
+			return truncated, err
+		}
+	}
+
+	return truncated, nil
+}
+
+// Evaluate the query and deliver each fully materialized row to the
+// callback. If the callback returns an error the query is aborted
+// and the error is returned.
+func (self *VQL) EvalWithCallback(
+	ctx context.Context,
+	scope types.Scope,
+	callback func(row Row) error) error {
+	sub_ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub_ctx, cancel_limits := withQueryLimits(sub_ctx, scope)
+	defer cancel_limits()
+
+	output_chan := self.Eval(sub_ctx, scope)
+	for row := range output_chan {
+		value := dict.RowToDict(sub_ctx, scope, row)
+		err := callback(value)
+		if err != nil {
+			// Abort the query and drain the channel so the
+			// query goroutines can exit.
+			cancel()
+			for range output_chan {
+			}
+			return err
+		}
+
+		// Throttle if needed.
+		scope.ChargeOp()
+	}
+
+	return checkQueryLimits(sub_ctx, scope)
+}
+
+// Evaluate the query like Eval() but also return a channel which
+// receives the error the query was aborted with, if any (e.g. errors
+// in strict mode or exceeding the memory quota). The error is sent
+// before the row channel is closed.
+func (self *VQL) EvalWithErrors(
+	ctx context.Context,
+	scope types.Scope) (<-chan Row, <-chan error) {
+	output_chan := make(chan Row)
+	error_chan := make(chan error, 1)
+
+	sub_ctx, cancel := withQueryLimits(ctx, scope)
+
+	go func() {
+		defer close(error_chan)
+		defer close(output_chan)
+		defer cancel()
+
+		for row := range self.Eval(sub_ctx, scope) {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+
+		err := checkQueryLimits(sub_ctx, scope)
+		if err != nil {
+			error_chan <- err
+		}
+	}()
+
+	return output_chan, error_chan
+}
+
+// Evaluate the query and deliver materialized rows to the callback in
+// batches of up to batch_size rows. The query does not progress while
+// the callback is running. If the callback returns an error the
+// query is aborted and the error is returned.
+func EvalWithBatchCallback(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	batch_size int,
+	callback func(rows []Row) error) error {
+	if batch_size <= 0 {
+		batch_size = 1
+	}
+
+	batch := make([]Row, 0, batch_size)
+	err := vql.EvalWithCallback(ctx, scope, func(row Row) error {
+		batch = append(batch, row)
+		if len(batch) < batch_size {
+			return nil
+		}
+
+		err := callback(batch)
+		batch = make([]Row, 0, batch_size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Send the last batch outstanding.
+	if len(batch) > 0 {
+		return callback(batch)
+	}
+	return nil
+}
+
+// Like EvalWithCallback but first delivers the query's schema (an
+// ordered mapping of column name to type name) to schema_callback
+// before any rows. Column types are inferred with TypeCheck(). When
+// the columns can not be determined up front (e.g. SELECT * from a
+// plugin without a RowType) the schema is inferred from the first
+// row instead.
+func (self *VQL) EvalWithSchemaCallback(
+	ctx context.Context,
+	scope types.Scope,
+	schema_callback func(schema *ordereddict.Dict) error,
+	callback func(row Row) error) error {
+	schema := self.TypeCheck(scope, nil)
+	_, has_wildcard := schema.Get("*")
+	sent := !has_wildcard
+	if sent {
+		err := schema_callback(schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := self.EvalWithCallback(ctx, scope, func(row Row) error {
+		if !sent {
+			sent = true
+			err := schema_callback(rowSchema(scope, row, schema))
+			if err != nil {
+				return err
+			}
+		}
+		return callback(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	// No rows were produced but we still send what we know.
+	if !sent {
+		return schema_callback(schema)
+	}
+	return nil
+}
+
+// Build the schema from the row, preferring the inferred types when
+// they are known.
+func rowSchema(scope types.Scope, row Row, inferred *ordereddict.Dict) *ordereddict.Dict {
+	checker := &typeChecker{scope: scope}
+	result := ordereddict.NewDict()
+	for _, member := range scope.GetMembers(row) {
+		type_name, pres := inferred.GetString(member)
+		if !pres || type_name == UnknownType {
+			value, _ := scope.Associative(row, member)
+			type_name = checker.typeName(value)
+		}
+		result.Set(member, type_name)
+	}
+	return result
+}
+
+// Like OutputJSONL but the first line is a schema header of the form
+// {"_schema": {"Column": "Type"}} so clients can prepare for the
+// rows before they arrive.
+func OutputJSONLWithSchema(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return vql.EvalWithSchemaCallback(ctx, scope,
+		func(schema *ordereddict.Dict) error {
+			return encoder.Encode(ordereddict.NewDict().
+				Set("_schema", schema))
+		},
+		func(row Row) error {
+			return encoder.Encode(row)
+		})
+}
+
 type Empty struct{}
@@ -1,8 +1,11 @@
 package vfilter
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/Velocidex/ordereddict"
@@ -51,6 +54,106 @@ func TestAPIGetResponseChannel(t *testing.T) {
 		golden.Set("OutputJSON", string(serialized))
 	}
 
+	{
+		// Only the first 2 rows should be serialized.
+		serialized, truncated, err := OutputJSONWithOptions(
+			vql, ctx, scope, marshal_indent, OutputOptions{MaxRows: 2})
+		assert.NoError(t, err)
+		assert.True(t, truncated)
+		golden.Set("OutputJSONWithOptions_MaxRows", string(serialized))
+
+		// Each row is about 30 bytes so only one fits.
+		serialized, truncated, err = OutputJSONWithOptions(
+			vql, ctx, scope, marshal_indent, OutputOptions{MaxBytes: 40})
+		assert.NoError(t, err)
+		assert.True(t, truncated)
+		golden.Set("OutputJSONWithOptions_MaxBytes", string(serialized))
+
+		// Limits not reached.
+		_, truncated, err = OutputJSONWithOptions(
+			vql, ctx, scope, marshal_indent, OutputOptions{MaxRows: 3})
+		assert.NoError(t, err)
+		assert.False(t, truncated)
+	}
+
+	{
+		// A truncation marker is appended to truncated output.
+		serialized, err := OutputJSONWithLimits(
+			vql, ctx, scope, marshal_indent, 2, 0)
+		assert.NoError(t, err)
+		golden.Set("OutputJSONWithLimits", string(serialized))
+	}
+
+	{
+		// OutputJSONL writes each row on its own line.
+		buf := &bytes.Buffer{}
+		err := OutputJSONL(vql, ctx, scope, buf)
+		assert.NoError(t, err)
+		golden.Set("OutputJSONL", strings.Split(
+			strings.TrimSpace(buf.String()), "\n"))
+	}
+
+	{
+		// EvalWithCallback delivers materialized rows.
+		rows := []Row{}
+		err := vql.EvalWithCallback(ctx, scope, func(row Row) error {
+			rows = append(rows, row)
+			return nil
+		})
+		assert.NoError(t, err)
+		golden.Set("EvalWithCallback", rows)
+
+		// Returning an error aborts the query.
+		rows = nil
+		err = vql.EvalWithCallback(ctx, scope, func(row Row) error {
+			rows = append(rows, row)
+			return errors.New("Stop")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, len(rows))
+	}
+
+	{
+		// Batches of 2 rows - the last batch is short.
+		batches := [][]Row{}
+		err := EvalWithBatchCallback(vql, ctx, scope, 2,
+			func(rows []Row) error {
+				batches = append(batches, rows)
+				return nil
+			})
+		assert.NoError(t, err)
+		golden.Set("EvalWithBatchCallback", batches)
+
+		// Returning an error aborts the query.
+		batches = nil
+		err = EvalWithBatchCallback(vql, ctx, scope, 2,
+			func(rows []Row) error {
+				batches = append(batches, rows)
+				return errors.New("Stop")
+			})
+		assert.Error(t, err)
+		assert.Equal(t, 1, len(batches))
+	}
+
+	{
+		// The schema is inferred from the first row for SELECT *
+		buf := &bytes.Buffer{}
+		err := OutputJSONLWithSchema(vql, ctx, scope, buf)
+		assert.NoError(t, err)
+		golden.Set("OutputJSONLWithSchema", strings.Split(
+			strings.TrimSpace(buf.String()), "\n"))
+
+		// The schema is known before the query runs.
+		typed_vql, err := Parse("SELECT foo + 1 AS Foo, 'x' AS X FROM test() WHERE FALSE")
+		assert.NoError(t, err)
+
+		buf = &bytes.Buffer{}
+		err = OutputJSONLWithSchema(typed_vql, ctx, scope, buf)
+		assert.NoError(t, err)
+		golden.Set("OutputJSONLWithSchema_NoRows", strings.Split(
+			strings.TrimSpace(buf.String()), "\n"))
+	}
+
 	g := goldie.New(
 		t,
 		goldie.WithFixtureDir("fixtures"),
@@ -27,7 +27,12 @@ import (
 // err := vfilter.ExtractArgs(scope, args, myarg)
 
 // We will raise an error if a required field is missing or has the
-// wrong type of args.
+// wrong type of args. The error is an *ArgError listing all the
+// missing, unexpected and invalid args.
+
+// Optional fields may declare a default value which is used when the
+// arg is not given:
+//    Count int64 `vfilter:"optional,field=count,default=10"`
 
 // NOTE: In order for the field to be populated by this function, the
 // field must be exported (i.e. name begins with cap) and it must have
@@ -48,6 +53,9 @@ func ExtractArgs(scope types.Scope, args *ordereddict.Dict, target interface{})
 
 	err = parser.Parse(context.Background(), scope, args, v)
 	scope.Explainer().ParseArgs(args, target, err)
+	if err != nil {
+		scope.ReportError(fmt.Errorf("%w: %v", types.ErrArgParse, err))
+	}
 	return err
 }
 
@@ -66,6 +74,9 @@ func ExtractArgsWithContext(
 
 	err = parser.Parse(ctx, scope, args, v)
 	scope.Explainer().ParseArgs(args, target, err)
+	if err != nil {
+		scope.ReportError(fmt.Errorf("%w: %v", types.ErrArgParse, err))
+	}
 	return err
 }
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Velocidex/ordereddict"
 	"github.com/alecthomas/assert"
@@ -248,6 +250,254 @@ func TestArgParsingDoesNotOverrideDefaults(t *testing.T) {
 	assert.Equal(t, 5, arg.Int)
 }
 
+type defaultArgs struct {
+	Name   string  `vfilter:"optional,field=name,default=hello world"`
+	Count  int64   `vfilter:"optional,field=count,default=10"`
+	Ratio  float64 `vfilter:"optional,field=ratio,default=0.5"`
+	Enable bool    `vfilter:"optional,field=enable,default=true"`
+	Query  string  `vfilter:"optional,field=query,default=SELECT * FROM x WHERE a=b"`
+	Other  int64   `vfilter:"optional,field=other"`
+}
+
+func TestArgParsingDefaults(t *testing.T) {
+	scope := makeTestScope()
+	ctx := context.Background()
+
+	arg := defaultArgs{}
+	err := arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().Set("count", 2), &arg)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultArgs{
+		Name:   "hello world",
+		Count:  2,
+		Ratio:  0.5,
+		Enable: true,
+		Query:  "SELECT * FROM x WHERE a=b",
+	}, arg)
+
+	// Bad defaults are reported.
+	bad := struct {
+		Count int64 `vfilter:"optional,field=count,default=ten"`
+	}{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &bad)
+	assert.Error(t, err)
+}
+
+func TestArgParsingReportsAllErrors(t *testing.T) {
+	scope := makeTestScope()
+	ctx := context.Background()
+
+	arg := struct {
+		A int64  `vfilter:"required,field=a"`
+		B string `vfilter:"required,field=b"`
+		C int64  `vfilter:"optional,field=c"`
+	}{}
+	err := arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("c", "not a number").
+			Set("x", 1).
+			Set("y", 2), &arg)
+
+	arg_error, ok := err.(*arg_parser.ArgError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, arg_error.Missing)
+	assert.Equal(t, []string{"x", "y"}, arg_error.Unexpected)
+	assert.Equal(t, 1, len(arg_error.Invalid))
+	assert.Equal(t, "Fields a, b are required; Unexpected args x, y; "+
+		"Field c Should be an int not string.", err.Error())
+}
+
+type validatedArgs struct {
+	Mode  string   `vfilter:"optional,field=mode,choices=read|write"`
+	Modes []string `vfilter:"optional,field=modes,choices=read|write"`
+	Count int64    `vfilter:"optional,field=count,min=1,max=100,default=10"`
+	Ratio float64  `vfilter:"optional,field=ratio,min=0,max=1"`
+	Name  string   `vfilter:"optional,field=name,regex=^[a-z_]+$"`
+}
+
+func TestArgParsingValidation(t *testing.T) {
+	scope := makeTestScope()
+	ctx := context.Background()
+
+	arg := validatedArgs{}
+	err := arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("mode", "read").
+			Set("modes", []string{"read", "write"}).
+			Set("ratio", 0.5).
+			Set("name", "foo_bar"), &arg)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), arg.Count)
+
+	arg = validatedArgs{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("mode", "delete").
+			Set("modes", []string{"read", "exec"}).
+			Set("count", 0).
+			Set("ratio", 1.5).
+			Set("name", "Foo"), &arg)
+	assert.Equal(t, "Field mode should be one of read, write, not \"delete\"; "+
+		"Field modes should be one of read, write, not \"exec\"; "+
+		"Field count should be at least 1, not 0; "+
+		"Field ratio should be at most 1, not 1.5; "+
+		"Field name should match ^[a-z_]+$, not \"Foo\"", err.Error())
+
+	// Invalid values are not set.
+	assert.Equal(t, "", arg.Mode)
+
+	// Validators must suit the field type.
+	bad := struct {
+		Count int64 `vfilter:"optional,field=count,choices=1|2"`
+	}{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &bad)
+	assert.Error(t, err)
+
+	// And defaults must be valid.
+	bad_default := struct {
+		Count int64 `vfilter:"optional,field=count,min=1,default=0"`
+	}{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &bad_default)
+	assert.Error(t, err)
+}
+
+type richArgs struct {
+	Time     time.Time           `vfilter:"optional,field=time"`
+	Duration time.Duration       `vfilter:"optional,field=duration,default=5m"`
+	IP       net.IP              `vfilter:"optional,field=ip"`
+	CIDR     *net.IPNet          `vfilter:"optional,field=cidr"`
+	Size     arg_parser.ByteSize `vfilter:"optional,field=size,default=1KiB"`
+}
+
+func TestArgParsingRichTypes(t *testing.T) {
+	scope := makeTestScope()
+	ctx := context.Background()
+
+	arg := richArgs{}
+	err := arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &arg)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, arg.Duration)
+	assert.Equal(t, arg_parser.ByteSize(1024), arg.Size)
+
+	for _, test_case := range []struct {
+		args     *ordereddict.Dict
+		expected string
+	}{
+		{ordereddict.NewDict().Set("time", "2021-03-04T05:06:07Z"),
+			"2021-03-04T05:06:07Z"},
+		{ordereddict.NewDict().Set("time", 1614834367),
+			"2021-03-04T05:06:07Z"},
+		{ordereddict.NewDict().Set("time", int64(1614834367000)),
+			"2021-03-04T05:06:07Z"},
+	} {
+		arg := richArgs{}
+		err := arg_parser.ExtractArgsWithContext(ctx, scope, test_case.args, &arg)
+		assert.NoError(t, err)
+		assert.Equal(t, test_case.expected, arg.Time.UTC().Format(time.RFC3339))
+	}
+
+	arg = richArgs{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("duration", "1h30m").
+			Set("ip", "192.168.1.1").
+			Set("cidr", "10.0.0.0/8").
+			Set("size", "10MB"), &arg)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, arg.Duration)
+	assert.Equal(t, "192.168.1.1", arg.IP.String())
+	assert.Equal(t, "10.0.0.0/8", arg.CIDR.String())
+	assert.True(t, arg.CIDR.Contains(net.ParseIP("10.1.2.3")))
+	assert.Equal(t, arg_parser.ByteSize(10000000), arg.Size)
+
+	// Numbers are seconds and a single IP is a network of one.
+	arg = richArgs{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("duration", 90).
+			Set("cidr", "192.168.1.1").
+			Set("size", 100), &arg)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, arg.Duration)
+	assert.Equal(t, "192.168.1.1/32", arg.CIDR.String())
+	assert.Equal(t, arg_parser.ByteSize(100), arg.Size)
+
+	// Bad values are reported.
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict().
+			Set("time", "yesterday").
+			Set("duration", "soon").
+			Set("ip", "1.2.3").
+			Set("cidr", "10.0.0.0/99").
+			Set("size", "10XB"), &richArgs{})
+	arg_error, ok := err.(*arg_parser.ArgError)
+	assert.True(t, ok)
+	assert.Equal(t, 5, len(arg_error.Invalid))
+}
+
+type materializeArgs struct {
+	Lazy   types.Any `vfilter:"optional,field=lazy,materialize=lazy"`
+	Reduce types.Any `vfilter:"optional,field=reduce,materialize=reduce"`
+	Query  types.Any `vfilter:"optional,field=query,materialize=query"`
+	Rows   types.Any `vfilter:"optional,field=rows,materialize=rows"`
+}
+
+func TestArgParsingMaterialize(t *testing.T) {
+	scope := makeTestScope()
+	ctx := context.Background()
+
+	vql, err := vfilter.Parse("SELECT * FROM foreach(row=[1, 2])")
+	assert.NoError(t, err)
+
+	for _, value := range []types.Any{vql, int64(5)} {
+		args := ordereddict.NewDict()
+		for _, name := range []string{"lazy", "reduce", "query", "rows"} {
+			args.Set(name, arg_parser.ToLazyExpr(scope, value))
+		}
+
+		arg := &materializeArgs{}
+		err = arg_parser.ExtractArgsWithContext(ctx, scope, args, arg)
+		assert.NoError(t, err)
+
+		_, ok := arg.Lazy.(types.LazyExpr)
+		assert.True(t, ok)
+
+		_, ok = arg.Query.(types.StoredQuery)
+		assert.True(t, ok)
+
+		rows, ok := arg.Rows.([]types.Row)
+		assert.True(t, ok)
+
+		if value == vql {
+			_, ok = arg.Reduce.(types.StoredQuery)
+			assert.True(t, ok)
+			assert.Equal(t, 2, len(rows))
+		} else {
+			assert.Equal(t, int64(5), arg.Reduce)
+			assert.Equal(t, 1, len(rows))
+		}
+	}
+
+	// Only types.Any fields may be materialized.
+	bad := struct {
+		Count int64 `vfilter:"optional,field=count,materialize=rows"`
+	}{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &bad)
+	assert.Error(t, err)
+
+	bad_mode := struct {
+		Any types.Any `vfilter:"optional,field=any,materialize=maybe"`
+	}{}
+	err = arg_parser.ExtractArgsWithContext(ctx, scope,
+		ordereddict.NewDict(), &bad_mode)
+	assert.Error(t, err)
+}
+
 func TestArgParsing(t *testing.T) {
 	// Store the result in ordered dict so we have a consistent golden file.
 	result := ordereddict.NewDict()
@@ -0,0 +1,56 @@
+package arg_parser
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// types.Any fields may be tagged with materialize= to control how
+// lazy args and stored queries are delivered:
+//
+//	materialize=lazy    Always a types.LazyExpr for the plugin to reduce.
+//	materialize=reduce  Reduced to a value. Stored queries are
+//	                    left as types.StoredQuery (the default).
+//	materialize=query   Always a types.StoredQuery. Other values are
+//	                    wrapped so they yield rows.
+//	materialize=rows    Stored queries are expanded into []types.Row.
+//	                    Arrays become rows and other values a single
+//	                    row.
+var materializeParsers = map[string]ParserDipatcher{
+	"lazy":   lazyMaterializeParser,
+	"reduce": anyParser,
+	"query":  storedQueryParser,
+	"rows":   rowsMaterializeParser,
+}
+
+func getMaterializeParser(
+	field_type reflect.Type, mode string) (ParserDipatcher, error) {
+	if field_type != anyType {
+		return nil, fmt.Errorf("materialize= only applies to types.Any fields")
+	}
+
+	parser, pres := materializeParsers[mode]
+	if !pres {
+		return nil, fmt.Errorf("Unknown materialize mode %v", mode)
+	}
+	return parser, nil
+}
+
+func lazyMaterializeParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	return ToLazyExpr(scope, arg), nil
+}
+
+func rowsMaterializeParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.ReduceWithScope(ctx, scope)
+	}
+
+	return types.Materialize(ctx, scope, ToStoredQuery(ctx, arg)), nil
+}
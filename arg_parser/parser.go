@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -47,6 +48,55 @@ type FieldParser struct {
 	FieldIdx int
 	Required bool
 	Parser   ParserDipatcher
+
+	// Set from the default= tag when the arg is not given.
+	Default *reflect.Value
+
+	// Checks from the choices=, min=, max= and regex= tags.
+	Validators []Validator
+}
+
+// Describes everything wrong with the args passed to a function or
+// plugin so the user can fix them all at once.
+type ArgError struct {
+	Missing    []string
+	Unexpected []string
+	Invalid    []error
+}
+
+func (self *ArgError) Error() string {
+	var messages []string
+
+	switch len(self.Missing) {
+	case 0:
+	case 1:
+		messages = append(messages, fmt.Sprintf(
+			"Field %s is required", self.Missing[0]))
+	default:
+		messages = append(messages, fmt.Sprintf(
+			"Fields %s are required", strings.Join(self.Missing, ", ")))
+	}
+
+	switch len(self.Unexpected) {
+	case 0:
+	case 1:
+		messages = append(messages, fmt.Sprintf(
+			"Unexpected arg %v", self.Unexpected[0]))
+	default:
+		messages = append(messages, fmt.Sprintf(
+			"Unexpected args %v", strings.Join(self.Unexpected, ", ")))
+	}
+
+	for _, err := range self.Invalid {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (self *ArgError) empty() bool {
+	return len(self.Missing) == 0 && len(self.Unexpected) == 0 &&
+		len(self.Invalid) == 0
 }
 
 type Parser struct {
@@ -56,12 +106,16 @@ type Parser struct {
 func (self *Parser) Parse(
 	ctx context.Context, scope types.Scope, args *ordereddict.Dict, target reflect.Value) error {
 	parsed := make([]string, 0, args.Len())
+	arg_error := &ArgError{}
 
 	for _, parser := range self.Fields {
 		value, pres := args.Get(parser.Field)
 		if !pres {
 			if parser.Required {
-				return fmt.Errorf("Field %s is required", parser.Field)
+				arg_error.Missing = append(arg_error.Missing, parser.Field)
+
+			} else if parser.Default != nil {
+				target.Field(parser.FieldIdx).Set(*parser.Default)
 			}
 			continue
 		}
@@ -72,7 +126,15 @@ func (self *Parser) Parse(
 		// Convert the value using the parser
 		new_value, err := parser.Parser(ctx, scope, args, value)
 		if err != nil {
-			return fmt.Errorf("Field %s %w", parser.Field, err)
+			arg_error.Invalid = append(arg_error.Invalid,
+				fmt.Errorf("Field %s %w", parser.Field, err))
+			continue
+		}
+
+		err = parser.validate(new_value)
+		if err != nil {
+			arg_error.Invalid = append(arg_error.Invalid, err)
+			continue
 		}
 
 		// Now set the field on the struct.
@@ -86,14 +148,74 @@ func (self *Parser) Parse(
 		// Slow path should only be taken on error.
 		for _, key := range args.Keys() {
 			if !utils.InString(&parsed, key) {
-				return fmt.Errorf("Unexpected arg %v", key)
+				arg_error.Unexpected = append(arg_error.Unexpected, key)
 			}
 		}
 	}
 
+	if !arg_error.empty() {
+		return arg_error
+	}
+
 	return nil
 }
 
+func (self *FieldParser) validate(value interface{}) error {
+	for _, validator := range self.Validators {
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Field %s %w", self.Field, err)
+		}
+	}
+	return nil
+}
+
+// Convert the default= tag into a value for the field.
+func parseDefault(field_type reflect.Type, value string) (*reflect.Value, error) {
+	var result interface{}
+	var err error
+
+	// Rich types (e.g. durations) are parsed from the string the
+	// same way as args.
+	parser, pres := richTypeParsers()[field_type]
+	if pres {
+		result, err = parser(context.Background(), nil, nil, value)
+		if err != nil {
+			return nil, err
+		}
+
+		converted := reflect.ValueOf(result)
+		return &converted, nil
+	}
+
+	switch field_type.Kind() {
+	case reflect.String:
+		result = value
+
+	case reflect.Bool:
+		result, err = strconv.ParseBool(value)
+
+	case reflect.Int, reflect.Int64:
+		result, err = strconv.ParseInt(value, 0, 64)
+
+	case reflect.Uint64:
+		result, err = strconv.ParseUint(value, 0, 64)
+
+	case reflect.Float32, reflect.Float64:
+		result, err = strconv.ParseFloat(value, 64)
+
+	default:
+		return nil, fmt.Errorf("Defaults are not supported for %v", field_type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	converted := reflect.ValueOf(result).Convert(field_type)
+	return &converted, nil
+}
+
 // The plugin may specify the arg as being a LazyExpr, in which case
 // it is completely up to it to evaluate the expression (if at all).
 // Note: Reducing the lazy expression may yield a StoredQuery - it is
@@ -339,7 +461,7 @@ func BuildParser(v reflect.Value) (*Parser, error) {
 		options := make(map[string]string)
 		for _, directive := range directives {
 			if strings.Contains(directive, "=") {
-				components := strings.Split(directive, "=")
+				components := strings.SplitN(directive, "=", 2)
 				if len(components) >= 2 {
 					options[components[0]] = components[1]
 				}
@@ -366,6 +488,33 @@ func BuildParser(v reflect.Value) (*Parser, error) {
 		}
 		result.Fields = append(result.Fields, field_parser)
 
+		validators, err := buildValidators(field_types_value.Type, options)
+		if err != nil {
+			return nil, fmt.Errorf("Field %s: %w", field_name, err)
+		}
+		field_parser.Validators = validators
+
+		default_value, pres := options["default"]
+		if pres {
+			if required {
+				return nil, fmt.Errorf(
+					"Field %s is required so can not have a default", field_name)
+			}
+
+			parsed_default, err := parseDefault(
+				field_types_value.Type, default_value)
+			if err != nil {
+				return nil, fmt.Errorf("Field %s: invalid default: %w",
+					field_name, err)
+			}
+
+			err = field_parser.validate(parsed_default.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("Invalid default: %w", err)
+			}
+			field_parser.Default = parsed_default
+		}
+
 		// Now figure out the required type that will go into
 		// the value output struct field.
 		field_value := v.Field(field_types_value.Index[0])
@@ -374,6 +523,16 @@ func BuildParser(v reflect.Value) (*Parser, error) {
 				"Field %s is unsettable.", field_name))
 		}
 
+		mode, pres := options["materialize"]
+		if pres {
+			parser, err := getMaterializeParser(field_types_value.Type, mode)
+			if err != nil {
+				return nil, fmt.Errorf("Field %s: %w", field_name, err)
+			}
+			field_parser.Parser = parser
+			continue
+		}
+
 		// Find a specialized parser for this type.
 		parser, pres := typeDispatcher[field_types_value.Type]
 		if pres {
@@ -444,6 +603,9 @@ func initDefaultTypeDispatcher() map[reflect.Type]ParserDipatcher {
 	result[storedQueryType] = storedQueryParser
 	result[lazyExprType] = lazyExprParser
 	result[dictExprType] = dictParser
+	for k, v := range richTypeParsers() {
+		result[k] = v
+	}
 	return result
 }
 
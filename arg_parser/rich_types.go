@@ -0,0 +1,183 @@
+package arg_parser
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Arg structs may use these richer types directly and args are
+// coerced into them:
+//
+//	time.Time      from times, epoch numbers and time strings
+//	time.Duration  from durations, strings like 5m and seconds
+//	net.IP         from IP strings
+//	*net.IPNet     from CIDR strings (a single IP is a /32 or /128)
+//	ByteSize       from numbers and strings like 10MB or 1.5GiB
+
+// A size in bytes.
+type ByteSize uint64
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP{})
+	ipNetType    = reflect.TypeOf(&net.IPNet{})
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+)
+
+// These parsers do not use the scope so they can also parse
+// default= tags.
+func richTypeParsers() map[reflect.Type]ParserDipatcher {
+	return map[reflect.Type]ParserDipatcher{
+		timeType:     timeParser,
+		durationType: durationParser,
+		ipType:       ipParser,
+		ipNetType:    ipNetParser,
+		byteSizeType: byteSizeParser,
+	}
+}
+
+func timeParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.Reduce(ctx)
+	}
+
+	switch t := arg.(type) {
+	case time.Time:
+		return t, nil
+
+	case *time.Time:
+		return *t, nil
+
+	case string:
+		return utils.ParseTimeString(t, "", time.UTC)
+	}
+
+	result, ok := utils.EpochToTime(arg)
+	if ok {
+		return result, nil
+	}
+	return nil, fmt.Errorf("Should be a time not %T.", arg)
+}
+
+// Numbers are taken as seconds.
+func durationParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.Reduce(ctx)
+	}
+
+	switch t := arg.(type) {
+	case time.Duration:
+		return t, nil
+
+	case *time.Duration:
+		return *t, nil
+
+	case string:
+		return utils.ParseDuration(t)
+
+	case float64:
+		return time.Duration(t * float64(time.Second)), nil
+	}
+
+	seconds, ok := utils.ToInt64(arg)
+	if ok {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return nil, fmt.Errorf("Should be a duration not %T.", arg)
+}
+
+func ipParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.Reduce(ctx)
+	}
+
+	switch t := arg.(type) {
+	case net.IP:
+		return t, nil
+
+	case string:
+		result := net.ParseIP(strings.TrimSpace(t))
+		if result != nil {
+			return result, nil
+		}
+		return nil, fmt.Errorf("Invalid IP %v", t)
+	}
+
+	return nil, fmt.Errorf("Should be an IP not %T.", arg)
+}
+
+func ipNetParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.Reduce(ctx)
+	}
+
+	switch t := arg.(type) {
+	case *net.IPNet:
+		return t, nil
+
+	case string:
+		value := strings.TrimSpace(t)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid CIDR %v", t)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+		}
+
+		_, result, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR %v", t)
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("Should be a CIDR not %T.", arg)
+}
+
+func byteSizeParser(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, arg interface{}) (interface{}, error) {
+	lazy_arg, ok := arg.(types.LazyExpr)
+	if ok {
+		arg = lazy_arg.Reduce(ctx)
+	}
+
+	switch t := arg.(type) {
+	case string:
+		result, err := utils.ParseByteSize(t)
+		return ByteSize(result), err
+
+	case float64:
+		if t >= 0 {
+			return ByteSize(t), nil
+		}
+	}
+
+	size, ok := utils.ToInt64(arg)
+	if ok && size >= 0 {
+		return ByteSize(size), nil
+	}
+	return nil, fmt.Errorf("Should be a size not %v.", arg)
+}
@@ -0,0 +1,125 @@
+package arg_parser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Checks a parsed arg value. The error is reported against the
+// field so it should read as "Field name <error>".
+type Validator func(value interface{}) error
+
+// Build validators from the choices=, min=, max= and regex= tags:
+//
+//	Mode  string `vfilter:"optional,field=mode,choices=read|write"`
+//	Count int64  `vfilter:"optional,field=count,min=1,max=100"`
+//	Name  string `vfilter:"optional,field=name,regex=^[a-z_]+$"`
+//
+// Since tags are split on commas, regex= may not contain one.
+func buildValidators(field_type reflect.Type,
+	options map[string]string) ([]Validator, error) {
+	var result []Validator
+
+	is_string := field_type.Kind() == reflect.String ||
+		(field_type.Kind() == reflect.Slice &&
+			field_type.Elem().Kind() == reflect.String)
+
+	choices, pres := options["choices"]
+	if pres {
+		if !is_string {
+			return nil, fmt.Errorf("choices= only applies to strings")
+		}
+
+		allowed := strings.Split(choices, "|")
+		result = append(result, forEachString(func(value string) error {
+			if !utils.InString(&allowed, value) {
+				return fmt.Errorf("should be one of %v, not %q",
+					strings.Join(allowed, ", "), value)
+			}
+			return nil
+		}))
+	}
+
+	expression, pres := options["regex"]
+	if pres {
+		if !is_string {
+			return nil, fmt.Errorf("regex= only applies to strings")
+		}
+
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, forEachString(func(value string) error {
+			if !re.MatchString(value) {
+				return fmt.Errorf("should match %v, not %q", expression, value)
+			}
+			return nil
+		}))
+	}
+
+	for _, limit := range []string{"min", "max"} {
+		limit_str, pres := options[limit]
+		if !pres {
+			continue
+		}
+
+		switch field_type.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return nil, fmt.Errorf("%v= only applies to numbers", limit)
+		}
+
+		limit_value, err := strconv.ParseFloat(limit_str, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		is_min := limit == "min"
+		result = append(result, func(value interface{}) error {
+			number, ok := utils.ToFloat(value)
+			if !ok {
+				return nil
+			}
+
+			if is_min && number < limit_value {
+				return fmt.Errorf("should be at least %v, not %v",
+					limit_str, value)
+			}
+
+			if !is_min && number > limit_value {
+				return fmt.Errorf("should be at most %v, not %v",
+					limit_str, value)
+			}
+			return nil
+		})
+	}
+
+	return result, nil
+}
+
+// Apply a string check to a string or each member of a string slice.
+func forEachString(check func(value string) error) Validator {
+	return func(value interface{}) error {
+		switch t := value.(type) {
+		case string:
+			return check(t)
+
+		case []string:
+			for _, item := range t {
+				err := check(item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
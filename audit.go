@@ -0,0 +1,160 @@
+package vfilter
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+const redactedArg = "<redacted>"
+
+// A record of a single plugin invocation.
+type PluginAuditRecord struct {
+	Plugin string `json:"plugin"`
+
+	// The args the plugin was called with. Args the plugin did not
+	// evaluate are recorded as their VQL expression so auditing
+	// does not change how the query runs.
+	Args *ordereddict.Dict `json:"args"`
+
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Number of rows the plugin emitted.
+	Rows int `json:"rows"`
+
+	CallChain string `json:"call_chain,omitempty"`
+}
+
+// Receives a record when each plugin call finishes. The hook may be
+// called from many goroutines.
+type PluginAuditHook func(scope types.Scope, record *PluginAuditRecord)
+
+type AuditOptions struct {
+	// Replace the values of args tagged as secret in the plugin's
+	// arg struct, e.g.
+	//
+	//	Password string `vfilter:"optional,field=password,secret"`
+	RedactSecrets bool
+}
+
+// Record every plugin invocation made with the scope (and its
+// children) for compliance purposes. This installs a plugin
+// middleware so it audits plugins called after it is installed. The
+// record of a plugin which was cancelled (e.g. by LIMIT) may arrive
+// after the query returned.
+func AuditPlugins(scope types.Scope, hook PluginAuditHook, options AuditOptions) {
+	scope.AddPluginMiddleware(func(ctx context.Context, scope types.Scope,
+		name string, args *ordereddict.Dict,
+		next types.PluginCallNext) <-chan Row {
+		record := &PluginAuditRecord{
+			Plugin:    name,
+			Start:     time.Now(),
+			CallChain: scope.CallChain(),
+		}
+
+		var secrets map[string]bool
+		if options.RedactSecrets {
+			secrets = getSecretArgs(scope, name)
+		}
+
+		output_chan := make(chan Row)
+		go func() {
+			defer close(output_chan)
+			defer func() {
+				record.End = time.Now()
+				record.Args = auditArgs(scope, args, secrets)
+				hook(scope, record)
+			}()
+
+			for row := range next(ctx, scope, args) {
+				select {
+				case <-ctx.Done():
+					// Keep draining the plugin so it can
+					// exit.
+				case output_chan <- row:
+					record.Rows++
+				}
+			}
+		}()
+
+		return output_chan
+	})
+}
+
+// Find the args of the plugin tagged as secret from its arg type.
+func getSecretArgs(scope types.Scope, name string) map[string]bool {
+	result := make(map[string]bool)
+
+	plugin, pres := scope.GetPlugin(name)
+	if !pres {
+		return result
+	}
+
+	type_map := types.NewTypeMap()
+	info := plugin.Info(scope, type_map)
+	if info == nil || info.ArgType == "" {
+		return result
+	}
+
+	desc, pres := type_map.Get(scope, info.ArgType)
+	if !pres {
+		return result
+	}
+
+	for _, field := range desc.Fields.Keys() {
+		value, _ := desc.Fields.Get(field)
+		ref, ok := value.(*types.TypeReference)
+		if !ok {
+			continue
+		}
+
+		for _, directive := range strings.Split(ref.Tag, ",") {
+			if directive == "secret" {
+				result[field] = true
+			}
+		}
+	}
+
+	return result
+}
+
+func auditArgs(scope types.Scope,
+	args *ordereddict.Dict, secrets map[string]bool) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	if args == nil {
+		return result
+	}
+
+	for _, k := range args.Keys() {
+		if secrets[k] {
+			result.Set(k, redactedArg)
+			continue
+		}
+
+		v, _ := args.Get(k)
+		switch t := v.(type) {
+		case *LazyExprImpl:
+			if t.Value != nil {
+				v = t.Value
+			} else {
+				v = FormatToString(scope, t.Expr)
+			}
+		}
+
+		// Record queries by their VQL rather than running them.
+		switch t := v.(type) {
+		case *_Select:
+			v = FormatToString(scope, t)
+		case *_StoredQuery:
+			v = FormatToString(scope, t.query)
+		}
+
+		result.Set(k, v)
+	}
+
+	return result
+}
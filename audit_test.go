@@ -0,0 +1,84 @@
+package vfilter
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type loginPluginArgs struct {
+	User     string `vfilter:"required,field=user"`
+	Password string `vfilter:"optional,field=password,secret"`
+}
+
+func TestAuditPlugins(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "login",
+		ArgType:    &loginPluginArgs{},
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			arg := &loginPluginArgs{}
+			err := ExtractArgs(scope, args, arg)
+			if err != nil {
+				return nil
+			}
+			return []Row{ordereddict.NewDict().Set("User", arg.User)}
+		},
+	})
+
+	var mu sync.Mutex
+	var records []*PluginAuditRecord
+	AuditPlugins(scope, func(scope types.Scope, record *PluginAuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, record)
+	}, AuditOptions{RedactSecrets: true})
+
+	vql, err := Parse("SELECT * FROM login(user='admin', password='hunter2')")
+	assert.NoError(t, err)
+	for range vql.Eval(context.Background(), scope) {
+	}
+
+	vql, err = Parse("SELECT * FROM foreach(row={ SELECT * FROM scope() }, " +
+		"query={ SELECT 1 FROM scope() })")
+	assert.NoError(t, err)
+	for range vql.Eval(context.Background(), scope) {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := []string{}
+	for _, record := range records {
+		names = append(names, record.Plugin)
+	}
+	assert.Contains(t, names, "login")
+	assert.Contains(t, names, "foreach")
+
+	for _, record := range records {
+		assert.False(t, record.End.Before(record.Start))
+
+		switch record.Plugin {
+		case "login":
+			assert.Equal(t, 1, record.Rows)
+			user, _ := record.Args.Get("user")
+			assert.Equal(t, "admin", user)
+
+			password, _ := record.Args.Get("password")
+			assert.Equal(t, "<redacted>", password)
+
+		case "foreach":
+			assert.Equal(t, 1, record.Rows)
+			row, _ := record.Args.Get("row")
+			assert.Equal(t, "SELECT * FROM scope()", row)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package vfilter
+
+import (
+	"context"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// When batching is enabled (see scope.SetBatchSize()) plugins which
+// implement types.BatchPluginGeneratorInterface send their rows to
+// the SELECT in batches. The rows are still filtered, transformed and
+// emitted one at a time but the channel sends between the plugin and
+// the SELECT are only needed for each batch instead of for each
+// row. Queries on other plugins (or stored queries) read rows one at
+// a time as before.
+
+// Call the plugin for its batches. Returns false if the plugin does
+// not support batches (or batching is disabled) so the caller should
+// use Eval() instead.
+func (self *Plugin) EvalBatches(ctx context.Context, scope types.Scope) (
+	<-chan []Row, bool) {
+	if !self.Call || scope.BatchSize() <= 0 {
+		return nil, false
+	}
+
+	// Cached plugins are called one row at a time.
+	cache, ttl := scope.QueryCache()
+	if cache != nil && ttl > 0 {
+		return nil, false
+	}
+
+	plugin, pres := scope.GetPlugin(
+		strings.Join(utils.SplitIdent(self.Name), "."))
+	if !pres {
+		return nil, false
+	}
+
+	_, ok := plugin.(types.BatchPluginGeneratorInterface)
+	if !ok {
+		return nil, false
+	}
+
+	output_chan := make(chan []Row)
+	if scope.CheckForOverflow() || !checkPluginAccess(ctx, scope, self.Name, plugin) {
+		close(output_chan)
+		return output_chan, true
+	}
+
+	args := buildArgsFromParameters(ctx, scope, self.Args)
+
+	scope.GetStats().IncPluginsCalled()
+
+	var result <-chan []Row
+	withPluginLabel(ctx, self.Name, func(ctx context.Context) {
+		result = scope.CallPluginBatches(ctx, self.Name, plugin, args)
+	})
+
+	return result, true
+}
+
+// Like Eval() but relays the plugin's batches. The rows are counted
+// as they are read by the rowReader.
+func (self *_From) EvalBatches(ctx context.Context, scope types.Scope) (
+	<-chan []Row, bool) {
+	if scope.BatchSize() <= 0 {
+		return nil, false
+	}
+
+	ctx, ok := enterSubquery(ctx, scope)
+	if !ok {
+		output_chan := make(chan []Row)
+		close(output_chan)
+		return output_chan, true
+	}
+
+	done := func() {}
+	if self.Name != nil {
+		sub_ctx, cancel := context.WithCancel(ctx)
+		unregister := scope.RegisterSubquery(*self.Name, cancel)
+		done = func() {
+			unregister()
+			cancel()
+		}
+		ctx = sub_ctx
+	}
+
+	input_chan, ok := self.Plugin.EvalBatches(ctx, scope)
+	if !ok {
+		done()
+		return nil, false
+	}
+
+	output_chan := make(chan []Row)
+	go func() {
+		defer close(output_chan)
+		defer done()
+
+		for batch := range input_chan {
+			select {
+			case <-ctx.Done():
+				return
+
+			case output_chan <- batch:
+			}
+		}
+	}()
+
+	return output_chan, true
+}
+
+// Reads the rows of the FROM clause one at a time, from batches if
+// the plugin supports them.
+type rowReader struct {
+	ctx   context.Context
+	scope types.Scope
+
+	rows    <-chan Row
+	batches <-chan []Row
+	batch   []Row
+}
+
+func (self *_From) Rows(ctx context.Context, scope types.Scope) *rowReader {
+	result := &rowReader{ctx: ctx, scope: scope}
+
+	batches, ok := self.EvalBatches(ctx, scope)
+	if ok {
+		result.batches = batches
+	} else {
+		result.rows = self.Eval(ctx, scope)
+	}
+
+	return result
+}
+
+// Returns false when there are no more rows or the query is
+// cancelled.
+func (self *rowReader) Next() (Row, bool) {
+	if self.rows != nil {
+		select {
+		case <-self.ctx.Done():
+			return nil, false
+
+		case row, ok := <-self.rows:
+			return row, ok
+		}
+	}
+
+	for len(self.batch) == 0 {
+		select {
+		case <-self.ctx.Done():
+			return nil, false
+
+		case batch, ok := <-self.batches:
+			if !ok {
+				return nil, false
+			}
+			self.batch = batch
+		}
+	}
+
+	row := self.batch[0]
+	self.batch = self.batch[1:]
+
+	// Rows which are not batched are counted by _From.Eval()
+	self.scope.GetStats().IncRowsScanned()
+	self.scope.ChargeOp()
+
+	if !chargeQueryRow(self.ctx, self.scope) {
+		return nil, false
+	}
+
+	return row, true
+}
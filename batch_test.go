@@ -0,0 +1,119 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Counts how the range plugin was called.
+type _CountingRangePlugin struct {
+	plugins.RangePlugin
+	calls, batches *int
+}
+
+func (self _CountingRangePlugin) Call(ctx context.Context,
+	scope types.Scope, args *ordereddict.Dict) <-chan Row {
+	*self.calls++
+	return self.RangePlugin.Call(ctx, scope, args)
+}
+
+func (self _CountingRangePlugin) CallBatches(ctx context.Context,
+	scope types.Scope, args *ordereddict.Dict) <-chan []Row {
+	output_chan := make(chan []Row)
+	go func() {
+		defer close(output_chan)
+		for batch := range self.RangePlugin.CallBatches(ctx, scope, args) {
+			*self.batches++
+			output_chan <- batch
+		}
+	}()
+	return output_chan
+}
+
+func TestBatchedEvaluation(t *testing.T) {
+	var calls, batches int
+
+	scope := NewScope().AppendPlugins(_CountingRangePlugin{
+		calls: &calls, batches: &batches})
+	defer scope.Close()
+
+	run := func(query string) []Row {
+		var result []Row
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		err = vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				result = append(result, row)
+				return nil
+			})
+		assert.NoError(t, err)
+		return result
+	}
+
+	for _, query := range []string{
+		"SELECT _value * 2 AS X FROM range(end=20) WHERE X > 6",
+		"SELECT * FROM range(start=1, end=20, step=3) LIMIT 4",
+		"SELECT _value FROM range(end=20) ORDER BY _value DESC",
+		"SELECT count() AS Count, _value FROM range(end=20) GROUP BY _value < 10",
+	} {
+		scope.SetBatchSize(0)
+		calls, batches = 0, 0
+		expected := run(query)
+		assert.Equal(t, 1, calls, query)
+		assert.Equal(t, 0, batches, query)
+
+		// The same rows are read in batches.
+		scope.SetBatchSize(7)
+		calls = 0
+		assert.Equal(t, expected, run(query), query)
+		assert.Equal(t, 0, calls, query)
+		assert.True(t, batches > 0, query)
+	}
+
+	// Batches of 7 rows.
+	calls, batches = 0, 0
+	assert.Equal(t, 20, len(run("SELECT * FROM range(end=20)")))
+	assert.Equal(t, 3, batches)
+
+	// Row limits apply to the rows in the batches.
+	scope.SetMaxRows(10)
+	vql, err := Parse("SELECT * FROM range(end=20)")
+	assert.NoError(t, err)
+
+	rows := 0
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			rows++
+			return nil
+		})
+	assert.ErrorIs(t, err, types.ErrRowLimitExceeded)
+	assert.Equal(t, 10, rows)
+	scope.SetMaxRows(0)
+
+	// Middleware sees the rows one at a time.
+	middleware_rows := 0
+	scope.AddPluginMiddleware(func(ctx context.Context, scope types.Scope,
+		name string, args *ordereddict.Dict,
+		next types.PluginCallNext) <-chan Row {
+		output_chan := make(chan Row)
+		go func() {
+			defer close(output_chan)
+			for row := range next(ctx, scope, args) {
+				middleware_rows++
+				output_chan <- row
+			}
+		}()
+		return output_chan
+	})
+
+	calls = 0
+	assert.Equal(t, 20, len(run("SELECT * FROM range(end=20)")))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 20, middleware_rows)
+}
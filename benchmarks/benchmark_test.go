@@ -22,9 +22,12 @@ func makeScope() vfilter.Scope {
 }
 
 func runBenchmark(b *testing.B, query string) {
+	runBenchmarkWithScope(b, makeScope(), query)
+}
+
+func runBenchmarkWithScope(b *testing.B, scope vfilter.Scope, query string) {
 	// Store the result in ordered dict so we have a consistent golden file.
 	result := ordereddict.NewDict()
-	scope := makeScope()
 
 	multi_vql, err := vfilter.MultiParse(query)
 	assert.NoError(b, err, "Failed to parse %v: %v", query, err)
@@ -52,6 +55,19 @@ WHERE Value =~ '.' AND 1 = 1 AND _value > 10`,
 	}
 }
 
+func BenchmarkRangeBatched10k(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		scope := makeScope()
+		scope.SetBatchSize(1000)
+
+		runBenchmarkWithScope(b, scope, `
+SELECT format(format='value %v', args=_value) AS Value
+FROM range(start=0, step=1, end=10000)
+WHERE Value =~ '.' AND 1 = 1 AND _value > 10`,
+		)
+	}
+}
+
 func BenchmarkForeach10k(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		runBenchmark(b, `
@@ -73,3 +89,10 @@ SELECT * FROM foreach(row={
 })`)
 	}
 }
+
+func BenchmarkCount10k(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		runBenchmark(b, `
+SELECT count() FROM range(start=0, step=1, end=10000)`)
+	}
+}
@@ -0,0 +1,99 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestCacheFunction(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	calls := 0
+	scope.AppendFunctions(functions.GenericFunction{
+		FunctionName: "expensive",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			calls++
+			value, _ := args.Get("x")
+			return value
+		},
+	})
+
+	run := func(query string) []Row {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var rows []Row
+		for row := range vql.Eval(context.Background(), scope) {
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	// Only evaluated once per distinct key.
+	rows := run(`
+SELECT cache(key=Size, expr=expensive(x=Size)) AS Value
+FROM foreach(row={
+  SELECT if(condition=_value > 4, then="big", else="small") AS Size
+  FROM foreach(row=range(end=10))
+})`)
+	assert.Equal(t, 10, len(rows))
+	assert.Equal(t, 2, calls)
+
+	value, _ := rows[7].(*ordereddict.Dict).Get("Value")
+	assert.Equal(t, "big", value)
+
+	// The cache outlives the query.
+	run(`SELECT cache(key="big", expr=expensive(x="big")) FROM scope()`)
+	assert.Equal(t, 2, calls)
+
+	// Expired values are evaluated again.
+	query := `SELECT cache(key="short", period=1, expr=expensive(x=0)) FROM scope()`
+	run(query)
+	run(query)
+	assert.Equal(t, 3, calls)
+
+	time.Sleep(1100 * time.Millisecond)
+	run(query)
+	assert.Equal(t, 4, calls)
+
+	// Queries are materialized once.
+	rows = run(`
+SELECT cache(key="q", expr={ SELECT expensive(x=_value) AS X FROM foreach(row=range(end=3)) }) AS Q
+FROM foreach(row=range(end=5))`)
+	assert.Equal(t, 5, len(rows))
+	assert.Equal(t, 7, calls)
+}
+
+// Expired entries are purged as the cache grows.
+func TestCacheFunctionPurgesExpiredEntries(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	run := func(query string) {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		for range vql.Eval(context.Background(), scope) {
+		}
+	}
+
+	run(`SELECT cache(key=format(format="a%v", args=_value), period=1, expr=_value)
+FROM foreach(row=range(end=1500))`)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	run(`SELECT cache(key=format(format="b%v", args=_value), period=60, expr=_value)
+FROM foreach(row=range(end=1500))`)
+
+	store, pres := scope.GetContext("__cache")
+	assert.True(t, pres)
+	assert.Equal(t, 1500, store.(interface{ Len() int }).Len())
+}
@@ -0,0 +1,38 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallChainInErrors(t *testing.T) {
+	scope := makeTestScope()
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", log.Ldate|log.Ltime|log.Lshortfile))
+
+	multi_vql, err := MultiParse(`
+LET Inner = SELECT Missing FROM scope()
+SELECT * FROM foreach(row=[1], query={ SELECT * FROM Inner })
+SELECT panic(column=2, value=2) AS Boom FROM scope()
+`)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	for _, vql := range multi_vql {
+		for _ = range vql.Eval(ctx, scope) {
+		}
+	}
+
+	logger.Contains(t, "Symbol Missing not found")
+	logger.Contains(t, "(VQL call chain: SELECT * FROM foreach(row=[1], "+
+		"query={ SELECT * FROM Inner }) → foreach → Inner)")
+
+	// Panics in functions are logged with the expression.
+	logger.Contains(t, "PANIC in panic(column=2, value=2)")
+	logger.Contains(t, "(VQL call chain: SELECT panic(column=2, value=2) "+
+		"AS Boom FROM scope())")
+}
@@ -0,0 +1,59 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+type channelEvent struct {
+	Name string
+	Size int
+}
+
+func TestChannelSource(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	events := make(chan *channelEvent)
+	numbers := make(chan int, 3)
+	numbers <- 1
+	numbers <- 2
+	numbers <- 3
+	close(numbers)
+
+	// Receive only channels work too.
+	var recv_events <-chan *channelEvent = events
+	scope.AppendVars(ordereddict.NewDict().
+		Set("Events", recv_events).
+		Set("Numbers", numbers))
+
+	go func() {
+		defer close(events)
+		for _, name := range []string{"a", "b", "c"} {
+			events <- &channelEvent{Name: name, Size: len(name)}
+		}
+	}()
+
+	vql, err := Parse("SELECT Name FROM Events WHERE Name != 'b'")
+	assert.NoError(t, err)
+
+	names := []string{}
+	for row := range vql.Eval(context.Background(), scope) {
+		name, _ := scope.Associative(row, "Name")
+		names = append(names, name.(string))
+	}
+	assert.Equal(t, []string{"a", "c"}, names)
+
+	vql, err = Parse("SELECT _value FROM foreach(row=Numbers) WHERE _value > 1")
+	assert.NoError(t, err)
+
+	values := []int{}
+	for row := range vql.Eval(context.Background(), scope) {
+		value, _ := scope.Associative(row, "_value")
+		values = append(values, value.(int))
+	}
+	assert.Equal(t, []int{2, 3}, values)
+}
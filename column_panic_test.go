@@ -0,0 +1,76 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type flakyObject struct{}
+
+// An associative protocol which panics when accessed.
+type _FlakyAssociative struct{}
+
+func (self _FlakyAssociative) Applicable(a Any, b Any) bool {
+	_, ok := a.(flakyObject)
+	return ok
+}
+
+func (self _FlakyAssociative) Associative(
+	scope types.Scope, a Any, b Any) (Any, bool) {
+	panic("flaky enrichment")
+}
+
+func (self _FlakyAssociative) GetMembers(scope types.Scope, a Any) []string {
+	return nil
+}
+
+func TestColumnPanicIsolation(t *testing.T) {
+	scope := makeTestScope().
+		AppendVars(ordereddict.NewDict().Set("Flaky", flakyObject{}))
+	scope.AddProtocolImpl(_FlakyAssociative{})
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", log.Ldate|log.Ltime|log.Lshortfile))
+
+	vql, err := Parse("SELECT _value AS A, Flaky.Name AS B, _value * 2 AS C " +
+		"FROM foreach(row=[1, 2])")
+	assert.NoError(t, err)
+
+	var rows []*ordereddict.Dict
+	for row := range vql.Eval(context.Background(), scope) {
+		rows = append(rows, row.(*ordereddict.Dict))
+	}
+
+	// The other columns are still emitted.
+	assert.Equal(t, 2, len(rows))
+	for i, row := range rows {
+		a, _ := row.GetInt64("A")
+		assert.Equal(t, int64(i+1), a)
+
+		b, _ := row.Get("B")
+		assert.True(t, types.IsNullObject(b))
+
+		c, _ := row.GetInt64("C")
+		assert.Equal(t, int64(2*(i+1)), c)
+	}
+
+	logger.Contains(t, "WARN:Column B set to NULL after PANIC: flaky enrichment")
+
+	// The stack is only logged for the first panic, the rest are
+	// counted.
+	stacks := 0
+	for _, line := range logger.logs {
+		if strings.Contains(line, "goroutine ") {
+			stacks++
+		}
+	}
+	assert.Equal(t, 1, stacks)
+	logger.Contains(t, "WARN:Column B set to NULL after PANIC (1 more times)")
+}
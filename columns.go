@@ -0,0 +1,79 @@
+package vfilter
+
+import (
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Returns the columns the query will produce without running it.
+//
+// Columns selected with * are resolved from the plugin's RowType or
+// from the stored query in the FROM clause. If the columns can not be
+// determined, "*" is returned in their place.
+func (self *VQL) Columns(scope types.Scope) []string {
+	if self.Query == nil {
+		return nil
+	}
+
+	return self.Query.Columns(scope)
+}
+
+func (self *_Select) Columns(scope types.Scope) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	add := func(names ...string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				result = append(result, name)
+			}
+		}
+	}
+
+	if self.SelectExpression == nil {
+		return result
+	}
+
+	if self.SelectExpression.All {
+		add(self.wildcardColumns(scope)...)
+	}
+
+	for _, expr := range self.SelectExpression.Expressions {
+		name := expr.GetName(scope)
+		if name == "*" {
+			add(self.wildcardColumns(scope)...)
+			continue
+		}
+		add(name)
+	}
+
+	return result
+}
+
+// Figure out which columns the plugin will produce.
+func (self *_Select) wildcardColumns(scope types.Scope) []string {
+	if self.From == nil {
+		return []string{"*"}
+	}
+
+	name := self.From.Plugin.Name
+
+	// Selecting from a stored query - ask the stored query.
+	symbol, pres := scope.Resolve(name)
+	if pres {
+		stored_query, ok := symbol.(*_StoredQuery)
+		if ok && stored_query.query != nil {
+			return stored_query.query.Columns(scope)
+		}
+	}
+
+	plugin, pres := scope.GetPlugin(name)
+	if pres {
+		info := plugin.Info(scope, types.NewTypeMap())
+		if info != nil && !utils.IsNil(info.RowType) {
+			return scope.GetMembers(info.RowType)
+		}
+	}
+
+	return []string{"*"}
+}
@@ -0,0 +1,54 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+var columnsTests = []struct {
+	query   string
+	columns []string
+}{
+	{"SELECT A, B AS C FROM info()", []string{"A", "C"}},
+	{"SELECT * FROM info()", []string{"Name", "Size"}},
+	{"SELECT *, Size + 1 AS Next FROM info()", []string{"Name", "Size", "Next"}},
+	{"SELECT *, Name FROM info()", []string{"Name", "Size"}},
+
+	// No RowType - columns are unknown.
+	{"SELECT * FROM test()", []string{"*"}},
+
+	// Resolved through the stored query.
+	{"SELECT * FROM Stored", []string{"Name", "Size", "Total"}},
+}
+
+func TestColumns(t *testing.T) {
+	scope := makeTestScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "info",
+		RowType: ordereddict.NewDict().
+			Set("Name", "").
+			Set("Size", 0),
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			return nil
+		},
+	})
+	defer scope.Close()
+
+	stored, err := Parse("LET Stored = SELECT *, Size AS Total FROM info()")
+	assert.NoError(t, err)
+	for range stored.Eval(context.Background(), scope) {
+	}
+
+	for _, test := range columnsTests {
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+		assert.Equal(t, test.columns, vql.Columns(scope), test.query)
+	}
+}
@@ -0,0 +1,185 @@
+package vfilter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/protocols"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A Program is a query prepared by Compile() to be evaluated many
+// times. Compiling does the work which does not depend on the rows
+// once, instead of for every row (or every time the query runs):
+//
+//  1. Functions are resolved from the scope passed to Compile() so
+//     later changes to the scope's functions are not seen.
+//  2. Regular expressions and globs with constant patterns are
+//     compiled (an invalid pattern or flag fails the compilation).
+//  3. Constant expressions (e.g. 5 * 1024) are evaluated so their
+//     operators are not dispatched at run time.
+//  4. Comparisons (=, !=, <, <=, >, >=) with a constant string or
+//     integer are bound to the builtin comparison for that type
+//     (e.g. WHERE Name = "foo" or WHERE Size > 1024). When the row's
+//     value has the same type it is compared directly, otherwise the
+//     comparison is dispatched through the scope's protocols as
+//     usual.
+//
+// The program runs on its own copy of the query so the original may
+// still be used as before. Example:
+//
+//	vql, _ := vfilter.Parse("SELECT * FROM files() WHERE Name =~ 'exe$'")
+//	program, err := vfilter.Compile(vql, scope)
+//	for i := 0; i < 100; i++ {
+//	    for row := range program.Eval(ctx, scope) { ... }
+//	}
+type Program struct {
+	vql *VQL
+}
+
+func Compile(vql *VQL, scope types.Scope) (*Program, error) {
+	copier := &specializer{scope: scope}
+	compiled := copier.copy(reflect.ValueOf(vql)).Interface().(*VQL)
+	compiled.source = vql.source
+
+	var err error
+	walkAST(reflect.ValueOf(compiled), func(node interface{}) {
+		switch t := node.(type) {
+		case *_SymbolRef:
+			compileFunction(scope, t)
+
+		case *_OpComparison:
+			if err == nil {
+				err = compileRegex(scope, copier, t)
+			}
+
+		case *_ConditionOperand:
+			compileComparison(scope, copier, t)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{vql: compiled}, nil
+}
+
+func compileFunction(scope types.Scope, symbol *_SymbolRef) {
+	if !symbol.Called {
+		return
+	}
+
+	function, pres := scope.GetFunction(symbol.Symbol)
+	if !pres {
+		// Probably defined with LET.
+		return
+	}
+
+	symbol.compiled = function
+	symbol.function = CopyFunction(function)
+}
+
+func compileRegex(scope types.Scope, copier *specializer,
+	comparison *_OpComparison) error {
+	if (comparison.Operator != "=~" && !comparison.isGlob()) ||
+		!copier.isConstant(comparison.Right) {
+		return nil
+	}
+
+	pattern, ok := comparison.Right.Reduce(context.Background(), scope).(string)
+	if !ok {
+		return nil
+	}
+
+	re, err := comparison.compile(protocols.CompileRegex, pattern)
+	if err != nil {
+		return fmt.Errorf("Compile: invalid regex %q: %w", pattern, err)
+	}
+	comparison.regex = re
+
+	return nil
+}
+
+// The builtin comparison of a value with a constant of the same
+// type. The protocol dispatchers always use these before any
+// protocol implementation so binding them does not change the
+// result.
+func compileComparison(scope types.Scope, copier *specializer,
+	operand *_ConditionOperand) {
+	if operand.Right == nil || !copier.isConstant(operand.Right.Right) {
+		return
+	}
+
+	var compare func(lhs Any) (int, bool)
+	switch constant := operand.Right.Right.Reduce(
+		context.Background(), scope).(type) {
+	case string:
+		compare = func(lhs Any) (int, bool) {
+			value, ok := lhs.(string)
+			if !ok {
+				return 0, false
+			}
+			return strings.Compare(value, constant), true
+		}
+
+	case int64:
+		compare = func(lhs Any) (int, bool) {
+			value, ok := lhs.(int64)
+			if !ok {
+				return 0, false
+			}
+			switch {
+			case value < constant:
+				return -1, true
+			case value > constant:
+				return 1, true
+			}
+			return 0, true
+		}
+
+	default:
+		return
+	}
+
+	var matches func(cmp int) bool
+	switch operand.Right.Operator {
+	case "=":
+		matches = func(cmp int) bool { return cmp == 0 }
+	case "!=":
+		matches = func(cmp int) bool { return cmp != 0 }
+	case "<":
+		matches = func(cmp int) bool { return cmp < 0 }
+	case "<=":
+		matches = func(cmp int) bool { return cmp <= 0 }
+	case ">":
+		matches = func(cmp int) bool { return cmp > 0 }
+	case ">=":
+		matches = func(cmp int) bool { return cmp >= 0 }
+	default:
+		return
+	}
+
+	operand.Right.bound = func(lhs Any) (Any, bool) {
+		cmp, ok := compare(lhs)
+		if !ok {
+			return false, false
+		}
+		return matches(cmp), true
+	}
+}
+
+func (self *Program) Eval(ctx context.Context, scope types.Scope) <-chan Row {
+	return self.vql.Eval(ctx, scope)
+}
+
+func (self *Program) EvalWithCallback(ctx context.Context, scope types.Scope,
+	callback func(row Row) error) error {
+	return self.vql.EvalWithCallback(ctx, scope, callback)
+}
+
+// The compiled query.
+func (self *Program) VQL() *VQL {
+	return self.vql
+}
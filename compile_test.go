@@ -0,0 +1,131 @@
+package vfilter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func makeGreetFunction(greeting string) functions.GenericFunction {
+	return functions.GenericFunction{
+		FunctionName: "greet",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			return greeting
+		},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	scope := makeTestScope().AppendFunctions(makeGreetFunction("hello"))
+	defer scope.Close()
+
+	vql, err := Parse(`SELECT _value AS Name, greet() AS Greeting, 2 * 1024 AS Size
+FROM foreach(row=["a.exe", "B.EXE", "c.txt"])
+WHERE Name =~ "exe$"`)
+	assert.NoError(t, err)
+
+	query := FormatToString(scope, vql)
+
+	program, err := Compile(vql, scope)
+	assert.NoError(t, err)
+
+	run := func(program interface {
+		Eval(ctx context.Context, scope types.Scope) <-chan Row
+	}) []Row {
+		var result []Row
+		for row := range program.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	expected := []Row{
+		ordereddict.NewDict().Set("Name", "a.exe").
+			Set("Greeting", "hello").Set("Size", int64(2048)),
+		ordereddict.NewDict().Set("Name", "B.EXE").
+			Set("Greeting", "hello").Set("Size", int64(2048)),
+	}
+	assert.Equal(t, expected, run(vql))
+
+	// The program may be run many times.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, expected, run(program))
+	}
+
+	// The original query is not changed.
+	assert.Equal(t, query, FormatToString(scope, vql))
+
+	// Functions are resolved when compiling.
+	scope.AppendFunctions(makeGreetFunction("bye"))
+	assert.Equal(t, expected, run(program))
+
+	vql, err = Parse(query)
+	assert.NoError(t, err)
+	greeting, _ := run(vql)[0].(*ordereddict.Dict).Get("Greeting")
+	assert.Equal(t, "bye", greeting)
+}
+
+func TestCompileInvalidRegex(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse(`SELECT * FROM foreach(row=["a"]) WHERE _value =~ "("`)
+	assert.NoError(t, err)
+
+	_, err = Compile(vql, scope)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid regex")
+
+	// Patterns which are not constant are compiled when they are
+	// used.
+	vql, err = Parse(`SELECT * FROM foreach(row=["a"]) WHERE _value =~ format(format="(")`)
+	assert.NoError(t, err)
+
+	_, err = Compile(vql, scope)
+	assert.NoError(t, err)
+}
+
+func TestCompileBindsComparisons(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse(`SELECT _value AS Value,
+  _value = "b" AS EqStr, _value != "b" AS NeStr, _value < "b" AS LtStr,
+  _value >= "b" AS GeStr, _value = 2 AS EqInt, _value > 2 AS GtInt,
+  _value <= 2 AS LeInt, _value < 2.5 AS LtFloat
+FROM foreach(row=["a", "b", "c", 1, 2, 3, 2.0, NULL])`)
+	assert.NoError(t, err)
+
+	program, err := Compile(vql, scope)
+	assert.NoError(t, err)
+
+	// String and integer constants are bound, other constants are
+	// dispatched as before.
+	bound := 0
+	walkAST(reflect.ValueOf(program.VQL()), func(node interface{}) {
+		comparison, ok := node.(*_OpComparison)
+		if ok && comparison.bound != nil {
+			bound++
+		}
+	})
+	assert.Equal(t, 7, bound)
+
+	run := func(program interface {
+		Eval(ctx context.Context, scope types.Scope) <-chan Row
+	}) []Row {
+		var result []Row
+		for row := range program.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	// Binding does not change the results.
+	assert.Equal(t, run(vql), run(program))
+}
@@ -0,0 +1,148 @@
+package vfilter
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// A streaming compressor. Flush() must write out everything
+// buffered so far so readers can decompress it.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type CompressorFactory func(w io.Writer) (Compressor, error)
+
+var (
+	compressor_mu sync.Mutex
+	compressors   = map[string]CompressorFactory{
+		"gzip": func(w io.Writer) (Compressor, error) {
+			return gzip.NewWriter(w), nil
+		},
+	}
+)
+
+// Make a compression available to OutputOptions.Compression. Gzip is
+// built in, others like zstd can be added by the embedder, e.g.
+//
+//	vfilter.RegisterCompressor("zstd", func(w io.Writer) (vfilter.Compressor, error) {
+//	    return zstd.NewWriter(w)
+//	})
+func RegisterCompressor(name string, factory CompressorFactory) {
+	compressor_mu.Lock()
+	defer compressor_mu.Unlock()
+
+	compressors[name] = factory
+}
+
+// How often compressed streams are flushed by default.
+const defaultFlushInterval = time.Second
+
+// Compresses into the underlying writer and flushes periodically so
+// readers of long lived streams (e.g. event queries) see rows within
+// the flush interval instead of when the compressor's buffer fills.
+type compressedWriter struct {
+	mu         sync.Mutex
+	compressor Compressor
+	dirty      bool
+	closed     bool
+	err        error
+	done       chan bool
+}
+
+func newCompressedWriter(w io.Writer, compression string,
+	flush_interval time.Duration) (*compressedWriter, error) {
+	compressor_mu.Lock()
+	factory, pres := compressors[compression]
+	compressor_mu.Unlock()
+
+	if !pres {
+		return nil, fmt.Errorf("Unsupported compression %v", compression)
+	}
+
+	compressor, err := factory(w)
+	if err != nil {
+		return nil, err
+	}
+
+	if flush_interval == 0 {
+		flush_interval = defaultFlushInterval
+	}
+
+	result := &compressedWriter{
+		compressor: compressor,
+		done:       make(chan bool),
+	}
+
+	go func() {
+		ticker := time.NewTicker(flush_interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-result.done:
+				return
+			case <-ticker.C:
+				result.flush()
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+func (self *compressedWriter) Write(data []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.err != nil {
+		return 0, self.err
+	}
+
+	if self.closed {
+		return 0, errors.New("Write to closed stream")
+	}
+
+	self.dirty = true
+	n, err := self.compressor.Write(data)
+	if err != nil {
+		self.err = err
+	}
+	return n, err
+}
+
+func (self *compressedWriter) flush() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if !self.dirty || self.closed || self.err != nil {
+		return
+	}
+
+	self.dirty = false
+	self.err = self.compressor.Flush()
+}
+
+// Write out the rest of the stream. This does not close the
+// underlying writer.
+func (self *compressedWriter) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return self.err
+	}
+	self.closed = true
+	close(self.done)
+
+	err := self.compressor.Close()
+	if self.err == nil {
+		self.err = err
+	}
+	return self.err
+}
@@ -0,0 +1,80 @@
+package vfilter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (self *syncBuffer) Write(data []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.buf.Write(data)
+}
+
+func (self *syncBuffer) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.buf.Len()
+}
+
+func TestCompressedOutput(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("SELECT * FROM test()")
+	assert.NoError(t, err)
+
+	plain := &bytes.Buffer{}
+	_, err = OutputJSONLWithOptions(vql, ctx, scope, plain, OutputOptions{})
+	assert.NoError(t, err)
+
+	compressed := &bytes.Buffer{}
+	_, err = OutputJSONLWithOptions(vql, ctx, scope, compressed,
+		OutputOptions{Compression: "gzip"})
+	assert.NoError(t, err)
+
+	reader, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, plain.String(), string(decompressed))
+
+	_, err = OutputJSONLWithOptions(vql, ctx, scope, compressed,
+		OutputOptions{Compression: "lzma"})
+	assert.Error(t, err)
+}
+
+func TestCompressedWriterFlushes(t *testing.T) {
+	output := &syncBuffer{}
+	writer, err := newCompressedWriter(output, "gzip", 10*time.Millisecond)
+	assert.NoError(t, err)
+
+	_, err = writer.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+
+	// The row reaches the output without closing the stream.
+	deadline := time.Now().Add(5 * time.Second)
+	for output.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, output.Len() > 0)
+
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, writer.Close())
+
+	_, err = writer.Write([]byte("more"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package vfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils/dict"
+)
+
+// A corpus is a directory of historical queries which is used to
+// validate engine upgrades against an existing query library. Each
+// query file (e.g. foo.vql) may contain several statements and has a
+// golden file next to it (foo.vql.golden) containing the JSON rows
+// emitted by each statement, keyed by statement index and text.
+const (
+	CorpusQuerySuffix  = ".vql"
+	CorpusGoldenSuffix = ".golden"
+)
+
+type CorpusOptions struct {
+	// Creates a fresh scope for each query file. The scope is
+	// closed after the file is evaluated.
+	NewScope func() types.Scope
+
+	// Rewrite the golden files with the current output instead of
+	// comparing them.
+	Update bool
+}
+
+type CorpusResult struct {
+	// The query file relative to the corpus directory.
+	Name   string
+	Passed bool
+
+	// Set when the query could not be parsed or the golden file
+	// could not be read.
+	Error error
+
+	Expected string
+	Actual   string
+}
+
+// Evaluate all the query files in the corpus directory (recursively)
+// and compare their output to the golden files.
+func RunCorpus(ctx context.Context, dir string,
+	options CorpusOptions) ([]*CorpusResult, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, CorpusQuerySuffix) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	result := make([]*CorpusResult, 0, len(files))
+	for _, path := range files {
+		name, _ := filepath.Rel(dir, path)
+		item := &CorpusResult{Name: name}
+		result = append(result, item)
+
+		item.Actual, item.Error = evalCorpusFile(ctx, path, options)
+		if item.Error != nil {
+			continue
+		}
+
+		golden_path := path + CorpusGoldenSuffix
+		if options.Update {
+			item.Error = ioutil.WriteFile(
+				golden_path, []byte(item.Actual), 0644)
+			item.Expected = item.Actual
+			item.Passed = item.Error == nil
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden_path)
+		if err != nil {
+			item.Error = err
+			continue
+		}
+		item.Expected = string(expected)
+		item.Passed = jsonEqual(expected, []byte(item.Actual))
+	}
+
+	return result, nil
+}
+
+func evalCorpusFile(ctx context.Context, path string,
+	options CorpusOptions) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	statements, err := MultiParse(string(data))
+	if err != nil {
+		return "", err
+	}
+
+	scope := options.NewScope()
+	defer scope.Close()
+
+	output := ordereddict.NewDict()
+	for idx, vql := range statements {
+		rows := []Row{}
+		for row := range vql.Eval(ctx, scope) {
+			rows = append(rows, dict.RowToDict(ctx, scope, row))
+		}
+
+		output.Set(fmt.Sprintf("%03d %s", idx,
+			FormatToString(scope, vql)), rows)
+	}
+
+	serialized, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(serialized), nil
+}
+
+// Compare the JSON documents ignoring formatting differences.
+func jsonEqual(a, b []byte) bool {
+	var a_obj, b_obj interface{}
+	if json.Unmarshal(a, &a_obj) != nil || json.Unmarshal(b, &b_obj) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	a_norm, _ := json.Marshal(a_obj)
+	b_norm, _ := json.Marshal(b_obj)
+	return bytes.Equal(a_norm, b_norm)
+}
@@ -0,0 +1,65 @@
+package vfilter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestCorpus(t *testing.T) {
+	options := CorpusOptions{
+		NewScope: func() types.Scope {
+			return makeTestScope()
+		},
+	}
+
+	results, err := RunCorpus(context.Background(),
+		"fixtures/corpus", options)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+
+	for _, result := range results {
+		assert.NoError(t, result.Error, result.Name)
+		assert.True(t, result.Passed, "%v: %v", result.Name, result.Actual)
+	}
+}
+
+func TestCorpusDetectsChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "corpus")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	query := filepath.Join(dir, "query.vql")
+	assert.NoError(t, ioutil.WriteFile(query,
+		[]byte("SELECT 1 AS A FROM scope()"), 0644))
+
+	options := CorpusOptions{
+		NewScope: func() types.Scope {
+			return makeTestScope()
+		},
+	}
+
+	// No golden file yet.
+	results, err := RunCorpus(context.Background(), dir, options)
+	assert.NoError(t, err)
+	assert.Error(t, results[0].Error)
+
+	options.Update = true
+	results, err = RunCorpus(context.Background(), dir, options)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Passed)
+
+	// Change the query so the output no longer matches.
+	options.Update = false
+	assert.NoError(t, ioutil.WriteFile(query,
+		[]byte("SELECT 2 AS A FROM scope()"), 0644))
+
+	results, err = RunCorpus(context.Background(), dir, options)
+	assert.NoError(t, err)
+	assert.False(t, results[0].Passed)
+}
@@ -0,0 +1,144 @@
+package vfilter
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// The state kept by aggregate functions between rows.
+type aggregateState interface {
+	GetContext(scope types.Scope) (types.Any, bool)
+	SetContext(scope types.Scope, value types.Any)
+}
+
+// A query like SELECT count() FROM plugin() only counts the rows of
+// the plugin. Returns the count() call if the query can be answered
+// without transforming each row.
+func (self *_Select) countOnly(scope types.Scope) (*_SymbolRef, bool) {
+	if self.Where != nil || self.GroupBy != nil ||
+		self.SelectExpression == nil || self.SelectExpression.All ||
+		len(self.SelectExpression.Expressions) != 1 {
+		return nil, false
+	}
+
+	expr := self.SelectExpression.Expressions[0]
+	if expr.Expression == nil ||
+		FormatToString(scope, expr.Expression) != "count()" {
+		return nil, false
+	}
+
+	function, pres := scope.GetFunction("count")
+	if !pres || !functions.IsCountFunction(function) {
+		return nil, false
+	}
+
+	var symbol *_SymbolRef
+	walkAST(reflect.ValueOf(expr.Expression), func(node interface{}) {
+		t, ok := node.(*_SymbolRef)
+		if ok && t.Called && symbol == nil {
+			symbol = t
+		}
+	})
+
+	return symbol, symbol != nil
+}
+
+// Emit the running count for each row of the plugin without
+// evaluating the count() function. The count is shared with the
+// function so it continues where the function would have.
+func (self *_Select) evalCountOnly(ctx context.Context, scope types.Scope,
+	symbol *_SymbolRef, output_chan chan Row) {
+	symbol.mu.Lock()
+	if symbol.function == nil {
+		function, _ := scope.GetFunction("count")
+		symbol.function = CopyFunction(function)
+	}
+	function := symbol.function
+	state, ok := function.(aggregateState)
+	symbol.mu.Unlock()
+
+	if !ok || !checkFunctionAccess(ctx, scope, "count", function) {
+		return
+	}
+
+	count := uint64(0)
+	previous, pres := state.GetContext(scope)
+	if pres {
+		count, _ = previous.(uint64)
+	}
+	defer func() {
+		state.SetContext(scope, count)
+	}()
+
+	name := self.SelectExpression.Expressions[0].GetName(scope)
+	explainer := scope.Explainer()
+	first_row := true
+
+	rows := self.From.Rows(ctx, scope)
+	for {
+		row, ok := rows.Next()
+		if !ok {
+			return
+		}
+
+		explainer.PluginOutput(&self.From.Plugin, row)
+		if first_row {
+			self.warnShadowing(ctx, scope, row)
+			first_row = false
+		}
+
+		count++
+		result := &countRow{ctx: ctx, scope: scope, name: name, count: count}
+
+		select {
+		case <-ctx.Done():
+			return
+		case output_chan <- result:
+			explainer.SelectOutput(result)
+		}
+	}
+}
+
+// The row emitted by the count() fast path. It holds a single column
+// so it is much cheaper to build than a dict.
+type countRow struct {
+	ctx   context.Context
+	scope types.Scope
+	name  string
+	count uint64
+}
+
+func (self *countRow) AddColumn(name string,
+	getter func(ctx context.Context, scope types.Scope) types.Any) types.LazyRow {
+	result := NewLazyRow(self.ctx, self.scope)
+	count := self.count
+	result.AddColumn(self.name, func(
+		ctx context.Context, scope types.Scope) types.Any {
+		return count
+	})
+	return result.AddColumn(name, getter)
+}
+
+func (self *countRow) Has(name string) bool {
+	return name == self.name
+}
+
+func (self *countRow) Get(name string) (types.Any, bool) {
+	if name == self.name {
+		return self.count, true
+	}
+	return Null{}, false
+}
+
+func (self *countRow) Columns() []string {
+	return []string{self.name}
+}
+
+func (self *countRow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ordereddict.NewDict().Set(self.name, self.count))
+}
@@ -0,0 +1,106 @@
+package vfilter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestCountOnlyQuery(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	run := func(query string) []*ordereddict.Dict {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []*ordereddict.Dict
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result,
+				MaterializedLazyRow(context.Background(), row, scope))
+		}
+		return result
+	}
+
+	vql, err := Parse("SELECT count() FROM foreach(row=range(end=5))")
+	assert.NoError(t, err)
+	symbol, ok := vql.Query.countOnly(scope)
+	assert.True(t, ok)
+	assert.Equal(t, "count", symbol.Symbol)
+
+	// The fast path gives the same rows as evaluating count().
+	fast := run("SELECT count() FROM foreach(row=range(end=5))")
+	slow := run("SELECT count() FROM foreach(row=range(end=5)) WHERE TRUE")
+	assert.Equal(t, 5, len(fast))
+	assert.Equal(t, slow, fast)
+
+	fast = run("SELECT count() AS Total FROM foreach(row=range(end=5)) LIMIT 2")
+	assert.Equal(t, []*ordereddict.Dict{
+		ordereddict.NewDict().Set("Total", uint64(1)),
+		ordereddict.NewDict().Set("Total", uint64(2)),
+	}, fast)
+
+	// The rows serialize like dicts.
+	vql, err = Parse("SELECT count() AS Total FROM range(end=1) LIMIT 1")
+	assert.NoError(t, err)
+	json_scope := makeTestScope()
+	defer json_scope.Close()
+	for row := range vql.Eval(context.Background(), json_scope) {
+		serialized, err := json.Marshal(row)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"Total":1}`, string(serialized))
+	}
+
+	// Other queries are evaluated normally.
+	for _, query := range []string{
+		"SELECT count(), 1 AS X FROM scope()",
+		"SELECT * FROM scope()",
+		"SELECT count() + 1 FROM scope()",
+		"SELECT count() FROM scope() WHERE TRUE",
+		"SELECT count() FROM scope() GROUP BY 1",
+	} {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+		_, ok := vql.Query.countOnly(scope)
+		assert.False(t, ok, query)
+	}
+
+	// Not when count() is replaced.
+	scope.AppendFunctions(replacedCountFunction{})
+	vql, err = Parse("SELECT count() FROM scope()")
+	assert.NoError(t, err)
+	_, ok = vql.Query.countOnly(scope)
+	assert.False(t, ok)
+}
+
+type replacedCountFunction struct{}
+
+func (self replacedCountFunction) Call(ctx context.Context,
+	scope types.Scope, args *ordereddict.Dict) Any {
+	return "replaced"
+}
+
+func (self replacedCountFunction) Info(scope types.Scope,
+	type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{Name: "count"}
+}
+
+func TestCountOnlyQuerySecurityPolicy(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.SetSecurityPolicy(&AccessPolicy{DeniedFunctions: []string{"count"}})
+
+	vql, err := Parse("SELECT count() FROM range(end=3)")
+	assert.NoError(t, err)
+
+	var result []Row
+	for row := range vql.Eval(context.Background(), scope) {
+		result = append(result, row)
+	}
+	assert.Equal(t, 0, len(result))
+}
@@ -0,0 +1,60 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Later values are returned sooner so concurrent queries emit them
+// out of order.
+func makeDeterministicTestScope() types.Scope {
+	return makeTestScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "delayed",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			value, _ := args.Get("value")
+			delay, _ := value.(int64)
+			time.Sleep(time.Duration(10-delay) * 5 * time.Millisecond)
+			return []Row{ordereddict.NewDict().Set("Value", value)}
+		},
+	})
+}
+
+func TestDeterministic(t *testing.T) {
+	scope := makeDeterministicTestScope()
+	defer scope.Close()
+
+	assert.False(t, scope.Deterministic())
+	scope.SetDeterministic(true)
+
+	multi_vql, err := MultiParse(`
+LET A = SELECT * FROM delayed(value=1)
+LET B = SELECT * FROM delayed(value=9)
+SELECT * FROM chain(a=A, b=B, async=TRUE)
+SELECT * FROM foreach(row=range(end=10), workers=10,
+   query={ SELECT * FROM delayed(value=_value) })
+`)
+	assert.NoError(t, err)
+
+	var result []int64
+	for _, vql := range multi_vql {
+		err := vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				value, _ := row.(*ordereddict.Dict).Get("Value")
+				result = append(result, value.(int64))
+				return nil
+			})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []int64{1, 9, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, result)
+
+	// Copies keep the setting.
+	assert.True(t, scope.Copy().Deterministic())
+}
@@ -0,0 +1,86 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type testErrorCollector struct {
+	mu     sync.Mutex
+	errors []*types.QueryError
+}
+
+func (self *testErrorCollector) CollectError(err *types.QueryError) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.errors = append(self.errors, err)
+}
+
+func (self *testErrorCollector) Has(kind error) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, err := range self.errors {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestErrorCollector(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	for _, test := range []struct {
+		query string
+		kind  error
+	}{
+		{"SELECT * FROM no_such_plugin()", types.ErrPluginNotFound},
+		{"SELECT * FROM no_such_plugin()", types.ErrUndefinedSymbol},
+		{"SELECT no_such_function() FROM scope()", types.ErrUndefinedSymbol},
+		{"SELECT * FROM foreach(row=[1], query={ SELECT Missing FROM scope() })",
+			types.ErrUndefinedSymbol},
+		{"SELECT if(then=1) FROM scope()", types.ErrArgParse},
+		{"SELECT panic(column=2, value=2) FROM scope()", types.ErrPanic},
+	} {
+		collector := &testErrorCollector{}
+		scope.SetErrorCollector(collector)
+
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+
+		for range vql.Eval(context.Background(), scope) {
+		}
+
+		assert.True(t, collector.Has(test.kind), test.query)
+	}
+
+	// Errors include the call chain.
+	collector := &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+
+	vql, _ := Parse("SELECT * FROM foreach(row=[1], query={ SELECT Missing FROM scope() })")
+	for range vql.Eval(context.Background(), scope) {
+	}
+
+	assert.Equal(t, 1, len(collector.errors))
+	assert.Equal(t, "Undefined symbol: Missing (VQL call chain: "+
+		"SELECT * FROM foreach(row=[1], query={ SELECT Missing "+
+		"FROM scope() }) → foreach)", collector.errors[0].Error())
+
+	// Good queries report nothing.
+	collector = &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+
+	vql, _ = Parse("SELECT * FROM foreach(row=[1, 2])")
+	for range vql.Eval(context.Background(), scope) {
+	}
+	assert.Equal(t, 0, len(collector.errors))
+}
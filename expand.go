@@ -0,0 +1,97 @@
+package vfilter
+
+import (
+	"reflect"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Format the query preceded by the LET statements it depends on so
+// the result may be run in a new scope. The definitions are the ones
+// recorded in the scope when the LET statements ran (see
+// Scope.GetDefinitions()) and each appears before the statements
+// which use it. Symbols which are not defined with LET (e.g. plugins,
+// functions or variables added by the caller) are left as they are.
+func (self *VQL) ToExpandedString(scope types.Scope) string {
+	expander := &queryExpander{
+		scope:       scope,
+		definitions: make(map[string]*types.Definition),
+		seen:        make(map[string]bool),
+	}
+
+	for _, definition := range scope.GetDefinitions() {
+		expander.definitions[definition.Name] = definition
+	}
+
+	// A LET statement does not depend on an earlier definition of
+	// the same name.
+	if self.Let != "" {
+		expander.seen[self.Let] = true
+	}
+
+	expander.expand(self, self.getParameters())
+
+	return strings.Join(append(expander.statements,
+		FormatToString(scope, self)), "\n")
+}
+
+type queryExpander struct {
+	scope       types.Scope
+	definitions map[string]*types.Definition
+
+	// Definitions which were already added (or are being added
+	// for recursive definitions).
+	seen       map[string]bool
+	statements []string
+}
+
+// Add the definitions of the symbols the node refers to, after their
+// own dependencies.
+func (self *queryExpander) expand(node interface{}, parameters []string) {
+	for _, name := range referencedSymbols(node) {
+		if self.seen[name] || utils.InString(&parameters, name) {
+			continue
+		}
+
+		definition, pres := self.definitions[name]
+		if !pres {
+			continue
+		}
+		self.seen[name] = true
+
+		vql, err := Parse(definition.Source)
+		if err != nil {
+			self.scope.Log("ToExpandedString: %v: %v", name, err)
+			continue
+		}
+
+		self.expand(vql, definition.Parameters)
+		self.statements = append(self.statements,
+			FormatToString(self.scope, vql))
+	}
+}
+
+// The names of the symbols and plugins in the node in the order they
+// appear. Only the first component of dotted names is returned.
+func referencedSymbols(node interface{}) []string {
+	result := []string{}
+	add := func(name string) {
+		name = strings.Split(name, ".")[0]
+		if !utils.InString(&result, name) {
+			result = append(result, name)
+		}
+	}
+
+	walkAST(reflect.ValueOf(node), func(node interface{}) {
+		switch t := node.(type) {
+		case *_SymbolRef:
+			add(t.Symbol)
+		case *_From:
+			add(t.Plugin.Name)
+		}
+	})
+
+	return result
+}
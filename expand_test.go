@@ -0,0 +1,61 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestToExpandedString(t *testing.T) {
+	run := func(scope types.Scope, query string) []Row {
+		multi_vql, err := MultiParse(query)
+		assert.NoError(t, err)
+
+		var result []Row
+		for _, vql := range multi_vql {
+			for row := range vql.Eval(context.Background(), scope) {
+				result = append(result, row)
+			}
+		}
+		return result
+	}
+
+	scope := makeTestScope()
+	defer scope.Close()
+
+	run(scope, `
+LET Unused = 1
+LET Max = 2
+LET Double(x) = x * 2
+LET Rows = SELECT Double(x=_value) AS Value FROM foreach(row=[1, 2, 3])
+LET Small = SELECT * FROM Rows WHERE Value <= Double(x=Max)
+LET Max = 1
+`)
+
+	vql, err := Parse("SELECT Value, Double(x=Value) AS Twice FROM Small")
+	assert.NoError(t, err)
+
+	expanded := vql.ToExpandedString(scope)
+	assert.Equal(t, `LET Double(x) = x * 2
+LET Rows = SELECT Double(x=_value) AS Value FROM foreach(row=[1, 2, 3])
+LET Max = 1
+LET Small = SELECT * FROM Rows WHERE Value <= Double(x=Max)
+SELECT Value, Double(x=Value) AS Twice FROM Small`, expanded)
+
+	// The expanded query gives the same rows in a new scope.
+	new_scope := makeTestScope()
+	defer new_scope.Close()
+
+	assert.Equal(t, run(scope, vql.Source(scope)), run(new_scope, expanded))
+
+	// Recursive definitions are only added once.
+	run(scope, "LET Fact(n) = if(condition=n <= 1, then=1, else=n * Fact(n=n - 1))")
+
+	vql, err = Parse("SELECT Fact(n=5) AS Fact FROM scope()")
+	assert.NoError(t, err)
+
+	assert.Equal(t, `LET Fact(n) = if(condition=n <= 1, then=1, else=n * Fact(n=n - 1))
+SELECT Fact(n=5) AS Fact FROM scope()`, vql.ToExpandedString(scope))
+}
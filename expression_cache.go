@@ -0,0 +1,97 @@
+package vfilter
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Caches the results of a LET function declared with the CACHE
+// modifier:
+//
+// LET f(x) = slow_function(arg=x) CACHE 1000
+//
+// or memoized with LET f(x) <= slow_function(arg=x)
+//
+// Results are cached per argument tuple. The cache holds at most size
+// results and evicts the least recently used result.
+type expressionCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// The number of results cached by memoized functions declared with
+// LET f(x) <= ... unless they specify CACHE.
+const defaultMemoizeSize = 10000
+
+type expressionCacheEntry struct {
+	key   string
+	value types.Any
+}
+
+func newExpressionCache(size int64) *expressionCache {
+	return &expressionCache{
+		size:    int(size),
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (self *expressionCache) Get(key string) (types.Any, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	element, pres := self.entries[key]
+	if !pres {
+		return nil, false
+	}
+	self.lru.MoveToFront(element)
+	return element.Value.(*expressionCacheEntry).value, true
+}
+
+func (self *expressionCache) Set(key string, value types.Any) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	element, pres := self.entries[key]
+	if pres {
+		element.Value.(*expressionCacheEntry).value = value
+		self.lru.MoveToFront(element)
+		return
+	}
+
+	self.entries[key] = self.lru.PushFront(
+		&expressionCacheEntry{key: key, value: value})
+
+	for self.lru.Len() > self.size {
+		oldest := self.lru.Back()
+		self.lru.Remove(oldest)
+		delete(self.entries, oldest.Value.(*expressionCacheEntry).key)
+	}
+}
+
+// Build a cache key from the call's args. The order of the args does
+// not matter.
+func expressionCacheKey(vars *ordereddict.Dict) string {
+	keys := vars.Keys()
+	sort.Strings(keys)
+
+	parts := make([]interface{}, 0, 2*len(keys))
+	for _, k := range keys {
+		v, _ := vars.Get(k)
+		parts = append(parts, k, v)
+	}
+
+	serialized, err := json.Marshal(parts)
+	if err != nil {
+		return fmt.Sprintf("%v", parts)
+	}
+	return string(serialized)
+}
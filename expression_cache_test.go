@@ -0,0 +1,114 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestCachedLetExpression(t *testing.T) {
+	calls := 0
+	scope := makeTestScope().AppendFunctions(
+		functions.GenericFunction{
+			FunctionName: "slow",
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) types.Any {
+				calls++
+				value, _ := args.Get("x")
+				return value
+			},
+		})
+	defer scope.Close()
+
+	multi_vql, err := MultiParse(`
+LET f(x) = slow(x=x) CACHE 2
+LET g(x) = slow(x=x)
+SELECT f(x=_value) AS F FROM foreach(row=[1, 1, 2, 1, 2])
+SELECT g(x=_value) AS G FROM foreach(row=[1, 1, 2, 1, 2])
+SELECT f(x=_value) AS F FROM foreach(row=[3, 4, 5, 3])
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "LET f(x) = slow(x=x) CACHE 2",
+		FormatToString(scope, multi_vql[0]))
+
+	ctx := context.Background()
+	var results []int
+	for _, vql := range multi_vql {
+		calls = 0
+		for _ = range vql.Eval(ctx, scope) {
+		}
+		results = append(results, calls)
+	}
+
+	// Repeated args are only computed once.
+	assert.Equal(t, 2, results[2])
+
+	// Without CACHE every call is computed.
+	assert.Equal(t, 5, results[3])
+
+	// The cache only holds 2 results so 3 is evicted by the time
+	// it is used again.
+	assert.Equal(t, 4, results[4])
+}
+
+func TestMemoizedLet(t *testing.T) {
+	calls := 0
+	scope := makeTestScope().AppendFunctions(
+		functions.GenericFunction{
+			FunctionName: "slow",
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) types.Any {
+				calls++
+				value, _ := args.Get("x")
+				return value
+			},
+		})
+	defer scope.Close()
+
+	multi_vql, err := MultiParse(`
+LET f(x) <= slow(x=x)
+LET q(x) <= SELECT slow(x=x) AS Value FROM scope()
+SELECT f(x=_value) AS F FROM foreach(row=[1, 1, 2, 1, 2])
+SELECT * FROM foreach(row=[1, 1, 2, 1, 2], query={
+  SELECT * FROM q(x=_value)
+})
+SELECT * FROM foreach(row=[1, 2], query={
+  SELECT * FROM q(x=_value)
+})
+SELECT q(x=_value) AS Q FROM foreach(row=[3, 3, 3])
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "LET f(x) <= slow(x=x)", FormatToString(scope, multi_vql[0]))
+
+	ctx := context.Background()
+	var results []int
+	var rows [][]Row
+	for _, vql := range multi_vql {
+		calls = 0
+		var query_rows []Row
+		for row := range vql.Eval(ctx, scope) {
+			query_rows = append(query_rows, row)
+		}
+		results = append(results, calls)
+		rows = append(rows, query_rows)
+	}
+
+	// Each distinct arg is only computed once.
+	assert.Equal(t, 2, results[2])
+	assert.Equal(t, 2, results[3])
+
+	value, _ := scope.Associative(rows[3][4], "Value")
+	assert.Equal(t, int64(2), value)
+
+	// The memoized query remembers its rows across queries.
+	assert.Equal(t, 0, results[4])
+	assert.Equal(t, 2, len(rows[4]))
+
+	// Calling the query like a function is memoized too.
+	assert.Equal(t, 1, results[5])
+	assert.Equal(t, 3, len(rows[5]))
+}
@@ -0,0 +1,116 @@
+package vfilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type FingerprintOptions struct {
+	// Replace literal values (strings, numbers, durations,
+	// booleans and NULL) with a placeholder so queries which only
+	// differ in their constants have the same fingerprint.
+	IgnoreLiterals bool
+}
+
+var (
+	fingerprintScope     types.Scope
+	fingerprintScopeOnce sync.Once
+)
+
+// Returns a hash of the normalized query which may be used to
+// recognize equivalent queries (e.g. as a cache key or to correlate
+// audit records). Whitespace, comments, keyword case and the quoting
+// of strings do not change the fingerprint. Fingerprints are only
+// comparable between the same versions of this library.
+func Fingerprint(vql *VQL) string {
+	return FingerprintWithOptions(vql, FingerprintOptions{})
+}
+
+func FingerprintWithOptions(vql *VQL, options FingerprintOptions) string {
+	digest := sha256.Sum256([]byte(NormalizedString(vql, options)))
+	return hex.EncodeToString(digest[:])
+}
+
+// The text which is hashed by FingerprintWithOptions().
+func NormalizedString(vql *VQL, options FingerprintOptions) string {
+	fingerprintScopeOnce.Do(func() {
+		fingerprintScope = NewScope()
+	})
+
+	normalizer := &queryNormalizer{options: options}
+	normalized := normalizer.copy(reflect.ValueOf(vql)).Interface()
+
+	return FormatToString(fingerprintScope, normalized)
+}
+
+// Copies the parsed fields of the AST without the comments and with
+// the literals in a canonical form.
+type queryNormalizer struct {
+	options FingerprintOptions
+}
+
+var literalPlaceholder = "?"
+
+func (self *queryNormalizer) copy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.New(value.Type().Elem())
+		result.Elem().Set(self.copy(value.Elem()))
+
+		literal, ok := result.Interface().(*_Value)
+		if ok {
+			self.normalizeValue(literal)
+		}
+		return result
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(self.copy(value.Index(i)))
+		}
+		return result
+
+	case reflect.Struct:
+		result := reflect.New(value.Type()).Elem()
+		value_type := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			field := value_type.Field(i)
+			if field.PkgPath != "" || field.Name == "Comments" {
+				continue
+			}
+			result.Field(i).Set(self.copy(value.Field(i)))
+		}
+		return result
+	}
+
+	return value
+}
+
+func (self *queryNormalizer) normalizeValue(value *_Value) {
+	if value.SymbolRef != nil || value.Subexpression != nil {
+		return
+	}
+
+	if self.options.IgnoreLiterals {
+		*value = _Value{String: &literalPlaceholder}
+		return
+	}
+
+	// 'a', "a" and '''a''' are the same string.
+	if value.String != nil {
+		quoted := strconv.Quote(utils.Unquote(*value.String))
+		value.String = &quoted
+	}
+}
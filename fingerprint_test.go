@@ -0,0 +1,62 @@
+package vfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(query string, options FingerprintOptions) string {
+		multi_vql, err := MultiParseWithComments(query)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(multi_vql))
+
+		return FingerprintWithOptions(multi_vql[0], options)
+	}
+
+	query := "SELECT Name, Size FROM glob(globs='/tmp/*') WHERE Size > 0x10"
+	expected := fingerprint(query, FingerprintOptions{})
+	assert.Equal(t, 64, len(expected))
+
+	vql, err := Parse(query)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, Fingerprint(vql))
+
+	// Equivalent queries.
+	for _, equivalent := range []string{
+		"select Name,Size from glob(globs=\"/tmp/*\") where Size>16",
+		`
+-- Find files
+SELECT Name, Size
+FROM glob(globs='''/tmp/*''')
+WHERE Size > 16`,
+	} {
+		assert.Equal(t, expected, fingerprint(equivalent, FingerprintOptions{}),
+			equivalent)
+	}
+
+	// Different queries.
+	for _, different := range []string{
+		"SELECT Size, Name FROM glob(globs='/tmp/*') WHERE Size > 16",
+		"SELECT Name, Size FROM glob(globs='/tmp/*') WHERE Size > 17",
+		"SELECT Name, Size FROM glob(globs='/TMP/*') WHERE Size > 16",
+		"SELECT name, Size FROM glob(globs='/tmp/*') WHERE Size > 16",
+	} {
+		assert.NotEqual(t, expected, fingerprint(different, FingerprintOptions{}),
+			different)
+	}
+
+	// Literals may be ignored.
+	options := FingerprintOptions{IgnoreLiterals: true}
+	expected = fingerprint(query, options)
+	assert.Equal(t, expected, fingerprint(
+		"SELECT Name, Size FROM glob(globs='/etc/*') WHERE Size > -5.5", options))
+	assert.NotEqual(t, expected, fingerprint(
+		"SELECT Name, Size FROM glob(globs='/etc/*') WHERE Size < 5", options))
+
+	vql, err = Parse(query)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT Name, Size FROM glob(globs=?) WHERE Size > ?",
+		NormalizedString(vql, options))
+}
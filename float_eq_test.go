@@ -0,0 +1,38 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloatEpsilon(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	count := func(query string) int {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		rows := 0
+		for range vql.Eval(context.Background(), scope) {
+			rows++
+		}
+		return rows
+	}
+
+	// 0.1 + 0.2 is not exactly 0.3 in floating point.
+	query := "SELECT * FROM scope() WHERE 0.1 + 0.2 = 0.3"
+	assert.Equal(t, 0, count(query))
+
+	scope.SetFloatEpsilon(1e-9)
+	assert.Equal(t, 1e-9, scope.FloatEpsilon())
+	assert.Equal(t, 1, count(query))
+	assert.Equal(t, 1, count("SELECT * FROM scope() WHERE 3 = 2.9999999999"))
+	assert.Equal(t, 0, count("SELECT * FROM scope() WHERE 0.3 = 0.31"))
+
+	// With a tolerance ints are compared as floats.
+	assert.False(t, scope.Eq(2, 2.000001))
+	assert.True(t, scope.Eq(2, 2.0000000001))
+}
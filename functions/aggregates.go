@@ -101,6 +101,16 @@ func (self _CountFunction) Call(
 	return count
 }
 
+// Is the function the builtin count() (as copied for an AST node)?
+// Queries which only count rows do not need to call it for each row.
+func IsCountFunction(function types.Any) bool {
+	switch function.(type) {
+	case _CountFunction, *_CountFunction:
+		return true
+	}
+	return false
+}
+
 type _SumFunctionArgs struct {
 	Item int64 `vfilter:"required,field=item"`
 }
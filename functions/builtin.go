@@ -18,5 +18,21 @@ func GetBuiltinFunctions() []types.FunctionInterface {
 		_EnumerateFunction{},
 		FormatFunction{},
 		LenFunction{},
+		_EagerFunction{},
+		_RangeFunction{},
+		_CoalesceFunction{},
+		_FirstOfFunction{},
+		_StartsWithFunction{},
+		_EndsWithFunction{},
+		_ContainsFunction{},
+		_TruncateFunction{},
+		_ElideMiddleFunction{},
+		_HumanizeBytesFunction{},
+		_HumanizeDurationFunction{},
+		_HumanizeNumberFunction{},
+		_ParseNumberFunction{},
+		_ParseIntFunction{},
+		_FormatIntFunction{},
+		_CacheFunction{},
 	}
 }
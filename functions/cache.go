@@ -0,0 +1,161 @@
+package functions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _CacheFunctionArgs struct {
+	Expr   types.LazyAny `vfilter:"required,field=expr,doc=The expression to evaluate when the key is not cached"`
+	Key    string        `vfilter:"required,field=key,doc=The key to cache the value under"`
+	Period int64         `vfilter:"optional,field=period,doc=How long to cache the value for in seconds (default 60)"`
+}
+
+type cacheEntry struct {
+	value   types.Any
+	expires time.Time
+}
+
+// All cached values are kept in a single store in the scope context.
+const cacheContextKey = "__cache"
+
+// Expired entries are only purged when the store grows, so the
+// store holds at most twice the number of live entries (and at
+// least minCachePurgeSize).
+const minCachePurgeSize = 1000
+
+type cacheStore struct {
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	purge_size int
+}
+
+func getCacheStore(scope types.Scope) *cacheStore {
+	store_any, pres := scope.GetContext(cacheContextKey)
+	if pres {
+		store, ok := store_any.(*cacheStore)
+		if ok {
+			return store
+		}
+	}
+
+	store := &cacheStore{
+		entries:    make(map[string]*cacheEntry),
+		purge_size: minCachePurgeSize,
+	}
+	scope.SetContext(cacheContextKey, store)
+	return store
+}
+
+func (self *cacheStore) Get(key string, now time.Time) (types.Any, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	entry, pres := self.entries[key]
+	if !pres || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (self *cacheStore) Set(key string, value types.Any, now, expires time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.entries[key] = &cacheEntry{value: value, expires: expires}
+	if len(self.entries) < self.purge_size {
+		return
+	}
+
+	for k, entry := range self.entries {
+		if !now.Before(entry.expires) {
+			delete(self.entries, k)
+		}
+	}
+
+	self.purge_size = 2 * len(self.entries)
+	if self.purge_size < minCachePurgeSize {
+		self.purge_size = minCachePurgeSize
+	}
+}
+
+// The number of entries in the store, including expired entries
+// which were not purged yet.
+func (self *cacheStore) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.entries)
+}
+
+// Cache the value of an expensive expression in the scope context so
+// it is only evaluated once per key for each period. For example,
+// looking up the owner of each file only calls lookup_user() once
+// per owner:
+//
+//	SELECT FullPath, cache(key=Uid, expr=lookup_user(uid=Uid)) AS Owner
+//	FROM glob(globs="/home/*/**")
+//
+// The cache is shared by all scopes using the same context so
+// different queries using the same key see each other's values.
+type _CacheFunction struct{}
+
+func (self _CacheFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "cache",
+		Doc:     "Evaluate the expression once per key and reuse its value for the period.",
+		ArgType: type_map.AddType(scope, _CacheFunctionArgs{}),
+	}
+}
+
+func (self _CacheFunction) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) types.Any {
+	arg := &_CacheFunctionArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("cache: %v", err)
+		return types.Null{}
+	}
+
+	period := time.Duration(arg.Period) * time.Second
+	if arg.Period == 0 {
+		period = time.Minute
+	}
+
+	store := getCacheStore(scope)
+	now := time.Now()
+
+	cached, pres := store.Get(arg.Key, now)
+	if pres {
+		return cached
+	}
+
+	value := arg.Expr
+	lazy_expr, ok := value.(types.LazyExpr)
+	if ok {
+		value = lazy_expr.ReduceWithScope(ctx, scope)
+	}
+
+	// Queries are materialized so the cached value does not run
+	// them again.
+	stored_query, ok := value.(types.StoredQuery)
+	if ok {
+		value = types.Materialize(ctx, scope, stored_query)
+	}
+
+	// Do not cache values of cancelled expressions.
+	if ctx.Err() != nil {
+		return value
+	}
+
+	store.Set(arg.Key, value, now, now.Add(period))
+
+	return value
+}
@@ -0,0 +1,44 @@
+package functions
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _CoalesceFunction struct{}
+
+func (self _CoalesceFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name: "coalesce",
+		Doc:  "Return the first argument which is not NULL. Later arguments are not evaluated.",
+	}
+}
+
+func (self _CoalesceFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	for _, k := range args.Keys() {
+		v, _ := args.Get(k)
+		lazy_arg, ok := v.(types.LazyExpr)
+		if ok {
+			v = lazy_arg.Reduce(ctx)
+		}
+
+		if !types.IsNullObject(v) {
+			return v
+		}
+	}
+	return types.Null{}
+}
+
+// firstof() is an alias for coalesce().
+type _FirstOfFunction struct {
+	_CoalesceFunction
+}
+
+func (self _FirstOfFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name: "firstof",
+		Doc:  "Return the first argument which is not NULL (alias for coalesce).",
+	}
+}
@@ -0,0 +1,47 @@
+package functions
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _EagerFunctionArgs struct {
+	Item types.Any `vfilter:"optional,field=item,doc=The expression to evaluate"`
+}
+
+// Columns are normally evaluated lazily - only when the row is
+// emitted or the column is referenced by the WHERE clause. When
+// eager() is the top level expression of a column the SELECT
+// evaluates the column for every row produced by the plugin, even
+// when the row is later rejected. This is useful when the column is
+// evaluated for its side effects.
+type _EagerFunction struct{}
+
+func (self _EagerFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "eager",
+		Doc:     "Evaluate the column for every row even if the row is filtered out.",
+		ArgType: type_map.AddType(scope, _EagerFunctionArgs{}),
+	}
+}
+
+func (self _EagerFunction) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) types.Any {
+	arg := &_EagerFunctionArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("eager: %s", err.Error())
+		return types.Null{}
+	}
+
+	if arg.Item == nil {
+		return types.Null{}
+	}
+
+	return arg.Item
+}
@@ -16,6 +16,7 @@ import (
 	"golang.org/x/text/transform"
 	"www.velocidex.com/golang/vfilter/arg_parser"
 	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
 )
 
 // A helper function to build a dict within the query.
@@ -44,15 +45,18 @@ func (self _DictFunc) Call(ctx context.Context, scope types.Scope, args *ordered
 }
 
 type _TimestampArg struct {
-	Epoch       int64 `vfilter:"optional,field=epoch"`
-	WinFileTime int64 `vfilter:"optional,field=winfiletime"`
+	Epoch       types.Any `vfilter:"optional,field=epoch,doc=Seconds, ms, us or ns since the epoch (autodetected)"`
+	WinFileTime int64     `vfilter:"optional,field=winfiletime,doc=Windows FILETIME (100ns since 1601)"`
+	String      string    `vfilter:"optional,field=string,doc=A string to parse as a time"`
+	Format      string    `vfilter:"optional,field=format,doc=A Go time layout to parse the string with"`
+	TZ          string    `vfilter:"optional,field=tz,doc=Timezone name (e.g. Australia/Brisbane) for times without one (default UTC)"`
 }
 type _Timestamp struct{}
 
 func (self _Timestamp) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
 	return &types.FunctionInfo{
 		Name:    "timestamp",
-		Doc:     "Convert seconds from epoch into a string.",
+		Doc:     "Convert seconds from epoch or a time string into a timestamp.",
 		ArgType: type_map.AddType(scope, _TimestampArg{}),
 	}
 }
@@ -65,12 +69,36 @@ func (self _Timestamp) Call(ctx context.Context, scope types.Scope, args *ordere
 		return types.Null{}
 	}
 
-	if arg.Epoch > 0 {
-		return time.Unix(arg.Epoch, 0)
+	location := time.UTC
+	if arg.TZ != "" {
+		location, err = time.LoadLocation(arg.TZ)
+		if err != nil {
+			scope.Log("timestamp: %v", err)
+			return types.Null{}
+		}
+	}
+
+	if arg.String != "" {
+		result, err := utils.ParseTimeString(arg.String, arg.Format, location)
+		if err != nil {
+			scope.Log("timestamp: %v", err)
+			return types.Null{}
+		}
+		return result
+	}
+
+	if !types.IsNullObject(arg.Epoch) {
+		result, ok := utils.EpochToTime(arg.Epoch)
+		if !ok {
+			scope.Log("timestamp: Unable to convert %v (%T) to a time",
+				arg.Epoch, arg.Epoch)
+			return types.Null{}
+		}
+		return result.In(location)
 	}
 
 	if arg.WinFileTime > 0 {
-		return time.Unix((arg.WinFileTime/10000000)-11644473600, 0)
+		return time.Unix((arg.WinFileTime/10000000)-11644473600, 0).In(location)
 	}
 
 	return types.Null{}
@@ -28,6 +28,15 @@ type GenericFunction struct {
 	Function     GenericFunctionInterface
 	Metadata     *ordereddict.Dict
 	ArgType      types.Any
+
+	// An example of the returned value used for type inference.
+	ReturnType types.Any
+
+	// A relative cost hint used by the planner.
+	Cost int
+
+	// The capabilities the function requires.
+	Capabilities []string
 }
 
 func (self GenericFunction) Copy() types.FunctionInterface {
@@ -44,9 +53,12 @@ func (self GenericFunction) Call(
 
 func (self GenericFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
 	result := &types.FunctionInfo{
-		Name:     self.FunctionName,
-		Doc:      self.Doc,
-		Metadata: self.Metadata,
+		Name:         self.FunctionName,
+		Doc:          self.Doc,
+		Metadata:     self.Metadata,
+		ReturnType:   self.ReturnType,
+		Cost:         self.Cost,
+		Capabilities: self.Capabilities,
 	}
 
 	if self.ArgType != nil {
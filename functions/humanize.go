@@ -0,0 +1,229 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Helpers to present large numbers in reports.
+
+var (
+	binaryUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	siUnits     = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+)
+
+func humanizeBytes(size int64, si bool) string {
+	base, units := 1024.0, binaryUnits
+	if si {
+		base, units = 1000.0, siUnits
+	}
+
+	sign := ""
+	value := float64(size)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	if value < base {
+		return fmt.Sprintf("%s%d B", sign, int64(value))
+	}
+
+	exp := 0
+	for value >= base && exp < len(units)-1 {
+		value /= base
+		exp++
+	}
+	return fmt.Sprintf("%s%.1f %s", sign, value, units[exp])
+}
+
+func humanizeDuration(duration time.Duration) string {
+	// Work on the magnitude as a uint64 since the most negative
+	// duration can not be negated.
+	sign := ""
+	magnitude := uint64(duration)
+	if duration < 0 {
+		sign = "-"
+		magnitude = uint64(-(duration + 1)) + 1
+	}
+
+	// Sub-second durations are best shown by Go.
+	if magnitude < uint64(time.Second) {
+		return sign + time.Duration(magnitude).String()
+	}
+
+	parts := []string{}
+	for _, unit := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		count := magnitude / uint64(unit.duration)
+		if count > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", count, unit.name))
+			magnitude -= count * uint64(unit.duration)
+		}
+	}
+	return sign + strings.Join(parts, " ")
+}
+
+// Insert sep between each group of three digits in the integer part.
+func groupDigits(number string, sep string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign = "-"
+		number = number[1:]
+	}
+
+	fraction := ""
+	idx := strings.Index(number, ".")
+	if idx >= 0 {
+		number, fraction = number[:idx], number[idx:]
+	}
+
+	result := []string{}
+	for len(number) > 3 {
+		result = append([]string{number[len(number)-3:]}, result...)
+		number = number[:len(number)-3]
+	}
+	result = append([]string{number}, result...)
+
+	return sign + strings.Join(result, sep) + fraction
+}
+
+type _HumanizeBytesArgs struct {
+	Bytes int64 `vfilter:"required,field=bytes,doc=The size in bytes"`
+	SI    bool  `vfilter:"optional,field=si,doc=Use powers of 1000 (kB, MB) instead of 1024 (KiB, MiB)"`
+}
+
+type _HumanizeBytesFunction struct{}
+
+func (self _HumanizeBytesFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "humanize_bytes",
+		Doc:     "Format a size in bytes as a human readable string (e.g. 13.0 GiB).",
+		ArgType: type_map.AddType(scope, _HumanizeBytesArgs{}),
+	}
+}
+
+func (self _HumanizeBytesFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_HumanizeBytesArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("humanize_bytes: %v", err)
+		return types.Null{}
+	}
+
+	return humanizeBytes(arg.Bytes, arg.SI)
+}
+
+type _HumanizeDurationArgs struct {
+	Duration types.Any `vfilter:"required,field=duration,doc=A duration or a number of seconds"`
+}
+
+type _HumanizeDurationFunction struct{}
+
+func (self _HumanizeDurationFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "humanize_duration",
+		Doc:     "Format a duration or a number of seconds as a human readable string (e.g. 1d 2h 3m 4s).",
+		ArgType: type_map.AddType(scope, _HumanizeDurationArgs{}),
+	}
+}
+
+func (self _HumanizeDurationFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_HumanizeDurationArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("humanize_duration: %v", err)
+		return types.Null{}
+	}
+
+	switch t := arg.Duration.(type) {
+	case time.Duration:
+		return humanizeDuration(t)
+
+	case *time.Duration:
+		return humanizeDuration(*t)
+	}
+
+	seconds, ok := utils.ToFloat(arg.Duration)
+	if !ok {
+		scope.Log("humanize_duration: duration should be a duration or a number, not %T",
+			arg.Duration)
+		return types.Null{}
+	}
+
+	// Durations are limited to about 292 years.
+	nanoseconds := seconds * float64(time.Second)
+	if math.IsNaN(nanoseconds) || nanoseconds >= math.MaxInt64 ||
+		nanoseconds < math.MinInt64 {
+		scope.Log("humanize_duration: duration %v is out of range", arg.Duration)
+		return types.Null{}
+	}
+
+	return humanizeDuration(time.Duration(nanoseconds))
+}
+
+type _HumanizeNumberArgs struct {
+	Number    types.Any `vfilter:"required,field=number,doc=The number to format"`
+	Separator string    `vfilter:"optional,field=sep,doc=The thousands separator (default ,)"`
+	Precision int64     `vfilter:"optional,field=precision,doc=Digits after the decimal point for floats (default as many as needed)"`
+}
+
+type _HumanizeNumberFunction struct{}
+
+func (self _HumanizeNumberFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "humanize_number",
+		Doc:     "Format a number with its digits grouped in thousands (e.g. 13,982,347,234).",
+		ArgType: type_map.AddType(scope, _HumanizeNumberArgs{}),
+	}
+}
+
+func (self _HumanizeNumberFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_HumanizeNumberArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("humanize_number: %v", err)
+		return types.Null{}
+	}
+
+	sep := ","
+	_, pres := args.Get("sep")
+	if pres {
+		sep = arg.Separator
+	}
+
+	precision := -1
+	_, pres = args.Get("precision")
+	if pres {
+		precision = int(arg.Precision)
+	}
+
+	if utils.IsInt(arg.Number) {
+		number, _ := utils.ToInt64(arg.Number)
+		return groupDigits(strconv.FormatInt(number, 10), sep)
+	}
+
+	number, ok := utils.ToFloat(arg.Number)
+	if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+		scope.Log("humanize_number: number should be a number, not %v", arg.Number)
+		return types.Null{}
+	}
+
+	return groupDigits(strconv.FormatFloat(number, 'f', precision, 64), sep)
+}
@@ -0,0 +1,119 @@
+package functions
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Conversions between integers and their representation in other
+// bases, e.g. hex bitmasks from the registry.
+
+var basePrefixes = map[int64]string{16: "0x", 8: "0o", 2: "0b"}
+
+type _ParseIntArgs struct {
+	String string `vfilter:"required,field=string,doc=The string to parse"`
+	Base   int64  `vfilter:"optional,field=base,default=10,doc=The base (2 to 36) or 0 to detect it from the prefix (0x, 0o, 0b)"`
+}
+
+type _ParseIntFunction struct{}
+
+func (self _ParseIntFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "parse_int",
+		Doc:     "Parse a string as an integer in the given base.",
+		ArgType: type_map.AddType(scope, _ParseIntArgs{}),
+	}
+}
+
+func (self _ParseIntFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_ParseIntArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("parse_int: %v", err)
+		return types.Null{}
+	}
+
+	value := strings.TrimSpace(arg.String)
+
+	// Allow the usual prefix when the base is explicit too.
+	prefix, pres := basePrefixes[arg.Base]
+	if pres && strings.HasPrefix(strings.ToLower(value), prefix) {
+		value = value[len(prefix):]
+	}
+
+	result, err := strconv.ParseInt(value, int(arg.Base), 64)
+	if err == nil {
+		return result
+	}
+
+	// Large values like 0xFFFFFFFFFFFFFFFF only fit in a uint64.
+	unsigned, err := strconv.ParseUint(value, int(arg.Base), 64)
+	if err == nil {
+		return unsigned
+	}
+
+	scope.Log("parse_int: %v", err)
+	return types.Null{}
+}
+
+type _FormatIntArgs struct {
+	Value types.Any `vfilter:"required,field=value,doc=The integer to format"`
+	Base  int64     `vfilter:"optional,field=base,default=16,min=2,max=36,doc=The base (2 to 36)"`
+	Pad   int64     `vfilter:"optional,field=pad,doc=Pad with zeros to at least this many digits"`
+}
+
+type _FormatIntFunction struct{}
+
+func (self _FormatIntFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "format_int",
+		Doc:     "Format an integer in the given base.",
+		ArgType: type_map.AddType(scope, _FormatIntArgs{}),
+	}
+}
+
+func (self _FormatIntFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_FormatIntArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("format_int: %v", err)
+		return types.Null{}
+	}
+
+	var digits string
+	switch t := arg.Value.(type) {
+	case uint64:
+		digits = strconv.FormatUint(t, int(arg.Base))
+
+	default:
+		value, ok := utils.ToInt64(arg.Value)
+		if !ok {
+			scope.Log("format_int: value should be an integer, not %T", arg.Value)
+			return types.Null{}
+		}
+		digits = strconv.FormatInt(value, int(arg.Base))
+	}
+
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	// Padding should only ever need a few digits.
+	if arg.Pad > 1000 {
+		scope.Log("format_int: pad %v exceeded memory limits", arg.Pad)
+		return types.Null{}
+	}
+
+	if int64(len(digits)) < arg.Pad {
+		digits = strings.Repeat("0", int(arg.Pad)-len(digits)) + digits
+	}
+
+	return sign + digits
+}
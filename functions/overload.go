@@ -0,0 +1,166 @@
+package functions
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Several functions may be registered under the same name as long
+// as they declare different args. On each call we pick the first
+// overload which accepts the args given, so a library can extend a
+// builtin like format() with new args while the builtin still
+// handles the old ones.
+
+type overload struct {
+	function types.FunctionInterface
+
+	// The args the function accepts and those it requires.
+	accepted []string
+	required []string
+}
+
+func newOverload(scope types.Scope, function types.FunctionInterface) *overload {
+	result := &overload{function: function}
+
+	type_map := types.NewTypeMap()
+	info := function.Info(scope, type_map)
+	desc, pres := type_map.Get(scope, info.ArgType)
+	if !pres {
+		return result
+	}
+
+	for _, name := range desc.Fields.Keys() {
+		result.accepted = append(result.accepted, name)
+
+		field, _ := desc.Fields.Get(name)
+		ref, ok := field.(*types.TypeReference)
+		if !ok {
+			continue
+		}
+
+		for _, directive := range strings.Split(ref.Tag, ",") {
+			if directive == "required" {
+				result.required = append(result.required, name)
+			}
+		}
+	}
+
+	return result
+}
+
+func (self *overload) matches(args *ordereddict.Dict) bool {
+	for _, name := range self.required {
+		_, pres := args.Get(name)
+		if !pres {
+			return false
+		}
+	}
+
+	for _, name := range args.Keys() {
+		if !inList(self.accepted, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func inList(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+type OverloadedFunction struct {
+	// Most recently registered first. The last one is the
+	// original function.
+	overloads []*overload
+}
+
+// Add function as an overload of existing. The result is a new
+// object so scopes sharing existing are not affected.
+func Overload(scope types.Scope,
+	existing types.FunctionInterface,
+	function types.FunctionInterface) *OverloadedFunction {
+	result := &OverloadedFunction{
+		overloads: []*overload{newOverload(scope, function)},
+	}
+
+	existing_overloads, ok := existing.(*OverloadedFunction)
+	if ok {
+		result.overloads = append(result.overloads,
+			existing_overloads.overloads...)
+	} else {
+		result.overloads = append(result.overloads,
+			newOverload(scope, existing))
+	}
+
+	return result
+}
+
+// All the overloads, most recently registered first.
+func (self *OverloadedFunction) Overloads() []types.FunctionInterface {
+	result := make([]types.FunctionInterface, 0, len(self.overloads))
+	for _, o := range self.overloads {
+		result = append(result, o.function)
+	}
+	return result
+}
+
+// The info of the original function.
+func (self *OverloadedFunction) Info(
+	scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return self.overloads[len(self.overloads)-1].function.Info(scope, type_map)
+}
+
+func (self *OverloadedFunction) Call(
+	ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	for _, o := range self.overloads {
+		if o.matches(args) {
+			return o.function.Call(ctx, scope, args)
+		}
+	}
+
+	// Nothing matched: Let the original function report the
+	// problem with the args.
+	return self.overloads[len(self.overloads)-1].function.Call(ctx, scope, args)
+}
+
+// Each AST node gets its own copy of the overloads so they may keep
+// state (e.g. aggregates).
+func (self *OverloadedFunction) Copy() types.FunctionInterface {
+	result := &OverloadedFunction{}
+	for _, o := range self.overloads {
+		result.overloads = append(result.overloads, &overload{
+			function: CopyFunction(o.function),
+			accepted: o.accepted,
+			required: o.required,
+		})
+	}
+	return result
+}
+
+// Make a fresh copy of a function for an AST node.
+func CopyFunction(in types.Any) types.FunctionInterface {
+	copier, ok := in.(types.FunctionCopier)
+	if ok {
+		return copier.Copy()
+	}
+
+	in_value := reflect.Indirect(reflect.ValueOf(in))
+	result := reflect.New(in_value.Type()).Interface()
+
+	// Handle aggregate functions specifically.
+	aggregate_func, ok := result.(AggregatorInterface)
+	if ok {
+		aggregate_func.SetNewAggregator()
+	}
+
+	return result.(types.FunctionInterface)
+}
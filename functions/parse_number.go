@@ -0,0 +1,105 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _ParseNumberArgs struct {
+	String  string `vfilter:"required,field=string,doc=The formatted number (e.g. 1,234.56)"`
+	Decimal string `vfilter:"optional,field=decimal,doc=The decimal separator (. or ,). If not given it is guessed from the string."`
+}
+
+// Characters used to group digits which are simply dropped.
+var digitGroupSeparators = strings.NewReplacer(
+	" ", "", "'", "", "_", "", "\u00a0", "", "\u202f", "")
+
+// Guess the decimal separator. When both . and , are present the
+// last one is the decimal separator. A separator seen more than once
+// must be grouping digits. Otherwise we assume 1,234 and 1.5
+func guessDecimal(number string) string {
+	last_dot := strings.LastIndex(number, ".")
+	last_comma := strings.LastIndex(number, ",")
+
+	switch {
+	case last_dot >= 0 && last_comma >= 0:
+		if last_dot > last_comma {
+			return "."
+		}
+		return ","
+
+	case last_comma >= 0 && strings.Count(number, ",") == 1 &&
+		len(number)-last_comma-1 != 3:
+		return ","
+
+	case last_dot >= 0 && strings.Count(number, ".") > 1:
+		return ","
+	}
+
+	return "."
+}
+
+func parseNumber(number string, decimal string) (types.Any, error) {
+	number = digitGroupSeparators.Replace(strings.TrimSpace(number))
+	if decimal == "" {
+		decimal = guessDecimal(number)
+	}
+
+	var thousands string
+	switch decimal {
+	case ".":
+		thousands = ","
+	case ",":
+		thousands = "."
+	default:
+		return nil, fmt.Errorf("decimal should be . or , not %q", decimal)
+	}
+
+	number = strings.ReplaceAll(number, thousands, "")
+	number = strings.ReplaceAll(number, decimal, ".")
+
+	if !strings.Contains(number, ".") {
+		result, err := strconv.ParseInt(number, 10, 64)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", number)
+	}
+	return result, nil
+}
+
+type _ParseNumberFunction struct{}
+
+func (self _ParseNumberFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "parse_number",
+		Doc:     "Parse a formatted number like 1,234.56 or 1.234,56 into an int or float.",
+		ArgType: type_map.AddType(scope, _ParseNumberArgs{}),
+	}
+}
+
+func (self _ParseNumberFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_ParseNumberArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("parse_number: %v", err)
+		return types.Null{}
+	}
+
+	result, err := parseNumber(arg.String, arg.Decimal)
+	if err != nil {
+		scope.Log("parse_number: %v", err)
+		return types.Null{}
+	}
+	return result
+}
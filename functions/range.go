@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _RangeFunctionArgs struct {
+	Start int64 `vfilter:"optional,field=start,doc=Start value (default 0)"`
+	End   int64 `vfilter:"required,field=end,doc=End value (not included)"`
+	Step  int64 `vfilter:"optional,field=step,doc=Step (default 1)"`
+}
+
+type _RangeFunction struct{}
+
+func (self _RangeFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "range",
+		Doc:     "Return a lazy range of integers which may be iterated over or tested with IN.",
+		ArgType: type_map.AddType(scope, _RangeFunctionArgs{}),
+	}
+}
+
+func (self _RangeFunction) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) types.Any {
+
+	arg := &_RangeFunctionArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("range: %v", err)
+		return types.Null{}
+	}
+
+	return types.NewRange(arg.Start, arg.End, arg.Step)
+}
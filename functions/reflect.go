@@ -0,0 +1,199 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	scopeType   = reflect.TypeOf((*types.Scope)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// A function made from a plain Go function by MakeFunction().
+type reflectedFunction struct {
+	name     string
+	doc      string
+	function reflect.Value
+
+	// The function takes a context and/or a scope first.
+	with_context bool
+	with_scope   bool
+
+	// The args are parsed into this struct. If the function takes
+	// the struct itself is_struct is set, otherwise each field is
+	// passed as a separate parameter.
+	struct_type reflect.Type
+	is_struct   bool
+	is_ptr      bool
+
+	has_error   bool
+	return_type types.Any
+}
+
+// Make a VQL function from a Go function. The function may take a
+// context.Context and a types.Scope first, followed by either an
+// arg struct (or pointer to one) with vfilter tags or plain typed
+// params named by arg_names which are all required. It returns a
+// value and optionally an error which is logged. For example:
+//
+//	functions.MakeFunction("repeat", "Repeat a string",
+//	    strings.Repeat, "string", "count")
+//
+//	functions.MakeFunction("lookup", "Lookup a user",
+//	    func(ctx context.Context, arg *LookupArgs) (*User, error) {
+//	        ....
+//	    })
+func MakeFunction(name, doc string, function interface{},
+	arg_names ...string) (types.FunctionInterface, error) {
+	value := reflect.ValueOf(function)
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("MakeFunction %v: Expected a function not %T",
+			name, function)
+	}
+
+	func_type := value.Type()
+	if func_type.IsVariadic() {
+		return nil, fmt.Errorf("MakeFunction %v: Variadic functions are not supported",
+			name)
+	}
+
+	result := &reflectedFunction{
+		name:     name,
+		doc:      doc,
+		function: value,
+	}
+
+	params := []reflect.Type{}
+	for i := 0; i < func_type.NumIn(); i++ {
+		params = append(params, func_type.In(i))
+	}
+
+	if len(params) > 0 && params[0] == contextType {
+		result.with_context = true
+		params = params[1:]
+	}
+
+	if len(params) > 0 && params[0] == scopeType {
+		result.with_scope = true
+		params = params[1:]
+	}
+
+	switch {
+	case len(arg_names) == 0 && len(params) == 1 && isStruct(params[0]):
+		result.is_struct = true
+		result.is_ptr = params[0].Kind() == reflect.Ptr
+		result.struct_type = params[0]
+		if result.is_ptr {
+			result.struct_type = params[0].Elem()
+		}
+
+	case len(arg_names) == len(params):
+		fields := []reflect.StructField{}
+		for i, param := range params {
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("Arg%d", i),
+				Type: param,
+				Tag: reflect.StructTag(fmt.Sprintf(
+					`vfilter:"required,field=%s"`, arg_names[i])),
+			})
+		}
+		result.struct_type = reflect.StructOf(fields)
+
+		// Make sure the arg parser supports the param types.
+		_, err := arg_parser.GetParser(reflect.New(result.struct_type).Elem())
+		if err != nil {
+			return nil, fmt.Errorf("MakeFunction %v: %v", name, err)
+		}
+
+	default:
+		return nil, fmt.Errorf("MakeFunction %v: Expected an arg struct or "+
+			"names for each of the %d params", name, len(params))
+	}
+
+	switch {
+	case func_type.NumOut() == 1 && func_type.Out(0) != errorType:
+
+	case func_type.NumOut() == 2 && func_type.Out(1) == errorType:
+		result.has_error = true
+
+	default:
+		return nil, fmt.Errorf("MakeFunction %v: function should return "+
+			"a value or a value and an error", name)
+	}
+
+	out_type := func_type.Out(0)
+	if out_type.Kind() != reflect.Interface {
+		result.return_type = reflect.Zero(out_type).Interface()
+	}
+
+	return result, nil
+}
+
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func (self *reflectedFunction) Call(
+	ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := reflect.New(self.struct_type)
+	err := arg_parser.ExtractArgsWithContext(ctx, scope, args, arg.Interface())
+	if err != nil {
+		scope.Log("%v: %v", self.name, err)
+		return types.Null{}
+	}
+
+	params := []reflect.Value{}
+	if self.with_context {
+		params = append(params, reflect.ValueOf(&ctx).Elem())
+	}
+
+	if self.with_scope {
+		params = append(params, reflect.ValueOf(&scope).Elem())
+	}
+
+	switch {
+	case self.is_ptr:
+		params = append(params, arg)
+
+	case self.is_struct:
+		params = append(params, arg.Elem())
+
+	default:
+		for i := 0; i < self.struct_type.NumField(); i++ {
+			params = append(params, arg.Elem().Field(i))
+		}
+	}
+
+	results := self.function.Call(params)
+	if self.has_error && !results[1].IsNil() {
+		scope.Log("%v: %v", self.name, results[1].Interface())
+		return types.Null{}
+	}
+
+	return results[0].Interface()
+}
+
+func (self *reflectedFunction) Info(
+	scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:       self.name,
+		Doc:        self.doc,
+		ArgType:    type_map.AddType(scope, reflect.New(self.struct_type).Interface()),
+		ReturnType: self.return_type,
+	}
+}
+
+// Each AST node gets its own copy but the function has no state.
+func (self *reflectedFunction) Copy() types.FunctionInterface {
+	return self
+}
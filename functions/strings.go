@@ -0,0 +1,278 @@
+package functions
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Prefix, suffix and substring tests. These are faster than anchored
+// regular expressions and do not need special characters escaped.
+
+type _ContainsArgs struct {
+	String    string   `vfilter:"required,field=string,doc=The string to test"`
+	Substring []string `vfilter:"required,field=substring,doc=One or more strings to look for"`
+	NoCase    bool     `vfilter:"optional,field=nocase,doc=Ignore case when comparing"`
+}
+
+type _StartsWithArgs struct {
+	String string   `vfilter:"required,field=string,doc=The string to test"`
+	Prefix []string `vfilter:"required,field=prefix,doc=One or more prefixes to look for"`
+	NoCase bool     `vfilter:"optional,field=nocase,doc=Ignore case when comparing"`
+}
+
+type _EndsWithArgs struct {
+	String string   `vfilter:"required,field=string,doc=The string to test"`
+	Suffix []string `vfilter:"required,field=suffix,doc=One or more suffixes to look for"`
+	NoCase bool     `vfilter:"optional,field=nocase,doc=Ignore case when comparing"`
+}
+
+// Returns true if test() is true for any of the needles.
+func matchAny(haystack string, needles []string, nocase bool,
+	test func(string, string) bool) bool {
+	if nocase {
+		haystack = strings.ToLower(haystack)
+	}
+
+	for _, needle := range needles {
+		if nocase {
+			needle = strings.ToLower(needle)
+		}
+		if test(haystack, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+type _StartsWithFunction struct{}
+
+func (self _StartsWithFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "starts_with",
+		Doc:     "Returns true if the string starts with any of the prefixes.",
+		ArgType: type_map.AddType(scope, _StartsWithArgs{}),
+	}
+}
+
+func (self _StartsWithFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_StartsWithArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("starts_with: %v", err)
+		return false
+	}
+
+	return matchAny(arg.String, arg.Prefix, arg.NoCase, strings.HasPrefix)
+}
+
+type _EndsWithFunction struct{}
+
+func (self _EndsWithFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "ends_with",
+		Doc:     "Returns true if the string ends with any of the suffixes.",
+		ArgType: type_map.AddType(scope, _EndsWithArgs{}),
+	}
+}
+
+func (self _EndsWithFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_EndsWithArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("ends_with: %v", err)
+		return false
+	}
+
+	return matchAny(arg.String, arg.Suffix, arg.NoCase, strings.HasSuffix)
+}
+
+type _ContainsFunction struct{}
+
+func (self _ContainsFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "contains",
+		Doc:     "Returns true if the string contains any of the substrings.",
+		ArgType: type_map.AddType(scope, _ContainsArgs{}),
+	}
+}
+
+func (self _ContainsFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_ContainsArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("contains: %v", err)
+		return false
+	}
+
+	return matchAny(arg.String, arg.Substring, arg.NoCase, strings.Contains)
+}
+
+// Truncation measures lengths in characters by default, or in bytes
+// when bytes=TRUE. Strings are never cut in the middle of a UTF-8
+// sequence so byte-length results may be slightly shorter than
+// requested.
+
+func stringLength(value string, bytes bool) int {
+	if bytes {
+		return len(value)
+	}
+	return utf8.RuneCountInString(value)
+}
+
+// The first length characters (or bytes) of value.
+func stringHead(value string, length int, bytes bool) string {
+	if length <= 0 {
+		return ""
+	}
+
+	if bytes {
+		if length >= len(value) {
+			return value
+		}
+		for length > 0 && !utf8.RuneStart(value[length]) {
+			length--
+		}
+		return value[:length]
+	}
+
+	count := 0
+	for i := range value {
+		if count == length {
+			return value[:i]
+		}
+		count++
+	}
+	return value
+}
+
+// The last length characters (or bytes) of value.
+func stringTail(value string, length int, bytes bool) string {
+	if length <= 0 {
+		return ""
+	}
+
+	if bytes {
+		if length >= len(value) {
+			return value
+		}
+		start := len(value) - length
+		for start < len(value) && !utf8.RuneStart(value[start]) {
+			start++
+		}
+		return value[start:]
+	}
+
+	total := utf8.RuneCountInString(value)
+	if length >= total {
+		return value
+	}
+
+	count := 0
+	for i := range value {
+		if count == total-length {
+			return value[i:]
+		}
+		count++
+	}
+	return ""
+}
+
+type _TruncateArgs struct {
+	String string `vfilter:"required,field=str,doc=The string to truncate"`
+	Length int64  `vfilter:"required,field=len,doc=The maximum length of the result"`
+	Suffix string `vfilter:"optional,field=suffix,doc=Appended to truncated strings (default …)"`
+	Bytes  bool   `vfilter:"optional,field=bytes,doc=Measure the length in bytes rather than characters"`
+}
+
+type _TruncateFunction struct{}
+
+func (self _TruncateFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "truncate",
+		Doc:     "Truncate a string to a maximum length, marking it with a suffix.",
+		ArgType: type_map.AddType(scope, _TruncateArgs{}),
+	}
+}
+
+func (self _TruncateFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_TruncateArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("truncate: %v", err)
+		return types.Null{}
+	}
+
+	suffix := "…"
+	_, pres := args.Get("suffix")
+	if pres {
+		suffix = arg.Suffix
+	}
+
+	length := int(arg.Length)
+	if stringLength(arg.String, arg.Bytes) <= length {
+		return arg.String
+	}
+
+	// No room for the suffix.
+	suffix_length := stringLength(suffix, arg.Bytes)
+	if suffix_length >= length {
+		return stringHead(arg.String, length, arg.Bytes)
+	}
+
+	return stringHead(arg.String, length-suffix_length, arg.Bytes) + suffix
+}
+
+type _ElideMiddleArgs struct {
+	String    string `vfilter:"required,field=str,doc=The string to shorten"`
+	Length    int64  `vfilter:"required,field=len,doc=The maximum length of the result"`
+	Separator string `vfilter:"optional,field=sep,doc=Replaces the middle of the string (default …)"`
+	Bytes     bool   `vfilter:"optional,field=bytes,doc=Measure the length in bytes rather than characters"`
+}
+
+type _ElideMiddleFunction struct{}
+
+func (self _ElideMiddleFunction) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "elide_middle",
+		Doc:     "Shorten a string to a maximum length by replacing its middle with a separator.",
+		ArgType: type_map.AddType(scope, _ElideMiddleArgs{}),
+	}
+}
+
+func (self _ElideMiddleFunction) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_ElideMiddleArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("elide_middle: %v", err)
+		return types.Null{}
+	}
+
+	separator := "…"
+	_, pres := args.Get("sep")
+	if pres {
+		separator = arg.Separator
+	}
+
+	length := int(arg.Length)
+	if stringLength(arg.String, arg.Bytes) <= length {
+		return arg.String
+	}
+
+	// No room for the separator.
+	remaining := length - stringLength(separator, arg.Bytes)
+	if remaining <= 0 {
+		return stringHead(arg.String, length, arg.Bytes)
+	}
+
+	// The head gets the extra character if the remaining length is
+	// odd.
+	tail_length := remaining / 2
+	head_length := remaining - tail_length
+	return stringHead(arg.String, head_length, arg.Bytes) + separator +
+		stringTail(arg.String, tail_length, arg.Bytes)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/Velocidex/ordereddict"
 	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
 )
 
 type DefaultGrouper struct{}
@@ -70,6 +71,17 @@ func (self *DefaultGrouper) Group(
 			// functions).
 			new_row := actor.MaterializeRow(ctx, row, new_scope)
 
+			// Only account for new bins - subsequent rows
+			// replace the bin's row.
+			if !pres {
+				err := scope.ChargeMemory(ctx, utils.EstimateSize(new_row))
+				if err != nil {
+					scope.Log("ERROR:GROUP BY: %v", err)
+					types.AbortQuery(ctx, err)
+					return
+				}
+			}
+
 			aggregate_ctx.row = new_row
 		}
 
@@ -0,0 +1,124 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestMaxRows(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("SELECT * FROM foreach(row=range(end=10))")
+	assert.NoError(t, err)
+
+	run := func() (int, error) {
+		rows := 0
+		err := vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				rows++
+				return nil
+			})
+		return rows, err
+	}
+
+	rows, err := run()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, rows)
+
+	scope.SetMaxRows(5)
+	rows, err = run()
+	assert.True(t, errors.Is(err, types.ErrRowLimitExceeded))
+	assert.Contains(t, err.Error(), "read more than 5 rows")
+	assert.Equal(t, 5, rows)
+}
+
+func TestMaxRowsIsExact(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.SetMaxRows(2)
+
+	// The rows within the limit are all delivered, even while the
+	// plugin already sent the next row, with and without batches.
+	for _, batch_size := range []int{0, 7} {
+		scope.SetBatchSize(batch_size)
+
+		for i := 0; i < 100; i++ {
+			vql, err := Parse("SELECT _value * 2 AS X FROM range(end=20)")
+			assert.NoError(t, err)
+
+			rows := 0
+			err = vql.EvalWithCallback(context.Background(), scope,
+				func(row Row) error {
+					rows++
+					return nil
+				})
+			assert.True(t, errors.Is(err, types.ErrRowLimitExceeded))
+			assert.Equal(t, 2, rows, "batch size %v", batch_size)
+		}
+	}
+}
+
+func TestMaxSubqueryDepth(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	collector := &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+	scope.SetMaxSubqueryDepth(3)
+
+	run := func(query string) error {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		return vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				return nil
+			})
+	}
+
+	// Three nested FROM clauses are allowed.
+	assert.NoError(t, run(`
+SELECT * FROM foreach(row={ SELECT * FROM foreach(row={ SELECT * FROM scope() }) })`))
+
+	err := run(`
+SELECT * FROM foreach(row={ SELECT * FROM foreach(row={
+  SELECT * FROM foreach(row={ SELECT * FROM scope() }) }) })`)
+	assert.True(t, errors.Is(err, types.ErrSubqueryDepthExceeded))
+	assert.True(t, collector.Has(types.ErrSubqueryDepthExceeded))
+}
+
+func TestMaxScopeDepth(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	collector := &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+
+	assert.Equal(t, 1000, scope.MaxScopeDepth())
+	scope.SetMaxScopeDepth(20)
+
+	multi_vql, err := MultiParse(`
+LET loop(x) = loop(x=x + 1)
+SELECT loop(x=1) AS Loop FROM scope()
+`)
+	assert.NoError(t, err)
+
+	var result []Row
+	for _, vql := range multi_vql {
+		err := vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				result = append(result, row)
+				return nil
+			})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, len(result))
+	assert.True(t, collector.Has(types.ErrScopeDepthExceeded))
+}
@@ -0,0 +1,168 @@
+package vfilter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Re-runs a query over a changing source (e.g. a plugin listing
+// processes) and reports only the rows added and removed since the
+// previous run. Rows are identified by the key columns or by all
+// their columns if none are given. When key columns are given a row
+// whose other columns changed is reported as removed and added
+// again.
+type IncrementalQuery struct {
+	vql         *VQL
+	key_columns []string
+
+	// Rows seen in the previous run by key, in query order.
+	previous       map[string]*incrementalEntry
+	previous_order []string
+}
+
+type incrementalEntry struct {
+	row     Row
+	content string
+	count   int
+}
+
+func NewIncrementalQuery(vql *VQL, key_columns ...string) *IncrementalQuery {
+	return &IncrementalQuery{
+		vql:         vql,
+		key_columns: key_columns,
+		previous:    make(map[string]*incrementalEntry),
+	}
+}
+
+func (self *IncrementalQuery) rowKey(
+	row *ordereddict.Dict) (key string, content string, err error) {
+	serialized, err := json.Marshal(row)
+	if err != nil {
+		return "", "", err
+	}
+	content = string(serialized)
+
+	if len(self.key_columns) == 0 {
+		return content, content, nil
+	}
+
+	values := make([]types.Any, 0, len(self.key_columns))
+	for _, column := range self.key_columns {
+		value, _ := row.Get(column)
+		values = append(values, value)
+	}
+
+	serialized, err = json.Marshal(values)
+	if err != nil {
+		return "", "", err
+	}
+	return string(serialized), content, nil
+}
+
+// Run the query and return the rows added and removed since the
+// last run. The first run returns all rows as added. If the query
+// fails the previous state is kept so the next run reports changes
+// since the last successful run.
+func (self *IncrementalQuery) Run(
+	ctx context.Context, scope types.Scope) (added []Row, removed []Row, err error) {
+	current := make(map[string]*incrementalEntry)
+	order := []string{}
+
+	err = self.vql.EvalWithCallback(ctx, scope, func(row Row) error {
+		dict := RowToDict(ctx, scope, row)
+		key, content, err := self.rowKey(dict)
+		if err != nil {
+			return fmt.Errorf("IncrementalQuery: %w", err)
+		}
+
+		entry, pres := current[key]
+		if pres {
+			entry.count++
+			return nil
+		}
+
+		current[key] = &incrementalEntry{row: dict, content: content, count: 1}
+		order = append(order, key)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range order {
+		entry := current[key]
+		old, pres := self.previous[key]
+		switch {
+		case !pres:
+			added = appendRepeated(added, entry.row, entry.count)
+
+		case old.content != entry.content:
+			removed = appendRepeated(removed, old.row, old.count)
+			added = appendRepeated(added, entry.row, entry.count)
+
+		case entry.count > old.count:
+			added = appendRepeated(added, entry.row, entry.count-old.count)
+
+		case entry.count < old.count:
+			removed = appendRepeated(removed, old.row, old.count-entry.count)
+		}
+	}
+
+	for _, key := range self.previous_order {
+		_, pres := current[key]
+		if !pres {
+			old := self.previous[key]
+			removed = appendRepeated(removed, old.row, old.count)
+		}
+	}
+
+	self.previous = current
+	self.previous_order = order
+	return added, removed, nil
+}
+
+func appendRepeated(rows []Row, row Row, count int) []Row {
+	for i := 0; i < count; i++ {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Run the query and emit the changed rows with a _diff column set to
+// "added" or "removed". Removed rows come first.
+func (self *IncrementalQuery) Eval(
+	ctx context.Context, scope types.Scope) <-chan Row {
+	output_chan := make(chan Row)
+
+	go func() {
+		defer close(output_chan)
+
+		added, removed, err := self.Run(ctx, scope)
+		if err != nil {
+			scope.Log("ERROR:IncrementalQuery: %v", err)
+			return
+		}
+
+		for _, diff := range []struct {
+			name string
+			rows []Row
+		}{{"removed", removed}, {"added", added}} {
+			for _, row := range diff.rows {
+				output := ordereddict.NewDict().Set("_diff", diff.name)
+				output.MergeFrom(row.(*ordereddict.Dict))
+
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- output:
+				}
+			}
+		}
+	}()
+
+	return output_chan
+}
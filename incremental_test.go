@@ -0,0 +1,92 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestIncrementalQuery(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// A source which changes between runs.
+	var processes []Row
+	process := func(pid int, name string) Row {
+		return ordereddict.NewDict().Set("Pid", pid).Set("Name", name)
+	}
+
+	scope.AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "processes",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			return processes
+		},
+	})
+
+	vql, err := Parse("SELECT * FROM processes()")
+	assert.NoError(t, err)
+
+	names := func(rows []Row) []string {
+		result := []string{}
+		for _, row := range rows {
+			name, _ := scope.Associative(row, "Name")
+			result = append(result, name.(string))
+		}
+		return result
+	}
+
+	query := NewIncrementalQuery(vql, "Pid")
+
+	// Everything is new on the first run.
+	processes = []Row{process(1, "init"), process(2, "bash")}
+	added, removed, err := query.Run(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init", "bash"}, names(added))
+	assert.Equal(t, []string{}, names(removed))
+
+	// Nothing changed.
+	added, removed, err = query.Run(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(added)+len(removed))
+
+	// bash exited, vim started and pid 1 changed its name.
+	processes = []Row{process(1, "systemd"), process(3, "vim")}
+	added, removed, err = query.Run(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"systemd", "vim"}, names(added))
+	assert.Equal(t, []string{"init", "bash"}, names(removed))
+
+	// Without key columns whole rows are compared and duplicates
+	// counted.
+	query = NewIncrementalQuery(vql)
+	processes = []Row{process(1, "init"), process(1, "init")}
+	added, _, err = query.Run(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(added))
+
+	processes = []Row{process(1, "init")}
+	added, removed, err = query.Run(ctx, scope)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(added))
+	assert.Equal(t, []string{"init"}, names(removed))
+
+	// Eval marks the changed rows.
+	processes = []Row{process(4, "sshd")}
+	var rows []*ordereddict.Dict
+	for row := range query.Eval(ctx, scope) {
+		rows = append(rows, row.(*ordereddict.Dict))
+	}
+	assert.Equal(t, 2, len(rows))
+	diff, _ := rows[0].GetString("_diff")
+	assert.Equal(t, "removed", diff)
+	diff, _ = rows[1].GetString("_diff")
+	assert.Equal(t, "added", diff)
+	name, _ := rows[1].GetString("Name")
+	assert.Equal(t, "sshd", name)
+}
@@ -0,0 +1,74 @@
+package vfilter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func stringData(t *testing.T, row Row, column string) uintptr {
+	value, _ := row.(*ordereddict.Dict).Get(column)
+	s, ok := value.(string)
+	assert.True(t, ok)
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestStringInterning(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// format() builds a new string for each row.
+	vql, err := Parse(`SELECT format(format="user%v", args=1) AS User
+FROM foreach(row=range(end=3))`)
+	assert.NoError(t, err)
+
+	run := func() []Row {
+		var result []Row
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	rows := run()
+	assert.Equal(t, 3, len(rows))
+	assert.NotEqual(t, stringData(t, rows[0], "User"), stringData(t, rows[1], "User"))
+
+	scope.SetStringInterning(100)
+	rows = run()
+	assert.Equal(t, 3, len(rows))
+	for _, row := range rows {
+		user, _ := row.(*ordereddict.Dict).Get("User")
+		assert.Equal(t, "user1", user)
+		assert.Equal(t, stringData(t, rows[0], "User"), stringData(t, row, "User"))
+	}
+
+	// Materialized variables are interned too.
+	vql, err = Parse(`
+LET users <= SELECT format(format="user%v", args=1) AS User,
+   dict(Name=format(format="group%v", args=1)) AS Group
+FROM foreach(row=range(end=2))`)
+	assert.NoError(t, err)
+
+	for range vql.Eval(context.Background(), scope) {
+	}
+
+	users, pres := scope.Resolve("users")
+	assert.True(t, pres)
+
+	rows = nil
+	for row := range users.(types.StoredQuery).Eval(context.Background(), scope) {
+		rows = append(rows, row)
+	}
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, stringData(t, rows[0], "User"), stringData(t, rows[1], "User"))
+
+	group_0, _ := rows[0].(*ordereddict.Dict).Get("Group")
+	group_1, _ := rows[1].(*ordereddict.Dict).Get("Group")
+	assert.Equal(t, stringData(t, group_0, "Name"), stringData(t, group_1, "Name"))
+}
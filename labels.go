@@ -0,0 +1,39 @@
+package vfilter
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"sync/atomic"
+)
+
+// Plugins run in their own goroutines. We label these goroutines
+// with the query and plugin name so CPU profiles of programs
+// embedding vfilter can attribute samples to specific queries and
+// plugins.
+const (
+	queryLabel  = "vql_query"
+	pluginLabel = "vql_plugin"
+)
+
+var query_id uint64
+
+// Label the context with a new query id unless we are already
+// running inside a labeled query (e.g. a subquery).
+func withQueryLabel(ctx context.Context) context.Context {
+	_, pres := pprof.Label(ctx, queryLabel)
+	if pres {
+		return ctx
+	}
+
+	id := atomic.AddUint64(&query_id, 1)
+	return pprof.WithLabels(ctx, pprof.Labels(
+		queryLabel, strconv.FormatUint(id, 10)))
+}
+
+// Call fn with the plugin label set. Any goroutines started by fn
+// inherit the labels.
+func withPluginLabel(ctx context.Context, name string,
+	fn func(ctx context.Context)) {
+	pprof.Do(ctx, pprof.Labels(pluginLabel, name), fn)
+}
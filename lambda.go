@@ -49,6 +49,16 @@ func (self *Lambda) Reduce(ctx context.Context, scope types.Scope, parameters []
 	return self.Expression.Reduce(ctx, subscope)
 }
 
+func init() {
+	types.ParseLambda = func(expression string) (types.Lambda, error) {
+		lambda, err := ParseLambda(expression)
+		if err != nil {
+			return nil, err
+		}
+		return lambda, nil
+	}
+}
+
 func ParseLambda(expression string) (*Lambda, error) {
 	lambda := &Lambda{}
 	err := lambdaParser.ParseString(expression, lambda)
@@ -0,0 +1,92 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type lookupArgs struct {
+	UID int64 `vfilter:"required,field=uid"`
+}
+
+func TestMakeFunction(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	repeat, err := functions.MakeFunction("repeat", "Repeat a string",
+		strings.Repeat, "string", "count")
+	assert.NoError(t, err)
+
+	lookup, err := functions.MakeFunction("lookup", "Lookup a user",
+		func(ctx context.Context, scope types.Scope, arg *lookupArgs) (*ordereddict.Dict, error) {
+			if arg.UID != 1000 {
+				return nil, errors.New("No such user")
+			}
+			return ordereddict.NewDict().Set("Name", "mic"), nil
+		})
+	assert.NoError(t, err)
+
+	// Arg structs may be passed by value too.
+	double, err := functions.MakeFunction("double", "",
+		func(arg lookupArgs) int64 {
+			return arg.UID * 2
+		})
+	assert.NoError(t, err)
+
+	scope.AppendFunctions(repeat, lookup, double)
+
+	vql, err := Parse("SELECT repeat(string='ab', count=3) AS Repeat, " +
+		"lookup(uid=1000).Name AS Name, lookup(uid=1) AS Missing, " +
+		"repeat(string='ab') AS BadArgs, double(uid=4) AS Double FROM scope()")
+	assert.NoError(t, err)
+
+	var rows []*ordereddict.Dict
+	for row := range vql.Eval(ctx, scope) {
+		rows = append(rows, RowToDict(ctx, scope, row))
+	}
+	assert.Equal(t, 1, len(rows))
+
+	value, _ := rows[0].GetString("Repeat")
+	assert.Equal(t, "ababab", value)
+
+	value, _ = rows[0].GetString("Name")
+	assert.Equal(t, "mic", value)
+
+	for _, name := range []string{"Missing", "BadArgs"} {
+		missing, _ := rows[0].Get(name)
+		assert.True(t, types.IsNullObject(missing), name)
+	}
+
+	doubled, _ := rows[0].Get("Double")
+	assert.Equal(t, int64(8), doubled)
+
+	// Info describes the function.
+	info := repeat.Info(scope, types.NewTypeMap())
+	assert.Equal(t, "repeat", info.Name)
+	assert.Equal(t, "", info.ReturnType)
+
+	// Bad signatures are rejected.
+	for _, test_case := range []struct {
+		function  interface{}
+		arg_names []string
+	}{
+		{1, nil},
+		{strings.Repeat, []string{"string"}},
+		{func(a string) {}, []string{"a"}},
+		{func(a string) error { return nil }, []string{"a"}},
+		{func(a ...string) string { return "" }, []string{"a"}},
+		{func(a chan int) string { return "" }, []string{"a"}},
+	} {
+		_, err := functions.MakeFunction("bad", "", test_case.function,
+			test_case.arg_names...)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,97 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type userArgs struct {
+	Prefix string `vfilter:"required,field=prefix,doc=Username prefix"`
+	Count  int64  `vfilter:"optional,field=count,default=2"`
+}
+
+type user struct {
+	Name string
+	UID  int64
+}
+
+func TestMakePlugin(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	plugin, err := plugins.MakePlugin("users", "List users",
+		func(ctx context.Context, scope types.Scope, arg *userArgs) ([]user, error) {
+			result := []user{}
+			for i := int64(0); i < arg.Count; i++ {
+				result = append(result, user{
+					Name: arg.Prefix + string(rune('a'+i)), UID: 1000 + i})
+			}
+			if arg.Count > 2 {
+				return result, errors.New("Too many users")
+			}
+			return result, nil
+		})
+	assert.NoError(t, err)
+	scope.AppendPlugins(plugin)
+
+	run := func(query string) []*ordereddict.Dict {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []*ordereddict.Dict
+		for row := range vql.Eval(ctx, scope) {
+			result = append(result, RowToDict(ctx, scope, row))
+		}
+		return result
+	}
+
+	rows := run("SELECT Name, UID FROM users(prefix='user_')")
+	assert.Equal(t, 2, len(rows))
+	name, _ := rows[1].GetString("Name")
+	assert.Equal(t, "user_b", name)
+
+	// Rows returned with an error are still emitted.
+	assert.Equal(t, 3, len(run("SELECT * FROM users(prefix='x', count=3)")))
+
+	// Arg errors produce no rows.
+	assert.Equal(t, 0, len(run("SELECT * FROM users()")))
+
+	// Info describes the args and columns.
+	type_map := types.NewTypeMap()
+	info := plugin.Info(scope, type_map)
+	assert.Equal(t, "users", info.Name)
+	assert.Equal(t, "List users", info.Doc)
+	assert.Equal(t, user{}, info.RowType)
+	desc, pres := type_map.Get(scope, info.ArgType)
+	assert.True(t, pres)
+	assert.Equal(t, []string{"prefix", "count"}, desc.Fields.Keys())
+
+	// Arg structs may be passed by value and rows may be any type.
+	_, err = plugins.MakePlugin("by_value", "",
+		func(ctx context.Context, scope types.Scope, arg userArgs) ([]Row, error) {
+			return nil, nil
+		})
+	assert.NoError(t, err)
+
+	// Bad signatures are rejected.
+	for _, function := range []interface{}{
+		1,
+		func() {},
+		func(ctx context.Context, scope types.Scope, arg int) ([]Row, error) {
+			return nil, nil
+		},
+		func(ctx context.Context, scope types.Scope, arg userArgs) []Row {
+			return nil
+		},
+	} {
+		_, err := plugins.MakePlugin("bad", "", function)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,86 @@
+package vfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/protocols"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// The flags which may be given to =~ and GLOB with WITH (...) so
+// users do not need to know the Go regexp syntax. Patterns are case
+// insensitive unless the case flag is given.
+var regexFlags = map[string]string{
+	"nocase":    "(?i)",
+	"case":      "(?-i)",
+	"multiline": "(?m)",
+	"dotall":    "(?s)",
+}
+
+// Translate the flags into the equivalent inline regex flags.
+func regexFlagsPrefix(flags []string) (string, error) {
+	result := ""
+	for _, flag := range flags {
+		prefix, pres := regexFlags[strings.ToLower(flag)]
+		if !pres {
+			return "", fmt.Errorf("unknown regex flag %v", flag)
+		}
+		result += prefix
+	}
+	return result, nil
+}
+
+func (self *_OpComparison) isGlob() bool {
+	return strings.ToUpper(self.Operator) == "GLOB"
+}
+
+// Compile the pattern with the operator's flags. Returns nil for
+// regexes which match anything.
+func (self *_OpComparison) compile(
+	compiler func(pattern string) (*regexp.Regexp, error),
+	pattern string) (*regexp.Regexp, error) {
+	// Invalid flags are an error even if the pattern matches
+	// anything.
+	prefix, err := regexFlagsPrefix(self.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if self.isGlob() {
+		pattern = protocols.GlobToRegex(pattern)
+	} else if protocols.IsMatchAll(pattern) {
+		return nil, nil
+	}
+
+	return compiler(prefix + pattern)
+}
+
+func (self *_OpComparison) match(scope types.Scope, lhs, rhs Any) bool {
+	lhs_str, ok := lhs.(string)
+	if ok && self.regex != nil {
+		return self.regex.MatchString(lhs_str)
+	}
+
+	// Flags only apply to string patterns - other patterns are
+	// handled by the protocols.
+	pattern, ok := rhs.(string)
+	if ok && len(self.Flags) > 0 {
+		re, err := self.compile(scope.CompileRegex, pattern)
+		if err != nil {
+			scope.Log("%v: %v", self.Operator, err)
+			return false
+		}
+
+		if re == nil {
+			return true
+		}
+		rhs = re
+	}
+
+	if self.isGlob() {
+		return scope.Glob(rhs, lhs)
+	}
+	return scope.Match(rhs, lhs)
+}
@@ -0,0 +1,86 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Globs match the names of the values in a dict.
+type _DictGlob struct{}
+
+func (self _DictGlob) Applicable(pattern types.Any, target types.Any) bool {
+	_, ok := target.(*ordereddict.Dict)
+	return ok
+}
+
+func (self _DictGlob) Glob(scope types.Scope,
+	pattern types.Any, target types.Any) bool {
+	return scope.Glob(pattern, target.(*ordereddict.Dict).Keys())
+}
+
+func TestGlobProtocol(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("SELECT dict(Foo=1, Bar=2) GLOB 'b*' AS Match FROM scope()")
+	assert.NoError(t, err)
+
+	run := func() Any {
+		for row := range vql.Eval(context.Background(), scope) {
+			value, _ := row.(*ordereddict.Dict).Get("Match")
+			return value
+		}
+		return nil
+	}
+
+	assert.Equal(t, false, run())
+
+	scope.AddProtocolImpl(_DictGlob{})
+	assert.Equal(t, true, run())
+}
+
+func TestCompileFlags(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse(`SELECT _value AS Name FROM foreach(row=["a.exe", "B.EXE", "c.txt"])
+WHERE Name GLOB "*.exe" WITH (case) OR Name =~ "^c" WITH (case)`)
+	assert.NoError(t, err)
+
+	program, err := Compile(vql, scope)
+	assert.NoError(t, err)
+
+	var result []Row
+	for row := range program.Eval(context.Background(), scope) {
+		result = append(result, row)
+	}
+	assert.Equal(t, []Row{
+		ordereddict.NewDict().Set("Name", "a.exe"),
+		ordereddict.NewDict().Set("Name", "c.txt"),
+	}, result)
+
+	vql, err = Parse(`SELECT * FROM foreach(row=["a"]) WHERE _value =~ "a" WITH (unknown)`)
+	assert.NoError(t, err)
+
+	_, err = Compile(vql, scope)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown regex flag unknown")
+
+	// Flags are checked even for patterns matching anything.
+	vql, err = Parse(`SELECT * FROM foreach(row=["a"]) WHERE _value =~ "." WITH (bogus)`)
+	assert.NoError(t, err)
+
+	_, err = Compile(vql, scope)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown regex flag bogus")
+
+	result = nil
+	for row := range vql.Eval(context.Background(), scope) {
+		result = append(result, row)
+	}
+	assert.Equal(t, 0, len(result))
+}
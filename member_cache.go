@@ -0,0 +1,73 @@
+// A per row cache of resolved member chains.
+
+package vfilter
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type memberCacheKeyType int
+
+const memberCacheKey memberCacheKeyType = 0
+
+type memberCacheEntry struct {
+	root types.Any
+	path string
+}
+
+// Dotted symbol references (e.g. a.b.c.d) are resolved by walking
+// each component through the Associative protocol. When the same
+// chain is referenced several times while processing a single row
+// (e.g. in several columns and in the WHERE clause) we only want to
+// pay for the protocol dispatch once.
+
+// The cache is keyed on the object the first component resolves to
+// and the rest of the chain. The first component is always resolved
+// from the scope so variables shadowed in sub scopes are never
+// confused with each other.
+type memberCache struct {
+	mu    sync.Mutex
+	cache map[memberCacheEntry]types.Any
+}
+
+func (self *memberCache) Get(root types.Any, path string) (types.Any, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	res, pres := self.cache[memberCacheEntry{root, path}]
+	return res, pres
+}
+
+func (self *memberCache) Set(root types.Any, path string, value types.Any) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.cache[memberCacheEntry{root, path}] = value
+}
+
+// Start a new member cache which will live as long as the row is
+// being processed.
+func withMemberCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memberCacheKey, &memberCache{
+		cache: make(map[memberCacheEntry]types.Any),
+	})
+}
+
+func getMemberCache(ctx context.Context) *memberCache {
+	if ctx == nil {
+		return nil
+	}
+
+	res, _ := ctx.Value(memberCacheKey).(*memberCache)
+	return res
+}
+
+// Only objects with a stable identity can be used as cache keys.
+func isCacheableRoot(root types.Any) bool {
+	rt := reflect.TypeOf(root)
+	return rt != nil && rt.Kind() == reflect.Ptr
+}
@@ -0,0 +1,89 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestMemoryQuota(t *testing.T) {
+	for _, query := range []string{
+		"SELECT * FROM range(start=1, end=1000) ORDER BY value",
+		"SELECT value FROM range(start=1, end=1000) GROUP BY value",
+		"SELECT * FROM foreach(row={SELECT * FROM range(start=1, end=1000)}) " +
+			"ORDER BY value DESC",
+		"SELECT * FROM join(lhs={SELECT * FROM range(start=1, end=1000)}, " +
+			"rhs={SELECT * FROM range(start=1, end=1000)}, on='value')",
+	} {
+		scope := makeTestScope()
+		logger := &logWriter{Writer: os.Stdout}
+		scope.SetLogger(log.New(logger, "Log: ", 0))
+		scope.SetMemoryQuota(1000)
+
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		err = vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error { return nil })
+		assert.Equal(t, types.ErrMemoryQuotaExceeded, err, query)
+		logger.Contains(t, "Memory quota exceeded")
+
+		scope.Close()
+	}
+}
+
+func TestMemoryQuotaMaterialize(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", 0))
+	scope.SetMemoryQuota(1000)
+
+	vql, err := Parse("LET X <= SELECT * FROM range(start=1, end=1000)")
+	assert.NoError(t, err)
+
+	for range vql.Eval(context.Background(), scope) {
+	}
+	logger.Contains(t, "During Materialize of StoredQuery")
+
+	// Within the quota.
+	scope.SetMemoryQuota(0)
+	vql, err = Parse("SELECT * FROM range(start=1, end=10) ORDER BY value")
+	assert.NoError(t, err)
+
+	rows := 0
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			rows++
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, rows)
+}
+
+func TestMemoryQuotaIsPerQuery(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// Enough for one query but not for all of them together.
+	scope.SetMemoryQuota(20000)
+
+	vql, err := Parse("SELECT * FROM range(start=1, end=100) ORDER BY value")
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		rows := 0
+		err = vql.EvalWithCallback(context.Background(), scope.Copy(),
+			func(row Row) error {
+				rows++
+				return nil
+			})
+		assert.NoError(t, err, "run %v", i)
+		assert.Equal(t, 100, rows)
+	}
+}
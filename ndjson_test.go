@@ -0,0 +1,61 @@
+package vfilter
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+const ndjsonData = `{"Name": "a", "Size": 1}
+{"Name": "b", "Size": 2}
+
+{"Name": "c", "Size": 3}
+`
+
+func TestNDJSONIterator(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.AppendVars(ordereddict.NewDict().
+		Set("Reader", types.NewNDJSONSource(strings.NewReader(ndjsonData))).
+		Set("Scanner", &types.NDJSONSource{
+			Scanner: bufio.NewScanner(strings.NewReader(ndjsonData)),
+		}))
+
+	for _, query := range []string{
+		"SELECT Name FROM Reader WHERE Size > 1",
+		"SELECT Name FROM foreach(row=Scanner) WHERE Size > 1",
+	} {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		names := []string{}
+		for row := range vql.Eval(context.Background(), scope) {
+			name, _ := scope.Associative(row, "Name")
+			names = append(names, name.(string))
+		}
+		assert.Equal(t, []string{"b", "c"}, names, query)
+	}
+}
+
+func TestNDJSONRequiresSource(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// A plain reader is not read as a stream of rows.
+	scope.AppendVars(ordereddict.NewDict().
+		Set("Reader", strings.NewReader(ndjsonData)))
+
+	vql, err := Parse("SELECT * FROM Reader")
+	assert.NoError(t, err)
+
+	for row := range vql.Eval(context.Background(), scope) {
+		_, pres := scope.Associative(row, "Name")
+		assert.False(t, pres)
+	}
+}
@@ -0,0 +1,128 @@
+package vfilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A running hash over the rows of a result set. The hash covers the
+// exact bytes written for the rows: each row serialized by
+// json.Marshal() followed by a new line for OutputJSONL(), or the
+// output of the encoder for OutputJSONWithOptions(). Consumers
+// verify the result by serializing the rows they received the same
+// way.
+type resultHasher struct {
+	hash hash.Hash
+	rows int
+}
+
+func newResultHasher() *resultHasher {
+	return &resultHasher{hash: sha256.New()}
+}
+
+// Serialize the row as a JSONL line.
+func jsonLine(row Row) ([]byte, error) {
+	serialized, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	return append(serialized, '\n'), nil
+}
+
+// Add a line produced by jsonLine() to the hash.
+func (self *resultHasher) Write(line []byte) {
+	self.hash.Write(line)
+	self.rows++
+}
+
+// Add the rows serialized together by an encoder to the hash.
+func (self *resultHasher) WriteEncoded(encoded []byte, rows int) {
+	self.hash.Write(encoded)
+	self.rows += rows
+}
+
+func (self *resultHasher) Add(row Row) error {
+	line, err := jsonLine(row)
+	if err != nil {
+		return err
+	}
+	self.Write(line)
+	return nil
+}
+
+// The footer record appended to the result. If the scope has a
+// ResultSigner the digest is signed too.
+func (self *resultHasher) Footer(scope types.Scope) (*ordereddict.Dict, error) {
+	digest := self.hash.Sum(nil)
+	result := ordereddict.NewDict().
+		Set("_footer", true).
+		Set("_rows", self.rows).
+		Set("_sha256", hex.EncodeToString(digest))
+
+	signer := scope.ResultSigner()
+	if signer != nil {
+		signature, err := signer.Sign(digest)
+		if err != nil {
+			return nil, err
+		}
+		result.Set("_signature", hex.EncodeToString(signature))
+	}
+
+	return result, nil
+}
+
+// Verify the rows of a result written by OutputJSONL() against its
+// footer record. Returns the digest and signature from the footer so
+// the caller can check the signature.
+func VerifyResultHash(rows []Row, footer *ordereddict.Dict) (
+	digest []byte, signature []byte, err error) {
+	hasher := newResultHasher()
+	for _, row := range rows {
+		err := hasher.Add(row)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return hasher.verify(footer)
+}
+
+// Verify the rows of a result written by OutputJSONWithOptions()
+// against its footer record. The rows are serialized with the same
+// encoder used to write them.
+func VerifyEncodedResultHash(rows []Row, footer *ordereddict.Dict,
+	encoder RowEncoder) (digest []byte, signature []byte, err error) {
+	encoded, err := encoder(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hasher := newResultHasher()
+	hasher.WriteEncoded(encoded, len(rows))
+
+	return hasher.verify(footer)
+}
+
+func (self *resultHasher) verify(footer *ordereddict.Dict) (
+	digest []byte, signature []byte, err error) {
+	expected, _ := footer.GetString("_sha256")
+	digest = self.hash.Sum(nil)
+	if expected != hex.EncodeToString(digest) {
+		return nil, nil, errors.New("Result hash does not match")
+	}
+
+	signature_str, pres := footer.GetString("_signature")
+	if pres {
+		signature, err = hex.DecodeString(signature_str)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return digest, signature, nil
+}
@@ -0,0 +1,103 @@
+package vfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+type hmacSigner struct {
+	key []byte
+}
+
+func (self hmacSigner) Sign(digest []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, self.key)
+	mac.Write(digest)
+	return mac.Sum(nil), nil
+}
+
+// Split the rows of a result from its footer.
+func splitFooter(t *testing.T, rows []*ordereddict.Dict) ([]Row, *ordereddict.Dict) {
+	assert.True(t, len(rows) > 0)
+
+	footer := rows[len(rows)-1]
+	is_footer, _ := footer.Get("_footer")
+	assert.Equal(t, true, is_footer)
+
+	result := []Row{}
+	for _, row := range rows[:len(rows)-1] {
+		result = append(result, row)
+	}
+	return result, footer
+}
+
+func TestOutputHash(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	signer := hmacSigner{key: []byte("secret")}
+	scope.SetResultSigner(signer)
+
+	vql, err := Parse("SELECT * FROM test()")
+	assert.NoError(t, err)
+
+	// The hash covers the bytes produced by the encoder.
+	encoder := func(rows []Row) ([]byte, error) {
+		return json.MarshalIndent(rows, "", "  ")
+	}
+	serialized, _, err := OutputJSONWithOptions(vql, ctx, scope,
+		encoder, OutputOptions{Hash: true})
+	assert.NoError(t, err)
+
+	var parsed []*ordereddict.Dict
+	assert.NoError(t, json.Unmarshal(serialized, &parsed))
+	rows, footer := splitFooter(t, parsed)
+	assert.Equal(t, 3, len(rows))
+
+	encoded, err := encoder(rows)
+	assert.NoError(t, err)
+	expected_digest := sha256.Sum256(encoded)
+
+	digest, signature, err := VerifyEncodedResultHash(rows, footer, encoder)
+	assert.NoError(t, err)
+	assert.Equal(t, expected_digest[:], digest)
+	expected, _ := signer.Sign(digest)
+	assert.Equal(t, expected, signature)
+
+	// Tampering with a row is detected.
+	rows[1].(*ordereddict.Dict).Set("foo", 100)
+	_, _, err = VerifyEncodedResultHash(rows, footer, encoder)
+	assert.Error(t, err)
+
+	// JSONL output carries the same footer.
+	buf := &bytes.Buffer{}
+	truncated, err := OutputJSONLWithOptions(vql, ctx, scope, buf,
+		OutputOptions{Hash: true, MaxRows: 2})
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+
+	parsed = nil
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		row := ordereddict.NewDict()
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), row))
+		parsed = append(parsed, row)
+	}
+
+	rows, footer = splitFooter(t, parsed)
+	assert.Equal(t, 2, len(rows))
+
+	jsonl_digest, _, err := VerifyResultHash(rows, footer)
+	assert.NoError(t, err)
+
+	// The first two rows hash differently to all three.
+	assert.NotEqual(t, digest, jsonl_digest)
+}
@@ -0,0 +1,90 @@
+package vfilter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _TemplateFormatArgs struct {
+	Format   string            `vfilter:"required,field=format"`
+	Template *ordereddict.Dict `vfilter:"required,field=template"`
+}
+
+// Extends format() with named placeholders.
+type _TemplateFormat struct{}
+
+func (self _TemplateFormat) Info(scope types.Scope, type_map *types.TypeMap) *types.FunctionInfo {
+	return &types.FunctionInfo{
+		Name:    "format",
+		ArgType: type_map.AddType(scope, &_TemplateFormatArgs{}),
+	}
+}
+
+func (self _TemplateFormat) Call(ctx context.Context, scope types.Scope, args *ordereddict.Dict) types.Any {
+	arg := &_TemplateFormatArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("format: %v", err)
+		return types.Null{}
+	}
+
+	result := arg.Format
+	for _, k := range arg.Template.Keys() {
+		v, _ := arg.Template.GetString(k)
+		result = strings.ReplaceAll(result, "{"+k+"}", v)
+	}
+	return result
+}
+
+func TestOverloadFunctions(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	subscope := scope.NewScope()
+	defer subscope.Close()
+
+	subscope.OverloadFunctions(_TemplateFormat{})
+
+	query := "SELECT format(format='%v-%v', args=[1, 2]) AS Builtin, " +
+		"format(format='{a}-{b}', template=dict(a='x', b='y')) AS Template " +
+		"FROM scope()"
+
+	vql, err := Parse(query)
+	assert.NoError(t, err)
+
+	var result []*ordereddict.Dict
+	for row := range vql.Eval(context.Background(), subscope) {
+		result = append(result, RowToDict(context.Background(), subscope, row))
+	}
+	assert.Equal(t, 1, len(result))
+
+	value, _ := result[0].GetString("Builtin")
+	assert.Equal(t, "1-2", value)
+
+	value, _ = result[0].GetString("Template")
+	assert.Equal(t, "x-y", value)
+
+	// Both overloads are described.
+	count := 0
+	for _, info := range subscope.Describe(types.NewTypeMap()).Functions {
+		if info.Name == "format" {
+			count++
+		}
+	}
+	assert.Equal(t, 2, count)
+
+	// Scopes created before the overload are not affected.
+	count = 0
+	for _, info := range scope.Describe(types.NewTypeMap()).Functions {
+		if info.Name == "format" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+}
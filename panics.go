@@ -0,0 +1,70 @@
+package vfilter
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A panicking column or function is usually hit on every row. The
+// stack is only useful once, so we log it for the first panic and
+// count the rest, reporting the count when the query ends.
+
+type panicsKeyType int
+
+const panicsKey panicsKeyType = 0
+
+// The panics seen by a query and its subqueries, keyed by the
+// message logged for them.
+type queryPanics struct {
+	mu    sync.Mutex
+	count map[string]int
+}
+
+// Track panics once per query, even when a subquery runs many times
+// (e.g. in a foreach()). The returned function reports the panics
+// which were not logged, and does nothing unless this call started
+// tracking.
+func withQueryPanics(ctx context.Context) (
+	context.Context, func(scope types.Scope)) {
+	if ctx.Value(panicsKey) != nil {
+		return ctx, func(scope types.Scope) {}
+	}
+
+	panics := &queryPanics{count: make(map[string]int)}
+	return context.WithValue(ctx, panicsKey, panics), panics.report
+}
+
+// Returns true the first time a panic is seen for this message in
+// the query.
+func firstPanic(ctx context.Context, message string) bool {
+	panics, ok := ctx.Value(panicsKey).(*queryPanics)
+	if !ok {
+		return true
+	}
+
+	panics.mu.Lock()
+	defer panics.mu.Unlock()
+
+	panics.count[message]++
+	return panics.count[message] == 1
+}
+
+func (self *queryPanics) report(scope types.Scope) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	messages := make([]string, 0, len(self.count))
+	for message, count := range self.count {
+		if count > 1 {
+			messages = append(messages, message)
+		}
+	}
+	sort.Strings(messages)
+
+	for _, message := range messages {
+		scope.Log("%v (%v more times)", message, self.count[message]-1)
+	}
+}
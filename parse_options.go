@@ -0,0 +1,169 @@
+package vfilter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Experimental grammar extensions may be enabled or disabled on each
+// call to ParseWithOptions(). This allows embedders to adopt new
+// language features gradually without breaking stored queries.
+type Feature string
+
+const (
+	// Lambda expressions (e.g. "x => x + 1"), see ParseLambda().
+	FeatureLambda Feature = "lambda"
+
+	// Duration literals (e.g. 5m or 2h30m).
+	FeatureDuration Feature = "duration"
+
+	// Caching LET expressions (e.g. "LET X = ... CACHE 100").
+	FeatureCache Feature = "cache"
+
+	// Named subqueries (e.g. "SELECT * FROM plugin() AS Name").
+	FeatureNamedSubquery Feature = "named_subquery"
+
+	// Regex flags (e.g. "X =~ 'a' WITH (nocase)").
+	FeatureRegexFlags Feature = "regex_flags"
+
+	// The GLOB operator (e.g. "X GLOB '*.exe'").
+	FeatureGlob Feature = "glob"
+
+	// Memoized LET functions (e.g. "LET f(x) <= ...").
+	FeatureMemoize Feature = "memoize"
+)
+
+// Returned when an expression uses a disabled feature.
+var ErrFeatureDisabled = errors.New("Feature disabled")
+
+type ParseOptions struct {
+	// Features which are explicitly enabled or disabled. Features
+	// not mentioned here take their default from DefaultFeatures.
+	Features map[Feature]bool
+}
+
+// Features enabled by Parse() and friends.
+var DefaultFeatures = map[Feature]bool{
+	FeatureLambda:        true,
+	FeatureDuration:      true,
+	FeatureCache:         true,
+	FeatureNamedSubquery: true,
+	FeatureRegexFlags:    true,
+	FeatureGlob:          true,
+	FeatureMemoize:       true,
+}
+
+// Grammar extensions which can appear in a VQL statement register a
+// check here to detect if a parsed statement uses them.
+var vqlFeatureChecks = map[Feature]func(vql *VQL) bool{
+	FeatureDuration: func(vql *VQL) bool {
+		return usesNode(vql, func(node interface{}) bool {
+			t, ok := node.(*_Value)
+			return ok && (t.StrDuration != nil || t.Duration != nil)
+		})
+	},
+
+	FeatureCache: func(vql *VQL) bool {
+		return vql.Cache != nil
+	},
+
+	FeatureNamedSubquery: func(vql *VQL) bool {
+		return usesNode(vql, func(node interface{}) bool {
+			t, ok := node.(*_From)
+			return ok && t.Name != nil
+		})
+	},
+
+	FeatureRegexFlags: func(vql *VQL) bool {
+		return usesNode(vql, func(node interface{}) bool {
+			t, ok := node.(*_OpComparison)
+			return ok && len(t.Flags) > 0
+		})
+	},
+
+	FeatureGlob: func(vql *VQL) bool {
+		return usesNode(vql, func(node interface{}) bool {
+			t, ok := node.(*_OpComparison)
+			return ok && t.isGlob()
+		})
+	},
+
+	FeatureMemoize: func(vql *VQL) bool {
+		return vql.isMemoized()
+	},
+}
+
+// Check if any node of the statement matches.
+func usesNode(vql *VQL, match func(node interface{}) bool) bool {
+	result := false
+	walkAST(reflect.ValueOf(vql), func(node interface{}) {
+		if !result && match(node) {
+			result = true
+		}
+	})
+	return result
+}
+
+func (self ParseOptions) Enabled(feature Feature) bool {
+	enabled, pres := self.Features[feature]
+	if pres {
+		return enabled
+	}
+	return DefaultFeatures[feature]
+}
+
+func (self ParseOptions) checkFeature(feature Feature) error {
+	if !self.Enabled(feature) {
+		return fmt.Errorf("%w: %v", ErrFeatureDisabled, feature)
+	}
+	return nil
+}
+
+func (self ParseOptions) checkStatements(statements []*VQL) error {
+	for feature, used := range vqlFeatureChecks {
+		for _, vql := range statements {
+			if used(vql) {
+				err := self.checkFeature(feature)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Parse the VQL expression allowing only the enabled features.
+func ParseWithOptions(expression string, options ParseOptions) (*VQL, error) {
+	vql, err := Parse(expression)
+	if err != nil {
+		return vql, err
+	}
+	return vql, options.checkStatements([]*VQL{vql})
+}
+
+// Parse a string into multiple VQL statements allowing only the
+// enabled features.
+func MultiParseWithOptions(
+	expression string, options ParseOptions) ([]*VQL, error) {
+	statements, err := MultiParse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	err = options.checkStatements(statements)
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func ParseLambdaWithOptions(
+	expression string, options ParseOptions) (*Lambda, error) {
+	err := options.checkFeature(FeatureLambda)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLambda(expression)
+}
@@ -0,0 +1,70 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	enabled := ParseOptions{}
+	disabled := ParseOptions{
+		Features: map[Feature]bool{FeatureLambda: false},
+	}
+
+	lambda, err := ParseLambdaWithOptions("x => x + 1", enabled)
+	assert.NoError(t, err)
+
+	scope := makeTestScope()
+	defer scope.Close()
+
+	result := lambda.Reduce(context.Background(), scope, []Any{1})
+	assert.Equal(t, int64(2), result)
+
+	_, err = ParseLambdaWithOptions("x => x + 1", disabled)
+	assert.True(t, errors.Is(err, ErrFeatureDisabled))
+
+	// Queries not using the feature parse normally.
+	_, err = ParseWithOptions("SELECT * FROM scope()", disabled)
+	assert.NoError(t, err)
+
+	statements, err := MultiParseWithOptions(
+		"LET X = 1 SELECT X FROM scope()", disabled)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(statements))
+}
+
+func TestParseOptionsGrammarFeatures(t *testing.T) {
+	for _, test := range []struct {
+		feature Feature
+		query   string
+	}{
+		{FeatureDuration, "SELECT * FROM scope() WHERE X > 5m"},
+		{FeatureCache, "LET X = 1 + 2 CACHE 10"},
+		{FeatureNamedSubquery, "SELECT * FROM scope() AS Name"},
+		{FeatureRegexFlags, "SELECT * FROM scope() WHERE X =~ 'a' WITH (nocase)"},
+		{FeatureGlob, "SELECT * FROM scope() WHERE X GLOB '*.exe'"},
+		{FeatureMemoize, "LET f(x) <= SELECT * FROM scope()"},
+	} {
+		disabled := ParseOptions{
+			Features: map[Feature]bool{test.feature: false},
+		}
+
+		_, err := ParseWithOptions(test.query, disabled)
+		assert.True(t, errors.Is(err, ErrFeatureDisabled), test.query)
+
+		_, err = MultiParseWithOptions(test.query, disabled)
+		assert.True(t, errors.Is(err, ErrFeatureDisabled), test.query)
+
+		// Enabled by default.
+		_, err = ParseWithOptions(test.query, ParseOptions{})
+		assert.NoError(t, err, test.query)
+
+		// Only the disabled feature is rejected.
+		_, err = ParseWithOptions(
+			"LET X = SELECT * FROM scope() WHERE Y = 1", disabled)
+		assert.NoError(t, err, test.feature)
+	}
+}
@@ -0,0 +1,197 @@
+package vfilter
+
+import (
+	"reflect"
+	"sort"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Relative costs used to order the terms of AND expressions so cheap
+// conditions are evaluated first and expensive ones are skipped when
+// possible. Functions and plugins may declare their own cost in
+// their Info().
+const (
+	defaultCost  = 1
+	regexCost    = 10
+	subqueryCost = 100
+)
+
+// The parts of a term's cost which do not depend on the scope. The
+// functions, plugins and variables it refers to are only looked up
+// when planning since they may be different in each scope.
+type termInfo struct {
+	expr      *_OrExpression
+	cost      int
+	functions []string
+	plugins   []string
+	symbols   []string
+}
+
+// Returns the terms of the AND expression in the order they should
+// be evaluated. Only terms without side effects may be moved: a term
+// calling a function or plugin which does not declare its cost in
+// Info(), or referring to a stored expression or query (which may
+// call anything), is evaluated exactly where it was written, and
+// other terms are never moved across it. This keeps conditions which
+// guard such calls (e.g. a regex before an upload()) in front of
+// them. Terms with equal cost keep their original order.
+//
+// The plan depends on the scope so it is worked out each time.
+func (self *_AndExpression) getPlan(scope types.Scope) []*_OrExpression {
+	type term struct {
+		expr *_OrExpression
+		cost int
+	}
+
+	plan := make([]*_OrExpression, 0, len(self.Right)+1)
+	var movable []term
+
+	// Sort the run of movable terms collected so far.
+	flush := func() {
+		sort.SliceStable(movable, func(i, j int) bool {
+			return movable[i].cost < movable[j].cost
+		})
+		for _, t := range movable {
+			plan = append(plan, t.expr)
+		}
+		movable = nil
+	}
+
+	for _, info := range self.getTerms() {
+		cost, pinned := info.estimateCost(scope)
+		if pinned {
+			flush()
+			plan = append(plan, info.expr)
+			continue
+		}
+		movable = append(movable, term{info.expr, cost})
+	}
+	flush()
+
+	return plan
+}
+
+func (self *_AndExpression) getTerms() []*termInfo {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.terms != nil {
+		return self.terms
+	}
+
+	exprs := []*_OrExpression{self.Left}
+	for _, right := range self.Right {
+		exprs = append(exprs, right.Term)
+	}
+
+	for _, expr := range exprs {
+		self.terms = append(self.terms, newTermInfo(expr))
+	}
+
+	return self.terms
+}
+
+// Collect the operations within the AST node. Costs of regular
+// expressions and subqueries are known without a scope.
+func newTermInfo(expr *_OrExpression) *termInfo {
+	result := &termInfo{expr: expr, cost: defaultCost}
+
+	walkAST(reflect.ValueOf(expr), func(node interface{}) {
+		switch t := node.(type) {
+		case *_OpComparison:
+			if t.Operator == "=~" || t.isGlob() {
+				result.cost += regexCost
+			}
+
+		case *_SymbolRef:
+			if t.Called {
+				result.functions = append(result.functions, t.Symbol)
+			} else {
+				result.symbols = append(result.symbols, t.Symbol)
+			}
+
+		case *_Select:
+			result.cost += subqueryCost
+			if t.From != nil {
+				result.plugins = append(result.plugins, t.From.Plugin.Name)
+			}
+		}
+	})
+
+	return result
+}
+
+// Estimate the cost of evaluating the term by adding the costs
+// declared by the functions and plugins it calls. Returns pinned if
+// the term calls a function or plugin which does not declare a cost
+// or refers to a stored expression or query, since it may have side
+// effects and must not be reordered.
+func (self *termInfo) estimateCost(scope types.Scope) (cost int, pinned bool) {
+	cost = self.cost
+
+	for _, name := range self.functions {
+		function, pres := scope.GetFunction(name)
+		if !pres {
+			// Probably defined with LET.
+			return cost, true
+		}
+
+		info := function.Info(scope, types.NewTypeMap())
+		if info == nil || info.Cost == 0 {
+			return cost, true
+		}
+		cost += info.Cost
+	}
+
+	for _, name := range self.plugins {
+		plugin, pres := scope.GetPlugin(name)
+		if !pres {
+			return cost, true
+		}
+
+		info := plugin.Info(scope, types.NewTypeMap())
+		if info == nil || info.Cost == 0 {
+			return cost, true
+		}
+		cost += info.Cost
+	}
+
+	// Referring to a stored expression or query evaluates it.
+	for _, name := range self.symbols {
+		value, _ := scope.Resolve(name)
+		switch value.(type) {
+		case *StoredExpression, types.StoredQuery:
+			return cost, true
+		}
+	}
+
+	return cost, false
+}
+
+// Visit all the parsed nodes reachable from value.
+func walkAST(value reflect.Value, cb func(node interface{})) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return
+		}
+		cb(value.Interface())
+		walkAST(value.Elem(), cb)
+
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			walkAST(value.Index(i), cb)
+		}
+
+	case reflect.Struct:
+		value_type := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			// Only follow the parsed fields.
+			if value_type.Field(i).PkgPath != "" {
+				continue
+			}
+			walkAST(value.Field(i), cb)
+		}
+	}
+}
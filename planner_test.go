@@ -0,0 +1,128 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestPlannerOrdersAndTerms(t *testing.T) {
+	calls := 0
+	scope := makeTestScope().AppendFunctions(
+		functions.GenericFunction{
+			FunctionName: "expensive",
+			Cost:         50,
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) types.Any {
+				calls++
+				return true
+			},
+		})
+	defer scope.Close()
+
+	vql, err := Parse(`SELECT * FROM foreach(row=[1, 2, 3, 4])
+WHERE expensive() AND _value = 2`)
+	assert.NoError(t, err)
+
+	rows := 0
+	for _ = range vql.Eval(context.Background(), scope) {
+		rows++
+	}
+	assert.Equal(t, 1, rows)
+
+	// The cheap comparison ran first so the expensive function was
+	// only called for the matching row.
+	assert.Equal(t, 1, calls)
+
+	// The regex is more expensive than the comparison.
+	vql, err = Parse(`SELECT * FROM scope() WHERE "a" =~ "a" AND 1 = 1`)
+	assert.NoError(t, err)
+
+	plan := vql.Query.Where.Left.getPlan(scope)
+	assert.Equal(t, 2, len(plan))
+	assert.Equal(t, "1 = 1", FormatToString(scope, plan[0]))
+}
+
+func TestPlannerKeepsUnannotatedFunctionsInPlace(t *testing.T) {
+	calls := 0
+	scope := makeTestScope().AppendFunctions(
+		functions.GenericFunction{
+			FunctionName: "upload",
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) types.Any {
+				calls++
+				return true
+			},
+		})
+	defer scope.Close()
+
+	// The regex guards the upload() so it must run first even
+	// though it is more expensive.
+	vql, err := Parse(`SELECT * FROM foreach(row=["a", "b", "c", "d"])
+WHERE _value =~ "a" AND upload()`)
+	assert.NoError(t, err)
+
+	rows := 0
+	for _ = range vql.Eval(context.Background(), scope) {
+		rows++
+	}
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, 1, calls)
+
+	// Terms are not moved across the upload() either.
+	vql, err = Parse(`SELECT * FROM scope()
+WHERE "a" =~ "a" AND upload() AND "b" =~ "b" AND 1 = 1`)
+	assert.NoError(t, err)
+
+	plan := vql.Query.Where.Left.getPlan(scope)
+	assert.Equal(t, 4, len(plan))
+	assert.Equal(t, `"a" =~ "a"`, FormatToString(scope, plan[0]))
+	assert.Equal(t, "upload()", FormatToString(scope, plan[1]))
+	assert.Equal(t, "1 = 1", FormatToString(scope, plan[2]))
+}
+
+func TestPlannerKeepsStoredExpressionsInPlace(t *testing.T) {
+	calls := 0
+	scope := makeTestScope().AppendFunctions(
+		functions.GenericFunction{
+			FunctionName: "slow",
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) types.Any {
+				calls++
+				return true
+			},
+		})
+	defer scope.Close()
+
+	// Referring to X evaluates slow() so the regex guarding it must
+	// run first.
+	multi_vql, err := MultiParse(`LET X = slow()
+SELECT * FROM foreach(row=["a", "b", "c", "d"])
+WHERE _value =~ "^a" AND X`)
+	assert.NoError(t, err)
+
+	rows := 0
+	for _, vql := range multi_vql {
+		for _ = range vql.Eval(context.Background(), scope) {
+			rows++
+		}
+	}
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, 1, calls)
+
+	// The same terms are reordered when X is a plain variable.
+	vql, err := Parse(`SELECT * FROM scope() WHERE "a" =~ "a" AND X`)
+	assert.NoError(t, err)
+
+	subscope := scope.Copy()
+	defer subscope.Close()
+	subscope.AppendVars(ordereddict.NewDict().Set("X", true))
+
+	plan := vql.Query.Where.Left.getPlan(subscope)
+	assert.Equal(t, 2, len(plan))
+	assert.Equal(t, "X", FormatToString(subscope, plan[0]))
+}
@@ -0,0 +1,72 @@
+package vfilter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Resolves Artifact.<name> to a plugin emitting the name.
+type artifactNamespace struct{}
+
+func (self artifactNamespace) GetPlugin(
+	scope types.Scope, name string) (types.PluginGeneratorInterface, bool) {
+	if !strings.HasPrefix(name, "Linux.") {
+		return nil, false
+	}
+
+	return plugins.GenericListPlugin{
+		PluginName: name,
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			return []Row{ordereddict.NewDict().Set("Artifact", name)}
+		},
+	}, true
+}
+
+func TestAliasPlugin(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	assert.NoError(t, scope.AliasPlugin("Utils.Each", "foreach"))
+	assert.NoError(t, scope.AliasPlugin("Utils.Loop", "Utils.Each"))
+	assert.NoError(t, scope.AliasPlugin("Artifact", artifactNamespace{}))
+	assert.Error(t, scope.AliasPlugin("Bad", 1))
+
+	// Aliases may loop but are not followed forever.
+	assert.NoError(t, scope.AliasPlugin("Loop.A", "Loop.B"))
+	assert.NoError(t, scope.AliasPlugin("Loop.B", "Loop.A"))
+
+	run := func(query string) []*ordereddict.Dict {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []*ordereddict.Dict
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, RowToDict(context.Background(), scope, row))
+		}
+		return result
+	}
+
+	assert.Equal(t, 2, len(run("SELECT * FROM Utils.Each(row=[1, 2])")))
+	assert.Equal(t, 3, len(run("SELECT * FROM Utils.Loop(row=[1, 2, 3])")))
+
+	rows := run("SELECT * FROM Artifact.Linux.Sys.Users()")
+	assert.Equal(t, 1, len(rows))
+	name, _ := rows[0].GetString("Artifact")
+	assert.Equal(t, "Linux.Sys.Users", name)
+
+	assert.Equal(t, 0, len(run("SELECT * FROM Artifact.Windows.Sys()")))
+	assert.Equal(t, 0, len(run("SELECT * FROM Loop.A()")))
+
+	// Subscopes see the aliases too.
+	subscope := scope.Copy()
+	defer subscope.Close()
+	_, pres := subscope.GetPlugin("Utils.Each")
+	assert.True(t, pres)
+}
@@ -0,0 +1,67 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestPluginMiddleware(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// Audit every plugin call.
+	var audit []string
+	scope.AddPluginMiddleware(func(ctx context.Context, scope types.Scope,
+		name string, args *ordereddict.Dict,
+		next types.PluginCallNext) <-chan Row {
+		audit = append(audit, name)
+		return next(ctx, scope, args)
+	})
+
+	// Deny the scope() plugin.
+	scope.AddPluginMiddleware(func(ctx context.Context, scope types.Scope,
+		name string, args *ordereddict.Dict,
+		next types.PluginCallNext) <-chan Row {
+		if name == "scope" {
+			output_chan := make(chan Row)
+			close(output_chan)
+			return output_chan
+		}
+		return next(ctx, scope, args)
+	})
+
+	// Rewrite the args to foreach()
+	scope.AddPluginMiddleware(func(ctx context.Context, scope types.Scope,
+		name string, args *ordereddict.Dict,
+		next types.PluginCallNext) <-chan Row {
+		if name == "foreach" {
+			args = ordereddict.NewDict().Set("row", []int64{42})
+		}
+		return next(ctx, scope, args)
+	})
+
+	vql, err := Parse("SELECT _value FROM foreach(row=[1, 2, 3])")
+	assert.NoError(t, err)
+
+	var values []types.Any
+	for row := range vql.Eval(context.Background(), scope) {
+		value, _ := scope.Associative(row, "_value")
+		values = append(values, value)
+	}
+	assert.Equal(t, []types.Any{int64(42)}, values)
+
+	vql, err = Parse("SELECT * FROM scope()")
+	assert.NoError(t, err)
+
+	rows := 0
+	for range vql.Eval(context.Background(), scope) {
+		rows++
+	}
+	assert.Equal(t, 0, rows)
+
+	assert.Equal(t, []string{"foreach", "scope"}, audit)
+}
@@ -13,7 +13,20 @@ func GetBuiltinPlugins() []types.PluginGeneratorInterface {
 		_FlattenPluginImpl{},
 		_ChainPlugin{},
 		_ForeachPluginImpl{},
+		_JoinPlugin{},
+		_ZipPlugin{},
+		_SamplePlugin{},
+		_HeadPlugin{},
+		_TailPlugin{},
+		_SortPlugin{},
+		_UniqPlugin{},
+		_StatsPlugin{},
+		_PivotPlugin{},
+		_UnpivotPlugin{},
+		_RollupPlugin{},
+		_WindowPlugin{},
 		RangePlugin{},
+		DefinitionsPlugin{},
 		&GenericListPlugin{
 			PluginName: "scope",
 			Function: func(ctx context.Context,
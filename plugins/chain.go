@@ -3,6 +3,7 @@ package plugins
 import (
 	"context"
 	"sort"
+	"sync"
 
 	"github.com/Velocidex/ordereddict"
 	"www.velocidex.com/golang/vfilter/arg_parser"
@@ -15,7 +16,9 @@ func (self _ChainPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types
 	return &types.PluginInfo{
 		Name: "chain",
 		Doc: "Chain the output of several queries into the same table." +
-			"This plugin takes any args and chains them.",
+			"This plugin takes any args and chains them. If async=TRUE " +
+			"is given, all queries run concurrently and their rows are " +
+			"interleaved as they arrive.",
 	}
 }
 
@@ -32,11 +35,30 @@ func (self _ChainPlugin) Call(
 	go func() {
 		defer close(output_chan)
 
+		async := false
 		for _, member := range members {
 			member_obj, pres := args.Get(member)
-			if pres {
-				queries = append(queries, arg_parser.ToStoredQuery(ctx, member_obj))
+			if !pres {
+				continue
 			}
+
+			if member == "async" {
+				lazy_arg, ok := member_obj.(types.LazyExpr)
+				if ok {
+					member_obj = lazy_arg.ReduceWithScope(ctx, scope)
+				}
+				async = scope.Bool(member_obj)
+				continue
+			}
+
+			queries = append(queries, arg_parser.ToStoredQuery(ctx, member_obj))
+		}
+
+		// In deterministic mode the queries run one after the
+		// other instead.
+		if async && !scope.Deterministic() {
+			chainAsync(ctx, scope, queries, output_chan)
+			return
 		}
 
 		for _, query := range queries {
@@ -60,3 +82,31 @@ func (self _ChainPlugin) Call(
 	return output_chan
 
 }
+
+// Run all the queries concurrently and relay their rows as they
+// arrive.
+func chainAsync(ctx context.Context, scope types.Scope,
+	queries []types.StoredQuery, output_chan chan types.Row) {
+	wg := &sync.WaitGroup{}
+
+	for _, query := range queries {
+		wg.Add(1)
+		go func(query types.StoredQuery) {
+			defer wg.Done()
+
+			new_scope := scope.Copy()
+			defer new_scope.Close()
+
+			for item := range query.Eval(ctx, new_scope) {
+				select {
+				case <-ctx.Done():
+					return
+
+				case output_chan <- item:
+				}
+			}
+		}(query)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,48 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type DefinitionsPlugin struct{}
+
+func (self DefinitionsPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		for _, definition := range scope.GetDefinitions() {
+			row := ordereddict.NewDict().
+				Set("Name", definition.Name).
+				Set("Operator", definition.Operator).
+				Set("Parameters", definition.Parameters).
+				Set("Source", definition.Source).
+				Set("Line", definition.Line).
+				Set("Column", definition.Column).
+				Set("QueryId", definition.QueryId)
+
+			select {
+			case <-ctx.Done():
+				return
+
+			case output_chan <- row:
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+func (self DefinitionsPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name: "definitions",
+		Doc:  "List the LET definitions made in this scope.",
+	}
+}
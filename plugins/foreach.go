@@ -15,6 +15,8 @@ type _ForeachPluginImplArgs struct {
 	Async   bool              `vfilter:"optional,field=async,doc=If set we run all queries asynchronously (implies workers=1000)."`
 	Workers int64             `vfilter:"optional,field=workers,doc=Total number of asynchronous workers."`
 	Column  string            `vfilter:"optional,field=column,doc=If set we only extract the column from row."`
+	Ordered bool              `vfilter:"optional,field=ordered,doc=If set, results from asynchronous workers are emitted in the order of the rows."`
+	Var     string            `vfilter:"optional,field=var,doc=If set, each row is bound to this variable in the query instead of adding all its columns to the scope."`
 }
 
 type _ForeachPluginImpl struct{}
@@ -38,8 +40,9 @@ func (self _ForeachPluginImpl) Call(ctx context.Context,
 			arg.Workers = 100
 		}
 
-		// At least one worker
-		if arg.Workers == 0 {
+		// At least one worker. A single worker runs the queries
+		// in the order of the rows.
+		if arg.Workers == 0 || scope.Deterministic() {
 			arg.Workers = 1
 		}
 
@@ -47,7 +50,8 @@ func (self _ForeachPluginImpl) Call(ctx context.Context,
 		if arg.Workers > 1 {
 			scope.Log("Creating %v workers for foreach plugin\n", arg.Workers)
 		}
-		pool := newWorkerPool(ctx, arg.Query, output_chan, int(arg.Workers))
+		pool := newWorkerPool(ctx, arg.Query, output_chan,
+			int(arg.Workers), arg.Ordered)
 		defer pool.Close()
 
 		row_chan := scope.Iterate(ctx, arg.Row)
@@ -62,6 +66,9 @@ func (self _ForeachPluginImpl) Call(ctx context.Context,
 					return
 				}
 
+				// Throttle if needed.
+				scope.ChargeOp()
+
 				// This allows callers to deconstruct
 				// a SELECT with dicts as columns into
 				// entire rows.
@@ -87,9 +94,15 @@ func (self _ForeachPluginImpl) Call(ctx context.Context,
 				// can refer to rows returned by the "row" query -
 				// therefore it is **not** isolated.
 				child_scope := scope.Copy()
+				child_scope.PushCallFrame("foreach")
 				// child_scope is closed in the pool worker.
 
-				child_scope.AppendVars(row_item)
+				if arg.Var != "" {
+					child_scope.AppendVars(
+						ordereddict.NewDict().Set(arg.Var, row_item))
+				} else {
+					child_scope.AppendVars(row_item)
+				}
 				pool.RunScope(child_scope)
 			}
 		}
@@ -105,34 +118,66 @@ func (self _ForeachPluginImpl) Name() string {
 func (self _ForeachPluginImpl) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
 	return &types.PluginInfo{
 		Name: "foreach",
-		Doc:  "Executes 'query' once for each row in the 'row' query.",
+		Doc:  "Executes 'query' once for each row in the 'row' query. The rows may come from any iterable value (queries, slices, dicts or channels).",
 
 		ArgType: type_map.AddType(scope, &_ForeachPluginImplArgs{}),
 	}
 }
 
+// A job for the worker pool. When the pool is ordered, the results of
+// the job are sent on the result channel instead of the output.
+type workerJob struct {
+	scope  types.Scope
+	result chan []types.Row
+}
+
 type workerPool struct {
 	wg          sync.WaitGroup
-	ch          chan types.Scope
+	ch          chan workerJob
 	query       types.StoredQuery
 	output_chan chan types.Row
+
+	// In ordered mode the jobs' result channels are queued in the
+	// order the rows were received. The emitter waits for each
+	// job in turn.
+	order_queue  chan chan []types.Row
+	emitter_done chan bool
 }
 
 func (self *workerPool) RunScope(scope types.Scope) {
-	self.ch <- scope
+	job := workerJob{scope: scope}
+	if self.order_queue != nil {
+		job.result = make(chan []types.Row, 1)
+		self.order_queue <- job.result
+	}
+	self.ch <- job
 }
 
 func (self *workerPool) Close() {
 	close(self.ch)
 	self.wg.Wait()
+
+	if self.order_queue != nil {
+		close(self.order_queue)
+		<-self.emitter_done
+	}
 }
 
-func (self *workerPool) runQuery(ctx context.Context, scope types.Scope) {
+func (self *workerPool) runQuery(ctx context.Context, job workerJob) {
 	child_ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	defer scope.Close()
+	defer job.scope.Close()
+
+	var rows []types.Row
+	if job.result != nil {
+		// Always deliver the result so the emitter does not
+		// wait forever.
+		defer func() {
+			job.result <- rows
+		}()
+	}
 
-	query_chan := self.query.Eval(child_ctx, scope)
+	query_chan := self.query.Eval(child_ctx, job.scope)
 	for {
 		select {
 		case <-ctx.Done():
@@ -142,6 +187,12 @@ func (self *workerPool) runQuery(ctx context.Context, scope types.Scope) {
 			if !ok {
 				return
 			}
+
+			if job.result != nil {
+				rows = append(rows, query_chan_item)
+				continue
+			}
+
 			select {
 			case <-ctx.Done():
 				return
@@ -151,6 +202,30 @@ func (self *workerPool) runQuery(ctx context.Context, scope types.Scope) {
 	}
 }
 
+func (self *workerPool) emitter(ctx context.Context) {
+	defer close(self.emitter_done)
+
+	for result := range self.order_queue {
+		var rows []types.Row
+		select {
+		case <-ctx.Done():
+			// Keep draining the queue so RunScope does not
+			// block.
+			continue
+		case rows = <-result:
+		}
+
+	emit:
+		for _, row := range rows {
+			select {
+			case <-ctx.Done():
+				break emit
+			case self.output_chan <- row:
+			}
+		}
+	}
+}
+
 func (self *workerPool) worker(ctx context.Context) {
 	defer self.wg.Done()
 	for {
@@ -161,23 +236,29 @@ func (self *workerPool) worker(ctx context.Context) {
 			// Take a scope from the channel and re-run
 			// the query with the new scope. Prepare for
 			// cancellations at any point.
-		case scope, ok := <-self.ch:
+		case job, ok := <-self.ch:
 			if !ok {
 				return
 			}
-			self.runQuery(ctx, scope)
+			self.runQuery(ctx, job)
 		}
 	}
 }
 
 func newWorkerPool(ctx context.Context, query types.StoredQuery,
-	output_chan chan types.Row, size int) *workerPool {
+	output_chan chan types.Row, size int, ordered bool) *workerPool {
 	self := &workerPool{
-		ch:          make(chan types.Scope),
+		ch:          make(chan workerJob),
 		query:       query,
 		output_chan: output_chan,
 	}
 
+	if ordered {
+		self.order_queue = make(chan chan []types.Row, size)
+		self.emitter_done = make(chan bool)
+		go self.emitter(ctx)
+	}
+
 	for i := 0; i < size; i++ {
 		self.wg.Add(1)
 		go self.worker(ctx)
@@ -29,6 +29,18 @@ type GenericListPlugin struct {
 
 	ArgType  types.Any
 	Metadata *ordereddict.Dict
+
+	// An example row used to describe the plugin's columns.
+	RowType types.Any
+
+	// A relative cost hint used by the planner.
+	Cost int
+
+	// The capabilities the plugin requires (e.g. types.CapabilityFilesystem).
+	Capabilities []string
+
+	// The function always returns the same rows for the same args.
+	Cacheable bool
 }
 
 func (self GenericListPlugin) Call(
@@ -58,9 +70,93 @@ func (self GenericListPlugin) Name() string {
 
 func (self GenericListPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
 	result := &types.PluginInfo{
-		Name:     self.PluginName,
-		Doc:      self.Doc,
-		Metadata: self.Metadata,
+		Name:         self.PluginName,
+		Doc:          self.Doc,
+		Metadata:     self.Metadata,
+		RowType:      self.RowType,
+		Cost:         self.Cost,
+		Capabilities: self.Capabilities,
+		Cacheable:    self.Cacheable,
+	}
+
+	if self.ArgType != nil {
+		result.ArgType = type_map.AddType(scope, self.ArgType)
+	}
+
+	return result
+}
+
+// Streaming plugins send their rows to the output channel as they
+// are produced. The function should stop when the context is done.
+type StreamFunctionPlugin func(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, output_chan chan<- types.Row)
+
+// Like GenericListPlugin but the function streams its rows so long
+// running sources need not buffer them. The output channel is closed
+// when the function returns. Example:
+//
+//	scope.AppendPlugins(GenericStreamPlugin{
+//	  PluginName: "my_plugin",
+//	  Function: func(ctx context.Context, scope types.Scope,
+//	      args *ordereddict.Dict, output_chan chan<- types.Row) {
+//	      for ... {
+//	          select {
+//	          case <-ctx.Done():
+//	              return
+//	          case output_chan <- row:
+//	          }
+//	      }
+//	  },
+//	})
+type GenericStreamPlugin struct {
+	PluginName string
+	Doc        string
+	Function   StreamFunctionPlugin
+
+	ArgType  types.Any
+	Metadata *ordereddict.Dict
+
+	// An example row used to describe the plugin's columns.
+	RowType types.Any
+
+	// A relative cost hint used by the planner.
+	Cost int
+
+	// The plugin emits rows forever (e.g. an event source).
+	Unbounded bool
+
+	// The capabilities the plugin requires.
+	Capabilities []string
+}
+
+func (self GenericStreamPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		self.Function(ctx, scope, args, output_chan)
+	}()
+
+	return output_chan
+}
+
+func (self GenericStreamPlugin) Name() string {
+	return self.PluginName
+}
+
+func (self GenericStreamPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	result := &types.PluginInfo{
+		Name:         self.PluginName,
+		Doc:          self.Doc,
+		Metadata:     self.Metadata,
+		RowType:      self.RowType,
+		Cost:         self.Cost,
+		Unbounded:    self.Unbounded,
+		Capabilities: self.Capabilities,
 	}
 
 	if self.ArgType != nil {
@@ -0,0 +1,144 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _HeadPluginArgs struct {
+	Query types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Count int64             `vfilter:"required,field=count,doc=Number of rows to pass."`
+}
+
+// Pass the first rows of the query and then cancel it. Unlike LIMIT
+// this can be applied to any stored query.
+type _HeadPlugin struct{}
+
+func (self _HeadPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "head",
+		Doc:     "Pass the first count rows from the query.",
+		ArgType: type_map.AddType(scope, &_HeadPluginArgs{}),
+	}
+}
+
+func (self _HeadPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_HeadPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("head: %v", err)
+			return
+		}
+
+		if arg.Count <= 0 {
+			return
+		}
+
+		// Cancel the upstream query when we have enough rows.
+		sub_ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		count := int64(0)
+		for row := range arg.Query.Eval(sub_ctx, scope) {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+
+			count++
+			if count >= arg.Count {
+				return
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+type _TailPluginArgs struct {
+	Query types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Count int64             `vfilter:"required,field=count,doc=Number of rows to pass."`
+}
+
+// The most rows tail() will buffer.
+const maxTailCount = 100000000
+
+// Pass the last rows of the query. Only count rows are buffered.
+type _TailPlugin struct{}
+
+func (self _TailPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "tail",
+		Doc:     "Pass the last count rows from the query.",
+		ArgType: type_map.AddType(scope, &_TailPluginArgs{}),
+	}
+}
+
+func (self _TailPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_TailPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("tail: %v", err)
+			return
+		}
+
+		if arg.Count < 0 || arg.Count > maxTailCount {
+			scope.Log("tail: count should be between 0 and %v, not %v",
+				maxTailCount, arg.Count)
+			return
+		}
+
+		if arg.Count == 0 {
+			return
+		}
+
+		// A ring buffer of the last rows seen. It only grows as
+		// rows arrive so a large count does not allocate memory
+		// for rows the query never produces.
+		ring := []types.Row{}
+		count := int64(0)
+		for row := range arg.Query.Eval(ctx, scope) {
+			if int64(len(ring)) < arg.Count {
+				ring = append(ring, row)
+			} else {
+				ring[count%arg.Count] = row
+			}
+			count++
+		}
+
+		start := int64(0)
+		if count > arg.Count {
+			start = count - arg.Count
+		}
+
+		for i := start; i < count; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- ring[i%arg.Count]:
+			}
+		}
+	}()
+
+	return output_chan
+}
@@ -0,0 +1,218 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _JoinPluginArgs struct {
+	Lhs   types.StoredQuery `vfilter:"required,field=lhs,doc=The left hand query."`
+	Rhs   types.StoredQuery `vfilter:"required,field=rhs,doc=The right hand query."`
+	On    string            `vfilter:"optional,field=on,doc=The column to join on (present in both queries)."`
+	LhsOn string            `vfilter:"optional,field=lhs_on,doc=The column of the left hand query to join on (default on)."`
+	RhsOn string            `vfilter:"optional,field=rhs_on,doc=The column of the right hand query to join on (default on)."`
+}
+
+// An inner join of two queries. Both queries are read concurrently
+// until one of them is exhausted. The exhausted (smaller) query is
+// used to build a hash table and the rows of the other query are
+// streamed against it. This is much more efficient than a nested
+// foreach() for large row sets.
+type _JoinPlugin struct{}
+
+func (self _JoinPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name: "join",
+		Doc: "Join the rows of two queries on a column. Columns from " +
+			"lhs take precedence over columns of the same name from rhs.",
+		ArgType: type_map.AddType(scope, &_JoinPluginArgs{}),
+	}
+}
+
+// One side of the join.
+type joinInput struct {
+	key    string
+	rows   []types.Row
+	input  <-chan types.Row
+	is_lhs bool
+}
+
+func (self _JoinPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := _JoinPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, &arg)
+		if err != nil {
+			scope.Log("join: %v", err)
+			return
+		}
+
+		if arg.LhsOn == "" {
+			arg.LhsOn = arg.On
+		}
+
+		if arg.RhsOn == "" {
+			arg.RhsOn = arg.On
+		}
+
+		if arg.LhsOn == "" || arg.RhsOn == "" {
+			scope.Log("join: on must be specified")
+			return
+		}
+
+		sub_ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		lhs := &joinInput{
+			key:    arg.LhsOn,
+			input:  arg.Lhs.Eval(sub_ctx, scope),
+			is_lhs: true,
+		}
+		rhs := &joinInput{
+			key:   arg.RhsOn,
+			input: arg.Rhs.Eval(sub_ctx, scope),
+		}
+
+		// Read both sides until one is exhausted.
+		var build, probe *joinInput
+		for build == nil {
+			var row types.Row
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+
+			case row, ok = <-lhs.input:
+				if !ok {
+					build, probe = lhs, rhs
+					continue
+				}
+				lhs.rows = append(lhs.rows, row)
+
+			case row, ok = <-rhs.input:
+				if !ok {
+					build, probe = rhs, lhs
+					continue
+				}
+				rhs.rows = append(rhs.rows, row)
+			}
+
+			err := scope.ChargeMemory(ctx, utils.EstimateSize(row))
+			if err != nil {
+				scope.Log("ERROR:join: %v", err)
+				types.AbortQuery(ctx, err)
+				return
+			}
+		}
+
+		table := make(map[string][]types.Row)
+		for _, row := range build.rows {
+			key, pres := joinKey(scope, row, build.key)
+			if pres {
+				table[key] = append(table[key], row)
+			}
+		}
+		build.rows = nil
+
+		emit := func(row types.Row) bool {
+			key, pres := joinKey(scope, row, probe.key)
+			if !pres {
+				return true
+			}
+
+			for _, match := range table[key] {
+				var joined *ordereddict.Dict
+				if probe.is_lhs {
+					joined = joinRows(scope, row, match)
+				} else {
+					joined = joinRows(scope, match, row)
+				}
+
+				select {
+				case <-ctx.Done():
+					return false
+				case output_chan <- joined:
+				}
+			}
+			return true
+		}
+
+		// Stream the probe side - first the rows we already
+		// read, then the rest of the query.
+		for _, row := range probe.rows {
+			if !emit(row) {
+				return
+			}
+		}
+		probe.rows = nil
+
+		for row := range probe.input {
+			if !emit(row) {
+				return
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+// Keys are tagged with their type so e.g. the string "1" does not
+// join with the number 1. Numbers of different types join when they
+// are equal.
+func joinKey(scope types.Scope, row types.Row, column string) (string, bool) {
+	value, pres := scope.Associative(row, column)
+	if !pres || types.IsNullObject(value) {
+		return "", false
+	}
+
+	switch t := value.(type) {
+	case string:
+		return "string:" + t, true
+
+	case bool:
+		return fmt.Sprintf("bool:%v", t), true
+
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < math.MaxInt64 {
+			return fmt.Sprintf("number:%d", int64(t)), true
+		}
+		return fmt.Sprintf("number:%v", t), true
+	}
+
+	number, ok := utils.ToInt64(value)
+	if ok {
+		return fmt.Sprintf("number:%d", number), true
+	}
+
+	return fmt.Sprintf("%T:%v", value, value), true
+}
+
+func joinRows(scope types.Scope, lhs, rhs types.Row) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	for _, member := range scope.GetMembers(lhs) {
+		value, _ := scope.Associative(lhs, member)
+		result.Set(member, value)
+	}
+
+	for _, member := range scope.GetMembers(rhs) {
+		_, pres := result.Get(member)
+		if !pres {
+			value, _ := scope.Associative(rhs, member)
+			result.Set(member, value)
+		}
+	}
+	return result
+}
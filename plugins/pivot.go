@@ -0,0 +1,175 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _PivotPluginArgs struct {
+	Query  types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Key    []string          `vfilter:"optional,field=key,doc=Rows with the same values in these columns are merged into one row."`
+	Column string            `vfilter:"required,field=column,doc=The column whose values become the new column names."`
+	Value  string            `vfilter:"required,field=value,doc=The column holding the values of the new columns."`
+}
+
+// Rotate the values of a column into columns. For example rows like
+// {Host, Metric, Value} become one row per Host with a column per
+// Metric.
+type _PivotPlugin struct{}
+
+func (self _PivotPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "pivot",
+		Doc:     "Rotate the values of a column into columns.",
+		ArgType: type_map.AddType(scope, &_PivotPluginArgs{}),
+	}
+}
+
+func (self _PivotPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_PivotPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("pivot: %v", err)
+			return
+		}
+
+		// Output rows keyed by the key columns, in order of first
+		// appearance.
+		groups := ordereddict.NewDict()
+		for row := range arg.Query.Eval(ctx, scope) {
+			err := scope.ChargeMemory(ctx, utils.EstimateSize(row))
+			if err != nil {
+				scope.Log("ERROR:pivot: %v", err)
+				types.AbortQuery(ctx, err)
+				return
+			}
+
+			column, pres := scope.Associative(row, arg.Column)
+			if !pres || types.IsNullObject(column) {
+				continue
+			}
+			value, _ := scope.Associative(row, arg.Value)
+
+			// Without key columns all rows are merged into one.
+			group_key := ""
+			if len(arg.Key) > 0 {
+				group_key = uniqKey(scope, row, arg.Key)
+			}
+
+			group_any, pres := groups.Get(group_key)
+			if !pres {
+				group := ordereddict.NewDict()
+				for _, key := range arg.Key {
+					key_value, _ := scope.Associative(row, key)
+					group.Set(key, key_value)
+				}
+				group_any = group
+				groups.Set(group_key, group)
+			}
+
+			group_any.(*ordereddict.Dict).Set(
+				types.ToString(ctx, scope, column), value)
+		}
+
+		for _, k := range groups.Keys() {
+			group, _ := groups.Get(k)
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- group:
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+type _UnpivotPluginArgs struct {
+	Query   types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Columns []string          `vfilter:"required,field=columns,doc=The columns to rotate into rows."`
+	Key     string            `vfilter:"optional,field=key,doc=The name of the column holding the rotated column name (default Key)."`
+	Value   string            `vfilter:"optional,field=value,doc=The name of the column holding the rotated value (default Value)."`
+}
+
+// Rotate columns into key/value rows. All other columns are copied
+// to each output row.
+type _UnpivotPlugin struct{}
+
+func (self _UnpivotPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "unpivot",
+		Doc:     "Rotate columns into key/value rows.",
+		ArgType: type_map.AddType(scope, &_UnpivotPluginArgs{}),
+	}
+}
+
+func (self _UnpivotPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_UnpivotPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("unpivot: %v", err)
+			return
+		}
+
+		if arg.Key == "" {
+			arg.Key = "Key"
+		}
+
+		if arg.Value == "" {
+			arg.Value = "Value"
+		}
+
+		for row := range arg.Query.Eval(ctx, scope) {
+			// Columns which are not rotated are kept.
+			base := ordereddict.NewDict()
+			for _, member := range scope.GetMembers(row) {
+				if !utils.InString(&arg.Columns, member) {
+					value, _ := scope.Associative(row, member)
+					base.Set(member, value)
+				}
+			}
+
+			for _, column := range arg.Columns {
+				value, pres := scope.Associative(row, column)
+				if !pres {
+					continue
+				}
+
+				result := ordereddict.NewDict()
+				for _, k := range base.Keys() {
+					v, _ := base.Get(k)
+					result.Set(k, v)
+				}
+				result.Set(arg.Key, column).Set(arg.Value, value)
+
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- result:
+				}
+			}
+		}
+	}()
+
+	return output_chan
+}
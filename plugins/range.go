@@ -25,30 +25,79 @@ func (self RangePlugin) Call(
 	go func() {
 		defer close(output_chan)
 
-		arg := &RangePluginArgs{}
-		err := arg_parser.ExtractArgs(scope, args, arg)
-		if err != nil {
-			scope.Log("range: %v", err)
+		arg, ok := self.parseArgs(scope, args)
+		if !ok {
 			return
 		}
 
-		if arg.Step == 0 {
-			arg.Step = 1
+		for i := arg.Start; i < arg.End; i += arg.Step {
+			select {
+			case <-ctx.Done():
+				return
+
+			case output_chan <- ordereddict.NewDict().Set("_value", i):
+			}
 		}
+	}()
 
+	return output_chan
+}
+
+func (self RangePlugin) CallBatches(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan []types.Row {
+	output_chan := make(chan []types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg, ok := self.parseArgs(scope, args)
+		if !ok {
+			return
+		}
+
+		batch_size := scope.BatchSize()
+		if batch_size <= 0 {
+			batch_size = 1
+		}
+
+		batch := make([]types.Row, 0, batch_size)
 		for i := arg.Start; i < arg.End; i += arg.Step {
+			batch = append(batch, ordereddict.NewDict().Set("_value", i))
+			if len(batch) < batch_size && i+arg.Step < arg.End {
+				continue
+			}
+
 			select {
 			case <-ctx.Done():
 				return
 
-			case output_chan <- ordereddict.NewDict().Set("_value", i):
+			case output_chan <- batch:
 			}
+			batch = make([]types.Row, 0, batch_size)
 		}
 	}()
 
 	return output_chan
 }
 
+func (self RangePlugin) parseArgs(
+	scope types.Scope, args *ordereddict.Dict) (*RangePluginArgs, bool) {
+	arg := &RangePluginArgs{}
+	err := arg_parser.ExtractArgs(scope, args, arg)
+	if err != nil {
+		scope.Log("range: %v", err)
+		return nil, false
+	}
+
+	if arg.Step == 0 {
+		arg.Step = 1
+	}
+
+	return arg, true
+}
+
 func (self RangePlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
 	return &types.PluginInfo{
 		Name:    "range",
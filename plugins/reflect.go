@@ -0,0 +1,141 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	scopeType   = reflect.TypeOf((*types.Scope)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// A plugin made from a plain Go function by MakePlugin().
+type reflectedPlugin struct {
+	name     string
+	doc      string
+	function reflect.Value
+	row_type types.Any
+
+	// The function's arg type which may be a pointer to the struct.
+	arg_type    reflect.Type
+	struct_type reflect.Type
+}
+
+// Make a plugin from a Go function with the signature:
+//
+//	func(ctx context.Context, scope types.Scope, arg *MyArgs) ([]MyRow, error)
+//
+// The args are parsed into MyArgs (a struct or pointer to a struct
+// with vfilter tags) with arg_parser.ExtractArgsWithContext() and the
+// returned rows are emitted. If MyRow is a struct it describes the
+// plugin's columns. Example:
+//
+//	plugin, err := plugins.MakePlugin("users", "List users",
+//	    func(ctx context.Context, scope types.Scope, arg *UserArgs) ([]User, error) {
+//	        ....
+//	    })
+func MakePlugin(name, doc string,
+	function interface{}) (types.PluginGeneratorInterface, error) {
+	value := reflect.ValueOf(function)
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("MakePlugin %v: Expected a function not %T",
+			name, function)
+	}
+
+	func_type := value.Type()
+	if func_type.NumIn() != 3 || func_type.NumOut() != 2 ||
+		func_type.In(0) != contextType || func_type.In(1) != scopeType ||
+		func_type.Out(0).Kind() != reflect.Slice ||
+		func_type.Out(1) != errorType {
+		return nil, fmt.Errorf("MakePlugin %v: function should be "+
+			"func(context.Context, types.Scope, ArgStruct) ([]Row, error) not %v",
+			name, func_type)
+	}
+
+	arg_type := func_type.In(2)
+	struct_type := arg_type
+	if struct_type.Kind() == reflect.Ptr {
+		struct_type = struct_type.Elem()
+	}
+	if struct_type.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("MakePlugin %v: args should be a struct not %v",
+			name, arg_type)
+	}
+
+	result := &reflectedPlugin{
+		name:        name,
+		doc:         doc,
+		function:    value,
+		arg_type:    arg_type,
+		struct_type: struct_type,
+	}
+
+	row_type := func_type.Out(0).Elem()
+	switch {
+	case row_type.Kind() == reflect.Struct:
+		result.row_type = reflect.New(row_type).Elem().Interface()
+
+	case row_type.Kind() == reflect.Ptr && row_type.Elem().Kind() == reflect.Struct:
+		result.row_type = reflect.New(row_type.Elem()).Interface()
+	}
+
+	return result, nil
+}
+
+func (self *reflectedPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := reflect.New(self.struct_type)
+		err := arg_parser.ExtractArgsWithContext(ctx, scope, args, arg.Interface())
+		if err != nil {
+			scope.Log("%v: %v", self.name, err)
+			return
+		}
+
+		if self.arg_type.Kind() != reflect.Ptr {
+			arg = arg.Elem()
+		}
+
+		results := self.function.Call([]reflect.Value{
+			reflect.ValueOf(ctx), reflect.ValueOf(scope), arg})
+
+		rows := results[0]
+		for i := 0; i < rows.Len(); i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- rows.Index(i).Interface():
+			}
+		}
+
+		// Rows returned with an error are still emitted.
+		if !results[1].IsNil() {
+			scope.Log("%v: %v", self.name, results[1].Interface())
+		}
+	}()
+
+	return output_chan
+}
+
+func (self *reflectedPlugin) Info(
+	scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    self.name,
+		Doc:     self.doc,
+		ArgType: type_map.AddType(scope, reflect.New(self.struct_type).Interface()),
+		RowType: self.row_type,
+	}
+}
@@ -0,0 +1,120 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _RollupPluginArgs struct {
+	Query types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Keys  []string          `vfilter:"required,field=keys,doc=The columns to group by, from the outermost level."`
+	Sum   []string          `vfilter:"optional,field=sum,doc=Numeric columns to total in each group."`
+}
+
+// Group rows like GROUP BY ... WITH ROLLUP. Rows are emitted for
+// each group of all the keys, then subtotals for each prefix of the
+// keys (the rolled up key columns are NULL) and finally the grand
+// total.
+type _RollupPlugin struct{}
+
+func (self _RollupPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name: "rollup",
+		Doc: "Count and total rows by the keys, emitting subtotals " +
+			"for each grouping level and a grand total.",
+		ArgType: type_map.AddType(scope, &_RollupPluginArgs{}),
+	}
+}
+
+type rollupGroup struct {
+	row   *ordereddict.Dict
+	count int64
+	sums  []float64
+}
+
+func (self _RollupPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_RollupPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("rollup: %v", err)
+			return
+		}
+
+		// One set of groups per level: levels[i] groups by the
+		// first len(keys) - i keys.
+		levels := make([]*ordereddict.Dict, len(arg.Keys)+1)
+		for i := range levels {
+			levels[i] = ordereddict.NewDict()
+		}
+
+		for row := range arg.Query.Eval(ctx, scope) {
+			for i, level := range levels {
+				keys := arg.Keys[:len(arg.Keys)-i]
+				group_key := ""
+				if len(keys) > 0 {
+					group_key = uniqKey(scope, row, keys)
+				}
+
+				group_any, pres := level.Get(group_key)
+				if !pres {
+					group_row := ordereddict.NewDict()
+					for idx, key := range arg.Keys {
+						value := types.Any(types.Null{})
+						if idx < len(keys) {
+							value, _ = scope.Associative(row, key)
+						}
+						group_row.Set(key, value)
+					}
+
+					group_any = &rollupGroup{
+						row:  group_row,
+						sums: make([]float64, len(arg.Sum)),
+					}
+					level.Set(group_key, group_any)
+				}
+
+				group := group_any.(*rollupGroup)
+				group.count++
+				for idx, column := range arg.Sum {
+					value, _ := scope.Associative(row, column)
+					number, ok := utils.ToFloat(value)
+					if ok {
+						group.sums[idx] += number
+					}
+				}
+			}
+		}
+
+		for _, level := range levels {
+			for _, k := range level.Keys() {
+				group_any, _ := level.Get(k)
+				group := group_any.(*rollupGroup)
+
+				result := group.row.Set("Count", group.count)
+				for idx, column := range arg.Sum {
+					result.Set(column, group.sums[idx])
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- result:
+				}
+			}
+		}
+	}()
+
+	return output_chan
+}
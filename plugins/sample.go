@@ -0,0 +1,63 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _SamplePluginArgs struct {
+	Query types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	N     int64             `vfilter:"required,field=n,doc=Pass every n'th row."`
+}
+
+type _SamplePlugin struct{}
+
+func (self _SamplePlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "sample",
+		Doc:     "Pass every n'th row from the query (starting with the first).",
+		ArgType: type_map.AddType(scope, &_SamplePluginArgs{}),
+	}
+}
+
+func (self _SamplePlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_SamplePluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("sample: %v", err)
+			return
+		}
+
+		if arg.N <= 0 {
+			scope.Log("sample: n must be positive")
+			return
+		}
+
+		count := int64(0)
+		for row := range arg.Query.Eval(ctx, scope) {
+			count++
+			if (count-1)%arg.N != 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+	}()
+
+	return output_chan
+}
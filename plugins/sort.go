@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _SortPluginArgs struct {
+	Query types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Key   string            `vfilter:"required,field=key,doc=The column to sort by or a lambda called with each row (e.g. 'x => len(list=x.Name)')."`
+	Desc  bool              `vfilter:"optional,field=desc,doc=Sort in descending order."`
+}
+
+// Sort the rows of a query in memory. Unlike ORDER BY this may be
+// used inside nested queries and foreach bodies.
+type _SortPlugin struct{}
+
+func (self _SortPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "sort",
+		Doc:     "Sort the rows of a query by an expression.",
+		ArgType: type_map.AddType(scope, &_SortPluginArgs{}),
+	}
+}
+
+type sortItem struct {
+	row types.Row
+	key types.Any
+}
+
+func (self _SortPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_SortPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("sort: %v", err)
+			return
+		}
+
+		var lambda types.Lambda
+		if strings.Contains(arg.Key, "=>") && types.ParseLambda != nil {
+			lambda, err = types.ParseLambda(arg.Key)
+			if err != nil {
+				scope.Log("sort: invalid key %q: %v", arg.Key, err)
+				return
+			}
+		}
+
+		items := []sortItem{}
+		for row := range arg.Query.Eval(ctx, scope) {
+			err := scope.ChargeMemory(ctx, utils.EstimateSize(row))
+			if err != nil {
+				scope.Log("ERROR:sort: %v", err)
+				types.AbortQuery(ctx, err)
+				return
+			}
+
+			var key types.Any
+			if lambda != nil {
+				key = lambda.Reduce(ctx, scope, []types.Any{row})
+			} else {
+				key, _ = scope.Associative(row, arg.Key)
+			}
+
+			items = append(items, sortItem{row: row, key: key})
+		}
+
+		sort.SliceStable(items, func(i, j int) bool {
+			if arg.Desc {
+				return scope.Lt(items[j].key, items[i].key)
+			}
+			return scope.Lt(items[i].key, items[j].key)
+		})
+
+		for _, item := range items {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- item.row:
+			}
+		}
+	}()
+
+	return output_chan
+}
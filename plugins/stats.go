@@ -0,0 +1,120 @@
+package plugins
+
+import (
+	"context"
+	"math"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _StatsPluginArgs struct {
+	Query   types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Columns []string          `vfilter:"required,field=columns,doc=The columns to summarize."`
+}
+
+// Summarize numeric columns of a query in constant memory. Non
+// numeric values are ignored.
+type _StatsPlugin struct{}
+
+func (self _StatsPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name: "stats",
+		Doc: "Emit one row per column with the count, sum, min, max, " +
+			"average and standard deviation of its values.",
+		ArgType: type_map.AddType(scope, &_StatsPluginArgs{}),
+	}
+}
+
+// Running statistics using Welford's algorithm.
+type columnStats struct {
+	count    int64
+	sum      float64
+	min, max float64
+	mean, m2 float64
+}
+
+func (self *columnStats) Add(value float64) {
+	if self.count == 0 || value < self.min {
+		self.min = value
+	}
+	if self.count == 0 || value > self.max {
+		self.max = value
+	}
+
+	self.count++
+	self.sum += value
+
+	delta := value - self.mean
+	self.mean += delta / float64(self.count)
+	self.m2 += delta * (value - self.mean)
+}
+
+func (self *columnStats) Row(column string) *ordereddict.Dict {
+	result := ordereddict.NewDict().
+		Set("Column", column).
+		Set("Count", self.count).
+		Set("Sum", self.sum)
+
+	if self.count == 0 {
+		return result.Set("Min", types.Null{}).
+			Set("Max", types.Null{}).
+			Set("Avg", types.Null{}).
+			Set("Stddev", types.Null{})
+	}
+
+	// Population standard deviation.
+	return result.Set("Min", self.min).
+		Set("Max", self.max).
+		Set("Avg", self.mean).
+		Set("Stddev", math.Sqrt(self.m2/float64(self.count)))
+}
+
+func (self _StatsPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_StatsPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("stats: %v", err)
+			return
+		}
+
+		stats := make([]*columnStats, len(arg.Columns))
+		for i := range stats {
+			stats[i] = &columnStats{}
+		}
+
+		for row := range arg.Query.Eval(ctx, scope) {
+			for i, column := range arg.Columns {
+				value, pres := scope.Associative(row, column)
+				if !pres {
+					continue
+				}
+
+				number, ok := utils.ToFloat(value)
+				if ok {
+					stats[i].Add(number)
+				}
+			}
+		}
+
+		for i, column := range arg.Columns {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- stats[i].Row(column):
+			}
+		}
+	}()
+
+	return output_chan
+}
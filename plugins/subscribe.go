@@ -0,0 +1,172 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A SubscribeFunction connects to an event source and calls emit()
+// for each event until ctx is done. emit() returns false once the
+// query is no longer interested in events - the function should
+// then unsubscribe and return. emit() may be called from any
+// goroutine but not after the function returns. The scope is only
+// used by this subscription so destructors added to it run when the
+// subscription ends.
+type SubscribeFunction func(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict, emit func(row types.Row) bool) error
+
+// A helper for event plugins (file watchers, process events etc)
+// which never finish by themselves. The plugin:
+//
+//  1. Runs the subscriber in its own goroutine with a context which
+//     is cancelled when the query stops reading, the query's context
+//     is done or the scope is closed. The subscriber gets a subscope
+//     which is closed when the subscription ends, so subscriptions
+//     do not leave destructors on the caller's scope.
+//  2. Buffers up to BufferSize events. When the buffer is full emit()
+//     blocks, unless DropWhenFull is set in which case the event is
+//     dropped (and the number of dropped events is logged).
+//  3. Closes the output channel only after the subscriber returned.
+//
+// Example:
+//
+//	scope.AppendPlugins(SubscribePlugin{
+//	  PluginName: "watch_events",
+//	  BufferSize: 100,
+//	  Function: func(ctx context.Context, scope types.Scope,
+//	      args *ordereddict.Dict, emit func(row types.Row) bool) error {
+//	      unsubscribe := source.Register(func(event Event) {
+//	          emit(event)
+//	      })
+//	      defer unsubscribe()
+//
+//	      <-ctx.Done()
+//	      return nil
+//	  },
+//	})
+type SubscribePlugin struct {
+	PluginName string
+	Doc        string
+	Function   SubscribeFunction
+
+	ArgType  types.Any
+	Metadata *ordereddict.Dict
+
+	// An example row used to describe the plugin's columns.
+	RowType types.Any
+
+	// Number of events to buffer before applying backpressure.
+	BufferSize int
+
+	// Drop events instead of blocking the event source when the
+	// buffer is full.
+	DropWhenFull bool
+
+	// The capabilities the plugin requires.
+	Capabilities []string
+}
+
+func (self SubscribePlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	// Closing the scope closes the subscope, which cancels the
+	// subscription. Once the subscription ends the subscope is
+	// closed and removed from the scope.
+	subscope := scope.Copy()
+	sub_ctx, cancel := context.WithCancel(ctx)
+	err := subscope.AddDestructor(cancel)
+	if err == nil && scope.IsClosed() {
+		err = errors.New("Scope already closed")
+	}
+	if err != nil {
+		subscope.Close()
+		close(output_chan)
+		scope.Log("%s: %v", self.PluginName, err)
+		return output_chan
+	}
+
+	buffer := make(chan types.Row, self.BufferSize)
+	var dropped uint64
+
+	emit := func(row types.Row) bool {
+		if self.DropWhenFull {
+			select {
+			case <-sub_ctx.Done():
+				return false
+			case buffer <- row:
+			default:
+				atomic.AddUint64(&dropped, 1)
+			}
+			return true
+		}
+
+		select {
+		case <-sub_ctx.Done():
+			return false
+		case buffer <- row:
+			return true
+		}
+	}
+
+	// The subscriber owns the buffer.
+	go func() {
+		defer close(buffer)
+		defer cancel()
+		defer types.RecoverVQL(scope)
+
+		err := self.Function(sub_ctx, subscope, args, emit)
+		if err != nil && sub_ctx.Err() == nil {
+			scope.Log("%s: %v", self.PluginName, err)
+		}
+	}()
+
+	go func() {
+		defer close(output_chan)
+		defer subscope.Close()
+
+		for row := range buffer {
+			// Once cancelled keep draining the buffer so the
+			// subscriber is never stuck.
+			select {
+			case <-sub_ctx.Done():
+			case output_chan <- row:
+			}
+		}
+
+		count := atomic.LoadUint64(&dropped)
+		if count > 0 {
+			scope.Log("%s: dropped %v events because the query was too slow",
+				self.PluginName, count)
+		}
+	}()
+
+	return output_chan
+}
+
+func (self SubscribePlugin) Name() string {
+	return self.PluginName
+}
+
+func (self SubscribePlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	result := &types.PluginInfo{
+		Name:         self.PluginName,
+		Doc:          self.Doc,
+		Metadata:     self.Metadata,
+		RowType:      self.RowType,
+		Unbounded:    true,
+		Capabilities: self.Capabilities,
+	}
+
+	if self.ArgType != nil {
+		result.ArgType = type_map.AddType(scope, self.ArgType)
+	}
+
+	return result
+}
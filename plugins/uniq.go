@@ -0,0 +1,106 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _UniqPluginArgs struct {
+	Query     types.StoredQuery `vfilter:"required,field=query,doc=Source query."`
+	Key       []string          `vfilter:"optional,field=key,doc=The columns to compare (default all columns)."`
+	All       bool              `vfilter:"optional,field=all,doc=Drop all duplicates, not just consecutive ones."`
+	CacheSize int64             `vfilter:"optional,field=cache_size,doc=How many keys to remember when all is set (default 10000)."`
+}
+
+// Drop duplicate rows from a stream. By default only consecutive
+// duplicates are dropped, which needs no memory. With all=TRUE a
+// bounded cache of recent keys is kept so duplicates further apart
+// than the cache size may still be emitted.
+type _UniqPlugin struct{}
+
+func (self _UniqPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "uniq",
+		Doc:     "Drop duplicate rows from the query.",
+		ArgType: type_map.AddType(scope, &_UniqPluginArgs{}),
+	}
+}
+
+func (self _UniqPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_UniqPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("uniq: %v", err)
+			return
+		}
+
+		if arg.CacheSize <= 0 {
+			arg.CacheSize = 10000
+		}
+
+		last_key := ""
+		first := true
+
+		// A FIFO of seen keys used when all is set.
+		seen := make(map[string]bool)
+		var order []string
+
+		for row := range arg.Query.Eval(ctx, scope) {
+			key := uniqKey(scope, row, arg.Key)
+
+			if arg.All {
+				if seen[key] {
+					continue
+				}
+
+				seen[key] = true
+				order = append(order, key)
+				if int64(len(order)) > arg.CacheSize {
+					delete(seen, order[0])
+					order = order[1:]
+				}
+
+			} else {
+				if !first && key == last_key {
+					continue
+				}
+				first = false
+				last_key = key
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+func uniqKey(scope types.Scope, row types.Row, columns []string) string {
+	if len(columns) == 0 {
+		columns = scope.GetMembers(row)
+	}
+
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		value, _ := scope.Associative(row, column)
+		parts = append(parts, fmt.Sprintf("%q=%v", column, value))
+	}
+	return strings.Join(parts, ",")
+}
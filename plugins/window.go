@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"context"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _WindowPluginArgs struct {
+	Query  types.StoredQuery `vfilter:"required,field=query,doc=Source query (may be an event query which never ends)."`
+	Period float64           `vfilter:"required,field=period,doc=The length of each window in seconds."`
+	Key    string            `vfilter:"optional,field=key,doc=Count rows separately for each value of this column."`
+}
+
+// Bucket rows into fixed time windows by their arrival time. At the
+// end of each window one row is emitted per key with the number of
+// rows seen and their rate per second. The last partial window is
+// emitted when the query ends.
+type _WindowPlugin struct{}
+
+func (self _WindowPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "window",
+		Doc:     "Count the rows of a query in fixed time windows.",
+		ArgType: type_map.AddType(scope, &_WindowPluginArgs{}),
+	}
+}
+
+func (self _WindowPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_WindowPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, arg)
+		if err != nil {
+			scope.Log("window: %v", err)
+			return
+		}
+
+		period := time.Duration(arg.Period * float64(time.Second))
+		if period <= 0 {
+			scope.Log("window: period must be positive")
+			return
+		}
+
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		start := time.Now()
+		counts := ordereddict.NewDict()
+
+		// Emit a row for each key and start a new window.
+		flush := func(end time.Time) bool {
+			for _, k := range counts.Keys() {
+				v, _ := counts.Get(k)
+				count := v.(*windowCount)
+
+				row := ordereddict.NewDict().
+					Set("Start", start).
+					Set("End", end)
+				if arg.Key != "" {
+					row.Set(arg.Key, count.key)
+				}
+				row.Set("Count", count.count).
+					Set("Rate", float64(count.count)/end.Sub(start).Seconds())
+
+				select {
+				case <-ctx.Done():
+					return false
+				case output_chan <- row:
+				}
+			}
+
+			start = end
+			counts = ordereddict.NewDict()
+			return true
+		}
+
+		input := arg.Query.Eval(ctx, scope)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case now := <-ticker.C:
+				if !flush(now) {
+					return
+				}
+
+			case row, ok := <-input:
+				if !ok {
+					flush(time.Now())
+					return
+				}
+
+				var key types.Any = types.Null{}
+				group := ""
+				if arg.Key != "" {
+					key, _ = scope.Associative(row, arg.Key)
+					group = uniqKey(scope, row, []string{arg.Key})
+				}
+
+				v, pres := counts.Get(group)
+				if !pres {
+					v = &windowCount{key: key}
+					counts.Set(group, v)
+				}
+				v.(*windowCount).count++
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+type windowCount struct {
+	key   types.Any
+	count int64
+}
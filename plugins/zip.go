@@ -0,0 +1,93 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type _ZipPluginArgs struct {
+	Lhs     types.StoredQuery `vfilter:"required,field=lhs,doc=The left hand query."`
+	Rhs     types.StoredQuery `vfilter:"required,field=rhs,doc=The right hand query."`
+	Longest bool              `vfilter:"optional,field=longest,doc=Keep going until both queries are exhausted (default stop at the shortest)."`
+}
+
+// Iterate two queries in lockstep, merging the Nth row of each
+// query into the same output row.
+type _ZipPlugin struct{}
+
+func (self _ZipPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name: "zip",
+		Doc: "Merge the rows of two queries row by row. Columns from " +
+			"lhs take precedence over columns of the same name from rhs.",
+		ArgType: type_map.AddType(scope, &_ZipPluginArgs{}),
+	}
+}
+
+func (self _ZipPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := _ZipPluginArgs{}
+		err := arg_parser.ExtractArgs(scope, args, &arg)
+		if err != nil {
+			scope.Log("zip: %v", err)
+			return
+		}
+
+		// Cancel the queries when we are done with them.
+		sub_ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		lhs_chan := arg.Lhs.Eval(sub_ctx, scope)
+		rhs_chan := arg.Rhs.Eval(sub_ctx, scope)
+
+		for {
+			lhs, lhs_ok := zipNextRow(ctx, lhs_chan)
+			rhs, rhs_ok := zipNextRow(ctx, rhs_chan)
+
+			if !lhs_ok && !rhs_ok {
+				return
+			}
+
+			if (!lhs_ok || !rhs_ok) && !arg.Longest {
+				return
+			}
+
+			if !lhs_ok {
+				lhs = ordereddict.NewDict()
+			}
+
+			if !rhs_ok {
+				rhs = ordereddict.NewDict()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- joinRows(scope, lhs, rhs):
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+// Read the next row from the channel. Returns false when the channel
+// is exhausted or we are cancelled.
+func zipNextRow(ctx context.Context, in <-chan types.Row) (types.Row, bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case row, ok := <-in:
+		return row, ok
+	}
+}
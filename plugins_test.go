@@ -2,9 +2,11 @@ package vfilter
 
 import (
 	"context"
+	"runtime/pprof"
 	"testing"
 
 	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/plugins"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils"
 )
@@ -95,3 +97,126 @@ func TestPlugins(t *testing.T) {
 		}
 	}
 }
+
+func TestPluginProfilerLabels(t *testing.T) {
+	var query_label, plugin_label string
+
+	scope := NewScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "labels",
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			query_label, _ = pprof.Label(ctx, queryLabel)
+			plugin_label, _ = pprof.Label(ctx, pluginLabel)
+			return nil
+		},
+	})
+
+	sql, err := Parse("SELECT * FROM labels()")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	for range sql.Eval(context.Background(), scope) {
+	}
+
+	if query_label == "" || plugin_label != "labels" {
+		t.Fatalf("Plugin labels not set: %v %v", query_label, plugin_label)
+	}
+}
+
+type traceIdKey struct{}
+
+func TestPluginGoContextValues(t *testing.T) {
+	var trace_id interface{}
+	var has_scope bool
+
+	scope := NewScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "ctx_values",
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			trace_id = ctx.Value(traceIdKey{})
+			_, has_scope = types.GetScope(ctx)
+			return nil
+		},
+	})
+	scope.SetGoContextValue(traceIdKey{}, "trace-1234")
+
+	// Values are visible from child scopes too.
+	value, pres := scope.Copy().GetGoContextValue(traceIdKey{})
+	if !pres || value != "trace-1234" {
+		t.Fatalf("Go context value not found in child scope: %v", value)
+	}
+
+	sql, err := Parse("SELECT * FROM ctx_values()")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	for range sql.Eval(context.Background(), scope) {
+	}
+
+	if trace_id != "trace-1234" || !has_scope {
+		t.Fatalf("Go context values not passed to plugin: %v %v",
+			trace_id, has_scope)
+	}
+}
+
+func TestGoContextValuesAreShared(t *testing.T) {
+	scope := NewScope()
+	new_scope := scope.NewScope()
+	new_context := scope.Copy()
+	new_context.ClearContext()
+
+	// Values set later are seen by all the derived scopes.
+	scope.SetGoContextValue(traceIdKey{}, "trace-1234")
+	for _, derived := range []types.Scope{new_scope, new_context} {
+		value, pres := derived.GetGoContextValue(traceIdKey{})
+		if !pres || value != "trace-1234" {
+			t.Fatalf("Go context value not shared: %v", value)
+		}
+	}
+}
+
+func TestNestedQueryKeepsScope(t *testing.T) {
+	var inner_scope types.Scope
+
+	scope := NewScope()
+	scope.AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "inner",
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			inner_scope, _ = types.GetScope(ctx)
+			return nil
+		},
+	}, plugins.GenericListPlugin{
+		PluginName: "outer",
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			sql, _ := Parse("SELECT * FROM inner()")
+			for range sql.Eval(ctx, scope) {
+			}
+			return nil
+		},
+	})
+
+	sql, err := Parse("SELECT * FROM outer()")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	for range sql.Eval(context.Background(), scope) {
+	}
+
+	// The scope is only attached by the outer query.
+	if inner_scope != scope {
+		t.Fatalf("Nested query replaced the scope")
+	}
+}
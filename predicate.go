@@ -0,0 +1,353 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type PredicateKind string
+
+const (
+	PredicateAnd     PredicateKind = "and"
+	PredicateOr      PredicateKind = "or"
+	PredicateNot     PredicateKind = "not"
+	PredicateCompare PredicateKind = "compare"
+
+	// A part of the WHERE clause which can not be converted
+	// (e.g. function calls or arithmetic). Expression holds its VQL.
+	PredicateOpaque PredicateKind = "opaque"
+)
+
+// A normalized form of the WHERE clause which embedders can
+// translate into the native filter of a database or search index
+// backing the FROM source.
+//
+// Comparisons always have the field on the left: `5 < X` becomes
+// `X > 5`. The operators are =, !=, <, <=, >, >=, in and =~. The
+// value of an `in` comparison is a list of literals. Flags given to
+// =~ (e.g. WITH (multiline)) are added to its pattern as inline regex
+// flags.
+type Predicate struct {
+	Kind PredicateKind `json:"kind"`
+
+	// The terms of an and, or or not predicate.
+	Children []*Predicate `json:"children,omitempty"`
+
+	Field string    `json:"field,omitempty"`
+	Op    string    `json:"op,omitempty"`
+	Value types.Any `json:"value,omitempty"`
+
+	Expression string `json:"expression,omitempty"`
+}
+
+// Returns true if no part of the predicate is opaque.
+func (self *Predicate) Exact() bool {
+	if self.Kind == PredicateOpaque {
+		return false
+	}
+
+	for _, child := range self.Children {
+		if !child.Exact() {
+			return false
+		}
+	}
+	return true
+}
+
+// Convert the WHERE clause of the query to a predicate tree. Returns
+// nil if the query has no WHERE clause.
+//
+// The query still applies the full WHERE clause to the rows so an
+// embedder may push down any filter which selects a superset of the
+// rows: the exact terms of an AND may be pushed down on their own,
+// but an OR or NOT containing opaque terms can not.
+func (self *VQL) WherePredicate(scope types.Scope) (*Predicate, error) {
+	query := self.Query
+	if query == nil {
+		query = self.StoredQuery
+	}
+	if query == nil {
+		return nil, errors.New("WherePredicate: not a SELECT query")
+	}
+
+	if query.Where == nil {
+		return nil, nil
+	}
+
+	converter := &predicateConverter{
+		scope:   scope,
+		aliases: make(map[string]*_AndExpression),
+	}
+
+	if scope.WhereAliases() && query.SelectExpression != nil {
+		for _, expr := range query.SelectExpression.Expressions {
+			if expr.As != "" && expr.Expression != nil {
+				converter.aliases[expr.GetName(scope)] = expr.Expression
+			}
+		}
+	}
+
+	if len(query.Where.Right) > 0 {
+		return converter.opaque(query.Where), nil
+	}
+
+	return converter.and(query.Where.Left), nil
+}
+
+type predicateConverter struct {
+	scope types.Scope
+
+	// WHERE sees the SELECT aliases.
+	aliases map[string]*_AndExpression
+}
+
+func (self *predicateConverter) opaque(node interface{}) *Predicate {
+	return &Predicate{
+		Kind:       PredicateOpaque,
+		Expression: FormatToString(self.scope, node),
+	}
+}
+
+func (self *predicateConverter) and(expr *_AndExpression) *Predicate {
+	if len(expr.Right) == 0 {
+		return self.or(expr.Left)
+	}
+
+	result := &Predicate{Kind: PredicateAnd}
+	terms := []*_OrExpression{expr.Left}
+	for _, right := range expr.Right {
+		terms = append(terms, right.Term)
+	}
+
+	for _, term := range terms {
+		child := self.or(term)
+
+		// Flatten nested ANDs
+		if child.Kind == PredicateAnd {
+			result.Children = append(result.Children, child.Children...)
+		} else {
+			result.Children = append(result.Children, child)
+		}
+	}
+	return result
+}
+
+func (self *predicateConverter) or(expr *_OrExpression) *Predicate {
+	if len(expr.Right) == 0 {
+		return self.condition(expr.Left)
+	}
+
+	result := &Predicate{Kind: PredicateOr}
+	terms := []*_ConditionOperand{expr.Left}
+	for _, right := range expr.Right {
+		terms = append(terms, right.Term)
+	}
+
+	for _, term := range terms {
+		child := self.condition(term)
+		if child.Kind == PredicateOr {
+			result.Children = append(result.Children, child.Children...)
+		} else {
+			result.Children = append(result.Children, child)
+		}
+	}
+	return result
+}
+
+var flippedOperators = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+func (self *predicateConverter) condition(expr *_ConditionOperand) *Predicate {
+	if expr.Not != nil {
+		return &Predicate{
+			Kind:     PredicateNot,
+			Children: []*Predicate{self.condition(expr.Not)},
+		}
+	}
+
+	// A parenthesized expression
+	if expr.Right == nil {
+		value := singleValue(expr.Left)
+		if value != nil && value.Subexpression != nil &&
+			len(value.Subexpression.Right) == 0 {
+			return self.and(value.Subexpression.Left)
+		}
+		return self.opaque(expr)
+	}
+
+	op := strings.ToLower(expr.Right.Operator)
+	if op == "<>" {
+		op = "!="
+	}
+
+	field, field_ok := self.field(expr.Left)
+	value, value_ok := self.literal(expr.Right.Right)
+
+	switch op {
+	case "glob":
+		return self.opaque(expr)
+
+	case "in", "=~":
+		if !field_ok || !value_ok {
+			return self.opaque(expr)
+		}
+
+		// IN a single value may mean a substring match.
+		switch value.(type) {
+		case []types.Any:
+			if op != "in" {
+				return self.opaque(expr)
+			}
+		case string:
+			if op != "=~" {
+				return self.opaque(expr)
+			}
+
+			// Plugins see the flags as inline regex flags.
+			prefix, err := regexFlagsPrefix(expr.Right.Flags)
+			if err != nil {
+				return self.opaque(expr)
+			}
+			value = prefix + value.(string)
+		default:
+			return self.opaque(expr)
+		}
+
+	default:
+		if !field_ok || !value_ok {
+			// Maybe the literal is on the left.
+			field, field_ok = self.field(expr.Right.Right)
+			value, value_ok = self.literal(expr.Left)
+			if !field_ok || !value_ok {
+				return self.opaque(expr)
+			}
+			op = flippedOperators[op]
+		}
+
+		if _, ok := value.([]types.Any); ok {
+			return self.opaque(expr)
+		}
+	}
+
+	return &Predicate{
+		Kind:  PredicateCompare,
+		Field: field,
+		Op:    op,
+		Value: value,
+	}
+}
+
+// The addition expression if it consists of a single value,
+// including its member operators.
+func singleMember(expr *_AdditionExpression) *_MemberExpression {
+	if expr == nil || len(expr.Right) > 0 ||
+		expr.Left == nil || len(expr.Left.Right) > 0 {
+		return nil
+	}
+	return expr.Left.Left
+}
+
+func singleValue(expr *_AdditionExpression) *_Value {
+	member := singleMember(expr)
+	if member == nil || len(member.Right) > 0 {
+		return nil
+	}
+	return member.Left
+}
+
+// Returns the field name referred to by the expression, e.g. Foo or
+// Foo.Bar.
+func (self *predicateConverter) field(expr *_AdditionExpression) (string, bool) {
+	member := singleMember(expr)
+	if member == nil || member.Left == nil || member.Left.Negated {
+		return "", false
+	}
+
+	symbol := member.Left.SymbolRef
+	if symbol == nil || symbol.Called {
+		return "", false
+	}
+
+	name := symbol.Symbol
+
+	// Resolve aliases which rename a column.
+	alias, pres := self.aliases[name]
+	if pres {
+		if len(alias.Right) > 0 {
+			return "", false
+		}
+		condition := alias.Left.Left
+		if len(alias.Left.Right) > 0 || condition.Not != nil ||
+			condition.Right != nil {
+			return "", false
+		}
+
+		// Avoid loops with aliases to themselves.
+		aliases := self.aliases
+		self.aliases = nil
+		name, pres = self.field(condition.Left)
+		self.aliases = aliases
+		if !pres {
+			return "", false
+		}
+	}
+
+	parts := []string{name}
+	for _, term := range member.Right {
+		if term.Term == nil {
+			return "", false
+		}
+		parts = append(parts, *term.Term)
+	}
+
+	return strings.Join(parts, "."), true
+}
+
+// Returns the value of a literal expression. Parenthesized lists of
+// literals are returned as a list.
+func (self *predicateConverter) literal(expr *_AdditionExpression) (types.Any, bool) {
+	value := singleValue(expr)
+	if value == nil || value.SymbolRef != nil {
+		return nil, false
+	}
+
+	if value.Subexpression != nil {
+		terms := []*_AndExpression{value.Subexpression.Left}
+		for _, right := range value.Subexpression.Right {
+			if right.Term != nil {
+				terms = append(terms, right.Term)
+			}
+		}
+
+		var result []types.Any
+		for _, term := range terms {
+			if len(term.Right) > 0 || len(term.Left.Right) > 0 ||
+				term.Left.Left.Not != nil || term.Left.Left.Right != nil {
+				return nil, false
+			}
+
+			item, ok := self.literal(term.Left.Left.Left)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, item)
+		}
+
+		// (5) is just 5
+		if len(value.Subexpression.Right) == 0 {
+			return result[0], true
+		}
+		return result, true
+	}
+
+	return value.Reduce(context.Background(), self.scope), true
+}
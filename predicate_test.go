@@ -0,0 +1,81 @@
+package vfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWherePredicate(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	for _, test := range []struct {
+		query    string
+		expected string
+		exact    bool
+	}{
+		{"SELECT * FROM scope()", "null", true},
+		{"SELECT * FROM scope() WHERE Size > 5",
+			`{"kind":"compare","field":"Size","op":">","value":5}`, true},
+
+		// Literals on the left are flipped.
+		{"SELECT * FROM scope() WHERE 5 <= Size",
+			`{"kind":"compare","field":"Size","op":">=","value":5}`, true},
+		{"SELECT * FROM scope() WHERE Size > -5.5",
+			`{"kind":"compare","field":"Size","op":">","value":-5.5}`, true},
+		{"SELECT * FROM scope() WHERE Name <> 'a' AND (Size < 10 OR Size > 20)",
+			`{"kind":"and","children":[` +
+				`{"kind":"compare","field":"Name","op":"!=","value":"a"},` +
+				`{"kind":"or","children":[` +
+				`{"kind":"compare","field":"Size","op":"<","value":10},` +
+				`{"kind":"compare","field":"Size","op":">","value":20}]}]}`, true},
+		{"SELECT * FROM scope() WHERE A.B =~ 'foo' AND NOT C IN ('x', 'y')",
+			`{"kind":"and","children":[` +
+				`{"kind":"compare","field":"A.B","op":"=~","value":"foo"},` +
+				`{"kind":"not","children":[` +
+				`{"kind":"compare","field":"C","op":"in","value":["x","y"]}]}]}`, true},
+
+		// Regex flags are passed as inline flags. Globs are opaque.
+		{"SELECT * FROM scope() WHERE Name =~ '^a' WITH (case, multiline) AND Name GLOB '*.exe'",
+			`{"kind":"and","children":[` +
+				`{"kind":"compare","field":"Name","op":"=~","value":"(?-i)(?m)^a"},` +
+				`{"kind":"opaque","expression":"Name GLOB '*.exe'"}]}`, false},
+
+		// Aliases which rename a column are resolved.
+		{"SELECT Size AS Length, len(list=Name) AS NameLen FROM scope() " +
+			"WHERE Length = 1 AND NameLen > 3",
+			`{"kind":"and","children":[` +
+				`{"kind":"compare","field":"Size","op":"=","value":1},` +
+				`{"kind":"opaque","expression":"NameLen > 3"}]}`, false},
+
+		// Functions and arithmetic are opaque.
+		{"SELECT * FROM scope() WHERE Size + 1 > 5 OR IsDir",
+			`{"kind":"or","children":[` +
+				`{"kind":"opaque","expression":"Size + 1 > 5"},` +
+				`{"kind":"opaque","expression":"IsDir"}]}`, false},
+	} {
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+
+		predicate, err := vql.WherePredicate(scope)
+		assert.NoError(t, err)
+
+		serialized := &bytes.Buffer{}
+		encoder := json.NewEncoder(serialized)
+		encoder.SetEscapeHTML(false)
+		assert.NoError(t, encoder.Encode(predicate))
+		assert.Equal(t, test.expected+"\n", serialized.String(), test.query)
+		if predicate != nil {
+			assert.Equal(t, test.exact, predicate.Exact(), test.query)
+		}
+	}
+
+	vql, err := Parse("LET X = 1")
+	assert.NoError(t, err)
+
+	_, err = vql.WherePredicate(scope)
+	assert.Error(t, err)
+}
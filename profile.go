@@ -0,0 +1,89 @@
+package vfilter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// The accumulated time spent evaluating a single expression.
+type ProfileEntry struct {
+	Expression string
+	Duration   time.Duration
+	Count      uint64
+}
+
+type profileStat struct {
+	duration time.Duration
+	count    uint64
+}
+
+// A Profiler accumulates the wall time spent in each AST node. Install
+// it with scope.SetProfiler() before running the query then call
+// Snapshot() to retrieve the results.
+//
+// Times are inclusive - a column calling a slow function will
+// account for the time spent in the function too.
+type Profiler struct {
+	mu    sync.Mutex
+	nodes map[interface{}]*profileStat
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{
+		nodes: make(map[interface{}]*profileStat),
+	}
+}
+
+func (self *Profiler) Record(ast_node interface{}, duration time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	stat, pres := self.nodes[ast_node]
+	if !pres {
+		stat = &profileStat{}
+		self.nodes[ast_node] = stat
+	}
+	stat.duration += duration
+	stat.count++
+}
+
+// Returns the profile sorted by total duration, slowest first.
+func (self *Profiler) Snapshot(scope types.Scope) []*ProfileEntry {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	result := make([]*ProfileEntry, 0, len(self.nodes))
+	for node, stat := range self.nodes {
+		result = append(result, &ProfileEntry{
+			Expression: FormatToString(scope, node),
+			Duration:   stat.duration,
+			Count:      stat.count,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Duration == result[j].Duration {
+			return result[i].Expression < result[j].Expression
+		}
+		return result[i].Duration > result[j].Duration
+	})
+
+	return result
+}
+
+// Start timing the AST node. The returned function must be called
+// when the evaluation is done.
+func profileNode(scope types.Scope, ast_node interface{}) func() {
+	profiler := scope.Profiler()
+	if profiler == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		profiler.Record(ast_node, time.Since(start))
+	}
+}
@@ -0,0 +1,40 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiler(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	profiler := NewProfiler()
+	scope.SetProfiler(profiler)
+
+	vql, err := Parse(
+		"SELECT foo, 'hello' =~ 'ell' AS Match FROM test() WHERE bar > 0")
+	assert.NoError(t, err)
+
+	for range vql.Eval(ctx, scope) {
+	}
+
+	// Nested nodes may format to the same expression (e.g. the
+	// WHERE clause and its only condition) but they are reported
+	// separately.
+	counts := make(map[string][]uint64)
+	for _, entry := range profiler.Snapshot(scope) {
+		counts[entry.Expression] = append(
+			counts[entry.Expression], entry.Count)
+	}
+
+	// test() produces 3 rows - the WHERE clause is evaluated for
+	// each row but only 2 rows pass so columns are evaluated twice.
+	assert.Equal(t, []uint64{3, 3}, counts["bar > 0"])
+	assert.Equal(t, []uint64{2}, counts["foo"])
+	assert.Equal(t, []uint64{2}, counts["'hello' =~ 'ell' AS Match"])
+	assert.Equal(t, []uint64{2}, counts["'hello' =~ 'ell'"])
+}
@@ -0,0 +1,152 @@
+package vfilter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type cachedObject struct{}
+
+// Only handles the Special member and counts how often it is asked.
+type _SpecialAssociative struct {
+	calls *int64
+}
+
+func (self _SpecialAssociative) TypeOnly() {}
+
+func (self _SpecialAssociative) Applicable(a Any, b Any) bool {
+	atomic.AddInt64(self.calls, 1)
+	_, a_ok := a.(cachedObject)
+	return a_ok && b == "Special"
+}
+
+func (self _SpecialAssociative) Associative(
+	scope types.Scope, a Any, b Any) (Any, bool) {
+	return "special", true
+}
+
+func (self _SpecialAssociative) GetMembers(scope types.Scope, a Any) []string {
+	return []string{"Special"}
+}
+
+type _OtherAssociative struct {
+	calls *int64
+	value string
+}
+
+func (self _OtherAssociative) TypeOnly() {}
+
+func (self _OtherAssociative) Applicable(a Any, b Any) bool {
+	atomic.AddInt64(self.calls, 1)
+	_, a_ok := a.(cachedObject)
+	return a_ok
+}
+
+func (self _OtherAssociative) Associative(
+	scope types.Scope, a Any, b Any) (Any, bool) {
+	return self.value, true
+}
+
+func (self _OtherAssociative) GetMembers(scope types.Scope, a Any) []string {
+	return nil
+}
+
+func TestProtocolDispatchCache(t *testing.T) {
+	scope := makeTestScope().
+		AppendVars(ordereddict.NewDict().Set("Obj", cachedObject{}))
+	defer scope.Close()
+
+	var special_calls, other_calls int64
+	scope.AddProtocolImpl(_OtherAssociative{calls: &other_calls, value: "other"})
+	scope.AddProtocolImpl(_SpecialAssociative{calls: &special_calls})
+
+	vql, err := Parse(`SELECT Obj.Name AS Name, Obj.Special AS Special
+FROM foreach(row=range(end=10))`)
+	assert.NoError(t, err)
+
+	var rows []Row
+	for row := range vql.Eval(context.Background(), scope) {
+		rows = append(rows, row)
+	}
+	assert.Equal(t, 10, len(rows))
+
+	// Each member is dispatched by its own implementation.
+	for _, row := range rows {
+		name, _ := row.(*ordereddict.Dict).Get("Name")
+		special, _ := row.(*ordereddict.Dict).Get("Special")
+		assert.Equal(t, "other", name)
+		assert.Equal(t, "special", special)
+	}
+
+	// After the first row only the cached implementation is asked.
+	assert.Equal(t, int64(11), special_calls)
+	assert.Equal(t, int64(10), other_calls)
+
+	// Adding an implementation takes precedence over cached lookups.
+	scope.AddProtocolImpl(_OtherAssociative{calls: &other_calls, value: "new"})
+	value, _ := scope.Associative(cachedObject{}, "Name")
+	assert.Equal(t, "new", value)
+}
+
+// Compares times with strings which parse as times.
+type _ParsedTimeEq struct{}
+
+func (self _ParsedTimeEq) Applicable(a Any, b Any) bool {
+	_, a_ok := a.(time.Time)
+	str, b_ok := b.(string)
+	if !a_ok || !b_ok {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339, str)
+	return err == nil
+}
+
+func (self _ParsedTimeEq) Eq(scope types.Scope, a Any, b Any) bool {
+	b_time, _ := time.Parse(time.RFC3339, b.(string))
+	return a.(time.Time).Equal(b_time)
+}
+
+// Other strings are never equal to a time.
+type _StringTimeEq struct{}
+
+func (self _StringTimeEq) TypeOnly() {}
+
+func (self _StringTimeEq) Applicable(a Any, b Any) bool {
+	_, a_ok := a.(time.Time)
+	_, b_ok := b.(string)
+	return a_ok && b_ok
+}
+
+func (self _StringTimeEq) Eq(scope types.Scope, a Any, b Any) bool {
+	return false
+}
+
+func TestProtocolDispatchCacheDependsOnValues(t *testing.T) {
+	scope := makeTestScope().
+		AppendVars(ordereddict.NewDict().
+			Set("Time", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	defer scope.Close()
+
+	scope.AddProtocolImpl(_StringTimeEq{})
+	scope.AddProtocolImpl(_ParsedTimeEq{})
+
+	vql, err := Parse(`SELECT Time = _value AS Equal
+FROM foreach(row=["bad", "2020-01-01T00:00:00Z", "bad", "2020-01-01T00:00:00Z"])`)
+	assert.NoError(t, err)
+
+	var results []Any
+	for row := range vql.Eval(context.Background(), scope) {
+		equal, _ := row.(*ordereddict.Dict).Get("Equal")
+		results = append(results, equal)
+	}
+
+	// The unparseable string must not cause the parseable ones to
+	// skip _ParsedTimeEq.
+	assert.Equal(t, []Any{false, true, false, true}, results)
+}
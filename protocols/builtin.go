@@ -8,7 +8,8 @@ func GetBuiltinTypes() []types.Any {
 	return []types.Any{
 		// Commented out protocols below are inlined for performance.
 
-		// Most common objects come first to optimise O(n) algorithm.
+		// Most common objects come first to optimise the O(n)
+		// search on the first dispatch of each type.
 		// _ScopeAssociative{},	_Lazytypes.RowAssociative{}, _DictAssociative{}, _types.NullAssociative{},
 		_StoredQueryAssociative{},
 
@@ -18,17 +19,21 @@ func GetBuiltinTypes() []types.Any {
 
 		// _types.NullEqProtocol{}, _StringEq{}, _IntEq{}, _NumericEq{},
 		// _ArrayEq{},
-		_DictEq{},
+		_DictEq{}, _TimeEq{}, _DurationEq{},
 
 		// _NumericLt{}, _StringLt{},
+		_TimeLt{}, _TimeGt{}, _DurationLt{}, _DurationGt{},
 
 		// _AddStrings{}, _AddInts{}, _AddFloats{}, _AddSlices{}, _AddSliceAny{}, _AddNull{},
-		_StoredQueryAdd{},
+		_StoredQueryAdd{}, _TimeAdd{}, _DurationAdd{},
 
 		// _SubInts{}, _SubFloats{},
+		_TimeSub{}, _DurationSub{},
 		//_SubstringMembership{},
+		_RangeMembership{},
 
 		// _MulInt{}, _NumericMul{},
+		_DurationMul{},
 		// _NumericDiv{},
 
 		// _SubstringRegex{},
@@ -36,5 +41,6 @@ func GetBuiltinTypes() []types.Any {
 		// _ArrayRegex{},
 
 		// _SliceIterator{}, // _LazyExprIterator{}, _StoredQueryIterator{}, _DictIterator{},
+		_NDJSONIterator{}, _ChannelIterator{}, _RangeIterator{},
 	}
 }
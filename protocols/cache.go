@@ -0,0 +1,110 @@
+package protocols
+
+import (
+	"reflect"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Stop remembering new keys past this many entries (e.g. when
+// dereferencing many computed member names).
+const maxProtocolCacheSize = 10000
+
+type protocolCacheKey struct {
+	a, b reflect.Type
+
+	// The member name for the Associative protocol since
+	// implementations often handle only some members of a type.
+	member string
+}
+
+// Remembers which implementation of a protocol applied to each pair
+// of types so dispatching does not need to scan all the
+// implementations each time. The remembered implementation is still
+// asked if it is applicable on each call. Implementations before it
+// are skipped so an implementation is only remembered when all the
+// ones before it are TypeOnlyProtocol - otherwise an earlier
+// implementation which depends on the values (e.g. parsing a string
+// as a time) may be skipped.
+//
+// Dispatcher copies made for a new context share the cache. Adding an
+// implementation replaces the cache rather than clearing it so those
+// copies keep their (still valid) entries.
+type protocolCache struct {
+	mu   sync.RWMutex
+	impl map[protocolCacheKey]int
+}
+
+// Implementations whose Applicable() only depends on the types of
+// its args (and the member name for Associative) declare it by
+// implementing this interface. This allows the protocol cache to
+// skip them for types they were not applicable to before.
+type TypeOnlyProtocol interface {
+	TypeOnly()
+}
+
+func isTypeOnly(impl types.Any) bool {
+	_, ok := impl.(TypeOnlyProtocol)
+	return ok
+}
+
+func newProtocolCache() *protocolCache {
+	return &protocolCache{
+		impl: make(map[protocolCacheKey]int),
+	}
+}
+
+func newProtocolCacheKey(a, b types.Any) protocolCacheKey {
+	return protocolCacheKey{a: reflect.TypeOf(a), b: reflect.TypeOf(b)}
+}
+
+func newAssociativeCacheKey(a, b types.Any) protocolCacheKey {
+	key := newProtocolCacheKey(a, b)
+	key.member, _ = b.(string)
+	return key
+}
+
+// Find the first of count implementations which is applicable. The
+// cache may be nil for dispatchers with no implementations added.
+func (self *protocolCache) find(key protocolCacheKey, count int,
+	applicable func(i int) bool, impl func(i int) types.Any) (int, bool) {
+	if self == nil {
+		for i := 0; i < count; i++ {
+			if applicable(i) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	self.mu.RLock()
+	i, pres := self.impl[key]
+	self.mu.RUnlock()
+
+	if pres && i < count && applicable(i) {
+		return i, true
+	}
+
+	// Whether the implementations tried so far were not applicable
+	// for any values of these types.
+	type_only := true
+	for i := 0; i < count; i++ {
+		if applicable(i) {
+			if type_only {
+				self.mu.Lock()
+				if len(self.impl) < maxProtocolCacheSize {
+					self.impl[key] = i
+				}
+				self.mu.Unlock()
+			}
+			return i, true
+		}
+
+		if !isTypeOnly(impl(i)) {
+			type_only = false
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package protocols
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Iterate over a Go channel. This allows Go applications to pipe live
+// data into a running query by placing a channel in the scope:
+//
+//	scope.AppendVars(ordereddict.NewDict().Set("Events", events_chan))
+//	SELECT * FROM Events
+//
+// Values which can not be used as rows (e.g. ints or strings) are
+// placed in the _value column. The query ends when the channel is
+// closed.
+type _ChannelIterator struct{}
+
+func (self _ChannelIterator) TypeOnly() {}
+
+func (self _ChannelIterator) Applicable(a types.Any) bool {
+	return utils.IsChannel(a)
+}
+
+func (self _ChannelIterator) Iterate(
+	ctx context.Context, scope types.Scope, a types.Any) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		cases := []reflect.SelectCase{{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ctx.Done()),
+		}, {
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(a),
+		}}
+
+		for {
+			chosen, value, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+
+			item := value.Interface()
+			if types.IsNullObject(item) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- channelRow(item):
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+func channelRow(item types.Any) types.Row {
+	switch item.(type) {
+	case *ordereddict.Dict, types.LazyRow:
+		return item
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(item)).Kind() {
+	case reflect.Struct, reflect.Map:
+		return item
+	}
+
+	return ordereddict.NewDict().Set("_value", item)
+}
@@ -6,6 +6,19 @@ import (
 	"www.velocidex.com/golang/vfilter/types"
 )
 
+// NULL propagation rules:
+//
+//   - Arithmetic (+, -, *, /) with a NULL operand produces NULL. The
+//     exception is array concatenation which appends the NULL.
+//   - Arithmetic on types no protocol handles produces NULL, as does
+//     division by zero.
+//   - Comparisons (<, >) with NULL are false. NULL = NULL is true and
+//     NULL is not equal to anything else.
+//   - NULL is false in a boolean context and is never a member of
+//     anything.
+//
+// Use coalesce() to substitute a default for NULL.
+
 func maybeReduce(a types.Any) types.Any {
 	lazy_expr, ok := a.(types.LazyExpr)
 	if ok {
@@ -0,0 +1,68 @@
+package protocols
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Iterate over NDJSON (newline delimited JSON) streams. Each line of
+// the stream is parsed as a JSON object and emitted as a row. Readers
+// wrapped in a types.NDJSONSource may be used directly as a source
+// of rows:
+//
+//	scope.AppendVars(ordereddict.NewDict().
+//	    Set("Stream", types.NewNDJSONSource(reader)))
+//	SELECT * FROM Stream
+type _NDJSONIterator struct{}
+
+func (self _NDJSONIterator) TypeOnly() {}
+
+func (self _NDJSONIterator) Applicable(a types.Any) bool {
+	_, ok := a.(*types.NDJSONSource)
+	return ok
+}
+
+func (self _NDJSONIterator) Iterate(
+	ctx context.Context, scope types.Scope, a types.Any) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	source, ok := a.(*types.NDJSONSource)
+	if !ok || source.Scanner == nil {
+		close(output_chan)
+		return output_chan
+	}
+	scanner := source.Scanner
+
+	go func() {
+		defer close(output_chan)
+
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			row := ordereddict.NewDict()
+			err := row.UnmarshalJSON(line)
+			if err != nil {
+				scope.Log("ERROR:NDJSON: Unable to parse line: %v", err)
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+
+		err := scanner.Err()
+		if err != nil {
+			scope.Log("ERROR:NDJSON: %v", err)
+		}
+	}()
+
+	return output_chan
+}
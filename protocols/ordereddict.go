@@ -46,6 +46,8 @@ func to_dict(a types.Any) (*ordereddict.Dict, bool) {
 	}
 }
 
+func (self _DictEq) TypeOnly() {}
+
 func (self _DictEq) Applicable(a types.Any, b types.Any) bool {
 	_, a_ok := to_dict(a)
 	_, b_ok := to_dict(b)
@@ -2,6 +2,8 @@ package protocols
 
 import (
 	"reflect"
+	"strconv"
+	"time"
 
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils"
@@ -14,12 +16,15 @@ type AddProtocol interface {
 }
 
 type AddDispatcher struct {
-	impl []AddProtocol
+	impl  []AddProtocol
+	cache *protocolCache
 }
 
 func (self AddDispatcher) Copy() AddDispatcher {
 	return AddDispatcher{
-		append([]AddProtocol{}, self.impl...)}
+		impl:  append([]AddProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self AddDispatcher) Add(scope types.Scope, a types.Any, b types.Any) types.Any {
@@ -41,6 +46,12 @@ func (self AddDispatcher) Add(scope types.Scope, a types.Any, b types.Any) types
 			return t + b_str
 		}
 
+		// 'Count: ' + 5 stringifies the number.
+		b_str, ok = stringify(b)
+		if ok {
+			return t + b_str
+		}
+
 	case types.Null, *types.Null, nil:
 		return &types.Null{}
 
@@ -51,6 +62,20 @@ func (self AddDispatcher) Add(scope types.Scope, a types.Any, b types.Any) types
 		}
 	}
 
+	// Arrays may be concatenated with NULL.
+	if types.IsNullObject(b) && !is_array(a) {
+		return &types.Null{}
+	}
+
+	// 5 + ' items' stringifies the number.
+	b_str, ok := b.(string)
+	if ok {
+		a_str, ok := stringify(a)
+		if ok {
+			return a_str + b_str
+		}
+	}
+
 	// Maybe its an integer.
 	a_int, ok := utils.ToInt64(a)
 	if ok {
@@ -60,11 +85,16 @@ func (self AddDispatcher) Add(scope types.Scope, a types.Any, b types.Any) types
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Add(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Add(scope, a, b)
 	}
 
 	// Handle array concatenation
@@ -84,6 +114,36 @@ func (self *AddDispatcher) AddImpl(elements ...AddProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]AddProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
+}
+
+// Scalars are converted to strings when added to a string. Other
+// types (e.g. arrays, dicts and NULL) are not stringified.
+func stringify(a types.Any) (string, bool) {
+	switch t := a.(type) {
+	case bool:
+		return strconv.FormatBool(t), true
+
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+
+	case time.Time:
+		return t.Format(time.RFC3339Nano), true
+
+	case *time.Time:
+		return t.Format(time.RFC3339Nano), true
+
+	case time.Duration:
+		return t.String(), true
+	}
+
+	value, ok := utils.ToInt64(a)
+	if ok {
+		return strconv.FormatInt(value, 10), true
+	}
+
+	return "", false
 }
 
 func convertToSlice(a types.Any) []types.Any {
@@ -23,12 +23,15 @@ type AssociativeProtocol interface {
 }
 
 type AssociativeDispatcher struct {
-	impl []AssociativeProtocol
+	impl  []AssociativeProtocol
+	cache *protocolCache
 }
 
 func (self AssociativeDispatcher) Copy() AssociativeDispatcher {
 	return AssociativeDispatcher{
-		append([]AssociativeProtocol{}, self.impl...)}
+		impl:  append([]AssociativeProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self *AssociativeDispatcher) Associative(
@@ -90,12 +93,17 @@ func (self *AssociativeDispatcher) Associative(
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			res, pres := impl.Associative(scope, a, b)
-			return res, pres
-		}
+	i, ok := self.cache.find(newAssociativeCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		res, pres := impl.Associative(scope, a, b)
+		return res, pres
 	}
 	res, pres := DefaultAssociative{}.Associative(scope, a, b)
 	return res, pres
@@ -121,11 +129,16 @@ func (self *AssociativeDispatcher) GetMembers(
 		return t.Members()
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, "") {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.GetMembers(scope, a)
-		}
+	i, ok := self.cache.find(newAssociativeCacheKey(a, ""), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, "")
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.GetMembers(scope, a)
 	}
 	return DefaultAssociative{}.GetMembers(scope, a)
 }
@@ -137,6 +150,8 @@ func (self *AssociativeDispatcher) AddImpl(elements ...AssociativeProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]AssociativeProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
 
 // Last resort associative - uses reflect package to resolve struct
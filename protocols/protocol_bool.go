@@ -9,12 +9,15 @@ import (
 )
 
 type BoolDispatcher struct {
-	impl []BoolProtocol
+	impl  []BoolProtocol
+	cache *protocolCache
 }
 
 func (self BoolDispatcher) Copy() BoolDispatcher {
 	return BoolDispatcher{
-		append([]BoolProtocol{}, self.impl...)}
+		impl:  append([]BoolProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self BoolDispatcher) Bool(ctx context.Context, scope types.Scope, a types.Any) bool {
@@ -64,11 +67,16 @@ func (self BoolDispatcher) Bool(ctx context.Context, scope types.Scope, a types.
 		return value_a.Len() > 0
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Bool(ctx, scope, a)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, nil), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Bool(ctx, scope, a)
 	}
 
 	scope.Trace("Protocol Bool not found for %v (%T)", a, a)
@@ -79,6 +87,8 @@ func (self *BoolDispatcher) AddImpl(elements ...BoolProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]BoolProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
 
 // This protocol implements the truth value.
@@ -12,16 +12,20 @@ type DivProtocol interface {
 }
 
 type DivDispatcher struct {
-	impl []DivProtocol
+	impl  []DivProtocol
+	cache *protocolCache
 }
 
 func (self DivDispatcher) Copy() DivDispatcher {
 	return DivDispatcher{
-		append([]DivProtocol{}, self.impl...)}
+		impl:  append([]DivProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self DivDispatcher) Div(scope types.Scope, a types.Any, b types.Any) types.Any {
 	a = maybeReduce(a)
+	b = maybeReduce(b)
 
 	switch t := a.(type) {
 	case types.Null, *types.Null, nil:
@@ -44,7 +48,7 @@ func (self DivDispatcher) Div(scope types.Scope, a types.Any, b types.Any) types
 	case float64:
 		a_float, ok := utils.ToFloat(a)
 		if ok {
-			if a_float == 0 {
+			if t == 0 {
 				return &types.Null{}
 			}
 			return a_float / t
@@ -63,11 +67,16 @@ func (self DivDispatcher) Div(scope types.Scope, a types.Any, b types.Any) types
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Div(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Div(scope, a, b)
 	}
 
 	scope.Trace("Protocol Div not found for %v (%T) and %v (%T)",
@@ -80,4 +89,6 @@ func (self *DivDispatcher) AddImpl(elements ...DivProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]DivProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
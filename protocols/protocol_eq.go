@@ -1,6 +1,7 @@
 package protocols
 
 import (
+	"math"
 	"reflect"
 	"time"
 
@@ -15,12 +16,28 @@ type EqProtocol interface {
 }
 
 type EqDispatcher struct {
-	impl []EqProtocol
+	impl  []EqProtocol
+	cache *protocolCache
+
+	// Floats within this tolerance of each other compare equal. A
+	// zero epsilon means exact comparison.
+	epsilon float64
 }
 
 func (self EqDispatcher) Copy() EqDispatcher {
 	return EqDispatcher{
-		append([]EqProtocol{}, self.impl...)}
+		impl:    append([]EqProtocol{}, self.impl...),
+		cache:   newProtocolCache(),
+		epsilon: self.epsilon,
+	}
+}
+
+func (self *EqDispatcher) SetEpsilon(epsilon float64) {
+	self.epsilon = math.Abs(epsilon)
+}
+
+func (self EqDispatcher) Epsilon() float64 {
+	return self.epsilon
 }
 
 func (self EqDispatcher) Eq(scope types.Scope, a types.Any, b types.Any) bool {
@@ -46,7 +63,7 @@ func (self EqDispatcher) Eq(scope types.Scope, a types.Any, b types.Any) bool {
 	case float64:
 		rhs, ok := utils.ToFloat(b)
 		if ok {
-			return t == rhs
+			return self.floatEq(t, rhs)
 		}
 
 	case time.Time:
@@ -62,6 +79,17 @@ func (self EqDispatcher) Eq(scope types.Scope, a types.Any, b types.Any) bool {
 		}
 	}
 
+	// With a tolerance, comparing an int to a float must not
+	// truncate the float. Without one we keep the exact integer
+	// comparison.
+	rhs_float, ok := b.(float64)
+	if ok && self.epsilon > 0 {
+		lhs, ok := utils.ToFloat(a)
+		if ok {
+			return self.floatEq(lhs, rhs_float)
+		}
+	}
+
 	lhs, ok := utils.ToInt64(a)
 	if ok {
 		rhs, ok := utils.ToInt64(b)
@@ -74,11 +102,16 @@ func (self EqDispatcher) Eq(scope types.Scope, a types.Any, b types.Any) bool {
 		return _ArrayEq(scope, a, b)
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Eq(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Eq(scope, a, b)
 	}
 
 	scope.Trace("Protocol Equal not found for %v (%T) and %v (%T)",
@@ -86,10 +119,19 @@ func (self EqDispatcher) Eq(scope types.Scope, a types.Any, b types.Any) bool {
 	return false
 }
 
+func (self EqDispatcher) floatEq(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= self.epsilon
+}
+
 func (self *EqDispatcher) AddImpl(elements ...EqProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]EqProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
 
 func _ArrayEq(scope types.Scope, a types.Any, b types.Any) bool {
@@ -0,0 +1,123 @@
+package protocols
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Glob Match protocol (the GLOB operator)
+type GlobProtocol interface {
+	Applicable(pattern types.Any, target types.Any) bool
+	Glob(scope types.Scope, pattern types.Any, target types.Any) bool
+}
+
+type GlobDispatcher struct {
+	impl  []GlobProtocol
+	cache *protocolCache
+}
+
+func (self GlobDispatcher) Copy() GlobDispatcher {
+	return GlobDispatcher{
+		impl:  append([]GlobProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
+}
+
+func (self GlobDispatcher) Glob(scope types.Scope, pattern types.Any, target types.Any) bool {
+	target = maybeReduce(target)
+
+	switch t := pattern.(type) {
+	case string:
+		target_str, ok := target.(string)
+		if ok {
+			return Match(scope, GlobToRegex(t), target_str)
+		}
+
+	// A glob already compiled to a regex.
+	case *regexp.Regexp:
+		target_str, ok := target.(string)
+		if ok {
+			return t.MatchString(target_str)
+		}
+	}
+
+	switch pattern.(type) {
+	case string, *regexp.Regexp:
+		if is_array(target) {
+			a_slice := reflect.ValueOf(target)
+			for i := 0; i < a_slice.Len(); i++ {
+				if scope.Glob(pattern, a_slice.Index(i).Interface()) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	i, ok := self.cache.find(newProtocolCacheKey(pattern, target), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(pattern, target)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Glob(scope, pattern, target)
+	}
+
+	scope.Trace("Protocol Glob not found for %v (%T) and %v (%T)",
+		pattern, pattern, target, target)
+
+	return false
+}
+
+func (self *GlobDispatcher) AddImpl(elements ...GlobProtocol) {
+	for _, impl := range elements {
+		self.impl = append([]GlobProtocol{impl}, self.impl...)
+	}
+
+	self.cache = newProtocolCache()
+}
+
+// Convert a glob to an equivalent regex which matches the whole
+// string. * matches any characters (including path separators), ?
+// matches a single character and [abc], [a-z] or [!abc] match a
+// character class. Everything else matches literally.
+func GlobToRegex(glob string) string {
+	result := &strings.Builder{}
+	result.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			result.WriteString(".*")
+
+		case '?':
+			result.WriteString(".")
+
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				result.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			result.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+
+		default:
+			result.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	result.WriteString("$")
+	return result.String()
+}
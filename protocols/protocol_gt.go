@@ -14,12 +14,15 @@ type GtProtocol interface {
 }
 
 type GtDispatcher struct {
-	impl []GtProtocol
+	impl  []GtProtocol
+	cache *protocolCache
 }
 
 func (self GtDispatcher) Copy() GtDispatcher {
 	return GtDispatcher{
-		append([]GtProtocol{}, self.impl...)}
+		impl:  append([]GtProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self GtDispatcher) Gt(scope types.Scope, a types.Any, b types.Any) bool {
@@ -60,11 +63,16 @@ func (self GtDispatcher) Gt(scope types.Scope, a types.Any, b types.Any) bool {
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Gt(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Gt(scope, a, b)
 	}
 
 	return false
@@ -74,4 +82,6 @@ func (self *GtDispatcher) AddImpl(elements ...GtProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]GtProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
@@ -10,12 +10,15 @@ import (
 
 // The Iterator protocol allows types to be iterated over.
 type IterateDispatcher struct {
-	impl []IterateProtocol
+	impl  []IterateProtocol
+	cache *protocolCache
 }
 
 func (self IterateDispatcher) Copy() IterateDispatcher {
 	return IterateDispatcher{
-		append([]IterateProtocol{}, self.impl...)}
+		impl:  append([]IterateProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self IterateDispatcher) Iterate(
@@ -50,11 +53,16 @@ func (self IterateDispatcher) Iterate(
 		return _SliceIterator(ctx, scope, a)
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Iterate(ctx, scope, a)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, nil), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Iterate(ctx, scope, a)
 	}
 
 	scope.Trace("Protocol Iterate not found for %v (%T)", a, a)
@@ -81,6 +89,8 @@ func (self *IterateDispatcher) AddImpl(elements ...IterateProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]IterateProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
 
 // This protocol implements the truth value.
@@ -14,12 +14,15 @@ type LtProtocol interface {
 }
 
 type LtDispatcher struct {
-	impl []LtProtocol
+	impl  []LtProtocol
+	cache *protocolCache
 }
 
 func (self LtDispatcher) Copy() LtDispatcher {
 	return LtDispatcher{
-		append([]LtProtocol{}, self.impl...)}
+		impl:  append([]LtProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self LtDispatcher) Lt(scope types.Scope, a types.Any, b types.Any) bool {
@@ -63,11 +66,16 @@ func (self LtDispatcher) Lt(scope types.Scope, a types.Any, b types.Any) bool {
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Lt(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Lt(scope, a, b)
 	}
 
 	return false
@@ -88,4 +96,6 @@ func (self *LtDispatcher) AddImpl(elements ...LtProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]LtProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
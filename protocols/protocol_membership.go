@@ -14,12 +14,15 @@ type MembershipProtocol interface {
 }
 
 type MembershipDispatcher struct {
-	impl []MembershipProtocol
+	impl  []MembershipProtocol
+	cache *protocolCache
 }
 
 func (self MembershipDispatcher) Copy() MembershipDispatcher {
 	return MembershipDispatcher{
-		append([]MembershipProtocol{}, self.impl...)}
+		impl:  append([]MembershipProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self MembershipDispatcher) Membership(scope types.Scope, a types.Any, b types.Any) bool {
@@ -38,11 +41,16 @@ func (self MembershipDispatcher) Membership(scope types.Scope, a types.Any, b ty
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Membership(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Membership(scope, a, b)
 	}
 
 	// Default behavior: Test lhs against each member in RHS -
@@ -73,4 +81,6 @@ func (self *MembershipDispatcher) AddImpl(elements ...MembershipProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]MembershipProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
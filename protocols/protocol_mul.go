@@ -14,12 +14,15 @@ type MulProtocol interface {
 }
 
 type MulDispatcher struct {
-	impl []MulProtocol
+	impl  []MulProtocol
+	cache *protocolCache
 }
 
 func (self MulDispatcher) Copy() MulDispatcher {
 	return MulDispatcher{
-		append([]MulProtocol{}, self.impl...)}
+		impl:  append([]MulProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self MulDispatcher) Mul(scope types.Scope, a types.Any, b types.Any) types.Any {
@@ -33,13 +36,7 @@ func (self MulDispatcher) Mul(scope types.Scope, a types.Any, b types.Any) types
 	case string:
 		b_int, ok := utils.ToInt64(b)
 		if ok {
-			// Estimate how much memory we will use when duplicating the string
-			memory := len(t) * int(b_int)
-			if memory > 100000000 { // 100mb
-				scope.Log("Multiply Str x Int exceeded memory limits")
-				return &types.Null{}
-			}
-			return strings.Repeat(t, int(b_int))
+			return repeatString(scope, t, b_int)
 		}
 
 	case float64:
@@ -53,6 +50,13 @@ func (self MulDispatcher) Mul(scope types.Scope, a types.Any, b types.Any) types
 	case types.Null, *types.Null, nil:
 		return &types.Null{}
 
+	case string:
+		// 3 * 'ab' is the same as 'ab' * 3
+		a_int, ok := utils.ToInt64(a)
+		if ok {
+			return repeatString(scope, t, a_int)
+		}
+
 	case float64:
 		a_float, ok := utils.ToFloat(a)
 		if ok {
@@ -68,11 +72,16 @@ func (self MulDispatcher) Mul(scope types.Scope, a types.Any, b types.Any) types
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Mul(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Mul(scope, a, b)
 	}
 	scope.Trace("Protocol Mul not found for %v (%T) and %v (%T)",
 		a, a, b, b)
@@ -80,8 +89,26 @@ func (self MulDispatcher) Mul(scope types.Scope, a types.Any, b types.Any) types
 	return types.Null{}
 }
 
+// Repeat a string count times. Negative counts produce an empty
+// string.
+func repeatString(scope types.Scope, a string, count int64) types.Any {
+	if count <= 0 {
+		return ""
+	}
+
+	// Estimate how much memory we will use when duplicating the string
+	memory := int64(len(a)) * count
+	if memory > 100000000 { // 100mb
+		scope.Log("Multiply Str x Int exceeded memory limits")
+		return &types.Null{}
+	}
+	return strings.Repeat(a, int(count))
+}
+
 func (self *MulDispatcher) AddImpl(elements ...MulProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]MulProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
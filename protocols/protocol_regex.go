@@ -14,32 +14,45 @@ type RegexProtocol interface {
 }
 
 type RegexDispatcher struct {
-	impl []RegexProtocol
+	impl  []RegexProtocol
+	cache *protocolCache
 }
 
 func (self RegexDispatcher) Copy() RegexDispatcher {
 	return RegexDispatcher{
-		append([]RegexProtocol{}, self.impl...)}
+		impl:  append([]RegexProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self RegexDispatcher) Match(scope types.Scope, pattern types.Any, target types.Any) bool {
 	target = maybeReduce(target)
 
-	pattern_str, ok := pattern.(string)
-	if ok {
+	switch t := pattern.(type) {
+	case string:
 		// Shortcut the match all operator - ignore LHS and just
 		// return TRUE. This allows a default regex to be provided
 		// which just skips all matches transparently.
-		switch pattern_str {
-		case ".", ".*", "":
+		if IsMatchAll(t) {
 			return true
 		}
 
-		switch t := target.(type) {
-		case string:
-			return Match(scope, pattern_str, t)
+		target_str, ok := target.(string)
+		if ok {
+			return Match(scope, t, target_str)
 		}
 
+	// Precompiled regexes match as they are (i.e. they are only
+	// case insensitive if compiled with (?i)).
+	case *regexp.Regexp:
+		target_str, ok := target.(string)
+		if ok {
+			return t.MatchString(target_str)
+		}
+	}
+
+	switch pattern.(type) {
+	case string, *regexp.Regexp:
 		if is_array(target) {
 			a_slice := reflect.ValueOf(target)
 			for i := 0; i < a_slice.Len(); i++ {
@@ -51,11 +64,16 @@ func (self RegexDispatcher) Match(scope types.Scope, pattern types.Any, target t
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(pattern, target) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Match(scope, pattern, target)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(pattern, target), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(pattern, target)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Match(scope, pattern, target)
 	}
 
 	scope.Trace("Protocol Regex not found for %v (%T) and %v (%T)",
@@ -68,26 +86,31 @@ func (self *RegexDispatcher) AddImpl(elements ...RegexProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]RegexProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
 
 func Match(scope types.Scope, pattern string, target string) bool {
-	var re *regexp.Regexp
-	key := "__re" + pattern
-
-	re_any, pres := scope.GetContext(key)
-	if pres {
-		re, _ = re_any.(*regexp.Regexp)
-
-	} else {
-		var err error
-		re, err = regexp.Compile("(?i)" + pattern)
-		if err != nil {
-			scope.Log("Compile regexp: %v", err)
-			return false
-		}
-
-		scope.SetContext(key, re)
+	re, err := scope.CompileRegex(pattern)
+	if err != nil {
+		scope.Log("Compile regexp: %v", err)
+		return false
 	}
 
 	return re.MatchString(target)
 }
+
+// Compile the pattern the way the =~ operator matches it (case
+// insensitive).
+func CompileRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// The patterns which match anything without compiling a regex.
+func IsMatchAll(pattern string) bool {
+	switch pattern {
+	case ".", ".*", "":
+		return true
+	}
+	return false
+}
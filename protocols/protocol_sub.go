@@ -12,12 +12,15 @@ type SubProtocol interface {
 }
 
 type SubDispatcher struct {
-	impl []SubProtocol
+	impl  []SubProtocol
+	cache *protocolCache
 }
 
 func (self SubDispatcher) Copy() SubDispatcher {
 	return SubDispatcher{
-		append([]SubProtocol{}, self.impl...)}
+		impl:  append([]SubProtocol{}, self.impl...),
+		cache: newProtocolCache(),
+	}
 }
 
 func (self SubDispatcher) Sub(scope types.Scope, a types.Any, b types.Any) types.Any {
@@ -54,11 +57,16 @@ func (self SubDispatcher) Sub(scope types.Scope, a types.Any, b types.Any) types
 		}
 	}
 
-	for i, impl := range self.impl {
-		if impl.Applicable(a, b) {
-			scope.GetStats().IncProtocolSearch(i)
-			return impl.Sub(scope, a, b)
-		}
+	i, ok := self.cache.find(newProtocolCacheKey(a, b), len(self.impl),
+		func(i int) bool {
+			return self.impl[i].Applicable(a, b)
+		}, func(i int) types.Any {
+			return self.impl[i]
+		})
+	if ok {
+		impl := self.impl[i]
+		scope.GetStats().IncProtocolSearch(i)
+		return impl.Sub(scope, a, b)
 	}
 
 	scope.Trace("Protocol Sub not found for %v (%T) and %v (%T)",
@@ -70,4 +78,6 @@ func (self *SubDispatcher) AddImpl(elements ...SubProtocol) {
 	for _, impl := range elements {
 		self.impl = append([]SubProtocol{impl}, self.impl...)
 	}
+
+	self.cache = newProtocolCache()
 }
@@ -0,0 +1,71 @@
+package protocols
+
+import (
+	"context"
+	"math"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Iterate over the values of a range() in the _value column.
+type _RangeIterator struct{}
+
+func (self _RangeIterator) TypeOnly() {}
+
+func (self _RangeIterator) Applicable(a types.Any) bool {
+	_, ok := a.(*types.Range)
+	return ok
+}
+
+func (self _RangeIterator) Iterate(
+	ctx context.Context, scope types.Scope, a types.Any) <-chan types.Row {
+	output_chan := make(chan types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		r := a.(*types.Range)
+		for i := int64(0); i < r.Len(); i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- ordereddict.NewDict().
+				Set("_value", r.Start+i*r.Step):
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+// 5 in range(end=10) is tested without iterating over the range.
+type _RangeMembership struct{}
+
+func (self _RangeMembership) TypeOnly() {}
+
+func (self _RangeMembership) Applicable(a types.Any, b types.Any) bool {
+	_, ok := b.(*types.Range)
+	return ok
+}
+
+func (self _RangeMembership) Membership(
+	scope types.Scope, a types.Any, b types.Any) bool {
+	r := b.(*types.Range)
+
+	// Only whole numbers can be in a range.
+	float_value, ok := a.(float64)
+	if ok {
+		if float_value != math.Trunc(float_value) {
+			return false
+		}
+		return r.Contains(int64(float_value))
+	}
+
+	value, ok := utils.ToInt64(a)
+	if !ok {
+		return false
+	}
+	return r.Contains(value)
+}
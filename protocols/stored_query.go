@@ -9,6 +9,8 @@ import (
 
 type _StoredQueryAssociative struct{}
 
+func (self _StoredQueryAssociative) TypeOnly() {}
+
 func (self _StoredQueryAssociative) Applicable(a types.Any, b types.Any) bool {
 	_, a_ok := a.(types.StoredQuery)
 	return a_ok
@@ -76,6 +78,8 @@ func (self _StoredQueryBool) Bool(ctx context.Context, scope types.Scope, a type
 	return false
 }
 
+func (self _StoredQueryBool) TypeOnly() {}
+
 func (self _StoredQueryBool) Applicable(a types.Any) bool {
 	_, a_ok := a.(types.StoredQuery)
 	return a_ok
@@ -83,6 +87,8 @@ func (self _StoredQueryBool) Applicable(a types.Any) bool {
 
 type _StoredQueryAdd struct{}
 
+func (self _StoredQueryAdd) TypeOnly() {}
+
 func (self _StoredQueryAdd) Applicable(a types.Any, b types.Any) bool {
 	_, a_ok := a.(types.StoredQuery)
 	_, b_ok := b.(types.StoredQuery)
@@ -0,0 +1,314 @@
+package protocols
+
+import (
+	"math"
+	"time"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Times may be compared with other times, epoch seconds (int or
+// float) and RFC3339 strings. The dispatchers already compare two
+// time.Time objects inline, these protocols handle the mixed cases.
+
+func isTime(a types.Any) bool {
+	_, ok := toTime(a)
+	return ok
+}
+
+// Convert a value to a time for comparison with a time.
+func toTimeValue(a types.Any) (time.Time, bool) {
+	switch t := a.(type) {
+	case time.Time:
+		return t, true
+
+	case *time.Time:
+		return *t, true
+
+	case float64:
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			result, err := time.Parse(layout, t)
+			if err == nil {
+				return result, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	sec, ok := utils.ToInt64(a)
+	if ok {
+		return time.Unix(sec, 0).UTC(), true
+	}
+
+	return time.Time{}, false
+}
+
+// Convert a value to a duration for time arithmetic. Numbers are
+// taken as seconds.
+func toDuration(a types.Any) (time.Duration, bool) {
+	switch t := a.(type) {
+	case time.Duration:
+		return t, true
+
+	case *time.Duration:
+		return *t, true
+
+	case float64:
+		return time.Duration(t * float64(time.Second)), true
+	}
+
+	sec, ok := utils.ToInt64(a)
+	if ok {
+		return time.Duration(sec) * time.Second, true
+	}
+	return 0, false
+}
+
+// Either side is a time and the other side can be converted to one.
+func timeApplicable(a types.Any, b types.Any) (time.Time, time.Time, bool) {
+	if !isTime(a) && !isTime(b) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	a_time, ok := toTimeValue(a)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	b_time, ok := toTimeValue(b)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return a_time, b_time, true
+}
+
+type _TimeEq struct{}
+
+func (self _TimeEq) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := timeApplicable(a, b)
+	return ok
+}
+
+func (self _TimeEq) Eq(scope types.Scope, a types.Any, b types.Any) bool {
+	a_time, b_time, _ := timeApplicable(a, b)
+	return a_time.Equal(b_time)
+}
+
+type _TimeLt struct{}
+
+func (self _TimeLt) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := timeApplicable(a, b)
+	return ok
+}
+
+func (self _TimeLt) Lt(scope types.Scope, a types.Any, b types.Any) bool {
+	a_time, b_time, _ := timeApplicable(a, b)
+	return a_time.Before(b_time)
+}
+
+type _TimeGt struct{}
+
+func (self _TimeGt) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := timeApplicable(a, b)
+	return ok
+}
+
+func (self _TimeGt) Gt(scope types.Scope, a types.Any, b types.Any) bool {
+	a_time, b_time, _ := timeApplicable(a, b)
+	return a_time.After(b_time)
+}
+
+// time + duration and duration + time produce a time.
+type _TimeAdd struct{}
+
+func (self _TimeAdd) TypeOnly() {}
+
+func (self _TimeAdd) Applicable(a types.Any, b types.Any) bool {
+	if isTime(a) {
+		_, ok := toDuration(b)
+		return ok
+	}
+
+	if isTime(b) {
+		_, ok := toDuration(a)
+		return ok
+	}
+	return false
+}
+
+func (self _TimeAdd) Add(scope types.Scope, a types.Any, b types.Any) types.Any {
+	if !isTime(a) {
+		a, b = b, a
+	}
+
+	a_time, _ := toTime(a)
+	duration, _ := toDuration(b)
+	return a_time.Add(duration)
+}
+
+// time - duration produces a time and time - time produces the
+// difference in seconds.
+type _TimeSub struct{}
+
+func (self _TimeSub) TypeOnly() {}
+
+func (self _TimeSub) Applicable(a types.Any, b types.Any) bool {
+	if !isTime(a) {
+		return false
+	}
+
+	if isTime(b) {
+		return true
+	}
+
+	_, ok := toDuration(b)
+	return ok
+}
+
+func (self _TimeSub) Sub(scope types.Scope, a types.Any, b types.Any) types.Any {
+	a_time, _ := toTime(a)
+	b_time, ok := toTime(b)
+	if ok {
+		return a_time.Sub(*b_time).Seconds()
+	}
+
+	duration, _ := toDuration(b)
+	return a_time.Add(-duration)
+}
+
+// Durations compare with other durations and with numbers of seconds.
+func durationApplicable(a types.Any, b types.Any) (time.Duration, time.Duration, bool) {
+	_, a_ok := a.(time.Duration)
+	_, b_ok := b.(time.Duration)
+	if !a_ok && !b_ok {
+		return 0, 0, false
+	}
+
+	a_duration, ok := toDuration(a)
+	if !ok {
+		return 0, 0, false
+	}
+
+	b_duration, ok := toDuration(b)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return a_duration, b_duration, true
+}
+
+type _DurationEq struct{}
+
+func (self _DurationEq) TypeOnly() {}
+
+func (self _DurationEq) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationApplicable(a, b)
+	return ok
+}
+
+func (self _DurationEq) Eq(scope types.Scope, a types.Any, b types.Any) bool {
+	a_duration, b_duration, _ := durationApplicable(a, b)
+	return a_duration == b_duration
+}
+
+type _DurationLt struct{}
+
+func (self _DurationLt) TypeOnly() {}
+
+func (self _DurationLt) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationApplicable(a, b)
+	return ok
+}
+
+func (self _DurationLt) Lt(scope types.Scope, a types.Any, b types.Any) bool {
+	a_duration, b_duration, _ := durationApplicable(a, b)
+	return a_duration < b_duration
+}
+
+type _DurationGt struct{}
+
+func (self _DurationGt) TypeOnly() {}
+
+func (self _DurationGt) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationApplicable(a, b)
+	return ok
+}
+
+func (self _DurationGt) Gt(scope types.Scope, a types.Any, b types.Any) bool {
+	a_duration, b_duration, _ := durationApplicable(a, b)
+	return a_duration > b_duration
+}
+
+// duration + duration produces a duration. Numbers are taken as
+// seconds.
+type _DurationAdd struct{}
+
+func (self _DurationAdd) TypeOnly() {}
+
+func (self _DurationAdd) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationApplicable(a, b)
+	return ok
+}
+
+func (self _DurationAdd) Add(scope types.Scope, a types.Any, b types.Any) types.Any {
+	a_duration, b_duration, _ := durationApplicable(a, b)
+	return a_duration + b_duration
+}
+
+// duration - duration produces a duration. Numbers are taken as
+// seconds.
+type _DurationSub struct{}
+
+func (self _DurationSub) TypeOnly() {}
+
+func (self _DurationSub) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationApplicable(a, b)
+	return ok
+}
+
+func (self _DurationSub) Sub(scope types.Scope, a types.Any, b types.Any) types.Any {
+	a_duration, b_duration, _ := durationApplicable(a, b)
+	return a_duration - b_duration
+}
+
+// duration * number and number * duration scale the duration.
+type _DurationMul struct{}
+
+func durationFactor(a types.Any, b types.Any) (time.Duration, float64, bool) {
+	duration, ok := a.(time.Duration)
+	if !ok {
+		duration, ok = b.(time.Duration)
+		if !ok {
+			return 0, 0, false
+		}
+		a, b = b, a
+	}
+
+	// duration * duration is not a duration.
+	_, is_duration := b.(time.Duration)
+	_, is_bool := b.(bool)
+	if is_duration || is_bool {
+		return 0, 0, false
+	}
+
+	factor, ok := utils.ToFloat(b)
+	return duration, factor, ok
+}
+
+func (self _DurationMul) TypeOnly() {}
+
+func (self _DurationMul) Applicable(a types.Any, b types.Any) bool {
+	_, _, ok := durationFactor(a, b)
+	return ok
+}
+
+func (self _DurationMul) Mul(scope types.Scope, a types.Any, b types.Any) types.Any {
+	duration, factor, _ := durationFactor(a, b)
+	return time.Duration(float64(duration) * factor)
+}
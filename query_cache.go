@@ -0,0 +1,282 @@
+package vfilter
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+	"www.velocidex.com/golang/vfilter/utils/dict"
+)
+
+// An in memory QueryCache holding at most size results. The least
+// recently used results are evicted first.
+type memoryQueryCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryQueryCacheEntry struct {
+	key     string
+	rows    []Row
+	expires time.Time
+}
+
+func NewMemoryQueryCache(size int) types.QueryCache {
+	return &memoryQueryCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (self *memoryQueryCache) Get(key string) ([]Row, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	element, pres := self.entries[key]
+	if !pres {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryQueryCacheEntry)
+	if time.Now().After(entry.expires) {
+		self.lru.Remove(element)
+		delete(self.entries, key)
+		return nil, false
+	}
+
+	self.lru.MoveToFront(element)
+	return entry.rows, true
+}
+
+func (self *memoryQueryCache) Set(key string, rows []Row, ttl time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	entry := &memoryQueryCacheEntry{
+		key:     key,
+		rows:    rows,
+		expires: time.Now().Add(ttl),
+	}
+
+	element, pres := self.entries[key]
+	if pres {
+		element.Value = entry
+		self.lru.MoveToFront(element)
+		return
+	}
+
+	self.entries[key] = self.lru.PushFront(entry)
+	for self.lru.Len() > self.size {
+		oldest := self.lru.Back()
+		self.lru.Remove(oldest)
+		delete(self.entries, oldest.Value.(*memoryQueryCacheEntry).key)
+	}
+}
+
+// Call the plugin, reusing its results from the scope's query cache
+// if it is cacheable.
+func callPluginCached(ctx context.Context, scope types.Scope,
+	name string, plugin types.PluginGeneratorInterface,
+	args *ordereddict.Dict) <-chan Row {
+	cache, ttl := scope.QueryCache()
+	if cache == nil || ttl <= 0 {
+		return scope.CallPlugin(ctx, name, plugin, args)
+	}
+
+	info := plugin.Info(scope, types.NewTypeMap())
+	if info == nil || !info.Cacheable {
+		return scope.CallPlugin(ctx, name, plugin, args)
+	}
+
+	// The plugin receives the reduced args so lazy args are not
+	// evaluated again.
+	args = reduceCacheArgs(ctx, args)
+	key, ok := queryCacheKey(scope, name, plugin, args)
+	if !ok {
+		return scope.CallPlugin(ctx, name, plugin, args)
+	}
+
+	output_chan := make(chan Row)
+
+	rows, pres := cache.Get(key)
+	if pres {
+		go func() {
+			defer close(output_chan)
+
+			for _, row := range rows {
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- copyRow(row):
+				}
+			}
+		}()
+		return output_chan
+	}
+
+	go func() {
+		defer close(output_chan)
+
+		var rows []Row
+		for row := range scope.CallPlugin(ctx, name, plugin, args) {
+			row = dict.RowToDict(ctx, scope, row)
+			rows = append(rows, copyRow(row))
+
+			select {
+			case <-ctx.Done():
+				// Do not cache partial results.
+				return
+			case output_chan <- row:
+			}
+		}
+
+		if ctx.Err() == nil {
+			cache.Set(key, rows, ttl)
+		}
+	}()
+
+	return output_chan
+}
+
+// Cached rows are shared by all the queries reading them, and
+// consumers may modify the rows they receive (e.g. InternRow()
+// replaces values in place). Each consumer and the cache therefore
+// get their own copy of the row.
+func copyRow(row Row) Row {
+	in, ok := row.(*ordereddict.Dict)
+	if !ok {
+		return row
+	}
+
+	result := ordereddict.NewDict()
+	if in.IsCaseInsensitive() {
+		result.SetCaseInsensitive()
+	}
+	for _, k := range in.Keys() {
+		v, _ := in.Get(k)
+		result.Set(k, v)
+	}
+	return result
+}
+
+// Reduce the lazy args into their values.
+func reduceCacheArgs(ctx context.Context, args *ordereddict.Dict) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	for _, k := range args.Keys() {
+		v, _ := args.Get(k)
+		lazy_v, ok := v.(types.LazyExpr)
+		if ok {
+			v = lazy_v.Reduce(ctx)
+		}
+		result.Set(k, v)
+	}
+	return result
+}
+
+// Build the cache key from the plugin name, the normalized query (for
+// stored queries) and the args reduced by reduceCacheArgs(). Returns
+// false if the args can not be serialized (e.g. subqueries).
+func queryCacheKey(scope types.Scope, name string,
+	plugin types.PluginGeneratorInterface, args *ordereddict.Dict) (string, bool) {
+	keys := args.Keys()
+	sort.Strings(keys)
+
+	parts := []interface{}{name}
+	stored_query, ok := plugin.(*_StoredQuery)
+	if ok {
+		parts = append(parts, FormatToString(scope, stored_query.query))
+	} else {
+		parts = append(parts, reflect.TypeOf(plugin).String())
+	}
+
+	for _, k := range keys {
+		v, _ := args.Get(k)
+		switch v.(type) {
+		case types.StoredQuery, types.LazyExpr, types.Materializer:
+			return "", false
+		}
+		parts = append(parts, k, v)
+	}
+
+	serialized, err := json.Marshal(parts)
+	if err != nil {
+		return "", false
+	}
+	return string(serialized), true
+}
+
+// A stored query is cacheable if all the plugins it calls are
+// cacheable and it only depends on its args. Functions called by the
+// query are assumed to be deterministic.
+func (self *_StoredQuery) isCacheable(scope types.Scope) bool {
+	// Args bound by Specialize() are not part of the cache key.
+	if self.bound != nil && self.bound.Len() > 0 {
+		return false
+	}
+
+	result := true
+	walkAST(reflect.ValueOf(self.query), func(node interface{}) {
+		if !result {
+			return
+		}
+
+		// Variables in the scope are not part of the cache key
+		// either. Columns which happen to share the name of a
+		// variable also prevent caching.
+		symbol, ok := node.(*_SymbolRef)
+		if ok {
+			name := strings.Split(symbol.Symbol, ".")[0]
+			if utils.InString(&self.parameters, name) {
+				return
+			}
+
+			_, pres := scope.Resolve(name)
+			if pres {
+				result = false
+			}
+			return
+		}
+
+		query, ok := node.(*_Select)
+		if !ok || query.From == nil {
+			return
+		}
+
+		// Named subqueries may be cancelled part way through.
+		if query.From.Name != nil {
+			result = false
+			return
+		}
+
+		name := query.From.Plugin.Name
+		var impl types.Any
+		impl, pres := scope.GetPlugin(name)
+		if !pres {
+			// Maybe another stored query
+			impl, pres = scope.Resolve(name)
+		}
+
+		plugin, ok := impl.(types.PluginGeneratorInterface)
+		if !pres || !ok || plugin == types.PluginGeneratorInterface(self) {
+			result = false
+			return
+		}
+
+		info := plugin.Info(scope, types.NewTypeMap())
+		if info == nil || !info.Cacheable {
+			result = false
+		}
+	})
+	return result
+}
@@ -0,0 +1,224 @@
+package vfilter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type queryCacheTestArgs struct {
+	Count int64 `vfilter:"required,field=count"`
+}
+
+func makeQueryCacheTestPlugin(name string, cacheable bool,
+	calls *int64) plugins.GenericListPlugin {
+	return plugins.GenericListPlugin{
+		PluginName: name,
+		Cacheable:  cacheable,
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			atomic.AddInt64(calls, 1)
+
+			arg := &queryCacheTestArgs{}
+			err := ExtractArgs(scope, args, arg)
+			if err != nil {
+				scope.Log("%s: %v", name, err)
+				return nil
+			}
+
+			var result []Row
+			for i := int64(0); i < arg.Count; i++ {
+				result = append(result, ordereddict.NewDict().Set("Value", i))
+			}
+			return result
+		},
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	var cached_calls, uncached_calls int64
+
+	scope := NewScope().AppendPlugins(
+		makeQueryCacheTestPlugin("cached", true, &cached_calls),
+		makeQueryCacheTestPlugin("uncached", false, &uncached_calls))
+	defer scope.Close()
+
+	scope.SetQueryCache(NewMemoryQueryCache(10), time.Minute)
+
+	run := func(query string) int {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		count := 0
+		err = vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				count++
+				return nil
+			})
+		assert.NoError(t, err)
+		return count
+	}
+
+	// The second query is served from the cache.
+	assert.Equal(t, 3, run("SELECT * FROM cached(count=3)"))
+	assert.Equal(t, 3, run("SELECT * FROM cached(count=1 + 2)"))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cached_calls))
+
+	// Different args are cached separately.
+	assert.Equal(t, 4, run("SELECT * FROM cached(count=4)"))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&cached_calls))
+
+	// Plugins must declare they are cacheable.
+	assert.Equal(t, 3, run("SELECT * FROM uncached(count=3)"))
+	assert.Equal(t, 3, run("SELECT * FROM uncached(count=3)"))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&uncached_calls))
+
+	// Partial results are not cached.
+	assert.Equal(t, 1, run("SELECT * FROM cached(count=5) LIMIT 1"))
+	assert.Equal(t, 5, run("SELECT * FROM cached(count=5)"))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&cached_calls))
+
+	// Whole stored queries over cacheable plugins are cached.
+	run("LET Q(X) = SELECT Value * X AS Value FROM cached(count=2)")
+	assert.Equal(t, 2, run("SELECT * FROM Q(X=10)"))
+	assert.Equal(t, 2, run("SELECT * FROM Q(X=10)"))
+
+	// The inner plugin was cached already and the stored query
+	// result was cached on the first call.
+	assert.Equal(t, int64(5), atomic.LoadInt64(&cached_calls))
+
+	// Stored queries over uncacheable plugins are not.
+	run("LET U = SELECT * FROM uncached(count=2)")
+	run("SELECT * FROM U")
+	run("SELECT * FROM U")
+	assert.Equal(t, int64(4), atomic.LoadInt64(&uncached_calls))
+}
+
+func TestQueryCacheTTL(t *testing.T) {
+	var calls int64
+
+	scope := NewScope().AppendPlugins(
+		makeQueryCacheTestPlugin("cached", true, &calls))
+	defer scope.Close()
+
+	scope.SetQueryCache(NewMemoryQueryCache(10), 10*time.Millisecond)
+
+	vql, err := Parse("SELECT * FROM cached(count=1)")
+	assert.NoError(t, err)
+
+	for range vql.Eval(context.Background(), scope) {
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+	time.Sleep(20 * time.Millisecond)
+	for range vql.Eval(context.Background(), scope) {
+	}
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+}
+
+func TestQueryCacheFreeVariables(t *testing.T) {
+	var calls int64
+
+	scope := NewScope().AppendPlugins(
+		makeQueryCacheTestPlugin("cached", true, &calls))
+	defer scope.Close()
+
+	scope.SetQueryCache(NewMemoryQueryCache(10), time.Minute)
+
+	// Q depends on Y which is not one of its args so it must not
+	// be served from the cache.
+	vql, err := MultiParse(`
+LET Q = SELECT * FROM cached(count=Y)
+SELECT * FROM foreach(
+   row={SELECT _value AS Y FROM foreach(row=[1, 2, 3])},
+   query={SELECT * FROM Q()})`)
+	assert.NoError(t, err)
+
+	rows := 0
+	for _, query := range vql {
+		for range query.Eval(context.Background(), scope) {
+			rows++
+		}
+	}
+	assert.Equal(t, 1+2+3, rows)
+}
+
+func TestMemoryQueryCacheEviction(t *testing.T) {
+	cache := NewMemoryQueryCache(2)
+	cache.Set("a", []Row{1}, time.Minute)
+	cache.Set("b", []Row{2}, time.Minute)
+
+	// Using a makes b the least recently used.
+	_, pres := cache.Get("a")
+	assert.True(t, pres)
+
+	cache.Set("c", []Row{3}, time.Minute)
+
+	_, pres = cache.Get("b")
+	assert.False(t, pres)
+
+	rows, pres := cache.Get("a")
+	assert.True(t, pres)
+	assert.Equal(t, []Row{1}, rows)
+}
+
+// Counts how often the arg is evaluated.
+type countingLazyExpr struct {
+	calls *int64
+	value Any
+}
+
+func (self countingLazyExpr) Reduce(ctx context.Context) Any {
+	atomic.AddInt64(self.calls, 1)
+	return self.value
+}
+
+func (self countingLazyExpr) ReduceWithScope(
+	ctx context.Context, scope types.Scope) Any {
+	return self.Reduce(ctx)
+}
+
+func TestQueryCacheArgsAndRows(t *testing.T) {
+	ctx := context.Background()
+
+	var calls, reduced int64
+	plugin := makeQueryCacheTestPlugin("cached", true, &calls)
+
+	scope := NewScope().AppendPlugins(plugin)
+	defer scope.Close()
+
+	scope.SetQueryCache(NewMemoryQueryCache(10), time.Minute)
+
+	collect := func() []Row {
+		args := ordereddict.NewDict().
+			Set("count", countingLazyExpr{calls: &reduced, value: 2})
+
+		var rows []Row
+		for row := range callPluginCached(ctx, scope, "cached", plugin, args) {
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	// Lazy args are only evaluated once for the key and the plugin.
+	rows := collect()
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&reduced))
+
+	// Modifying the rows does not change the cached rows.
+	rows[0].(*ordereddict.Dict).Set("Value", "modified")
+	cached := collect()
+	cached[1].(*ordereddict.Dict).Set("Value", "modified")
+
+	value, _ := collect()[0].(*ordereddict.Dict).Get("Value")
+	assert.Equal(t, int64(0), value)
+	value, _ = collect()[1].(*ordereddict.Dict).Get("Value")
+	assert.Equal(t, int64(1), value)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+}
@@ -0,0 +1,273 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// What to do with a listener which does not keep up with the query.
+type SlowConsumerPolicy int
+
+const (
+	// The query waits for the listener (and so do all other
+	// listeners).
+	BlockSlowConsumer SlowConsumerPolicy = iota
+
+	// Rows are dropped for this listener only.
+	DropForSlowConsumer
+
+	// The listener is unsubscribed.
+	DisconnectSlowConsumer
+)
+
+type SubscribeOptions struct {
+	// Number of rows buffered for the listener before the policy
+	// applies.
+	BufferSize int
+	Policy     SlowConsumerPolicy
+}
+
+var defaultSubscribeOptions = SubscribeOptions{
+	BufferSize: 100,
+	Policy:     BlockSlowConsumer,
+}
+
+type queryListener struct {
+	callback func(row Row)
+	options  SubscribeOptions
+	rows     chan Row
+
+	// Closed when the listener is unsubscribed.
+	stop      chan bool
+	stop_once sync.Once
+
+	dropped uint64
+}
+
+func (self *queryListener) unsubscribe() {
+	self.stop_once.Do(func() {
+		close(self.stop)
+	})
+}
+
+func (self *queryListener) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		select {
+		case <-self.stop:
+			return
+		case row, ok := <-self.rows:
+			if !ok {
+				return
+			}
+
+			// Do not deliver buffered rows after unsubscribing.
+			select {
+			case <-self.stop:
+				return
+			default:
+			}
+			self.callback(row)
+		}
+	}
+}
+
+// A QueryHandle runs a query once and fans its rows out to any
+// number of listeners, e.g. several views of the same results in a
+// GUI. Listeners receive the fully materialized rows and must not
+// modify them as they are shared. Example:
+//
+//	handle := NewQueryHandle(vql, scope)
+//	handle.Subscribe(func(row Row) { table.Add(row) })
+//	handle.SubscribeWithOptions(func(row Row) { chart.Add(row) },
+//	    SubscribeOptions{BufferSize: 10, Policy: DropForSlowConsumer})
+//	handle.Start(ctx)
+//	err := handle.Wait()
+type QueryHandle struct {
+	mu sync.Mutex
+
+	vql   *VQL
+	scope types.Scope
+
+	listeners []*queryListener
+	wg        sync.WaitGroup
+
+	started  bool
+	finished bool
+	cancel   func()
+	done     chan bool
+	err      error
+}
+
+func NewQueryHandle(vql *VQL, scope types.Scope) *QueryHandle {
+	return &QueryHandle{
+		vql:    vql,
+		scope:  scope,
+		cancel: func() {},
+		done:   make(chan bool),
+	}
+}
+
+// Deliver each row to the callback. Returns a function which removes
+// the listener. Listeners added while the query runs only see rows
+// produced after they subscribed.
+func (self *QueryHandle) Subscribe(callback func(row Row)) func() {
+	return self.SubscribeWithOptions(callback, defaultSubscribeOptions)
+}
+
+func (self *QueryHandle) SubscribeWithOptions(
+	callback func(row Row), options SubscribeOptions) func() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.finished {
+		return func() {}
+	}
+
+	if options.BufferSize < 0 {
+		options.BufferSize = 0
+	}
+
+	listener := &queryListener{
+		callback: callback,
+		options:  options,
+		rows:     make(chan Row, options.BufferSize),
+		stop:     make(chan bool),
+	}
+	self.listeners = append(self.listeners, listener)
+
+	self.wg.Add(1)
+	go listener.run(&self.wg)
+
+	return func() {
+		self.removeListener(listener)
+	}
+}
+
+func (self *QueryHandle) removeListener(listener *queryListener) {
+	listener.unsubscribe()
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for idx, l := range self.listeners {
+		if l == listener {
+			self.listeners = append(self.listeners[:idx:idx],
+				self.listeners[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (self *QueryHandle) getListeners() []*queryListener {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.listeners
+}
+
+// Start running the query in the background. A handle can only be
+// started once.
+func (self *QueryHandle) Start(ctx context.Context) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.started {
+		return errors.New("QueryHandle: query already started")
+	}
+	self.started = true
+
+	sub_ctx, cancel := context.WithCancel(ctx)
+	self.cancel = cancel
+
+	go func() {
+		defer close(self.done)
+		defer cancel()
+
+		err := self.vql.EvalWithCallback(sub_ctx, self.scope,
+			func(row Row) error {
+				for _, listener := range self.getListeners() {
+					self.deliver(sub_ctx, listener, row)
+				}
+				return nil
+			})
+
+		self.mu.Lock()
+		self.err = err
+		self.finished = true
+		listeners := self.listeners
+		self.mu.Unlock()
+
+		// Let the listeners drain their buffers.
+		for _, listener := range listeners {
+			close(listener.rows)
+		}
+		self.wg.Wait()
+
+		for _, listener := range listeners {
+			dropped := atomic.LoadUint64(&listener.dropped)
+			if dropped > 0 {
+				self.scope.Log("QueryHandle: dropped %v rows for a slow listener",
+					dropped)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (self *QueryHandle) deliver(
+	ctx context.Context, listener *queryListener, row Row) {
+	switch listener.options.Policy {
+	case DropForSlowConsumer:
+		select {
+		case <-listener.stop:
+		case listener.rows <- row:
+		default:
+			atomic.AddUint64(&listener.dropped, 1)
+		}
+
+	case DisconnectSlowConsumer:
+		select {
+		case <-listener.stop:
+		case listener.rows <- row:
+		default:
+			self.scope.Log("QueryHandle: disconnecting slow listener")
+			self.removeListener(listener)
+		}
+
+	default:
+		select {
+		case <-ctx.Done():
+		case <-listener.stop:
+		case listener.rows <- row:
+		}
+	}
+}
+
+// Stop the query. Listeners still receive the rows already buffered
+// for them.
+func (self *QueryHandle) Cancel() {
+	self.mu.Lock()
+	cancel := self.cancel
+	self.mu.Unlock()
+
+	cancel()
+}
+
+// Wait for the query to finish and all listeners to process their
+// rows. Returns the error the query was aborted with, if any. The
+// handle must have been started.
+func (self *QueryHandle) Wait() error {
+	<-self.done
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.err
+}
@@ -0,0 +1,81 @@
+package vfilter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHandle(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("SELECT _value AS X FROM foreach(row=range(end=14))")
+	assert.NoError(t, err)
+
+	handle := NewQueryHandle(vql, scope)
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	count := func(name string) func(row Row) {
+		return func(row Row) {
+			mu.Lock()
+			defer mu.Unlock()
+			counts[name]++
+		}
+	}
+
+	// Two views of the same query see every row.
+	handle.Subscribe(count("table"))
+	handle.Subscribe(count("chart"))
+
+	// This view only wants the first row.
+	var unsubscribe func()
+	unsubscribe = handle.Subscribe(func(row Row) {
+		count("first")(row)
+		unsubscribe()
+	})
+
+	// Slow listeners which never keep up.
+	block := make(chan bool)
+	handle.SubscribeWithOptions(func(row Row) {
+		count("dropping")(row)
+		<-block
+	}, SubscribeOptions{BufferSize: 1, Policy: DropForSlowConsumer})
+
+	handle.SubscribeWithOptions(func(row Row) {
+		count("disconnected")(row)
+		<-block
+	}, SubscribeOptions{BufferSize: 1, Policy: DisconnectSlowConsumer})
+
+	assert.NoError(t, handle.Start(context.Background()))
+	assert.Error(t, handle.Start(context.Background()))
+
+	// Wait until the table view got everything before releasing
+	// the slow listeners.
+	for {
+		mu.Lock()
+		done := counts["table"] == 14
+		mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+
+	assert.NoError(t, handle.Wait())
+
+	assert.Equal(t, 14, counts["table"])
+	assert.Equal(t, 14, counts["chart"])
+	assert.Equal(t, 1, counts["first"])
+	assert.True(t, counts["dropping"] <= 2, counts["dropping"])
+	assert.True(t, counts["disconnected"] <= 2, counts["disconnected"])
+
+	// Subscribing after the query finished is a no-op.
+	handle.Subscribe(count("late"))()
+	assert.Equal(t, 0, counts["late"])
+}
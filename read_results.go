@@ -0,0 +1,250 @@
+package vfilter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/arg_parser"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+type _ReadResultsArgs struct {
+	Filename string `vfilter:"required,field=filename,doc=The result file to read"`
+	Verify   bool   `vfilter:"optional,field=verify,doc=Check the rows against the hash footer before emitting any (the rows are held in memory)"`
+}
+
+// Reads result files written by OutputJSON(), OutputJSONL() and
+// their WithOptions variants (including gzip compressed and hashed
+// output) so saved results can be queried again.
+//
+// Since this plugin reads files it is not a builtin - embedders
+// which want it add it with scope.AppendPlugins(). Open may be set
+// to restrict or redirect file access.
+type ReadResultsPlugin struct {
+	Open func(filename string) (io.ReadCloser, error)
+
+	// The encoder the JSON arrays were written with, used to
+	// verify their hash (default json.Marshal).
+	Encoder RowEncoder
+}
+
+func (self ReadResultsPlugin) Info(scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:    "read_results",
+		Doc:     "Read rows from a saved JSON or JSONL result file.",
+		ArgType: type_map.AddType(scope, &_ReadResultsArgs{}),
+	}
+}
+
+func (self ReadResultsPlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan Row {
+	output_chan := make(chan Row)
+
+	go func() {
+		defer close(output_chan)
+
+		arg := &_ReadResultsArgs{}
+		err := arg_parser.ExtractArgsWithContext(ctx, scope, args, arg)
+		if err != nil {
+			scope.Log("read_results: %v", err)
+			return
+		}
+
+		open := self.Open
+		if open == nil {
+			open = func(filename string) (io.ReadCloser, error) {
+				return os.Open(filename)
+			}
+		}
+
+		fd, err := open(arg.Filename)
+		if err != nil {
+			scope.Log("read_results: %v", err)
+			return
+		}
+		defer fd.Close()
+
+		emit := func(row *ordereddict.Dict) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case output_chan <- row:
+				return true
+			}
+		}
+
+		// When verifying, no rows are emitted until the whole
+		// file is known to match its hash.
+		var rows []*ordereddict.Dict
+		var memory_err error
+		reader := &resultReader{verify: arg.Verify, encoder: self.Encoder}
+		if arg.Verify {
+			err = reader.Read(ctx, fd, func(row *ordereddict.Dict) bool {
+				memory_err = scope.ChargeMemory(ctx, utils.EstimateSize(row))
+				if memory_err != nil {
+					return false
+				}
+				rows = append(rows, row)
+				return true
+			})
+			if err == nil && memory_err != nil {
+				err = memory_err
+			}
+		} else {
+			err = reader.Read(ctx, fd, emit)
+		}
+
+		if err != nil {
+			scope.Log("ERROR:read_results: %v: %v", arg.Filename, err)
+			err = fmt.Errorf("read_results: %v: %w", arg.Filename, err)
+			scope.ReportError(err)
+
+			// Unverified rows must not be used.
+			if arg.Verify {
+				types.AbortQuery(ctx, err)
+			}
+			return
+		}
+
+		for _, row := range rows {
+			if !emit(row) {
+				return
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+var errNoFooter = errors.New("No hash footer found")
+
+type resultReader struct {
+	verify  bool
+	encoder RowEncoder
+	hasher  *resultHasher
+	footer  *ordereddict.Dict
+
+	// JSON arrays are hashed as the encoder serializes all the
+	// rows together.
+	is_array bool
+	rows     []Row
+}
+
+// Decode the rows and pass them to emit until it returns false.
+func (self *resultReader) Read(ctx context.Context, fd io.Reader,
+	emit func(row *ordereddict.Dict) bool) error {
+	self.hasher = newResultHasher()
+
+	reader := bufio.NewReader(fd)
+
+	// Gzip streams start with a magic number.
+	magic, _ := reader.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		return err
+	}
+
+	// OutputJSON() writes an array of rows and OutputJSONL() a row
+	// per line. json.Decoder handles a stream of rows either way.
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		_, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		self.is_array = true
+	}
+
+	for decoder.More() {
+		row := ordereddict.NewDict()
+		err := decoder.Decode(row)
+		if err != nil {
+			return err
+		}
+
+		is_footer, _ := row.Get("_footer")
+		if is_footer == true {
+			self.footer = row
+			continue
+		}
+
+		if self.verify && self.is_array {
+			self.rows = append(self.rows, row)
+
+		} else if self.verify {
+			err := self.hasher.Add(row)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !emit(row) {
+			return nil
+		}
+	}
+
+	if !self.verify {
+		return nil
+	}
+
+	if self.footer == nil {
+		return errNoFooter
+	}
+
+	if self.is_array {
+		encoder := self.encoder
+		if encoder == nil {
+			encoder = func(rows []Row) ([]byte, error) {
+				return json.Marshal(rows)
+			}
+		}
+
+		encoded, err := encoder(self.rows)
+		if err != nil {
+			return err
+		}
+		self.hasher.WriteEncoded(encoded, len(self.rows))
+	}
+
+	_, _, err = self.hasher.verify(self.footer)
+	return err
+}
+
+// Peek at the first non whitespace byte without consuming it.
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for i := 1; ; i++ {
+		data, err := reader.Peek(i)
+		if len(data) < i {
+			if err == io.EOF {
+				return 0, nil
+			}
+			return 0, err
+		}
+
+		switch c := data[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return c, nil
+		}
+	}
+}
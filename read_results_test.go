@@ -0,0 +1,124 @@
+package vfilter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadResults(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.AppendPlugins(ReadResultsPlugin{})
+
+	dir, err := ioutil.TempDir("", "read_results")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	vql, err := Parse("SELECT * FROM test()")
+	assert.NoError(t, err)
+
+	write := func(name string, options OutputOptions) string {
+		buf := &bytes.Buffer{}
+		_, err := OutputJSONLWithOptions(vql, ctx, scope, buf, options)
+		assert.NoError(t, err)
+
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(filename, buf.Bytes(), 0600))
+		return filename
+	}
+
+	run := func(query string, filename string) []*ordereddict.Dict {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		subscope := scope.Copy()
+		defer subscope.Close()
+		subscope.AppendVars(ordereddict.NewDict().Set("Filename", filename))
+
+		var result []*ordereddict.Dict
+		for row := range vql.Eval(ctx, subscope) {
+			result = append(result, RowToDict(ctx, subscope, row))
+		}
+		return result
+	}
+
+	plain := write("plain.jsonl", OutputOptions{})
+	rows := run("SELECT * FROM read_results(filename=Filename)", plain)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, 2, len(run(
+		"SELECT * FROM read_results(filename=Filename) WHERE foo > 0", plain)))
+
+	// Compressed and hashed output reads back the same rows and
+	// the footer is not a row.
+	hashed := write("hashed.jsonl.gz", OutputOptions{Compression: "gzip", Hash: true})
+	assert.Equal(t, rows, run(
+		"SELECT * FROM read_results(filename=Filename, verify=TRUE)", hashed))
+
+	// OutputJSON arrays can be read too. They are verified with
+	// the encoder they were written with.
+	indent := func(rows []Row) ([]byte, error) {
+		return json.MarshalIndent(rows, "", " ")
+	}
+	serialized, _, err := OutputJSONWithOptions(vql, ctx, scope,
+		indent, OutputOptions{Hash: true})
+	assert.NoError(t, err)
+	array := filepath.Join(dir, "array.json")
+	assert.NoError(t, ioutil.WriteFile(array, serialized, 0600))
+	assert.Equal(t, 0, len(run(
+		"SELECT * FROM read_results(filename=Filename, verify=TRUE)", array)))
+
+	scope.AppendPlugins(ReadResultsPlugin{Encoder: indent})
+	assert.Equal(t, rows, run(
+		"SELECT * FROM read_results(filename=Filename, verify=TRUE)", array))
+
+	// Tampering is detected when verifying.
+	data, err := ioutil.ReadFile(array)
+	assert.NoError(t, err)
+	tampered := filepath.Join(dir, "tampered.json")
+	assert.NoError(t, ioutil.WriteFile(tampered,
+		[]byte(strings.Replace(string(data), `"foo": 2`, `"foo": 20`, 1)), 0600))
+
+	collector := &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+	assert.Equal(t, 0, len(run(
+		"SELECT * FROM read_results(filename=Filename, verify=TRUE)", tampered)))
+	assert.Equal(t, 1, len(collector.errors))
+
+	// Verifying output without a footer fails too.
+	run("SELECT * FROM read_results(filename=Filename, verify=TRUE)", plain)
+	assert.Equal(t, 2, len(collector.errors))
+
+	// The query fails when verification fails.
+	verify_vql, err := Parse(
+		"SELECT * FROM read_results(filename=Filename, verify=TRUE)")
+	assert.NoError(t, err)
+
+	subscope := scope.Copy()
+	defer subscope.Close()
+	subscope.AppendVars(ordereddict.NewDict().Set("Filename", tampered))
+
+	err = verify_vql.EvalWithCallback(ctx, subscope,
+		func(row Row) error { return nil })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Result hash does not match")
+
+	// File access may be redirected.
+	scope.AppendPlugins(ReadResultsPlugin{
+		Open: func(filename string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(`{"A": 1}`)), nil
+		},
+	})
+	assert.Equal(t, 1, len(run("SELECT * FROM read_results(filename='x')", "")))
+}
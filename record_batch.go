@@ -0,0 +1,268 @@
+package vfilter
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Columnar output for dataframe tooling. Rows are collected into
+// typed record batches which a RecordBatchWriter encodes, e.g. as
+// Apache Arrow IPC using the arrow package's ipc.Writer. Embedders
+// provide the writer so vfilter does not depend on arrow.
+
+type ColumnType int
+
+const (
+	ColumnInt64 ColumnType = iota
+	ColumnFloat64
+	ColumnBool
+	ColumnString
+	ColumnTimestamp
+
+	// Anything else (dicts, lists) is encoded as JSON strings.
+	ColumnJSON
+
+	// Only used while inferring the schema.
+	columnUnknown ColumnType = -1
+)
+
+func (self ColumnType) String() string {
+	switch self {
+	case ColumnInt64:
+		return "int64"
+	case ColumnFloat64:
+		return "float64"
+	case ColumnBool:
+		return "bool"
+	case ColumnString:
+		return "string"
+	case ColumnTimestamp:
+		return "timestamp"
+	default:
+		return "json"
+	}
+}
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// A batch of rows stored by column. Values[i] holds the values of
+// Schema[i] which are int64, float64, bool, string, time.Time or
+// nil for NULL according to the column type.
+type RecordBatch struct {
+	Schema []Column
+	Length int
+	Values [][]types.Any
+}
+
+type RecordBatchWriter interface {
+	// Called once before the first batch. The schema is fixed by
+	// the first batch of rows.
+	WriteSchema(schema []Column) error
+	WriteBatch(batch *RecordBatch) error
+}
+
+func columnTypeOf(value types.Any) (ColumnType, bool) {
+	switch value.(type) {
+	case nil, types.Null, *types.Null:
+		return 0, false
+	case bool:
+		return ColumnBool, true
+	case float32, float64:
+		return ColumnFloat64, true
+	case string:
+		return ColumnString, true
+	case time.Time, *time.Time:
+		return ColumnTimestamp, true
+	}
+
+	if utils.IsInt(value) {
+		return ColumnInt64, true
+	}
+	return ColumnJSON, true
+}
+
+// Convert the value to the column's type. Values which do not fit
+// are NULL and return false, except for string and JSON columns which
+// take anything.
+func coerceColumnValue(column_type ColumnType, value types.Any) (types.Any, bool) {
+	if types.IsNullObject(value) {
+		return nil, true
+	}
+
+	switch column_type {
+	case ColumnInt64:
+		_, is_bool := value.(bool)
+		result, ok := utils.ToInt64(value)
+		if ok && !is_bool {
+			return result, true
+		}
+
+	case ColumnFloat64:
+		_, is_bool := value.(bool)
+		result, ok := utils.ToFloat(value)
+		if ok && !is_bool {
+			return result, true
+		}
+
+	case ColumnBool:
+		result, ok := value.(bool)
+		if ok {
+			return result, true
+		}
+
+	case ColumnTimestamp:
+		switch t := value.(type) {
+		case time.Time:
+			return t, true
+		case *time.Time:
+			return *t, true
+		}
+
+	case ColumnString:
+		result, ok := value.(string)
+		if ok {
+			return result, true
+		}
+		return jsonString(value), true
+
+	default:
+		return jsonString(value), true
+	}
+
+	return nil, false
+}
+
+func jsonString(value types.Any) types.Any {
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return string(serialized)
+}
+
+// Infer the schema from the first batch. A column's type is the
+// type of its first non NULL value. Ints mixed with floats make a
+// float column and other mixtures a JSON column.
+func inferSchema(rows []*ordereddict.Dict) []Column {
+	var schema []Column
+	index := make(map[string]int)
+
+	for _, row := range rows {
+		for _, name := range row.Keys() {
+			value, _ := row.Get(name)
+			column_type, ok := columnTypeOf(value)
+
+			idx, pres := index[name]
+			if !pres {
+				index[name] = len(schema)
+				schema = append(schema, Column{Name: name, Type: columnUnknown})
+				idx = len(schema) - 1
+			}
+
+			if !ok {
+				continue
+			}
+
+			existing := schema[idx].Type
+			switch {
+			case existing == columnUnknown || existing == column_type:
+				schema[idx].Type = column_type
+
+			case (existing == ColumnInt64 && column_type == ColumnFloat64) ||
+				(existing == ColumnFloat64 && column_type == ColumnInt64):
+				schema[idx].Type = ColumnFloat64
+
+			default:
+				schema[idx].Type = ColumnJSON
+			}
+		}
+	}
+
+	// Columns which were always NULL are strings.
+	for i := range schema {
+		if schema[i].Type == columnUnknown {
+			schema[i].Type = ColumnString
+		}
+	}
+
+	return schema
+}
+
+// Evaluate the query and write its rows to the writer in record
+// batches of up to batch_size rows. The schema can not change once it
+// is written: columns which appear after the first batch are dropped
+// and values which do not fit their column's type are NULL, with a
+// warning for each column.
+func OutputRecordBatches(
+	vql *VQL,
+	ctx context.Context,
+	scope types.Scope,
+	writer RecordBatchWriter,
+	batch_size int) error {
+	var schema []Column
+	warned := make(map[string]bool)
+	warned_type := make(map[string]bool)
+
+	return EvalWithBatchCallback(vql, ctx, scope, batch_size,
+		func(rows []Row) error {
+			dicts := make([]*ordereddict.Dict, 0, len(rows))
+			for _, row := range rows {
+				dicts = append(dicts, RowToDict(ctx, scope, row))
+			}
+
+			if schema == nil {
+				schema = inferSchema(dicts)
+				err := writer.WriteSchema(schema)
+				if err != nil {
+					return err
+				}
+			}
+
+			batch := &RecordBatch{
+				Schema: schema,
+				Length: len(dicts),
+				Values: make([][]types.Any, len(schema)),
+			}
+
+			for _, row := range dicts {
+				for i, column := range schema {
+					value, _ := row.Get(column.Name)
+					coerced, ok := coerceColumnValue(column.Type, value)
+					if !ok && !warned_type[column.Name] {
+						scope.Log("WARN:OutputRecordBatches: Value %v of column %v "+
+							"does not fit the column type %v and will be NULL",
+							types.ToString(ctx, scope, value), column.Name, column.Type)
+						warned_type[column.Name] = true
+					}
+					batch.Values[i] = append(batch.Values[i], coerced)
+				}
+
+				for _, name := range row.Keys() {
+					if !warned[name] && !inSchema(schema, name) {
+						scope.Log("WARN:OutputRecordBatches: Column %v is "+
+							"not in the schema and will be dropped", name)
+						warned[name] = true
+					}
+				}
+			}
+
+			return writer.WriteBatch(batch)
+		})
+}
+
+func inSchema(schema []Column, name string) bool {
+	for _, column := range schema {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+type testBatchWriter struct {
+	schema  []Column
+	batches []*RecordBatch
+}
+
+func (self *testBatchWriter) WriteSchema(schema []Column) error {
+	self.schema = schema
+	return nil
+}
+
+func (self *testBatchWriter) WriteBatch(batch *RecordBatch) error {
+	self.batches = append(self.batches, batch)
+	return nil
+}
+
+func TestOutputRecordBatches(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse(`
+SELECT _value AS Int, _value / 2.0 AS Float, _value > 1 AS Bool,
+       format(format="row %v", args=_value) AS String,
+       timestamp(epoch=_value) AS Time,
+       if(condition=_value = 2, then=1.5, else=_value) AS Mixed,
+       dict(a=_value) AS Dict,
+       NULL AS Empty,
+       if(condition=_value = 3, then="late") AS Late
+FROM foreach(row=[1, 2, 3])`)
+	assert.NoError(t, err)
+
+	writer := &testBatchWriter{}
+	err = OutputRecordBatches(vql, ctx, scope, writer, 2)
+	assert.NoError(t, err)
+
+	types_by_name := make(map[string]string)
+	for _, column := range writer.schema {
+		types_by_name[column.Name] = column.Type.String()
+	}
+	assert.Equal(t, map[string]string{
+		"Int":    "int64",
+		"Float":  "float64",
+		"Bool":   "bool",
+		"String": "string",
+		"Time":   "timestamp",
+		"Mixed":  "float64",
+		"Dict":   "json",
+		"Empty":  "string",
+		"Late":   "string",
+	}, types_by_name)
+
+	// Batches of 2 rows.
+	assert.Equal(t, 2, len(writer.batches))
+	assert.Equal(t, 2, writer.batches[0].Length)
+	assert.Equal(t, 1, writer.batches[1].Length)
+
+	first := writer.batches[0].Values
+	assert.Equal(t, []types.Any{int64(1), int64(2)}, first[0])
+	assert.Equal(t, []types.Any{1.0, 1.5}, first[5])
+	assert.Equal(t, []types.Any{`{"a":1}`, `{"a":2}`}, first[6])
+	assert.Equal(t, []types.Any{nil, nil}, first[7])
+	assert.Equal(t, time.Unix(1, 0).Unix(), first[4][0].(time.Time).Unix())
+
+	// Values which first appear in later batches fit the schema.
+	assert.Equal(t, []types.Any{"late"}, writer.batches[1].Values[8])
+}
+
+func TestOutputRecordBatchesSchemaMismatch(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", 0))
+
+	// The schema is fixed to int64 by the first batch.
+	vql, err := Parse(`
+SELECT if(condition=_value < 3, then=_value, else="three") AS Value
+FROM foreach(row=[1, 2, 3, 4])`)
+	assert.NoError(t, err)
+
+	writer := &testBatchWriter{}
+	err = OutputRecordBatches(vql, ctx, scope, writer, 2)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []types.Any{nil, nil}, writer.batches[1].Values[0])
+	logger.Contains(t, "Value three of column Value does not fit the column type int64")
+}
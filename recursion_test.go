@@ -0,0 +1,93 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestRecursiveLetQuery(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.SetMaxRecursionDepth(5)
+
+	multi_vql, err := MultiParse(`
+LET tree <= dict(name="root", children=[
+   dict(name="a", children=[dict(name="a1", children=[])]),
+   dict(name="b", children=[])])
+
+LET walk(node, path) = SELECT * FROM chain(
+  a={ SELECT path + "/" + node.name AS Path FROM scope() },
+  b={ SELECT * FROM foreach(row=node.children, var="child", query={
+        SELECT * FROM walk(node=child, path=path + "/" + node.name)
+      })
+  })
+
+SELECT * FROM walk(node=tree, path="")
+`)
+	assert.NoError(t, err)
+
+	var paths []interface{}
+	for _, vql := range multi_vql {
+		err := vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				path, _ := row.(*ordereddict.Dict).Get("Path")
+				paths = append(paths, path)
+				return nil
+			})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []interface{}{"/root", "/root/a", "/root/a/a1", "/root/b"}, paths)
+
+	// The tree is 3 levels deep.
+	scope.SetMaxRecursionDepth(2)
+	err = multi_vql[2].EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			return nil
+		})
+	assert.True(t, errors.Is(err, types.ErrRecursionDepthExceeded))
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.SetMaxRecursionDepth(10)
+
+	multi_vql, err := MultiParse(`
+LET depth(x) = if(condition=x > 0, then=depth(x=x - 1) + 1, else=0)
+SELECT depth(x=9) AS Depth FROM scope()
+SELECT depth(x=10) AS Depth FROM scope()
+`)
+	assert.NoError(t, err)
+
+	run := func(vql *VQL) (Row, error) {
+		var result Row
+		err := vql.EvalWithCallback(context.Background(), scope,
+			func(row Row) error {
+				result = row
+				return nil
+			})
+		return result, err
+	}
+
+	_, err = run(multi_vql[0])
+	assert.NoError(t, err)
+
+	// 10 nested calls are allowed.
+	row, err := run(multi_vql[1])
+	assert.NoError(t, err)
+	depth, _ := row.(*ordereddict.Dict).Get("Depth")
+	assert.Equal(t, int64(9), depth)
+
+	// The 11th aborts the query.
+	_, err = run(multi_vql[2])
+	assert.True(t, errors.Is(err, types.ErrRecursionDepthExceeded))
+	assert.Contains(t, err.Error(), "depth nested deeper than 10 calls")
+}
@@ -0,0 +1,73 @@
+package vfilter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexCache(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	re, err := scope.CompileRegex("^a")
+	assert.NoError(t, err)
+	assert.True(t, re.MatchString("ABC"))
+
+	// Child scopes share the cache.
+	subscope := scope.Copy()
+	subscope.ClearContext()
+	cached, err := subscope.CompileRegex("^a")
+	assert.NoError(t, err)
+	assert.True(t, re == cached)
+
+	// Errors are cached too.
+	_, err = scope.CompileRegex("(")
+	assert.Error(t, err)
+	_, err = scope.CompileRegex("(")
+	assert.Error(t, err)
+
+	// The least recently used patterns are evicted.
+	scope.SetRegexCacheSize(2)
+	scope.CompileRegex("^a")
+	scope.CompileRegex("^b")
+	scope.CompileRegex("^c")
+
+	cached, _ = scope.CompileRegex("^a")
+	assert.False(t, re == cached)
+}
+
+func TestPrecompiledRegex(t *testing.T) {
+	scope := makeTestScope().AppendVars(ordereddict.NewDict().
+		Set("Re", regexp.MustCompile("^A")))
+	defer scope.Close()
+
+	run := func(query string) []interface{} {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []interface{}
+		for row := range vql.Eval(context.Background(), scope) {
+			value, _ := row.(*ordereddict.Dict).Get("_value")
+			result = append(result, value)
+		}
+		return result
+	}
+
+	// Precompiled regexes are case sensitive unless compiled with (?i).
+	assert.Equal(t, []interface{}{"Apple"},
+		run(`SELECT _value FROM foreach(row=["Apple", "apple", "Banana"])
+WHERE _value =~ Re`))
+
+	assert.Equal(t, []interface{}{"Apple", "apple"},
+		run(`SELECT _value FROM foreach(row=["Apple", "apple", "Banana"])
+WHERE _value =~ "^A"`))
+
+	// Lists match if any member matches.
+	assert.Equal(t, 1, len(run(`SELECT * FROM foreach(row=[
+  dict(Names=["x", "Ay"]), dict(Names=["x", "ay"])])
+WHERE Names =~ Re`)))
+}
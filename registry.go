@@ -0,0 +1,60 @@
+package vfilter
+
+import (
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// The package level registry. Plugin collections may register
+// themselves at init time:
+//
+//	func init() {
+//	    vfilter.RegisterPlugin(MyPlugin{})
+//	}
+//
+// so embedders only need a blank import of the collection and:
+//
+//	scope.ImportRegistry(vfilter.DefaultRegistry)
+var DefaultRegistry = types.NewRegistry()
+
+func RegisterFunction(functions ...types.FunctionInterface) {
+	DefaultRegistry.RegisterFunction(functions...)
+}
+
+func RegisterPlugin(plugins ...types.PluginGeneratorInterface) {
+	DefaultRegistry.RegisterPlugin(plugins...)
+}
+
+// Build a scope for a given trust level. Plugins and functions from
+// the DefaultRegistry are only included if the capabilities allow
+// all those they declare. Builtins and anything not declaring any
+// capabilities are always included. Example:
+//
+//	scope := NewScopeWithCapabilities(types.CapabilityFilesystem)
+func NewScopeWithCapabilities(capabilities ...string) types.Scope {
+	scope := NewScope()
+
+	for _, function := range DefaultRegistry.Functions() {
+		info := function.Info(scope, types.NewTypeMap())
+		if info != nil && hasCapabilities(capabilities, info.Capabilities) {
+			scope.AppendFunctions(function)
+		}
+	}
+
+	for _, plugin := range DefaultRegistry.Plugins() {
+		info := plugin.Info(scope, types.NewTypeMap())
+		if info != nil && hasCapabilities(capabilities, info.Capabilities) {
+			scope.AppendPlugins(plugin)
+		}
+	}
+
+	return scope
+}
+
+func hasCapabilities(allowed []string, required []string) bool {
+	for _, capability := range required {
+		if !inList(allowed, capability) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package vfilter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestImportRegistry(t *testing.T) {
+	registry := types.NewRegistry()
+
+	// Collections may register concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			registry.RegisterPlugin(plugins.GenericListPlugin{
+				PluginName: fmt.Sprintf("plugin%d", i),
+				Function: func(ctx context.Context, scope types.Scope,
+					args *ordereddict.Dict) []Row {
+					return []Row{ordereddict.NewDict().Set("Plugin", i)}
+				},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	registry.RegisterFunction(functions.GenericFunction{
+		FunctionName: "answer",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			return 42
+		},
+	})
+	assert.Equal(t, 10, len(registry.Plugins()))
+	assert.Equal(t, 1, len(registry.Functions()))
+
+	scope := NewScope().ImportRegistry(registry)
+	defer scope.Close()
+
+	vql, err := Parse("SELECT Plugin, answer() AS Answer FROM plugin7()")
+	assert.NoError(t, err)
+
+	var rows []*ordereddict.Dict
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			rows = append(rows, row.(*ordereddict.Dict))
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rows))
+
+	plugin, _ := rows[0].Get("Plugin")
+	assert.Equal(t, 7, plugin)
+
+	answer, _ := rows[0].Get("Answer")
+	assert.Equal(t, 42, answer)
+}
+
+func TestNewScopeWithCapabilities(t *testing.T) {
+	make_plugin := func(name string, capabilities ...string) plugins.GenericListPlugin {
+		return plugins.GenericListPlugin{
+			PluginName:   name,
+			Capabilities: capabilities,
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict) []Row {
+				return nil
+			},
+		}
+	}
+
+	RegisterPlugin(
+		make_plugin("test_caps_plain"),
+		make_plugin("test_caps_read_file", types.CapabilityFilesystem),
+		make_plugin("test_caps_http", types.CapabilityNetwork),
+		make_plugin("test_caps_upload",
+			types.CapabilityFilesystem, types.CapabilityNetwork))
+
+	RegisterFunction(functions.GenericFunction{
+		FunctionName: "test_caps_execve",
+		Capabilities: []string{types.CapabilityProcess},
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			return nil
+		},
+	})
+
+	for _, test := range []struct {
+		capabilities []string
+		expected     []string
+	}{
+		{nil, []string{"test_caps_plain"}},
+		{[]string{types.CapabilityFilesystem},
+			[]string{"test_caps_plain", "test_caps_read_file"}},
+		{[]string{types.CapabilityFilesystem, types.CapabilityNetwork},
+			[]string{"test_caps_plain", "test_caps_read_file",
+				"test_caps_http", "test_caps_upload"}},
+	} {
+		scope := NewScopeWithCapabilities(test.capabilities...)
+
+		var names []string
+		for _, name := range []string{"test_caps_plain", "test_caps_read_file",
+			"test_caps_http", "test_caps_upload"} {
+			_, pres := scope.GetPlugin(name)
+			if pres {
+				names = append(names, name)
+			}
+		}
+		assert.Equal(t, test.expected, names, test.capabilities)
+
+		// Builtins are always available.
+		_, pres := scope.GetPlugin("foreach")
+		assert.True(t, pres)
+
+		_, pres = scope.GetFunction("test_caps_execve")
+		assert.False(t, pres)
+
+		scope.Close()
+	}
+
+	scope := NewScopeWithCapabilities(types.CapabilityProcess)
+	defer scope.Close()
+
+	_, pres := scope.GetFunction("test_caps_execve")
+	assert.True(t, pres)
+}
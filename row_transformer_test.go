@@ -0,0 +1,61 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestRowTransformer(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// Add a constant column.
+	scope.AddRowTransformer(func(scope types.Scope, row Row) Row {
+		return RowToDict(context.Background(), scope, row).
+			Set("Hostname", "host1")
+	})
+
+	// Drop rows with a secret.
+	scope.AddRowTransformer(func(scope types.Scope, row Row) Row {
+		secret, _ := scope.Associative(row, "Secret")
+		if scope.Bool(secret) {
+			return nil
+		}
+		return row
+	})
+
+	vql, err := Parse("SELECT _value AS Value, _value = 2 AS Secret " +
+		"FROM foreach(row=[1, 2, 3]) WHERE Value > 0")
+	assert.NoError(t, err)
+
+	var result []*ordereddict.Dict
+	for row := range vql.Eval(context.Background(), scope) {
+		result = append(result, RowToDict(context.Background(), scope, row))
+	}
+
+	assert.Equal(t, 2, len(result))
+	for _, row := range result {
+		hostname, _ := row.GetString("Hostname")
+		assert.Equal(t, "host1", hostname)
+	}
+
+	// Subqueries and LET are not transformed, only the rows the
+	// query finally emits.
+	multi_vql, err := MultiParse("LET X = SELECT 1 AS A FROM scope() " +
+		"SELECT len(list=X[0]) AS Columns FROM scope()")
+	assert.NoError(t, err)
+
+	result = nil
+	for _, vql := range multi_vql {
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, RowToDict(context.Background(), scope, row))
+		}
+	}
+	assert.Equal(t, 1, len(result))
+	columns, _ := result[0].Get("Columns")
+	assert.Equal(t, 1, columns)
+}
@@ -0,0 +1,317 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Queries with a higher priority run before queries with a lower
+// priority.
+type QueryPriority int
+
+const (
+	PriorityLow    QueryPriority = -1
+	PriorityNormal QueryPriority = 0
+	PriorityHigh   QueryPriority = 1
+)
+
+type SchedulerOptions struct {
+	// Maximum number of queries running at the same time (default
+	// 1).
+	MaxConcurrent int
+
+	// Maximum number of queries a single tenant may run at the same
+	// time (default no limit).
+	MaxPerTenant int
+}
+
+type ScheduledQuery struct {
+	VQL *VQL
+
+	// The query runs in a copy of this scope so concurrent queries
+	// do not see each other's LET definitions.
+	Scope types.Scope
+
+	// Queries of the same priority are taken from each tenant in
+	// turn so one tenant can not starve the others.
+	Tenant   string
+	Priority QueryPriority
+
+	// Receives each row of the query. May be nil.
+	Callback func(row Row) error
+}
+
+type SchedulerStats struct {
+	Running int
+	Queued  int
+}
+
+var ErrSchedulerClosed = errors.New("Scheduler is closed")
+
+type schedulerWaiter struct {
+	tenant  string
+	ready   chan bool
+	granted bool
+}
+
+// The queued queries of one priority class.
+type schedulerClass struct {
+	// Tenants with queued queries in round robin order.
+	tenants []string
+	queues  map[string][]*schedulerWaiter
+}
+
+// A Scheduler runs many queries concurrently while limiting the
+// number running at once. Queries wait for a free slot in priority
+// order and tenants take turns within each priority. Example:
+//
+//	scheduler := NewScheduler(SchedulerOptions{MaxConcurrent: 4})
+//	defer scheduler.Close()
+//
+//	go func() {
+//	    err := scheduler.Run(ctx, &ScheduledQuery{
+//	        VQL: vql, Scope: scope, Tenant: "org1",
+//	        Priority: PriorityHigh,
+//	        Callback: func(row Row) error { ... },
+//	    })
+//	}()
+type Scheduler struct {
+	mu sync.Mutex
+
+	options SchedulerOptions
+	running int
+	queued  int
+	closed  bool
+
+	tenant_running map[string]int
+	classes        map[QueryPriority]*schedulerClass
+}
+
+func NewScheduler(options SchedulerOptions) *Scheduler {
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = 1
+	}
+
+	return &Scheduler{
+		options:        options,
+		tenant_running: make(map[string]int),
+		classes:        make(map[QueryPriority]*schedulerClass),
+	}
+}
+
+// Wait for a free slot then run the query. Returns the query's error
+// or the context's error if it was cancelled while queued.
+func (self *Scheduler) Run(ctx context.Context, query *ScheduledQuery) error {
+	waiter, err := self.enqueue(query)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-waiter.ready:
+	case <-ctx.Done():
+		self.cancel(query.Priority, waiter)
+		return ctx.Err()
+	}
+	defer self.release(query.Tenant)
+
+	// The scheduler was closed while we were queued.
+	if !waiter.granted {
+		return ErrSchedulerClosed
+	}
+
+	subscope := query.Scope.Copy()
+	defer subscope.Close()
+
+	callback := query.Callback
+	if callback == nil {
+		callback = func(row Row) error { return nil }
+	}
+
+	return query.VQL.EvalWithCallback(ctx, subscope, callback)
+}
+
+// Refuse new queries and fail all queued queries. Running queries
+// are not affected.
+func (self *Scheduler) Close() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.closed = true
+	for _, class := range self.classes {
+		for _, queue := range class.queues {
+			for _, waiter := range queue {
+				// release() is called for the waiter.
+				self.running++
+				self.tenant_running[waiter.tenant]++
+				close(waiter.ready)
+			}
+		}
+	}
+	self.classes = make(map[QueryPriority]*schedulerClass)
+	self.queued = 0
+}
+
+func (self *Scheduler) Stats() SchedulerStats {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return SchedulerStats{
+		Running: self.running,
+		Queued:  self.queued,
+	}
+}
+
+func (self *Scheduler) enqueue(query *ScheduledQuery) (*schedulerWaiter, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return nil, ErrSchedulerClosed
+	}
+
+	class, pres := self.classes[query.Priority]
+	if !pres {
+		class = &schedulerClass{
+			queues: make(map[string][]*schedulerWaiter),
+		}
+		self.classes[query.Priority] = class
+	}
+
+	waiter := &schedulerWaiter{
+		tenant: query.Tenant,
+		ready:  make(chan bool),
+	}
+
+	queue, pres := class.queues[query.Tenant]
+	if !pres {
+		class.tenants = append(class.tenants, query.Tenant)
+	}
+	class.queues[query.Tenant] = append(queue, waiter)
+	self.queued++
+
+	self.dispatch()
+
+	return waiter, nil
+}
+
+func (self *Scheduler) cancel(priority QueryPriority, waiter *schedulerWaiter) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	// We lost the race - the slot is ours so give it back.
+	select {
+	case <-waiter.ready:
+		self.releaseLocked(waiter.tenant)
+		return
+	default:
+	}
+
+	class, pres := self.classes[priority]
+	if !pres {
+		return
+	}
+
+	queue := class.queues[waiter.tenant]
+	for idx, w := range queue {
+		if w == waiter {
+			self.queued--
+			class.queues[waiter.tenant] = append(queue[:idx:idx], queue[idx+1:]...)
+			break
+		}
+	}
+
+	if len(class.queues[waiter.tenant]) == 0 {
+		self.removeTenant(class, waiter.tenant)
+	}
+}
+
+func (self *Scheduler) release(tenant string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.releaseLocked(tenant)
+}
+
+func (self *Scheduler) releaseLocked(tenant string) {
+	self.running--
+	self.tenant_running[tenant]--
+	if self.tenant_running[tenant] <= 0 {
+		delete(self.tenant_running, tenant)
+	}
+	self.dispatch()
+}
+
+// Start as many queued queries as there are free slots. Must be
+// called with the lock held.
+func (self *Scheduler) dispatch() {
+	priorities := make([]QueryPriority, 0, len(self.classes))
+	for k := range self.classes {
+		priorities = append(priorities, k)
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		return priorities[i] > priorities[j]
+	})
+
+	for _, priority := range priorities {
+		class := self.classes[priority]
+		for self.running < self.options.MaxConcurrent {
+			waiter := self.next(class)
+			if waiter == nil {
+				break
+			}
+
+			waiter.granted = true
+			self.running++
+			self.queued--
+			self.tenant_running[waiter.tenant]++
+			close(waiter.ready)
+		}
+
+		if len(class.tenants) == 0 {
+			delete(self.classes, priority)
+		}
+	}
+}
+
+// Take the next query from the first tenant in round robin order
+// which is below its limit.
+func (self *Scheduler) next(class *schedulerClass) *schedulerWaiter {
+	for idx, tenant := range class.tenants {
+		if self.options.MaxPerTenant > 0 &&
+			self.tenant_running[tenant] >= self.options.MaxPerTenant {
+			continue
+		}
+
+		queue := class.queues[tenant]
+		waiter := queue[0]
+		queue = queue[1:]
+
+		// The tenant goes to the back of the line.
+		class.tenants = append(class.tenants[:idx:idx], class.tenants[idx+1:]...)
+		if len(queue) > 0 {
+			class.queues[tenant] = queue
+			class.tenants = append(class.tenants, tenant)
+		} else {
+			delete(class.queues, tenant)
+		}
+
+		return waiter
+	}
+
+	return nil
+}
+
+func (self *Scheduler) removeTenant(class *schedulerClass, tenant string) {
+	delete(class.queues, tenant)
+	for idx, t := range class.tenants {
+		if t == tenant {
+			class.tenants = append(class.tenants[:idx:idx], class.tenants[idx+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package vfilter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func makeSchedulerTestScope(unblock chan bool) types.Scope {
+	return NewScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "block",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			<-unblock
+			return []Row{ordereddict.NewDict().Set("Blocked", true)}
+		},
+	})
+}
+
+func waitForStats(t *testing.T, scheduler *Scheduler, expected SchedulerStats) {
+	for i := 0; i < 500; i++ {
+		if scheduler.Stats() == expected {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected scheduler stats %v: %v", expected, scheduler.Stats())
+}
+
+func TestSchedulerConcurrency(t *testing.T) {
+	var running, max_running int64
+
+	scope := NewScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "slow",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			current := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+
+			for {
+				max := atomic.LoadInt64(&max_running)
+				if current <= max ||
+					atomic.CompareAndSwapInt64(&max_running, max, current) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			return []Row{ordereddict.NewDict().Set("Done", true)}
+		},
+	})
+	defer scope.Close()
+
+	scheduler := NewScheduler(SchedulerOptions{MaxConcurrent: 2})
+	defer scheduler.Close()
+
+	vql, err := Parse("SELECT * FROM slow()")
+	assert.NoError(t, err)
+
+	var rows int64
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := scheduler.Run(context.Background(), &ScheduledQuery{
+				VQL:   vql,
+				Scope: scope,
+				Callback: func(row Row) error {
+					atomic.AddInt64(&rows, 1)
+					return nil
+				},
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(6), rows)
+	assert.Equal(t, int64(2), max_running)
+	assert.Equal(t, SchedulerStats{}, scheduler.Stats())
+}
+
+func TestSchedulerPriorityAndFairness(t *testing.T) {
+	unblock := make(chan bool)
+	scope := makeSchedulerTestScope(unblock)
+	defer scope.Close()
+
+	scheduler := NewScheduler(SchedulerOptions{MaxConcurrent: 1})
+	defer scheduler.Close()
+
+	// Hold the only slot while the other queries queue up.
+	blocker, err := Parse("SELECT * FROM block()")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scheduler.Run(context.Background(), &ScheduledQuery{
+			VQL: blocker, Scope: scope})
+	}()
+	waitForStats(t, scheduler, SchedulerStats{Running: 1})
+
+	var mu sync.Mutex
+	var order []string
+
+	submit := func(tenant string, priority QueryPriority, name string) {
+		vql, err := Parse(fmt.Sprintf("SELECT %q AS Name FROM scope()", name))
+		assert.NoError(t, err)
+
+		queued := scheduler.Stats().Queued
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := scheduler.Run(context.Background(), &ScheduledQuery{
+				VQL:      vql,
+				Scope:    scope,
+				Tenant:   tenant,
+				Priority: priority,
+				Callback: func(row Row) error {
+					name, _ := row.(*ordereddict.Dict).Get("Name")
+					mu.Lock()
+					order = append(order, name.(string))
+					mu.Unlock()
+					return nil
+				},
+			})
+			assert.NoError(t, err)
+		}()
+
+		// Make sure the queries are queued in order.
+		waitForStats(t, scheduler, SchedulerStats{Running: 1, Queued: queued + 1})
+	}
+
+	submit("A", PriorityLow, "A-low")
+	submit("A", PriorityNormal, "A1")
+	submit("A", PriorityNormal, "A2")
+	submit("A", PriorityNormal, "A3")
+	submit("B", PriorityNormal, "B1")
+	submit("C", PriorityHigh, "C-high")
+
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, []string{"C-high", "A1", "B1", "A2", "A3", "A-low"}, order)
+}
+
+func TestSchedulerCancelAndClose(t *testing.T) {
+	unblock := make(chan bool)
+	scope := makeSchedulerTestScope(unblock)
+	defer scope.Close()
+
+	scheduler := NewScheduler(SchedulerOptions{MaxConcurrent: 1})
+
+	vql, err := Parse("SELECT * FROM block()")
+	assert.NoError(t, err)
+
+	errors := make(chan error, 3)
+	run := func(ctx context.Context) {
+		go func() {
+			errors <- scheduler.Run(ctx, &ScheduledQuery{VQL: vql, Scope: scope})
+		}()
+	}
+
+	run(context.Background())
+	waitForStats(t, scheduler, SchedulerStats{Running: 1})
+
+	// Cancelling a queued query removes it from the queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	run(ctx)
+	waitForStats(t, scheduler, SchedulerStats{Running: 1, Queued: 1})
+	cancel()
+	assert.Equal(t, context.Canceled, <-errors)
+	assert.Equal(t, SchedulerStats{Running: 1}, scheduler.Stats())
+
+	// Closing fails queued queries but not running ones.
+	run(context.Background())
+	waitForStats(t, scheduler, SchedulerStats{Running: 1, Queued: 1})
+	scheduler.Close()
+	assert.Equal(t, ErrSchedulerClosed, <-errors)
+
+	close(unblock)
+	assert.NoError(t, <-errors)
+
+	assert.Equal(t, ErrSchedulerClosed, scheduler.Run(context.Background(),
+		&ScheduledQuery{VQL: vql, Scope: scope}))
+	assert.Equal(t, SchedulerStats{}, scheduler.Stats())
+}
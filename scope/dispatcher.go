@@ -1,13 +1,16 @@
 package scope
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/functions"
 	"www.velocidex.com/golang/vfilter/grouper"
 	"www.velocidex.com/golang/vfilter/materializer"
 	"www.velocidex.com/golang/vfilter/protocols"
@@ -24,6 +27,10 @@ type protocolDispatcher struct {
 	functions map[string]types.FunctionInterface
 	plugins   map[string]types.PluginGeneratorInterface
 
+	// Plugins registered with AliasPlugin(). Values are plugin
+	// names, plugins or PluginNamespaces.
+	plugin_aliases map[string]types.Any
+
 	Stats *types.Stats
 
 	// Protocol dispatchers control operators.
@@ -38,6 +45,7 @@ type protocolDispatcher struct {
 	membership  protocols.MembershipDispatcher
 	associative protocols.AssociativeDispatcher
 	regex       protocols.RegexDispatcher
+	glob        protocols.GlobDispatcher
 	iterator    protocols.IterateDispatcher
 
 	// Sorters allow VQL to sort result sets.
@@ -45,6 +53,65 @@ type protocolDispatcher struct {
 	Grouper      types.Grouper
 	Materializer types.ScopeMaterializer
 	explainer    types.Explainer
+	profiler     types.Profiler
+
+	// Receives runtime errors
+	error_collector types.ErrorCollector
+
+	// Resolves unregistered plugins and functions.
+	unknown_handler types.UnknownPluginHandler
+
+	// Applied to rows emitted by top level queries.
+	row_transformers []types.RowTransformer
+
+	// Signs the hash of query results.
+	result_signer types.ResultSigner
+
+	// Wrap every plugin call.
+	plugin_middleware []types.PluginMiddleware
+
+	// Decides which plugins and functions may be called.
+	security_policy types.SecurityPolicy
+
+	// Caches results of cacheable plugins for query_cache_ttl.
+	query_cache     types.QueryCache
+	query_cache_ttl time.Duration
+
+	// Maximum time a query may run for.
+	max_duration time.Duration
+
+	// Guards against pathological queries.
+	max_scope_depth    int
+	max_rows           int64
+	max_subquery_depth int
+
+	// Memory each query may use for materialized rows.
+	memory_quota int64
+
+	// Deduplicates strings in result rows. nil when disabled.
+	string_interner *utils.StringInterner
+
+	// Undefined symbols abort the query.
+	strict bool
+
+	// Each top level query sees a snapshot of the variables.
+	snapshot_isolation bool
+
+	// Plugins do not run their subqueries concurrently.
+	deterministic bool
+
+	// Warn when a query hides an existing variable.
+	shadowing_warnings bool
+
+	// Rows per batch for plugins which support batches. 0 when
+	// disabled.
+	batch_size int
+
+	// How deeply LET functions may call themselves.
+	max_recursion_depth int
+
+	// The WHERE clause only sees the source row, not the aliases.
+	no_where_aliases bool
 
 	Logger *log.Logger
 
@@ -53,6 +120,17 @@ type protocolDispatcher struct {
 	Tracer *log.Logger
 
 	context *ordereddict.Dict
+
+	go_context_values *goContextValues
+
+	// Subqueries named with FROM ... AS name.
+	subqueries *namedSubqueries
+
+	// Regexes compiled for the =~ operator.
+	regexes *regexCache
+
+	// LET definitions made in this scope.
+	definitions *ordereddict.Dict
 }
 
 func (self *protocolDispatcher) SetContext(context *ordereddict.Dict) {
@@ -73,6 +151,20 @@ func (self *protocolDispatcher) SetGrouper(grouper types.Grouper) {
 	self.Unlock()
 }
 
+func (self *protocolDispatcher) GetSorter() types.Sorter {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.Sorter
+}
+
+func (self *protocolDispatcher) GetGrouper() types.Grouper {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.Grouper
+}
+
 func (self *protocolDispatcher) SetMaterializer(materializer types.ScopeMaterializer) {
 	self.Lock()
 	self.Materializer = materializer
@@ -96,6 +188,340 @@ func (self *protocolDispatcher) Explainer() types.Explainer {
 	return res
 }
 
+func (self *protocolDispatcher) SetProfiler(profiler types.Profiler) {
+	self.Lock()
+	self.profiler = profiler
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) Profiler() types.Profiler {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.profiler
+}
+
+func (self *protocolDispatcher) SetErrorCollector(collector types.ErrorCollector) {
+	self.Lock()
+	self.error_collector = collector
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) ErrorCollector() types.ErrorCollector {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.error_collector
+}
+
+func (self *protocolDispatcher) SetUnknownPluginHandler(
+	handler types.UnknownPluginHandler) {
+	self.Lock()
+	self.unknown_handler = handler
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) UnknownPluginHandler() types.UnknownPluginHandler {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.unknown_handler
+}
+
+func (self *protocolDispatcher) AddRowTransformer(transformer types.RowTransformer) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.row_transformers = append(self.row_transformers, transformer)
+}
+
+func (self *protocolDispatcher) RowTransformers() []types.RowTransformer {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.row_transformers
+}
+
+func (self *protocolDispatcher) AddPluginMiddleware(
+	middleware types.PluginMiddleware) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.plugin_middleware = append(self.plugin_middleware, middleware)
+}
+
+func (self *protocolDispatcher) PluginMiddleware() []types.PluginMiddleware {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.plugin_middleware
+}
+
+func (self *protocolDispatcher) SetSecurityPolicy(policy types.SecurityPolicy) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.security_policy = policy
+}
+
+func (self *protocolDispatcher) SecurityPolicy() types.SecurityPolicy {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.security_policy
+}
+
+func (self *protocolDispatcher) SetQueryCache(
+	cache types.QueryCache, ttl time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.query_cache = cache
+	self.query_cache_ttl = ttl
+}
+
+func (self *protocolDispatcher) QueryCache() (types.QueryCache, time.Duration) {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.query_cache, self.query_cache_ttl
+}
+
+func (self *protocolDispatcher) SetResultSigner(signer types.ResultSigner) {
+	self.Lock()
+	defer self.Unlock()
+
+	self.result_signer = signer
+}
+
+func (self *protocolDispatcher) ResultSigner() types.ResultSigner {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.result_signer
+}
+
+func (self *protocolDispatcher) SetMaxDuration(max_duration time.Duration) {
+	self.Lock()
+	self.max_duration = max_duration
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) MaxDuration() time.Duration {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.max_duration
+}
+
+func (self *protocolDispatcher) SetMaxScopeDepth(depth int) {
+	self.Lock()
+	self.max_scope_depth = depth
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) MaxScopeDepth() int {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.max_scope_depth <= 0 {
+		return DefaultMaxScopeDepth
+	}
+	return self.max_scope_depth
+}
+
+func (self *protocolDispatcher) SetMaxRows(rows int64) {
+	self.Lock()
+	self.max_rows = rows
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) MaxRows() int64 {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.max_rows
+}
+
+func (self *protocolDispatcher) SetMaxSubqueryDepth(depth int) {
+	self.Lock()
+	self.max_subquery_depth = depth
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) MaxSubqueryDepth() int {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.max_subquery_depth
+}
+
+func (self *protocolDispatcher) SetStringInterner(interner *utils.StringInterner) {
+	self.Lock()
+	self.string_interner = interner
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) StringInterner() *utils.StringInterner {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.string_interner
+}
+
+func (self *protocolDispatcher) SetFloatEpsilon(epsilon float64) {
+	self.Lock()
+	self.eq.SetEpsilon(epsilon)
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) FloatEpsilon() float64 {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.eq.Epsilon()
+}
+
+func (self *protocolDispatcher) SetWhereAliases(enabled bool) {
+	self.Lock()
+	self.no_where_aliases = !enabled
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) WhereAliases() bool {
+	self.Lock()
+	defer self.Unlock()
+
+	return !self.no_where_aliases
+}
+
+func (self *protocolDispatcher) SetStrictMode(strict bool) {
+	self.Lock()
+	self.strict = strict
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) StrictMode() bool {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.strict
+}
+
+func (self *protocolDispatcher) SetSnapshotIsolation(enabled bool) {
+	self.Lock()
+	self.snapshot_isolation = enabled
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) SnapshotIsolation() bool {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.snapshot_isolation
+}
+
+func (self *protocolDispatcher) SetDeterministic(enabled bool) {
+	self.Lock()
+	self.deterministic = enabled
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) Deterministic() bool {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.deterministic
+}
+
+func (self *protocolDispatcher) SetShadowingWarnings(enabled bool) {
+	self.Lock()
+	self.shadowing_warnings = enabled
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) ShadowingWarnings() bool {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.shadowing_warnings
+}
+
+func (self *protocolDispatcher) SetBatchSize(size int) {
+	self.Lock()
+	self.batch_size = size
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) BatchSize() int {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.batch_size
+}
+
+func (self *protocolDispatcher) SetMaxRecursionDepth(depth int) {
+	self.Lock()
+	self.max_recursion_depth = depth
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) MaxRecursionDepth() int {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.max_recursion_depth
+}
+
+func (self *protocolDispatcher) SetMemoryQuota(quota int64) {
+	self.Lock()
+	self.memory_quota = quota
+	self.Unlock()
+}
+
+func (self *protocolDispatcher) ChargeMemory(
+	ctx context.Context, size int) error {
+	self.Lock()
+	quota := self.memory_quota
+	self.Unlock()
+
+	used := types.ChargeQueryMemory(ctx, int64(size))
+	if quota > 0 && used > quota {
+		return types.ErrMemoryQuotaExceeded
+	}
+	return nil
+}
+
+func (self *protocolDispatcher) AddDefinition(definition *types.Definition) {
+	self.Lock()
+	defer self.Unlock()
+
+	// Move redefined symbols to the end.
+	self.definitions.Delete(definition.Name)
+	self.definitions.Set(definition.Name, definition)
+}
+
+func (self *protocolDispatcher) GetDefinitions() []*types.Definition {
+	self.Lock()
+	defer self.Unlock()
+
+	result := make([]*types.Definition, 0, self.definitions.Len())
+	for _, k := range self.definitions.Keys() {
+		v, _ := self.definitions.Get(k)
+		result = append(result, v.(*types.Definition))
+	}
+	return result
+}
+
+func copyDict(in *ordereddict.Dict) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	for _, k := range in.Keys() {
+		v, _ := in.Get(k)
+		result.Set(k, v)
+	}
+	return result
+}
+
 func (self *protocolDispatcher) SetContextValue(name string, value types.Any) {
 	self.Lock()
 	defer self.Unlock()
@@ -133,6 +559,13 @@ func (self *protocolDispatcher) Describe(scope *Scope, type_map *types.TypeMap)
 	}
 
 	for _, func_item := range self.functions {
+		overloaded, ok := func_item.(*functions.OverloadedFunction)
+		if ok {
+			for _, item := range overloaded.Overloads() {
+				result.Functions = append(result.Functions, item.Info(scope, type_map))
+			}
+			continue
+		}
 		result.Functions = append(result.Functions, func_item.Info(scope, type_map))
 	}
 
@@ -156,12 +589,42 @@ func (self *protocolDispatcher) WithNewContext() *protocolDispatcher {
 		membership:   self.membership,
 		associative:  self.associative,
 		regex:        self.regex,
+		glob:         self.glob,
 		iterator:     self.iterator,
 		Sorter:       self.Sorter,
 		Grouper:      self.Grouper,
 		Materializer: self.Materializer,
+		profiler:     self.profiler,
+		max_duration: self.max_duration,
+		memory_quota: self.memory_quota,
+		strict:       self.strict,
 		Logger:       self.Logger,
 		Tracer:       self.Tracer,
+
+		go_context_values: self.go_context_values,
+		subqueries:        self.subqueries,
+		regexes:           self.regexes,
+		definitions:       self.definitions,
+		no_where_aliases:  self.no_where_aliases,
+		error_collector:   self.error_collector,
+		unknown_handler:   self.unknown_handler,
+		plugin_aliases:    self.plugin_aliases,
+		row_transformers:  self.row_transformers,
+		result_signer:     self.result_signer,
+		plugin_middleware: self.plugin_middleware,
+		security_policy:   self.security_policy,
+		query_cache:       self.query_cache,
+		query_cache_ttl:   self.query_cache_ttl,
+
+		snapshot_isolation:  self.snapshot_isolation,
+		deterministic:       self.deterministic,
+		shadowing_warnings:  self.shadowing_warnings,
+		batch_size:          self.batch_size,
+		max_recursion_depth: self.max_recursion_depth,
+		max_scope_depth:     self.max_scope_depth,
+		max_rows:            self.max_rows,
+		max_subquery_depth:  self.max_subquery_depth,
+		string_interner:     self.string_interner,
 	}
 }
 
@@ -176,6 +639,11 @@ func (self *protocolDispatcher) Copy() *protocolDispatcher {
 		plugins_copy[k] = v
 	}
 
+	aliases_copy := make(map[string]types.Any)
+	for k, v := range self.plugin_aliases {
+		aliases_copy[k] = v
+	}
+
 	return &protocolDispatcher{
 		Stats:        &types.Stats{},
 		context:      ordereddict.NewDict(),
@@ -192,13 +660,44 @@ func (self *protocolDispatcher) Copy() *protocolDispatcher {
 		membership:   self.membership.Copy(),
 		associative:  self.associative.Copy(),
 		regex:        self.regex.Copy(),
+		glob:         self.glob.Copy(),
 		iterator:     self.iterator.Copy(),
 		Sorter:       self.Sorter,
 		Grouper:      self.Grouper,
 		Materializer: self.Materializer,
 		explainer:    self.explainer,
+		profiler:     self.profiler,
+		max_duration: self.max_duration,
+		memory_quota: self.memory_quota,
+		strict:       self.strict,
 		Logger:       self.Logger,
 		Tracer:       self.Tracer,
+
+		go_context_values: self.go_context_values,
+		subqueries:        newNamedSubqueries(),
+		regexes:           newRegexCache(self.regexes.Size()),
+		definitions:       copyDict(self.definitions),
+		no_where_aliases:  self.no_where_aliases,
+		error_collector:   self.error_collector,
+		unknown_handler:   self.unknown_handler,
+		plugin_aliases:    aliases_copy,
+		row_transformers:  append([]types.RowTransformer{}, self.row_transformers...),
+		result_signer:     self.result_signer,
+		plugin_middleware: append([]types.PluginMiddleware{},
+			self.plugin_middleware...),
+		security_policy: self.security_policy,
+		query_cache:     self.query_cache,
+		query_cache_ttl: self.query_cache_ttl,
+
+		snapshot_isolation:  self.snapshot_isolation,
+		deterministic:       self.deterministic,
+		shadowing_warnings:  self.shadowing_warnings,
+		batch_size:          self.batch_size,
+		max_recursion_depth: self.max_recursion_depth,
+		max_scope_depth:     self.max_scope_depth,
+		max_rows:            self.max_rows,
+		max_subquery_depth:  self.max_subquery_depth,
+		string_interner:     self.string_interner,
 	}
 }
 
@@ -226,6 +725,22 @@ func (self *protocolDispatcher) AppendFunctions(
 	}
 }
 
+func (self *protocolDispatcher) OverloadFunctions(
+	scope *Scope, overloads ...types.FunctionInterface) {
+	self.Lock()
+	defer self.Unlock()
+
+	for _, function := range overloads {
+		info := function.Info(scope, nil)
+		existing, pres := self.functions[info.Name]
+		if !pres {
+			self.functions[info.Name] = function
+			continue
+		}
+		self.functions[info.Name] = functions.Overload(scope, existing, function)
+	}
+}
+
 func (self *protocolDispatcher) GetFunction(name string) (types.FunctionInterface, bool) {
 	res, pres := self.functions[name]
 	return res, pres
@@ -236,6 +751,63 @@ func (self *protocolDispatcher) GetPlugin(name string) (types.PluginGeneratorInt
 	return res, pres
 }
 
+func (self *protocolDispatcher) AliasPlugin(alias string, target types.Any) error {
+	switch target.(type) {
+	case string, types.PluginGeneratorInterface, types.PluginNamespace:
+	default:
+		return fmt.Errorf("AliasPlugin: Unsupported target %T for %v",
+			target, alias)
+	}
+
+	self.Lock()
+	self.plugin_aliases[alias] = target
+	self.Unlock()
+
+	return nil
+}
+
+// Resolve a plugin through the aliases. An alias for the exact name
+// takes precedence, otherwise we look for a namespace registered for
+// the longest dotted prefix of the name.
+func (self *protocolDispatcher) GetAliasedPlugin(
+	scope *Scope, name string, depth int) (types.PluginGeneratorInterface, bool) {
+	// Aliases may refer to other aliases but must not loop.
+	if depth > 10 {
+		return nil, false
+	}
+
+	self.Lock()
+	target, pres := self.plugin_aliases[name]
+	self.Unlock()
+
+	if pres {
+		switch t := target.(type) {
+		case string:
+			plugin, pres := self.GetPlugin(t)
+			if pres {
+				return plugin, true
+			}
+			return self.GetAliasedPlugin(scope, t, depth+1)
+
+		case types.PluginGeneratorInterface:
+			return t, true
+		}
+	}
+
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		self.Lock()
+		target, pres := self.plugin_aliases[name[:i]]
+		self.Unlock()
+
+		namespace, ok := target.(types.PluginNamespace)
+		if pres && ok {
+			return namespace.GetPlugin(scope, name[i+1:])
+		}
+	}
+
+	return nil, false
+}
+
 func (self *protocolDispatcher) Info(scope *Scope,
 	type_map *types.TypeMap, name string) (*types.PluginInfo, bool) {
 	self.Lock()
@@ -300,6 +872,8 @@ func (self *protocolDispatcher) AddProtocolImpl(implementations ...types.Any) {
 			self.associative.AddImpl(t)
 		case protocols.RegexProtocol:
 			self.regex.AddImpl(t)
+		case protocols.GlobProtocol:
+			self.glob.AddImpl(t)
 		case protocols.IterateProtocol:
 			self.iterator.AddImpl(t)
 		default:
@@ -339,5 +913,11 @@ func newprotocolDispatcher() *protocolDispatcher {
 		plugins:      make(map[string]types.PluginGeneratorInterface),
 		context:      ordereddict.NewDict(),
 		Stats:        &types.Stats{},
+
+		go_context_values: newGoContextValues(),
+		subqueries:        newNamedSubqueries(),
+		regexes:           newRegexCache(DefaultRegexCacheSize),
+		plugin_aliases:    make(map[string]types.Any),
+		definitions:       ordereddict.NewDict(),
 	}
 }
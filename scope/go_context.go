@@ -0,0 +1,54 @@
+package scope
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Values provided by the embedding application (e.g. auth tokens or
+// trace IDs). These are shared by all scopes derived from the same
+// root scope, including those made by NewScope() or with a new
+// context.
+//
+// The values are looked up for every ctx.Value() call during the
+// query so lookups do not lock: Set() replaces the map instead of
+// modifying it.
+type goContextValues struct {
+	mu     sync.Mutex
+	values atomic.Value // map[interface{}]interface{}
+}
+
+func newGoContextValues() *goContextValues {
+	result := &goContextValues{}
+	result.values.Store(make(map[interface{}]interface{}))
+	return result
+}
+
+func (self *goContextValues) Set(key, value interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	old_values := self.values.Load().(map[interface{}]interface{})
+	new_values := make(map[interface{}]interface{}, len(old_values)+1)
+	for k, v := range old_values {
+		new_values[k] = v
+	}
+	new_values[key] = value
+	self.values.Store(new_values)
+}
+
+func (self *goContextValues) Get(key interface{}) (interface{}, bool) {
+	values := self.values.Load().(map[interface{}]interface{})
+	value, pres := values[key]
+	return value, pres
+}
+
+// Store a value which plugins and functions can retrieve from their
+// context.Context using ctx.Value(key) or from the scope.
+func (self *Scope) SetGoContextValue(key, value interface{}) {
+	self.dispatcher.go_context_values.Set(key, value)
+}
+
+func (self *Scope) GetGoContextValue(key interface{}) (interface{}, bool) {
+	return self.dispatcher.go_context_values.Get(key)
+}
@@ -0,0 +1,99 @@
+package scope
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/protocols"
+)
+
+// Number of compiled regexes kept by default.
+const DefaultRegexCacheSize = 1000
+
+// An LRU cache of the regexes compiled for the =~ operator. Patterns
+// which fail to compile are cached too so the error is not repeated
+// for every row. The cache is shared by all scopes derived from the
+// same root scope.
+type regexCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type regexCacheEntry struct {
+	pattern string
+	re      *regexp.Regexp
+	err     error
+}
+
+func newRegexCache(size int) *regexCache {
+	return &regexCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (self *regexCache) Compile(pattern string) (*regexp.Regexp, error) {
+	self.mu.Lock()
+	element, pres := self.entries[pattern]
+	if pres {
+		self.lru.MoveToFront(element)
+		entry := element.Value.(*regexCacheEntry)
+		self.mu.Unlock()
+		return entry.re, entry.err
+	}
+	self.mu.Unlock()
+
+	// Compile without holding the lock - another goroutine may
+	// compile the same pattern at the same time, which is harmless.
+	re, err := protocols.CompileRegex(pattern)
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	_, pres = self.entries[pattern]
+	if !pres && self.size > 0 {
+		self.entries[pattern] = self.lru.PushFront(&regexCacheEntry{
+			pattern: pattern,
+			re:      re,
+			err:     err,
+		})
+
+		for self.lru.Len() > self.size {
+			oldest := self.lru.Back()
+			self.lru.Remove(oldest)
+			delete(self.entries, oldest.Value.(*regexCacheEntry).pattern)
+		}
+	}
+
+	return re, err
+}
+
+func (self *regexCache) SetSize(size int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.size = size
+	for self.lru.Len() > self.size {
+		oldest := self.lru.Back()
+		self.lru.Remove(oldest)
+		delete(self.entries, oldest.Value.(*regexCacheEntry).pattern)
+	}
+}
+
+func (self *regexCache) Size() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.size
+}
+
+func (self *regexCache) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return self.lru.Len()
+}
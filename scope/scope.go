@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"runtime/debug"
 	"strings"
 	"sync"
@@ -24,11 +25,15 @@ var (
 	idx uint64
 )
 
+// Scopes nested deeper than this are not evaluated unless the scope
+// sets a different MaxScopeDepth.
+const DefaultMaxScopeDepth = 1000
+
 // Destructors are attached to each scope in the stack - they are
 // called when scope.Close() is called.
 type _destructors struct {
 	mu           sync.Mutex
-	fn           []func()
+	fn           []func(ctx context.Context)
 	is_destroyed bool
 	wg           sync.WaitGroup
 }
@@ -47,14 +52,14 @@ func (self *_destructors) IsDestroyed() bool {
 	return self.is_destroyed
 }
 
-func (self *_destructors) AddDestructor(fn func()) {
+func (self *_destructors) AddDestructor(fn func(ctx context.Context)) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
 	self.fn = append(self.fn, fn)
 }
 
-func (self *_destructors) RemoveDestructors() []func() {
+func (self *_destructors) RemoveDestructors() []func(ctx context.Context) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 
@@ -63,6 +68,9 @@ func (self *_destructors) RemoveDestructors() []func() {
 	return result
 }
 
+// How long to wait for each destructor when closing the scope.
+var DestructorTimeout = 60 * time.Second
+
 /* The scope is a common environment passed to all plugins, functions
    and operators.
 
@@ -105,6 +113,10 @@ type Scope struct {
 	throttler types.Throttler
 
 	id uint64
+
+	// The VQL call chain which led to this scope.
+	frame_mu   sync.Mutex
+	call_frame *types.CallFrame
 }
 
 func (self *Scope) SetLogger(logger *log.Logger) {
@@ -217,16 +229,21 @@ func (self *Scope) ChargeOp() {
 }
 
 func (self *Scope) CheckForOverflow() bool {
+	limit := self.dispatcher.MaxScopeDepth()
+
 	self.Lock()
 	defer self.Unlock()
 
-	if self.stack_depth < 1000 {
+	if self.stack_depth < limit {
 		return false
 	}
 
 	// Log the query for overflow
 	query, _ := self._Resolve("$Query")
-	self.Log("Stack Overflow: %v", query)
+	err := fmt.Errorf("%w: more than %v nested scopes",
+		types.ErrScopeDepthExceeded, limit)
+	self.Log("ERROR:Stack Overflow: %v: %v", err, query)
+	self.ReportError(err)
 
 	return true
 }
@@ -291,6 +308,11 @@ func (self *Scope) Match(a types.Any, b types.Any) bool {
 	return self.dispatcher.regex.Match(self, a, b)
 }
 
+// Does the glob a match object b.
+func (self *Scope) Glob(a types.Any, b types.Any) bool {
+	return self.dispatcher.glob.Glob(self, a, b)
+}
+
 func (self *Scope) Iterate(ctx context.Context, a types.Any) <-chan types.Row {
 	return self.dispatcher.iterator.Iterate(ctx, self, a)
 }
@@ -325,6 +347,7 @@ func (self *Scope) Copy() types.Scope {
 		enable_explainer: self.enable_explainer,
 		throttler:        self.throttler,
 		id:               NextId(),
+		call_frame:       self.getCallFrame(),
 	}
 
 	// Compact the children list lazily
@@ -349,6 +372,43 @@ func (self *Scope) Copy() types.Scope {
 	return child_scope
 }
 
+// Like Copy() but the child also gets its own copy of the variables
+// which are dicts. A query running in the snapshot does not see
+// changes the caller makes to these dicts while it runs.
+func (self *Scope) Snapshot() types.Scope {
+	child_scope := self.Copy().(*Scope)
+
+	child_scope.Lock()
+	defer child_scope.Unlock()
+
+	for idx, vars := range child_scope.vars {
+		dict, ok := vars.(*ordereddict.Dict)
+		if ok {
+			child_scope.vars[idx] = snapshotDict(dict)
+		}
+	}
+
+	return child_scope
+}
+
+func snapshotDict(in *ordereddict.Dict) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	if in.IsCaseInsensitive() {
+		result.SetCaseInsensitive()
+	}
+
+	for _, k := range in.Keys() {
+		v, _ := in.Get(k)
+		result.Set(k, v)
+	}
+
+	default_value := in.GetDefault()
+	if default_value != nil {
+		result.SetDefault(default_value)
+	}
+	return result
+}
+
 // Add various protocol implementations into this
 // scope. Implementations must be one of the supported protocols or
 // this function will panic.
@@ -376,6 +436,14 @@ func (self *Scope) AppendFunctions(functions ...types.FunctionInterface) types.S
 	return self
 }
 
+// Add functions to the scope without replacing functions of the
+// same name. Each call picks the most recently added function which
+// accepts the args given.
+func (self *Scope) OverloadFunctions(functions ...types.FunctionInterface) types.Scope {
+	self.dispatcher.OverloadFunctions(self, functions...)
+	return self
+}
+
 // Add plugins (data sources) to the scope. VQL queries may select
 // from these newly added plugins.
 func (self *Scope) AppendPlugins(plugins ...types.PluginGeneratorInterface) types.Scope {
@@ -383,12 +451,30 @@ func (self *Scope) AppendPlugins(plugins ...types.PluginGeneratorInterface) type
 	return self
 }
 
+// Add all the functions and plugins in the registry to the scope.
+func (self *Scope) ImportRegistry(registry *types.Registry) types.Scope {
+	self.dispatcher.AppendFunctions(self, registry.Functions()...)
+	self.dispatcher.AppendPlugins(self, registry.Plugins()...)
+	return self
+}
+
 func (self *Scope) GetFunction(name string) (types.FunctionInterface, bool) {
 	return self.dispatcher.GetFunction(name)
 }
 
 func (self *Scope) GetPlugin(name string) (types.PluginGeneratorInterface, bool) {
-	return self.dispatcher.GetPlugin(name)
+	plugin, pres := self.dispatcher.GetPlugin(name)
+	if pres {
+		return plugin, true
+	}
+	return self.dispatcher.GetAliasedPlugin(self, name, 0)
+}
+
+// Make a plugin available under another (usually dotted) name. The
+// target may be the name of another plugin, a plugin or a
+// PluginNamespace which resolves all the names under the alias.
+func (self *Scope) AliasPlugin(alias string, target types.Any) error {
+	return self.dispatcher.AliasPlugin(alias, target)
 }
 
 func (self *Scope) Info(type_map *types.TypeMap, name string) (*types.PluginInfo, bool) {
@@ -396,11 +482,175 @@ func (self *Scope) Info(type_map *types.TypeMap, name string) (*types.PluginInfo
 }
 
 func (self *Scope) Log(format string, a ...interface{}) {
+	if strings.HasPrefix(format, "ERROR:") {
+		format, a = self.addCallChain(format, a)
+	}
 	self.dispatcher.Log(format, a...)
 }
 
 func (self *Scope) Error(format string, a ...interface{}) {
-	self.dispatcher.Log("ERROR:"+format, a...)
+	format, a = self.addCallChain("ERROR:"+format, a)
+	self.dispatcher.Log(format, a...)
+}
+
+func (self *Scope) SetUnknownPluginHandler(handler types.UnknownPluginHandler) {
+	self.dispatcher.SetUnknownPluginHandler(handler)
+}
+
+func (self *Scope) UnknownPluginHandler() types.UnknownPluginHandler {
+	return self.dispatcher.UnknownPluginHandler()
+}
+
+// Transformers are applied in order to every row emitted by a top
+// level query run with this scope.
+func (self *Scope) AddRowTransformer(transformer types.RowTransformer) {
+	self.dispatcher.AddRowTransformer(transformer)
+}
+
+// Apply all the row transformers. Returns nil if the row should be
+// dropped.
+func (self *Scope) TransformRow(row types.Row) types.Row {
+	for _, transformer := range self.dispatcher.RowTransformers() {
+		row = transformer(self, row)
+		if utils.IsNil(row) {
+			return nil
+		}
+	}
+	return row
+}
+
+// Middleware wraps every plugin call made with this scope. The first
+// middleware added is the outermost.
+func (self *Scope) AddPluginMiddleware(middleware types.PluginMiddleware) {
+	self.dispatcher.AddPluginMiddleware(middleware)
+}
+
+// Call the plugin through the middleware chain.
+func (self *Scope) CallPlugin(ctx context.Context, name string,
+	plugin types.PluginGeneratorInterface,
+	args *ordereddict.Dict) <-chan types.Row {
+	next := types.PluginCallNext(plugin.Call)
+
+	middleware := self.dispatcher.PluginMiddleware()
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = wrapPluginCall(name, middleware[i], next)
+	}
+
+	return next(ctx, self, args)
+}
+
+func (self *Scope) CallPluginBatches(ctx context.Context, name string,
+	plugin types.PluginGeneratorInterface,
+	args *ordereddict.Dict) <-chan []types.Row {
+	batch_plugin, ok := plugin.(types.BatchPluginGeneratorInterface)
+	if ok && self.BatchSize() > 0 &&
+		len(self.dispatcher.PluginMiddleware()) == 0 {
+		return batch_plugin.CallBatches(ctx, self, args)
+	}
+
+	output_chan := make(chan []types.Row)
+
+	go func() {
+		defer close(output_chan)
+
+		for row := range self.CallPlugin(ctx, name, plugin, args) {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- []types.Row{row}:
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+func wrapPluginCall(name string, middleware types.PluginMiddleware,
+	next types.PluginCallNext) types.PluginCallNext {
+	return func(ctx context.Context, scope types.Scope,
+		args *ordereddict.Dict) <-chan types.Row {
+		return middleware(ctx, scope, name, args, next)
+	}
+}
+
+// When a security policy is set every plugin and function call is
+// checked against it.
+func (self *Scope) SetSecurityPolicy(policy types.SecurityPolicy) {
+	self.dispatcher.SetSecurityPolicy(policy)
+}
+
+func (self *Scope) SecurityPolicy() types.SecurityPolicy {
+	return self.dispatcher.SecurityPolicy()
+}
+
+// Results of cacheable plugins and stored queries are reused from the
+// cache for ttl. A nil cache disables caching.
+func (self *Scope) SetQueryCache(cache types.QueryCache, ttl time.Duration) {
+	self.dispatcher.SetQueryCache(cache, ttl)
+}
+
+func (self *Scope) QueryCache() (types.QueryCache, time.Duration) {
+	return self.dispatcher.QueryCache()
+}
+
+// The signer is used to sign the hash of query results (see
+// OutputOptions.Hash).
+func (self *Scope) SetResultSigner(signer types.ResultSigner) {
+	self.dispatcher.SetResultSigner(signer)
+}
+
+func (self *Scope) ResultSigner() types.ResultSigner {
+	return self.dispatcher.ResultSigner()
+}
+
+func (self *Scope) SetErrorCollector(collector types.ErrorCollector) {
+	self.dispatcher.SetErrorCollector(collector)
+}
+
+func (self *Scope) ReportError(err error) {
+	collector := self.dispatcher.ErrorCollector()
+	if collector == nil || err == nil {
+		return
+	}
+
+	collector.CollectError(&types.QueryError{
+		Err:       err,
+		CallChain: self.CallChain(),
+	})
+}
+
+func (self *Scope) addCallChain(
+	format string, a []interface{}) (string, []interface{}) {
+	chain := self.CallChain()
+	if chain == "" {
+		return format, a
+	}
+	return strings.TrimRight(format, "\n") + " (VQL call chain: %v)",
+		append(a, chain)
+}
+
+func (self *Scope) PushCallFrame(description string) {
+	self.frame_mu.Lock()
+	defer self.frame_mu.Unlock()
+
+	self.call_frame = self.call_frame.Push(description)
+}
+
+func (self *Scope) CallChain() string {
+	self.frame_mu.Lock()
+	defer self.frame_mu.Unlock()
+
+	if self.call_frame == nil {
+		return ""
+	}
+	return self.call_frame.String()
+}
+
+func (self *Scope) getCallFrame() *types.CallFrame {
+	self.frame_mu.Lock()
+	defer self.frame_mu.Unlock()
+
+	return self.call_frame
 }
 
 func (self *Scope) Debug(format string, a ...interface{}) {
@@ -418,17 +668,27 @@ func (self *Scope) Trace(format string, a ...interface{}) {
 func (self *Scope) Sort(
 	ctx context.Context, scope types.Scope, input <-chan types.Row,
 	key string, desc bool) <-chan types.Row {
-	return self.dispatcher.Sorter.Sort(ctx, scope, input, key, desc)
+	return self.dispatcher.GetSorter().Sort(ctx, scope, input, key, desc)
 }
 
 func (self *Scope) Group(
 	ctx context.Context, scope types.Scope, actor types.GroupbyActor) <-chan types.Row {
-	return self.dispatcher.Grouper.Group(ctx, scope, actor)
+	return self.dispatcher.GetGrouper().Group(ctx, scope, actor)
 }
 
 // Adding a destructor to the current scope will call it when any
 // parent scopes are closed.
 func (self *Scope) AddDestructor(fn func()) error {
+	return self.AddDestructorWithContext(func(ctx context.Context) {
+		fn()
+	})
+}
+
+// Like AddDestructor but the destructor receives a context which
+// expires when the scope stops waiting for it (after
+// DestructorTimeout). Destructors may use it to switch from a
+// graceful to a forced shutdown.
+func (self *Scope) AddDestructorWithContext(fn func(ctx context.Context)) error {
 	self.Lock()
 	self.Unlock()
 
@@ -498,17 +758,21 @@ func (self *Scope) Close() {
 	// Destructors are called in reverse order to their
 	// declerations.
 	for i := len(ds) - 1; i >= 0; i-- {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
-		go func() {
-			ds[i]()
-			cancel()
-		}()
+		ctx, cancel := context.WithTimeout(
+			context.Background(), DestructorTimeout)
+		done := make(chan bool)
+		go func(fn func(ctx context.Context)) {
+			defer close(done)
+			fn(ctx)
+		}(ds[i])
 
 		select {
-		// Wait a maximum 60 seconds for the
+		// Wait a maximum DestructorTimeout for the
 		// destructor before moving on.
 		case <-ctx.Done():
+		case <-done:
 		}
+		cancel()
 	}
 }
 
@@ -551,6 +815,16 @@ func (self *Scope) SetGrouper(grouper types.Grouper) {
 	self.dispatcher.SetGrouper(grouper)
 }
 
+// The current sorter and grouper, e.g. for a custom implementation
+// to fall back to.
+func (self *Scope) Sorter() types.Sorter {
+	return self.dispatcher.GetSorter()
+}
+
+func (self *Scope) Grouper() types.Grouper {
+	return self.dispatcher.GetGrouper()
+}
+
 func (self *Scope) SetMaterializer(materializer types.ScopeMaterializer) {
 	self.dispatcher.SetMaterializer(materializer)
 }
@@ -576,6 +850,158 @@ func (self *Scope) Explainer() types.Explainer {
 	return NULL_EXPLAINER
 }
 
+func (self *Scope) SetProfiler(profiler types.Profiler) {
+	self.dispatcher.SetProfiler(profiler)
+}
+
+func (self *Scope) Profiler() types.Profiler {
+	return self.dispatcher.Profiler()
+}
+
+func (self *Scope) SetMaxDuration(max_duration time.Duration) {
+	self.dispatcher.SetMaxDuration(max_duration)
+}
+
+func (self *Scope) MaxDuration() time.Duration {
+	return self.dispatcher.MaxDuration()
+}
+
+func (self *Scope) SetMaxScopeDepth(depth int) {
+	self.dispatcher.SetMaxScopeDepth(depth)
+}
+
+func (self *Scope) MaxScopeDepth() int {
+	return self.dispatcher.MaxScopeDepth()
+}
+
+func (self *Scope) SetMaxRows(rows int64) {
+	self.dispatcher.SetMaxRows(rows)
+}
+
+func (self *Scope) MaxRows() int64 {
+	return self.dispatcher.MaxRows()
+}
+
+func (self *Scope) SetMaxSubqueryDepth(depth int) {
+	self.dispatcher.SetMaxSubqueryDepth(depth)
+}
+
+func (self *Scope) MaxSubqueryDepth() int {
+	return self.dispatcher.MaxSubqueryDepth()
+}
+
+// Intern the strings in rows emitted by top level queries and in
+// materialized LET variables so repeated values share memory. At
+// most size distinct strings are interned. A size of 0 disables
+// interning.
+func (self *Scope) SetStringInterning(size int) {
+	if size <= 0 {
+		self.dispatcher.SetStringInterner(nil)
+		return
+	}
+	self.dispatcher.SetStringInterner(utils.NewStringInterner(size))
+}
+
+func (self *Scope) InternRow(row types.Row) types.Row {
+	interner := self.dispatcher.StringInterner()
+	if interner == nil {
+		return row
+	}
+	return interner.InternValue(row)
+}
+
+// Compile the pattern for the =~ operator. Compiled patterns are
+// cached so each pattern is only compiled once.
+func (self *Scope) CompileRegex(pattern string) (*regexp.Regexp, error) {
+	return self.dispatcher.regexes.Compile(pattern)
+}
+
+// Keep at most size compiled regexes (default
+// DefaultRegexCacheSize). A size of 0 disables the cache.
+func (self *Scope) SetRegexCacheSize(size int) {
+	self.dispatcher.regexes.SetSize(size)
+}
+
+func (self *Scope) SetFloatEpsilon(epsilon float64) {
+	self.dispatcher.SetFloatEpsilon(epsilon)
+}
+
+func (self *Scope) FloatEpsilon() float64 {
+	return self.dispatcher.FloatEpsilon()
+}
+
+func (self *Scope) SetWhereAliases(enabled bool) {
+	self.dispatcher.SetWhereAliases(enabled)
+}
+
+func (self *Scope) WhereAliases() bool {
+	return self.dispatcher.WhereAliases()
+}
+
+func (self *Scope) SetStrictMode(strict bool) {
+	self.dispatcher.SetStrictMode(strict)
+}
+
+func (self *Scope) StrictMode() bool {
+	return self.dispatcher.StrictMode()
+}
+
+func (self *Scope) SetSnapshotIsolation(enabled bool) {
+	self.dispatcher.SetSnapshotIsolation(enabled)
+}
+
+func (self *Scope) SnapshotIsolation() bool {
+	return self.dispatcher.SnapshotIsolation()
+}
+
+func (self *Scope) SetDeterministic(enabled bool) {
+	self.dispatcher.SetDeterministic(enabled)
+}
+
+func (self *Scope) Deterministic() bool {
+	return self.dispatcher.Deterministic()
+}
+
+func (self *Scope) SetShadowingWarnings(enabled bool) {
+	self.dispatcher.SetShadowingWarnings(enabled)
+}
+
+func (self *Scope) ShadowingWarnings() bool {
+	return self.dispatcher.ShadowingWarnings()
+}
+
+func (self *Scope) SetBatchSize(size int) {
+	self.dispatcher.SetBatchSize(size)
+}
+
+func (self *Scope) BatchSize() int {
+	return self.dispatcher.BatchSize()
+}
+
+func (self *Scope) SetMaxRecursionDepth(depth int) {
+	self.dispatcher.SetMaxRecursionDepth(depth)
+}
+
+func (self *Scope) MaxRecursionDepth() int {
+	return self.dispatcher.MaxRecursionDepth()
+}
+
+func (self *Scope) SetMemoryQuota(quota int64) {
+	self.dispatcher.SetMemoryQuota(quota)
+}
+
+func (self *Scope) ChargeMemory(ctx context.Context, size int) error {
+	return self.dispatcher.ChargeMemory(ctx, size)
+}
+
+func (self *Scope) AddDefinition(definition *types.Definition) {
+	self.dispatcher.AddDefinition(definition)
+}
+
+func (self *Scope) GetDefinitions() []*types.Definition {
+	return self.dispatcher.GetDefinitions()
+}
+
 // Fetch the field from the scope variables.
 func (self *Scope) Resolve(field string) (interface{}, bool) {
 	if self.CheckForOverflow() {
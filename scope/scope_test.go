@@ -12,6 +12,7 @@ import (
 	"www.velocidex.com/golang/vfilter"
 	"www.velocidex.com/golang/vfilter/arg_parser"
 	"www.velocidex.com/golang/vfilter/functions"
+	scope_module "www.velocidex.com/golang/vfilter/scope"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils/dict"
 )
@@ -231,3 +232,41 @@ func logMarkers(format string, args ...interface{}) {
 
 	markers = append(markers, fmt.Sprintf(format, args...))
 }
+
+func TestDestructorWithContext(t *testing.T) {
+	old_timeout := scope_module.DestructorTimeout
+	defer func() { scope_module.DestructorTimeout = old_timeout }()
+	scope_module.DestructorTimeout = 100 * time.Millisecond
+
+	scope := vfilter.NewScope()
+
+	normal_called := false
+	scope.AddDestructor(func() {
+		normal_called = true
+	})
+
+	// This destructor never finishes gracefully - it notices the
+	// deadline and gives up.
+	deadline_expired := make(chan bool)
+	scope.AddDestructorWithContext(func(ctx context.Context) {
+		<-ctx.Done()
+		close(deadline_expired)
+	})
+
+	start := time.Now()
+	scope.Close()
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("Close did not honor the destructor timeout")
+	}
+
+	select {
+	case <-deadline_expired:
+	case <-time.After(time.Second):
+		t.Fatalf("Destructor context did not expire")
+	}
+
+	if !normal_called {
+		t.Fatalf("Destructor not called")
+	}
+}
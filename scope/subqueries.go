@@ -0,0 +1,94 @@
+package scope
+
+import (
+	"sort"
+	"sync"
+)
+
+// Subqueries named with FROM ... AS name which are currently
+// running. The same name may be running several times (e.g. inside a
+// foreach) so each registration gets its own id. These are shared by
+// all scopes derived from the same root scope, even those with a new
+// context.
+type namedSubqueries struct {
+	mu      sync.Mutex
+	next_id uint64
+	running map[string]map[uint64]func()
+}
+
+func newNamedSubqueries() *namedSubqueries {
+	return &namedSubqueries{
+		running: make(map[string]map[uint64]func()),
+	}
+}
+
+func (self *namedSubqueries) Register(name string, cancel func()) func() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.next_id++
+	id := self.next_id
+
+	instances, pres := self.running[name]
+	if !pres {
+		instances = make(map[uint64]func())
+		self.running[name] = instances
+	}
+	instances[id] = cancel
+
+	return func() {
+		self.mu.Lock()
+		defer self.mu.Unlock()
+
+		instances := self.running[name]
+		delete(instances, id)
+		if len(instances) == 0 {
+			delete(self.running, name)
+		}
+	}
+}
+
+func (self *namedSubqueries) Cancel(name string) bool {
+	self.mu.Lock()
+	instances := self.running[name]
+	delete(self.running, name)
+	self.mu.Unlock()
+
+	// Cancel outside the lock since cancellation may unregister
+	// other subqueries.
+	for _, cancel := range instances {
+		cancel()
+	}
+	return len(instances) > 0
+}
+
+func (self *namedSubqueries) Names() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	result := make([]string, 0, len(self.running))
+	for k := range self.running {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Register a running named subquery. The cancel function is called
+// by CancelSubquery(). Call the returned function when the subquery
+// is done.
+func (self *Scope) RegisterSubquery(name string, cancel func()) func() {
+	return self.dispatcher.subqueries.Register(name, cancel)
+}
+
+// Cancel all running subqueries with this name. The rest of the
+// query carries on as if the subquery produced no more rows. Returns
+// false if no such subquery is running.
+func (self *Scope) CancelSubquery(name string) bool {
+	return self.dispatcher.subqueries.Cancel(name)
+}
+
+// The names of the named subqueries currently running.
+func (self *Scope) RunningSubqueries() []string {
+	return self.dispatcher.subqueries.Names()
+}
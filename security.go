@@ -0,0 +1,132 @@
+package vfilter
+
+import (
+	"context"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A SecurityPolicy based on allow and deny lists. Deny lists take
+// precedence. An empty allow list allows everything not denied.
+//
+// Plugins and functions may declare the capabilities they require in
+// their Info(). A call is refused unless all its capabilities are
+// listed in AllowedCapabilities.
+//
+// Example for running untrusted queries:
+//
+//	scope.SetSecurityPolicy(&AccessPolicy{
+//	    DeniedPlugins:       []string{"execve"},
+//	    AllowedCapabilities: []string{"FILESYSTEM_READ"},
+//	})
+type AccessPolicy struct {
+	AllowedPlugins   []string
+	DeniedPlugins    []string
+	AllowedFunctions []string
+	DeniedFunctions  []string
+
+	AllowedCapabilities []string
+}
+
+func (self *AccessPolicy) CheckPlugin(
+	scope types.Scope, name string, info *types.PluginInfo) error {
+	var info_name string
+	var capabilities []string
+	if info != nil {
+		info_name = info.Name
+		capabilities = info.Capabilities
+	}
+
+	return self.check("plugin", name, info_name, capabilities,
+		self.AllowedPlugins, self.DeniedPlugins)
+}
+
+func (self *AccessPolicy) CheckFunction(
+	scope types.Scope, name string, info *types.FunctionInfo) error {
+	var info_name string
+	var capabilities []string
+	if info != nil {
+		info_name = info.Name
+		capabilities = info.Capabilities
+	}
+
+	return self.check("function", name, info_name, capabilities,
+		self.AllowedFunctions, self.DeniedFunctions)
+}
+
+// The name the query used may be an alias so we check the
+// registered name too.
+func (self *AccessPolicy) check(kind, name, info_name string,
+	capabilities, allowed, denied []string) error {
+	if inList(denied, name) || inList(denied, info_name) {
+		return &types.AccessDeniedError{
+			Kind: kind, Name: name, Reason: "denied by policy"}
+	}
+
+	if len(allowed) > 0 && !inList(allowed, name) && !inList(allowed, info_name) {
+		return &types.AccessDeniedError{
+			Kind: kind, Name: name, Reason: "not in allowed list"}
+	}
+
+	for _, capability := range capabilities {
+		if !inList(self.AllowedCapabilities, capability) {
+			return &types.AccessDeniedError{
+				Kind: kind, Name: name,
+				Reason: "requires capability " + capability}
+		}
+	}
+
+	return nil
+}
+
+func inList(list []string, item string) bool {
+	if item == "" {
+		return false
+	}
+
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Refused calls abort the query with the policy's error.
+func refuseCall(ctx context.Context, scope types.Scope, err error) {
+	scope.Log("ERROR:%v", err)
+	scope.ReportError(err)
+	types.AbortQuery(ctx, err)
+}
+
+func checkPluginAccess(ctx context.Context, scope types.Scope,
+	name string, plugin types.PluginGeneratorInterface) bool {
+	policy := scope.SecurityPolicy()
+	if policy == nil {
+		return true
+	}
+
+	err := policy.CheckPlugin(scope, name,
+		plugin.Info(scope, types.NewTypeMap()))
+	if err != nil {
+		refuseCall(ctx, scope, err)
+		return false
+	}
+	return true
+}
+
+func checkFunctionAccess(ctx context.Context, scope types.Scope,
+	name string, function types.FunctionInterface) bool {
+	policy := scope.SecurityPolicy()
+	if policy == nil {
+		return true
+	}
+
+	err := policy.CheckFunction(scope, name,
+		function.Info(scope, types.NewTypeMap()))
+	if err != nil {
+		refuseCall(ctx, scope, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// A plugin which needs to read files.
+type readFilePlugin struct{}
+
+func (self readFilePlugin) Call(ctx context.Context, scope types.Scope,
+	args *ordereddict.Dict) <-chan Row {
+	output_chan := make(chan Row, 1)
+	output_chan <- ordereddict.NewDict().Set("Data", "secret")
+	close(output_chan)
+	return output_chan
+}
+
+func (self readFilePlugin) Info(
+	scope types.Scope, type_map *types.TypeMap) *types.PluginInfo {
+	return &types.PluginInfo{
+		Name:         "read_file",
+		Capabilities: []string{"FILESYSTEM"},
+	}
+}
+
+func TestSecurityPolicy(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.AppendPlugins(readFilePlugin{})
+	scope.SetSecurityPolicy(&AccessPolicy{
+		DeniedPlugins:   []string{"range"},
+		DeniedFunctions: []string{"format"},
+	})
+
+	for _, test := range []struct {
+		query  string
+		denied bool
+	}{
+		{"SELECT * FROM foreach(row=[1, 2])", false},
+		{"SELECT * FROM range()", true},
+		{"SELECT len(list=[1]) FROM scope()", false},
+		{"SELECT format(format='%v', args=1) FROM scope()", true},
+
+		// Needs a capability the policy does not grant.
+		{"SELECT * FROM read_file()", true},
+
+		// Calls within subqueries are checked too.
+		{"SELECT * FROM foreach(row={ SELECT * FROM read_file() })", true},
+	} {
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+
+		rows, errors_chan := vql.EvalWithErrors(context.Background(), scope)
+		for range rows {
+		}
+		err = <-errors_chan
+		if test.denied {
+			assert.True(t, errors.Is(err, types.ErrAccessDenied), test.query)
+
+			access_err := &types.AccessDeniedError{}
+			assert.True(t, errors.As(err, &access_err), test.query)
+		} else {
+			assert.NoError(t, err, test.query)
+		}
+	}
+
+	// Granting the capability allows the plugin.
+	scope.SetSecurityPolicy(&AccessPolicy{
+		AllowedPlugins:      []string{"read_file"},
+		AllowedCapabilities: []string{"FILESYSTEM"},
+	})
+
+	vql, err := Parse("SELECT * FROM read_file()")
+	assert.NoError(t, err)
+
+	rows, errors_chan := vql.EvalWithErrors(context.Background(), scope)
+	count := 0
+	for range rows {
+		count++
+	}
+	assert.NoError(t, <-errors_chan)
+	assert.Equal(t, 1, count)
+
+	// Only allowed plugins may be called.
+	vql, err = Parse("SELECT * FROM scope()")
+	assert.NoError(t, err)
+
+	rows, errors_chan = vql.EvalWithErrors(context.Background(), scope)
+	for range rows {
+	}
+	assert.True(t, errors.Is(<-errors_chan, types.ErrAccessDenied))
+}
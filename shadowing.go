@@ -0,0 +1,97 @@
+package vfilter
+
+import (
+	"context"
+	"sync"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Silently shadowing a variable is a common source of wrong results
+// (e.g. a plugin emitting a column with the same name as a LET
+// query). When enabled by Scope.SetShadowingWarnings() we warn when a
+// query hides an existing symbol.
+
+type shadowingKeyType int
+
+const shadowingKey shadowingKeyType = 0
+
+// The warnings already reported by a query and its subqueries.
+type shadowingWarnings struct {
+	mu   sync.Mutex
+	seen map[types.ShadowingWarning]bool
+}
+
+// Report each warning once per query, even when a subquery runs many
+// times (e.g. in a foreach()).
+func withShadowingWarnings(ctx context.Context) context.Context {
+	if ctx.Value(shadowingKey) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, shadowingKey, &shadowingWarnings{
+		seen: make(map[types.ShadowingWarning]bool),
+	})
+}
+
+func reportShadowing(ctx context.Context, scope types.Scope,
+	warning *types.ShadowingWarning) {
+	warnings, ok := ctx.Value(shadowingKey).(*shadowingWarnings)
+	if ok {
+		warnings.mu.Lock()
+		seen := warnings.seen[*warning]
+		warnings.seen[*warning] = true
+		warnings.mu.Unlock()
+
+		if seen {
+			return
+		}
+	}
+
+	scope.Warn("%v", warning)
+	scope.ReportError(warning)
+}
+
+func isDefined(scope types.Scope, name string) bool {
+	_, pres := scope.Resolve(name)
+	return pres
+}
+
+// Warn when a LET statement redefines an existing variable.
+func warnShadowedLet(ctx context.Context, scope types.Scope, name string) {
+	if scope.ShadowingWarnings() && isDefined(scope, name) {
+		reportShadowing(ctx, scope, &types.ShadowingWarning{
+			Kind: "LET", Name: name,
+		})
+	}
+}
+
+// Warn when the first row of a query contains columns or aliases
+// that shadow existing variables. We only check the first row to
+// avoid flooding the log.
+func (self *_Select) warnShadowing(
+	ctx context.Context, scope types.Scope, row Row) {
+	if !scope.ShadowingWarnings() {
+		return
+	}
+
+	for _, expr := range self.SelectExpression.Expressions {
+		if expr.As == "" {
+			continue
+		}
+
+		name := expr.GetName(scope)
+		if isDefined(scope, name) {
+			reportShadowing(ctx, scope, &types.ShadowingWarning{
+				Kind: "Alias", Name: name,
+			})
+		}
+	}
+
+	for _, column := range scope.GetMembers(row) {
+		if isDefined(scope, column) {
+			reportShadowing(ctx, scope, &types.ShadowingWarning{
+				Kind: "Column", Name: column, Plugin: self.From.Plugin.Name,
+			})
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestShadowingWarnings(t *testing.T) {
+	scope := makeTestScope()
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", log.Ldate|log.Ltime|log.Lshortfile))
+	scope.SetShadowingWarnings(true)
+
+	collector := &testErrorCollector{}
+	scope.SetErrorCollector(collector)
+
+	multi_vql, err := MultiParse(`
+LET Value = 1
+LET Value = 2
+LET Other = 3
+SELECT 1 AS Other, _value FROM range(end=1)
+SELECT * FROM foreach(row=[dict(Value=1, Fresh=2)])
+`)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	for _, vql := range multi_vql {
+		for _ = range vql.Eval(ctx, scope) {
+		}
+	}
+
+	logger.Contains(t, "LET Value shadows an existing variable")
+	logger.NotContains(t, "LET Other shadows")
+	logger.Contains(t, "Alias Other shadows an existing variable")
+	logger.Contains(t, "Column Value from plugin foreach shadows an existing variable")
+	logger.NotContains(t, "Column Fresh")
+	logger.NotContains(t, "Column _value")
+
+	// The warnings are also reported in a structured form.
+	assert.True(t, collector.Has(types.ErrShadowing))
+
+	var warning *types.ShadowingWarning
+	for _, err := range collector.errors {
+		if errors.As(err, &warning) && warning.Kind == "Column" {
+			break
+		}
+	}
+	assert.Equal(t, &types.ShadowingWarning{
+		Kind: "Column", Name: "Value", Plugin: "foreach"}, warning)
+}
+
+func TestShadowingWarningsOncePerQuery(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", 0))
+	scope.AppendVars(ordereddict.NewDict().Set("X", 1))
+
+	vql, err := Parse(`SELECT * FROM foreach(
+   row={SELECT * FROM range(end=49)},
+   query={SELECT 2 AS X FROM scope()})`)
+	assert.NoError(t, err)
+
+	count_warnings := func() int {
+		result := 0
+		for _, line := range logger.logs {
+			if strings.Contains(line, "Alias X shadows") {
+				result++
+			}
+		}
+		return result
+	}
+
+	// Off by default.
+	for _ = range vql.Eval(context.Background(), scope) {
+	}
+	assert.Equal(t, 0, count_warnings())
+
+	// The subquery runs 50 times but only warns once.
+	scope.SetShadowingWarnings(true)
+	for _ = range vql.Eval(context.Background(), scope) {
+	}
+	assert.Equal(t, 1, count_warnings())
+}
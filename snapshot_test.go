@@ -0,0 +1,75 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils/dict"
+)
+
+// Runs a query which reads Value from the scope for each row while
+// the caller changes it between rows.
+func runWithChangingEnv(t *testing.T, isolation bool) []interface{} {
+	env := ordereddict.NewDict().Set("Value", 1)
+	next := make(chan bool)
+
+	scope := NewScope().AppendVars(env).AppendPlugins(
+		plugins.GenericStreamPlugin{
+			PluginName: "stepper",
+			Function: func(ctx context.Context, scope types.Scope,
+				args *ordereddict.Dict, output_chan chan<- types.Row) {
+				for i := 0; i < 2; i++ {
+					<-next
+					output_chan <- ordereddict.NewDict().Set("Step", i)
+				}
+			},
+		})
+	defer scope.Close()
+
+	scope.SetSnapshotIsolation(isolation)
+
+	vql, err := Parse("SELECT Step, Value FROM stepper()")
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	output_chan := vql.Eval(ctx, scope)
+
+	var result []interface{}
+	for i := 0; i < 2; i++ {
+		next <- true
+		row := dict.RowToDict(ctx, scope, <-output_chan)
+		value, _ := row.Get("Value")
+		result = append(result, value)
+
+		// Another session changes the environment mid flight.
+		env.Set("Value", 2)
+	}
+
+	for range output_chan {
+	}
+
+	return result
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	assert.Equal(t, []interface{}{1, 2}, runWithChangingEnv(t, false))
+	assert.Equal(t, []interface{}{1, 1}, runWithChangingEnv(t, true))
+}
+
+func TestSnapshotKeepsDictProperties(t *testing.T) {
+	scope := NewScope().AppendVars(ordereddict.NewDict().
+		SetCaseInsensitive().
+		Set("Foo", 1))
+	defer scope.Close()
+
+	snapshot := scope.Snapshot()
+	defer snapshot.Close()
+
+	value, pres := snapshot.Resolve("foo")
+	assert.True(t, pres)
+	assert.Equal(t, 1, value)
+}
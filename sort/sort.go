@@ -5,6 +5,7 @@ import (
 	"sort"
 
 	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
 )
 
 type DefaultSorter struct{}
@@ -51,6 +52,14 @@ func (self DefaultSorter) Sort(ctx context.Context,
 				if !ok {
 					return
 				}
+				err := scope.ChargeMemory(ctx, utils.EstimateSize(row))
+				if err != nil {
+					scope.Log("ERROR:ORDER BY: %v", err)
+					types.AbortQuery(ctx, err)
+					sort_ctx.Items = nil
+					return
+				}
+
 				// Collect all the rows
 				sort_ctx.Items = append(sort_ctx.Items, row)
 			}
@@ -0,0 +1,114 @@
+package vfilter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Sorts the Name column ignoring case and leaves other columns to the
+// previous sorter.
+type caseInsensitiveSorter struct {
+	next types.Sorter
+}
+
+func (self caseInsensitiveSorter) Sort(ctx context.Context,
+	scope types.Scope, input <-chan Row, key string, desc bool) <-chan Row {
+	if key != "Name" {
+		return self.next.Sort(ctx, scope, input, key, desc)
+	}
+
+	name := func(row Row) string {
+		value, _ := scope.Associative(row, key)
+		s, _ := value.(string)
+		return strings.ToLower(s)
+	}
+
+	output_chan := make(chan Row)
+	go func() {
+		defer close(output_chan)
+
+		var rows []Row
+		for row := range input {
+			rows = append(rows, row)
+		}
+
+		sort.SliceStable(rows, func(i, j int) bool {
+			if desc {
+				return name(rows[i]) > name(rows[j])
+			}
+			return name(rows[i]) < name(rows[j])
+		})
+
+		for _, row := range rows {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+	}()
+	return output_chan
+}
+
+type countingGrouper struct {
+	next  types.Grouper
+	calls *int64
+}
+
+func (self countingGrouper) Group(ctx context.Context,
+	scope types.Scope, actor types.GroupbyActor) <-chan Row {
+	atomic.AddInt64(self.calls, 1)
+	return self.next.Group(ctx, scope, actor)
+}
+
+func TestCustomSorterAndGrouper(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	scope.SetSorter(caseInsensitiveSorter{next: scope.Sorter()})
+
+	var calls int64
+	scope.SetGrouper(countingGrouper{next: scope.Grouper(), calls: &calls})
+
+	run := func(query string) []Row {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []Row
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	column := func(rows []Row, name string) []interface{} {
+		var result []interface{}
+		for _, row := range rows {
+			value, _ := row.(*ordereddict.Dict).Get(name)
+			result = append(result, value)
+		}
+		return result
+	}
+
+	rows := run(`SELECT _value AS Name FROM foreach(row=["b", "C", "a"])
+ORDER BY Name`)
+	assert.Equal(t, []interface{}{"a", "b", "C"}, column(rows, "Name"))
+
+	// Other columns use the default sorter.
+	rows = run(`SELECT _value AS Other FROM foreach(row=["b", "C", "a"])
+ORDER BY Other`)
+	assert.Equal(t, []interface{}{"C", "a", "b"}, column(rows, "Other"))
+
+	rows = run(`SELECT _value AS Name, count() AS Count
+FROM foreach(row=["b", "C", "a", "b"])
+GROUP BY Name ORDER BY Name DESC`)
+	assert.Equal(t, []interface{}{"C", "b", "a"}, column(rows, "Name"))
+	assert.Equal(t, int64(1), calls)
+}
@@ -0,0 +1,279 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Specializing a parameterized stored query binds the given
+// parameters to a copy of the query and substitutes their values
+// where they can not be shadowed, then pre-computes any expressions
+// which no longer depend on other symbols. This avoids resolving the
+// parameters and re-evaluating constant args when the same query is
+// called many times with the same args:
+//
+//	LET Q(Path, MinSize) = SELECT * FROM glob(globs=Path + "/*", min=MinSize * 1024) WHERE Size > MinSize
+//	specialized, err := vfilter.Specialize(scope, q, ordereddict.NewDict().
+//	    Set("MinSize", 5))
+//
+// produces the equivalent of SELECT * FROM glob(globs=Path + "/*",
+// min=5120) WHERE Size > MinSize with the single parameter Path and
+// MinSize bound to 5.
+//
+// Only the args of the query's plugin are substituted since they are
+// evaluated before any row is seen. Elsewhere (e.g. in the WHERE
+// clause or in subqueries) a column with the same name as a parameter
+// shadows it, so those references are resolved from the bound args
+// at run time as before. Only strings, numbers, booleans and NULL are
+// substituted.
+func Specialize(scope types.Scope, query types.StoredQuery,
+	args *ordereddict.Dict) (types.StoredQuery, error) {
+	stored_query, ok := query.(*_StoredQuery)
+	if !ok {
+		return nil, fmt.Errorf("Specialize: %T is not a LET query", query)
+	}
+
+	return stored_query.Specialize(scope, args)
+}
+
+func (self *_StoredQuery) Specialize(scope types.Scope,
+	args *ordereddict.Dict) (types.StoredQuery, error) {
+	if self.query == nil {
+		return nil, errors.New("Specialize: no query")
+	}
+
+	literals := make(map[string]types.Any)
+	bound := ordereddict.NewDict()
+	if self.bound != nil {
+		for _, k := range self.bound.Keys() {
+			v, _ := self.bound.Get(k)
+			bound.Set(k, v)
+		}
+	}
+
+	for _, k := range args.Keys() {
+		if !stringInSlice(self.parameters, k) {
+			return nil, fmt.Errorf("Specialize: %v is not a parameter of %v",
+				k, self.name)
+		}
+
+		v, _ := args.Get(k)
+		_, ok := literalValue(v)
+		if ok {
+			literals[k] = v
+		}
+		bound.Set(k, v)
+	}
+
+	folder := &specializer{scope: scope}
+	query := folder.copy(reflect.ValueOf(self.query)).Interface().(*_Select)
+
+	if query.From != nil {
+		substituter := &specializer{
+			scope:    scope,
+			literals: literals,
+		}
+		query.From.Plugin.Args = substituter.copy(
+			reflect.ValueOf(query.From.Plugin.Args)).Interface().([]*_Args)
+	}
+
+	var parameters []string
+	for _, p := range self.parameters {
+		_, pres := args.Get(p)
+		if !pres {
+			parameters = append(parameters, p)
+		}
+	}
+
+	return &_StoredQuery{
+		query:      query,
+		name:       self.name,
+		parameters: parameters,
+		bound:      bound,
+	}, nil
+}
+
+func stringInSlice(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Build a literal AST node for the value if possible.
+func literalValue(value types.Any) (*_Value, bool) {
+	result := &_Value{}
+
+	switch t := value.(type) {
+	case nil, types.Null, *types.Null:
+		result.Null = true
+		result.cache = types.Null{}
+
+	case string:
+		quoted := utils.Quote(t)
+		result.String = &quoted
+		result.cache = t
+
+	case bool:
+		boolean := "FALSE"
+		if t {
+			boolean = "TRUE"
+		}
+		result.Boolean = &boolean
+		result.cache = t
+
+	case float64:
+		result.Float = &t
+
+	case float32:
+		float_value := float64(t)
+		result.Float = &float_value
+
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		int_value := reflect.ValueOf(t).Convert(reflect.TypeOf(int64(0))).Int()
+		result.Int = &int_value
+
+	default:
+		return nil, false
+	}
+
+	return result, true
+}
+
+type specializer struct {
+	scope    types.Scope
+	literals map[string]types.Any
+}
+
+// Make a deep copy of the parsed fields of the AST, substituting
+// parameters and folding constants on the way. Unexported fields
+// only hold caches so they start out empty in the copy.
+func (self *specializer) copy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		// Rows and lambda parameters may shadow the parameters
+		// within subqueries and lambdas so nothing is
+		// substituted there.
+		copier := self
+		switch value.Interface().(type) {
+		case *_Select, *Lambda:
+			if self.literals != nil {
+				copier = &specializer{scope: self.scope}
+			}
+		}
+
+		result := reflect.New(value.Type().Elem())
+		result.Elem().Set(copier.copy(value.Elem()))
+		return reflect.ValueOf(copier.transform(result.Interface()))
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(self.copy(value.Index(i)))
+		}
+		return result
+
+	case reflect.Struct:
+		result := reflect.New(value.Type()).Elem()
+		value_type := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			if value_type.Field(i).PkgPath != "" {
+				continue
+			}
+			result.Field(i).Set(self.copy(value.Field(i)))
+		}
+		return result
+	}
+
+	return value
+}
+
+// Called on each copied node after its children were copied.
+func (self *specializer) transform(node interface{}) interface{} {
+	switch t := node.(type) {
+	case *_Value:
+		if t.SymbolRef == nil || t.SymbolRef.Called || t.Negated {
+			return t
+		}
+
+		value, pres := self.literals[t.SymbolRef.Symbol]
+		if !pres {
+			return t
+		}
+		result, _ := literalValue(value)
+		result.Comments = t.Comments
+		return result
+
+	case *_MultiplicationExpression:
+		if len(t.Right) > 0 && self.isConstant(t) {
+			return self.fold(t, t.Reduce).Left.Left
+		}
+
+	case *_AdditionExpression:
+		if len(t.Right) > 0 && self.isConstant(t) {
+			return self.fold(t, t.Reduce).Left
+		}
+
+	case *_ConditionOperand:
+		if (t.Right != nil || t.Not != nil) && self.isConstant(t) {
+			return self.fold(t, t.Reduce)
+		}
+	}
+
+	return node
+}
+
+// A node is constant if it does not refer to any symbols or queries.
+func (self *specializer) isConstant(node interface{}) bool {
+	result := true
+	walkAST(reflect.ValueOf(node), func(node interface{}) {
+		switch node.(type) {
+		case *_SymbolRef, *_Select, *Lambda:
+			result = false
+		}
+	})
+	return result
+}
+
+// Evaluate the node now and replace it with its value. Returns the
+// node unchanged if the value can not be represented as a literal.
+func (self *specializer) fold(node interface{},
+	reduce func(ctx context.Context, scope types.Scope) Any) *_ConditionOperand {
+	value := reduce(context.Background(), self.scope)
+
+	literal, ok := literalValue(value)
+	if !ok {
+		switch t := node.(type) {
+		case *_ConditionOperand:
+			return t
+		case *_AdditionExpression:
+			return &_ConditionOperand{Left: t}
+		case *_MultiplicationExpression:
+			return &_ConditionOperand{Left: &_AdditionExpression{Left: t}}
+		}
+	}
+
+	return &_ConditionOperand{
+		Left: &_AdditionExpression{
+			Left: &_MultiplicationExpression{
+				Left: &_MemberExpression{
+					Left: literal,
+				},
+			},
+		},
+	}
+}
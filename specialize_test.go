@@ -0,0 +1,131 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestSpecialize(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("LET Q(Value, Min) = SELECT value AS X " +
+		"FROM range(start=Min * 2, end=6) WHERE Value = 'yes'")
+	assert.NoError(t, err)
+	for range vql.Eval(ctx, scope) {
+	}
+
+	stored, pres := scope.Resolve("Q")
+	assert.True(t, pres)
+	query := stored.(types.StoredQuery)
+
+	format := func(query string) string {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+		return FormatToString(scope, vql.Query)
+	}
+
+	get_values := func(query types.StoredQuery, args *ordereddict.Dict) []types.Any {
+		var result []types.Any
+		rows := query.(types.PluginGeneratorInterface).Call(ctx, scope, args)
+		for row := range rows {
+			value, _ := scope.Associative(row, "X")
+			result = append(result, value)
+		}
+		return result
+	}
+
+	// Substituting Min in the plugin args folds the
+	// multiplication. The WHERE clause still resolves Value at
+	// run time.
+	specialized, err := Specialize(scope, query,
+		ordereddict.NewDict().Set("Min", 1).Set("Value", "no"))
+	assert.NoError(t, err)
+	assert.Equal(t, format("SELECT value AS X FROM range(start=2, end=6) "+
+		"WHERE Value = 'yes'"),
+		FormatToString(scope, specialized.(*_StoredQuery).query))
+	assert.Equal(t, 0, len(get_values(specialized, ordereddict.NewDict())))
+
+	specialized, err = Specialize(scope, query,
+		ordereddict.NewDict().Set("Min", 1))
+	assert.NoError(t, err)
+
+	expected := get_values(query, ordereddict.NewDict().
+		Set("Min", 1).Set("Value", "yes"))
+	assert.Equal(t, 5, len(expected))
+	assert.Equal(t, expected, get_values(specialized,
+		ordereddict.NewDict().Set("Value", "yes")))
+
+	// Values which are not literals are bound instead.
+	specialized, err = Specialize(scope, query,
+		ordereddict.NewDict().Set("Min", []int64{1}).Set("Value", "yes"))
+	assert.NoError(t, err)
+	assert.Contains(t, FormatToString(scope, specialized.(*_StoredQuery).query),
+		"Min * 2")
+
+	// The original query is unchanged.
+	assert.Equal(t, expected, get_values(query, ordereddict.NewDict().
+		Set("Min", 1).Set("Value", "yes")))
+
+	_, err = Specialize(scope, query, ordereddict.NewDict().Set("Other", 1))
+	assert.Error(t, err)
+}
+
+func TestSpecializeShadowing(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// The value column shadows the parameter outside the plugin
+	// args.
+	vql, err := MultiParse(`
+LET Q(value) = SELECT value AS X FROM range(start=value, end=value + 2)
+   WHERE value > 0 AND X = value
+LET S(Name) = SELECT _value AS X FROM foreach(row=[Name])
+`)
+	assert.NoError(t, err)
+	for _, statement := range vql {
+		for range statement.Eval(ctx, scope) {
+		}
+	}
+
+	get_values := func(name string, args *ordereddict.Dict,
+		specialize *ordereddict.Dict) []types.Any {
+		stored, _ := scope.Resolve(name)
+		query := stored.(types.StoredQuery)
+		if specialize != nil {
+			query, err = Specialize(scope, query, specialize)
+			assert.NoError(t, err)
+
+			// The specialized query can be formatted and
+			// parsed again.
+			_, err = Parse(FormatToString(scope, query.(*_StoredQuery).query))
+			assert.NoError(t, err)
+		}
+
+		var result []types.Any
+		rows := query.(types.PluginGeneratorInterface).Call(ctx, scope, args)
+		for row := range rows {
+			value, _ := scope.Associative(row, "X")
+			result = append(result, value)
+		}
+		return result
+	}
+
+	args := ordereddict.NewDict().Set("value", 1)
+	expected := get_values("Q", args, nil)
+	assert.Equal(t, 3, len(expected))
+	assert.Equal(t, expected, get_values("Q", ordereddict.NewDict(), args))
+
+	// Strings are escaped properly.
+	for _, name := range []string{"ends with '", "'''", `back\slash "quoted"`} {
+		args = ordereddict.NewDict().Set("Name", name)
+		assert.Equal(t, []types.Any{name}, get_values("S", args, nil))
+		assert.Equal(t, []types.Any{name}, get_values("S", ordereddict.NewDict(), args))
+	}
+}
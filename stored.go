@@ -22,10 +22,12 @@ package vfilter
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/Velocidex/ordereddict"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils"
+	"www.velocidex.com/golang/vfilter/utils/dict"
 )
 
 // A stored expression is stored in a LET clause either with or
@@ -36,6 +38,12 @@ type _StoredQuery struct {
 	query      *_Select
 	name       string
 	parameters []string
+
+	// Args bound by Specialize().
+	bound *ordereddict.Dict
+
+	// Set for memoized queries declared with LET f(x) <= SELECT ...
+	cache *expressionCache
 }
 
 func NewStoredQuery(query *_Select, name string) *_StoredQuery {
@@ -55,6 +63,14 @@ func (self *_StoredQuery) Eval(ctx context.Context, scope types.Scope) <-chan Ro
 		new_scope := scope.Copy()
 		defer new_scope.Close()
 
+		if self.name != "" {
+			new_scope.PushCallFrame(self.name)
+		}
+
+		if self.bound != nil && self.bound.Len() > 0 {
+			new_scope.AppendVars(self.bound)
+		}
+
 		for row := range self.query.Eval(ctx, new_scope) {
 			select {
 			case <-ctx.Done():
@@ -70,7 +86,9 @@ func (self *_StoredQuery) Eval(ctx context.Context, scope types.Scope) <-chan Ro
 // Stored queries can also behave like plugins. This just means we
 // evaluate it with a subscope built on top of the args.
 func (self *_StoredQuery) Info(scope types.Scope, type_map *TypeMap) *PluginInfo {
-	return &PluginInfo{}
+	return &PluginInfo{
+		Cacheable: self.isCacheable(scope),
+	}
 }
 
 func (self *_StoredQuery) Call(ctx context.Context,
@@ -82,6 +100,23 @@ func (self *_StoredQuery) Call(ctx context.Context,
 
 	self.checkCallingArgs(sub_scope, args)
 
+	if !enterLetFunction(ctx, sub_scope, self.name) {
+		output_chan := make(chan Row)
+		close(output_chan)
+		return output_chan
+	}
+
+	vars := materializeArgs(ctx, sub_scope, args)
+	sub_scope.AppendVars(vars)
+	if self.cache != nil {
+		return self.evalMemoized(ctx, sub_scope, vars)
+	}
+	return self.Eval(ctx, sub_scope)
+}
+
+// Expand lazy args and subqueries into their values.
+func materializeArgs(ctx context.Context,
+	scope types.Scope, args *ordereddict.Dict) *ordereddict.Dict {
 	vars := ordereddict.NewDict()
 	for _, k := range args.Keys() {
 		v, _ := args.Get(k)
@@ -91,16 +126,64 @@ func (self *_StoredQuery) Call(ctx context.Context,
 			v = t.Reduce(ctx)
 
 		case types.Materializer:
-			v = t.Materialize(ctx, sub_scope)
+			v = t.Materialize(ctx, scope)
 
 		case types.StoredQuery:
-			v = types.Materialize(ctx, sub_scope, t)
+			v = types.Materialize(ctx, scope, t)
 		}
 		vars.Set(k, v)
 	}
 
-	sub_scope.AppendVars(vars)
-	return self.Eval(ctx, sub_scope)
+	return vars
+}
+
+// Replay the rows of a previous call with the same args or run the
+// query and remember its rows. Partial results (e.g. when the caller
+// stops reading) are not remembered.
+func (self *_StoredQuery) evalMemoized(ctx context.Context,
+	scope types.Scope, vars *ordereddict.Dict) <-chan Row {
+	output_chan := make(chan Row)
+
+	key := expressionCacheKey(vars)
+	cached, pres := self.cache.Get(key)
+	rows, ok := cached.([]Row)
+	if pres && ok {
+		go func() {
+			defer close(output_chan)
+
+			for _, row := range rows {
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- row:
+				}
+			}
+		}()
+		return output_chan
+	}
+
+	row_chan := self.Eval(ctx, scope)
+	go func() {
+		defer close(output_chan)
+
+		rows := []Row{}
+		for row := range row_chan {
+			row = dict.RowToDict(ctx, scope, row)
+			rows = append(rows, row)
+
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- row:
+			}
+		}
+
+		if ctx.Err() == nil {
+			self.cache.Set(key, rows)
+		}
+	}()
+
+	return output_chan
 }
 
 func (self *_StoredQuery) checkCallingArgs(scope types.Scope, args *ordereddict.Dict) {
@@ -145,6 +228,9 @@ type StoredExpression struct {
 	Expr       *_AndExpression
 	name       string
 	parameters []string
+
+	// Set when the expression was declared with CACHE.
+	cache *expressionCache
 }
 
 func (self *StoredExpression) Reduce(
@@ -160,6 +246,10 @@ func (self *StoredExpression) Call(ctx context.Context,
 	sub_scope := scope.Copy()
 	defer sub_scope.Close()
 
+	if !enterLetFunction(ctx, sub_scope, self.name) {
+		return types.Null{}
+	}
+
 	vars := ordereddict.NewDict()
 	for _, k := range args.Keys() {
 		v, _ := args.Get(k)
@@ -173,9 +263,71 @@ func (self *StoredExpression) Call(ctx context.Context,
 		vars.Set(k, v)
 	}
 
-	sub_scope.AppendVars(vars)
+	return self.reduceWithArgs(ctx, sub_scope, vars)
+}
 
-	return self.Reduce(ctx, sub_scope)
+// Reduce the expression with the call's args in the scope. If the
+// expression was declared with CACHE the result is cached per
+// argument tuple.
+func (self *StoredExpression) reduceWithArgs(ctx context.Context,
+	scope types.Scope, vars *ordereddict.Dict) types.Any {
+	if self.cache == nil {
+		scope.AppendVars(vars)
+		return self.Reduce(ctx, scope)
+	}
+
+	// The args need to be reduced to build the cache key.
+	reduced := ordereddict.NewDict()
+	for _, k := range vars.Keys() {
+		v, _ := vars.Get(k)
+		lazy_v, ok := v.(types.LazyExpr)
+		if ok {
+			v = lazy_v.Reduce(ctx)
+		}
+		reduced.Set(k, v)
+	}
+
+	key := expressionCacheKey(reduced)
+	result, pres := self.cache.Get(key)
+	if pres {
+		return result
+	}
+
+	scope.AppendVars(reduced)
+	result = self.Reduce(ctx, scope)
+	self.cache.Set(key, result)
+
+	return result
+}
+
+// LET functions may call themselves (e.g. to walk a tree). When the
+// scope has a recursion limit we keep track of how deeply the
+// function is nested in the scope it is evaluated in. Returns false
+// (and aborts the query) if the limit is exceeded.
+func enterLetFunction(ctx context.Context, scope types.Scope, name string) bool {
+	limit := scope.MaxRecursionDepth()
+	if limit <= 0 || name == "" {
+		return true
+	}
+
+	key := "$RecursionDepth." + name
+	depth := 0
+	value, pres := scope.Resolve(key)
+	if pres {
+		depth, _ = value.(int)
+	}
+
+	if depth >= limit {
+		err := fmt.Errorf("%w: %v nested deeper than %v calls",
+			types.ErrRecursionDepthExceeded, name, limit)
+		scope.Log("ERROR:%v", err)
+		scope.ReportError(err)
+		types.AbortQuery(ctx, err)
+		return false
+	}
+
+	scope.AppendVars(ordereddict.NewDict().Set(key, depth+1))
+	return true
 }
 
 func (self *StoredExpression) checkCallingArgs(scope types.Scope, args *ordereddict.Dict) {
@@ -212,9 +364,17 @@ func (self *StoredExpression) checkCallingArgs(scope types.Scope, args *orderedd
 type StoredQueryCallSite struct {
 	query StoredQuery
 	scope Scope
+
+	// The args of the call, used to look up memoized queries.
+	vars *ordereddict.Dict
 }
 
 func (self *StoredQueryCallSite) Eval(ctx context.Context, scope Scope) <-chan Row {
+	stored_query, ok := self.query.(*_StoredQuery)
+	if ok && stored_query.cache != nil {
+		return stored_query.evalMemoized(ctx, self.scope, self.vars)
+	}
+
 	// Use our embedded scope instead.
 	return self.query.Eval(ctx, self.scope)
 }
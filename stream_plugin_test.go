@@ -0,0 +1,57 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestGenericStreamPlugin(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	stopped := make(chan bool)
+
+	// Counts forever until cancelled.
+	scope.AppendPlugins(plugins.GenericStreamPlugin{
+		PluginName: "counter",
+		Unbounded:  true,
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict, output_chan chan<- types.Row) {
+			defer close(stopped)
+
+			for i := 0; ; i++ {
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- ordereddict.NewDict().Set("Count", i):
+				}
+			}
+		},
+	})
+
+	vql, err := Parse("SELECT * FROM counter() LIMIT 5")
+	assert.NoError(t, err)
+
+	rows := 0
+	for range vql.Eval(ctx, scope) {
+		rows++
+	}
+	assert.Equal(t, 5, rows)
+
+	// The plugin saw the query finish.
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Streaming plugin was not cancelled")
+	}
+
+	plugin, _ := scope.GetPlugin("counter")
+	assert.True(t, plugin.Info(scope, types.NewTypeMap()).Unbounded)
+}
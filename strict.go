@@ -0,0 +1,47 @@
+package vfilter
+
+import (
+	"context"
+	"fmt"
+
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// Arithmetic protocols return NULL when they can not handle their
+// operands (see protocols/common.go). In strict mode this aborts the
+// query instead - unless one of the operands was NULL to begin with.
+func checkArithmetic(ctx context.Context, scope types.Scope,
+	operator string, lhs, rhs, result types.Any) types.Any {
+	if !types.IsNullObject(result) || !scope.StrictMode() {
+		return result
+	}
+
+	lhs = reduceOperand(ctx, lhs)
+	rhs = reduceOperand(ctx, rhs)
+	if types.IsNullObject(lhs) || types.IsNullObject(rhs) {
+		return result
+	}
+
+	var err error
+	divisor, ok := utils.ToFloat(rhs)
+	if operator == "/" && ok && divisor == 0 {
+		err = fmt.Errorf("%w: %v / %v", types.ErrDivisionByZero, lhs, rhs)
+	} else {
+		err = fmt.Errorf("%w: Can not apply %v to %T and %T",
+			types.ErrTypeMismatch, operator, lhs, rhs)
+	}
+
+	scope.Log("ERROR:%v", err)
+	scope.ReportError(err)
+	types.AbortQuery(ctx, err)
+	return result
+}
+
+func reduceOperand(ctx context.Context, value types.Any) types.Any {
+	lazy_expr, ok := value.(types.LazyExpr)
+	if ok {
+		return lazy_expr.Reduce(ctx)
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestStrictMode(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	vql, err := Parse("SELECT _value, Misspelled FROM foreach(row=[1, 2, 3])")
+	assert.NoError(t, err)
+
+	// By default undefined symbols produce Null.
+	rows := 0
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			rows++
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rows)
+
+	// In strict mode they abort the query.
+	scope.SetStrictMode(true)
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			return nil
+		})
+	assert.True(t, errors.Is(err, types.ErrUndefinedSymbol))
+	assert.Contains(t, err.Error(), "Misspelled")
+
+	// Defined symbols are fine.
+	vql, err = Parse("SELECT _value FROM foreach(row=[1, 2, 3])")
+	assert.NoError(t, err)
+
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			return nil
+		})
+	assert.NoError(t, err)
+}
+
+func TestStrictModeErrors(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	for _, test := range []struct {
+		query string
+		err   error
+	}{
+		{"SELECT 1 / 0 FROM scope()", types.ErrDivisionByZero},
+		{"SELECT 1.5 / 0 FROM scope()", types.ErrDivisionByZero},
+		{"SELECT 'a' - 'b' FROM scope()", types.ErrTypeMismatch},
+		{"SELECT dict(a=1) * 2 FROM scope()", types.ErrTypeMismatch},
+		{"SELECT no_such_function() FROM scope()", types.ErrUndefinedSymbol},
+		{"SELECT * FROM no_such_plugin()", types.ErrUndefinedSymbol},
+		{"SELECT * FROM no_such_plugin()", types.ErrPluginNotFound},
+
+		// NULL operands are not errors.
+		{"SELECT 1 + NULL, NULL / 0 FROM scope()", nil},
+		{"SELECT 'a' + 1, 4 / 2 FROM scope()", nil},
+	} {
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+
+		// Outside strict mode these produce NULL.
+		scope.SetStrictMode(false)
+		rows, errors_chan := vql.EvalWithErrors(context.Background(), scope)
+		for range rows {
+		}
+		assert.NoError(t, <-errors_chan, test.query)
+
+		scope.SetStrictMode(true)
+		rows, errors_chan = vql.EvalWithErrors(context.Background(), scope)
+		for range rows {
+		}
+		err = <-errors_chan
+		if test.err == nil {
+			assert.NoError(t, err, test.query)
+		} else {
+			assert.True(t, errors.Is(err, test.err), test.query)
+		}
+	}
+}
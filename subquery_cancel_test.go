@@ -0,0 +1,69 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+func TestCancelSubquery(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	// Counts forever until cancelled.
+	scope.AppendPlugins(plugins.GenericStreamPlugin{
+		PluginName: "counter",
+		Unbounded:  true,
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict, output_chan chan<- types.Row) {
+			for i := 0; ; i++ {
+				select {
+				case <-ctx.Done():
+					return
+				case output_chan <- ordereddict.NewDict().Set("Count", i):
+				}
+			}
+		},
+	})
+
+	assert.False(t, scope.CancelSubquery("runaway"))
+
+	vql, err := Parse(`
+SELECT * FROM chain(
+  a={SELECT Count FROM counter() AS runaway},
+  b={SELECT "done" AS Status FROM scope()})`)
+	assert.NoError(t, err)
+
+	// The name survives reformatting.
+	assert.Contains(t, FormatToString(scope, vql), "counter() AS runaway")
+
+	var running []string
+	var last Row
+	counted := 0
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			last = row
+			_, pres := row.(*ordereddict.Dict).Get("Count")
+			if pres {
+				counted++
+				if counted == 10 {
+					running = scope.RunningSubqueries()
+					assert.True(t, scope.CancelSubquery("runaway"))
+				}
+			}
+			return nil
+		})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"runaway"}, running)
+	assert.Empty(t, scope.RunningSubqueries())
+
+	// Only the named subquery was cancelled - the rest of the
+	// query ran to completion.
+	status, _ := last.(*ordereddict.Dict).Get("Status")
+	assert.Equal(t, "done", status)
+}
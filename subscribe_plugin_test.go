@@ -0,0 +1,130 @@
+package vfilter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// An event source which fires from its own goroutine, like a file
+// watcher would.
+func makeTicker(stopped chan bool) plugins.SubscribeFunction {
+	return func(ctx context.Context, scope types.Scope,
+		args *ordereddict.Dict, emit func(row types.Row) bool) error {
+		defer close(stopped)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if !emit(ordereddict.NewDict().Set("Event", i)) {
+					return
+				}
+			}
+		}()
+
+		<-ctx.Done()
+		return nil
+	}
+}
+
+func TestSubscribePlugin(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	stopped := make(chan bool)
+	scope.AppendPlugins(plugins.SubscribePlugin{
+		PluginName: "ticker",
+		BufferSize: 2,
+		Function:   makeTicker(stopped),
+	})
+
+	vql, err := Parse("SELECT * FROM ticker() LIMIT 5")
+	assert.NoError(t, err)
+
+	var events []types.Any
+	for row := range vql.Eval(ctx, scope) {
+		value, _ := scope.Associative(row, "Event")
+		events = append(events, value)
+	}
+	assert.Equal(t, []types.Any{0, 1, 2, 3, 4}, events)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Subscriber was not cancelled")
+	}
+
+	plugin, _ := scope.GetPlugin("ticker")
+	assert.True(t, plugin.Info(scope, types.NewTypeMap()).Unbounded)
+}
+
+func TestSubscribePluginClosesSubscope(t *testing.T) {
+	ctx := context.Background()
+	scope := makeTestScope()
+	defer scope.Close()
+
+	closed := make(chan bool)
+	scope.AppendPlugins(plugins.SubscribePlugin{
+		PluginName: "once",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict, emit func(row types.Row) bool) error {
+			err := scope.AddDestructor(func() { close(closed) })
+			if err != nil {
+				return err
+			}
+			emit(ordereddict.NewDict().Set("Event", 1))
+			return nil
+		},
+	})
+
+	vql, err := Parse("SELECT * FROM once()")
+	assert.NoError(t, err)
+
+	for range vql.Eval(ctx, scope) {
+	}
+
+	// Destructors added by the subscriber run when the
+	// subscription ends, not when the caller's scope is closed.
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Subscope was not closed")
+	}
+}
+
+func TestSubscribePluginScopeClose(t *testing.T) {
+	scope := makeTestScope()
+
+	stopped := make(chan bool)
+	plugin := plugins.SubscribePlugin{
+		PluginName:   "ticker",
+		DropWhenFull: true,
+		Function:     makeTicker(stopped),
+	}
+
+	// Nobody reads the events so they are all dropped.
+	output_chan := plugin.Call(context.Background(), scope, ordereddict.NewDict())
+
+	// Closing the scope stops the subscriber.
+	scope.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Subscriber was not cancelled")
+	}
+
+	for range output_chan {
+	}
+}
@@ -1,6 +1,7 @@
 package vfilter
 
 import (
+	"sync"
 	"time"
 
 	"www.velocidex.com/golang/vfilter/types"
@@ -48,3 +49,39 @@ func NewTimeThrottler(rate float64) types.Throttler {
 
 	return result
 }
+
+// A BatchThrottler lets a batch of operations run at full speed then
+// sleeps before the next batch. This limits the CPU impact of long
+// running queries while keeping the overhead per op small.
+type BatchThrottler struct {
+	mu         sync.Mutex
+	batch_size int
+	delay      time.Duration
+	count      int
+}
+
+func (self *BatchThrottler) ChargeOp() {
+	self.mu.Lock()
+	self.count++
+	if self.count < self.batch_size {
+		self.mu.Unlock()
+		return
+	}
+	self.count = 0
+	self.mu.Unlock()
+
+	time.Sleep(self.delay)
+}
+
+func (self *BatchThrottler) Close() {}
+
+func NewBatchThrottler(batch_size int, delay time.Duration) types.Throttler {
+	if batch_size <= 0 {
+		batch_size = 1
+	}
+
+	return &BatchThrottler{
+		batch_size: batch_size,
+		delay:      delay,
+	}
+}
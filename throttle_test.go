@@ -0,0 +1,50 @@
+package vfilter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingThrottler struct {
+	count int64
+}
+
+func (self *countingThrottler) ChargeOp() {
+	atomic.AddInt64(&self.count, 1)
+}
+
+func (self *countingThrottler) Close() {}
+
+func TestThrottlerIsCharged(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	throttler := &countingThrottler{}
+	scope.SetThrottler(throttler)
+
+	vql, err := Parse("SELECT * FROM foreach(row=[dict(A=1), dict(A=2), dict(A=3)])")
+	assert.NoError(t, err)
+
+	for range vql.Eval(context.Background(), scope) {
+	}
+
+	// Each row is charged by foreach() and by the SELECT.
+	assert.Equal(t, int64(6), atomic.LoadInt64(&throttler.count))
+}
+
+func TestBatchThrottler(t *testing.T) {
+	throttler := NewBatchThrottler(5, 10*time.Millisecond)
+	defer throttler.Close()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		throttler.ChargeOp()
+	}
+
+	// Two full batches - should have slept twice.
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
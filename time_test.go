@@ -0,0 +1,66 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeProtocols(t *testing.T) {
+	scope := makeTestScope().AppendVars(ordereddict.NewDict().
+		Set("Timestamp", time.Unix(1600000000, 0).UTC()).
+		Set("Later", time.Unix(1600000060, 0).UTC()))
+	defer scope.Close()
+
+	for _, test := range []struct {
+		clause string
+		result bool
+	}{
+		{"Timestamp = 1600000000", true},
+		{"Timestamp = 1600000000.0", true},
+		{"1600000000 = Timestamp", true},
+		{"Timestamp = '2020-09-13T12:26:40Z'", true},
+		{"Timestamp < 1600000001", true},
+		{"Timestamp > 1600000001", false},
+		{"1599999999 < Timestamp", true},
+		{"Timestamp > '2020-01-01'", true},
+		{"Timestamp + 60 = Later", true},
+		{"60 + Timestamp = Later", true},
+		{"Later - 60 = Timestamp", true},
+		{"Later - Timestamp = 60", true},
+		{"Timestamp < Later", true},
+		{"Timestamp = 'hello'", false},
+
+		// Duration literals.
+		{"Timestamp + 1m = Later", true},
+		{"Later - 1m = Timestamp", true},
+		{"Later - Timestamp = 1m", true},
+		{"Later - Timestamp < 2m", true},
+		{"Timestamp + 1h30m > Later", true},
+
+		// Duration arithmetic.
+		{"1h + 30m = 1h30m", true},
+		{"1h - 30m = 30m", true},
+		{"2 * 1h = 2h", true},
+		{"1h * 1.5 = 90m", true},
+		{"1h + 60 = 61m", true},
+		{"Timestamp + 30s * 2 = Later", true},
+	} {
+		vql, err := Parse("SELECT * FROM scope() WHERE " + test.clause)
+		assert.NoError(t, err)
+
+		value := vql.Query.Where.Reduce(context.Background(), scope)
+		assert.Equal(t, test.result, scope.Bool(value), test.clause)
+	}
+
+	// Durations are also supported.
+	later := scope.Add(time.Unix(1600000000, 0), 90*time.Second)
+	assert.True(t, scope.Eq(later, 1600000090))
+
+	assert.Equal(t, 90*time.Minute, scope.Add(time.Hour, 30*time.Minute))
+	assert.Equal(t, 30*time.Minute, scope.Sub(time.Hour, 30*time.Minute))
+	assert.Equal(t, 2*time.Hour, scope.Mul(2, time.Hour))
+}
@@ -0,0 +1,131 @@
+package vfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Returned when a query runs longer than the scope's MaxDuration.
+var ErrQueryTimeout = errors.New("Query timed out")
+
+type queryLimitsKeyType int
+
+const queryLimitsKey queryLimitsKeyType = 0
+
+type queryLimits struct {
+	max_duration time.Duration
+	once         sync.Once
+
+	// Rows read by the query and all its subqueries.
+	max_rows int64
+	rows     int64
+}
+
+type subqueryDepthKeyType int
+
+const subqueryDepthKey subqueryDepthKeyType = 0
+
+// Apply the scope's limits to the query: the query may be aborted by
+// components exceeding their quota (see types.AbortQuery) and is
+// terminated after the scope's MaxDuration. Subqueries share the
+// limits of the outer query so they apply to the query as a whole.
+func withQueryLimits(
+	ctx context.Context, scope types.Scope) (context.Context, func()) {
+	if ctx.Value(queryLimitsKey) != nil {
+		return ctx, func() {}
+	}
+
+	limits := &queryLimits{
+		max_duration: scope.MaxDuration(),
+		max_rows:     scope.MaxRows(),
+	}
+	ctx = context.WithValue(ctx, queryLimitsKey, limits)
+	ctx = types.WithQueryMemory(ctx)
+	ctx, abort := types.WithQueryAbort(ctx)
+	if limits.max_duration <= 0 {
+		return ctx, abort
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, limits.max_duration)
+	return ctx, func() {
+		cancel()
+		abort()
+	}
+}
+
+// Check if the query was terminated because it exceeded one of its
+// limits. The timeout is only logged once per query - other errors
+// are logged by the component aborting the query.
+func checkQueryLimits(ctx context.Context, scope types.Scope) error {
+	err := types.QueryAbortError(ctx)
+	if err != nil {
+		return err
+	}
+
+	limits, ok := ctx.Value(queryLimitsKey).(*queryLimits)
+	if !ok || ctx.Err() != context.DeadlineExceeded {
+		return nil
+	}
+
+	limits.once.Do(func() {
+		scope.Log("ERROR:%v: exceeded %v", ErrQueryTimeout,
+			limits.max_duration)
+	})
+	return ErrQueryTimeout
+}
+
+// Count a row read by the query. Returns false once the query read
+// more than the scope's MaxRows: the caller must then stop reading
+// rows. The query is failed rather than cancelled so rows within the
+// limit which are still in flight are delivered, and the query ends
+// once they are.
+func chargeQueryRow(ctx context.Context, scope types.Scope) bool {
+	limits, ok := ctx.Value(queryLimitsKey).(*queryLimits)
+	if !ok || limits.max_rows <= 0 {
+		return true
+	}
+
+	rows := atomic.AddInt64(&limits.rows, 1)
+	if rows <= limits.max_rows {
+		return true
+	}
+
+	// Only report the first row over the limit.
+	if rows == limits.max_rows+1 {
+		err := fmt.Errorf("%w: read more than %v rows",
+			types.ErrRowLimitExceeded, limits.max_rows)
+		scope.Log("ERROR:%v", err)
+		scope.ReportError(err)
+		types.FailQuery(ctx, err)
+	}
+	return false
+}
+
+// Each FROM clause nests a subquery one level deeper. Returns the
+// context for the subquery or false if it is nested deeper than the
+// scope's MaxSubqueryDepth (which aborts the query).
+func enterSubquery(ctx context.Context,
+	scope types.Scope) (context.Context, bool) {
+	limit := scope.MaxSubqueryDepth()
+	if limit <= 0 {
+		return ctx, true
+	}
+
+	depth, _ := ctx.Value(subqueryDepthKey).(int)
+	if depth >= limit {
+		err := fmt.Errorf("%w: more than %v nested queries",
+			types.ErrSubqueryDepthExceeded, limit)
+		scope.Log("ERROR:%v", err)
+		scope.ReportError(err)
+		types.AbortQuery(ctx, err)
+		return ctx, false
+	}
+
+	return context.WithValue(ctx, subqueryDepthKey, depth+1), true
+}
@@ -0,0 +1,68 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Produces rows until cancelled.
+type infinitePlugin struct{}
+
+func (self infinitePlugin) Call(
+	ctx context.Context,
+	scope types.Scope,
+	args *ordereddict.Dict) <-chan Row {
+	output_chan := make(chan Row)
+
+	go func() {
+		defer close(output_chan)
+
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case output_chan <- ordereddict.NewDict().Set("Count", i):
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	return output_chan
+}
+
+func (self infinitePlugin) Info(scope types.Scope, type_map *TypeMap) *PluginInfo {
+	return &PluginInfo{
+		Name:      "infinite",
+		Unbounded: true,
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	scope := makeTestScope().AppendPlugins(infinitePlugin{})
+	defer scope.Close()
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", log.Ldate|log.Ltime|log.Lshortfile))
+	scope.SetMaxDuration(100 * time.Millisecond)
+
+	vql, err := Parse("SELECT * FROM infinite()")
+	assert.NoError(t, err)
+
+	rows := 0
+	err = vql.EvalWithCallback(context.Background(), scope,
+		func(row Row) error {
+			rows++
+			return nil
+		})
+	assert.Equal(t, ErrQueryTimeout, err)
+	assert.True(t, rows > 0)
+
+	logger.Contains(t, "Query timed out")
+}
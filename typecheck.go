@@ -0,0 +1,270 @@
+package vfilter
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+	"www.velocidex.com/golang/vfilter/types"
+	"www.velocidex.com/golang/vfilter/utils"
+)
+
+// The type name reported when the type can not be inferred.
+const UnknownType = "Any"
+
+// Infer the type of each column the query produces without running
+// it. Types are inferred from literals and operators, from the
+// ReturnType of functions and from the RowType of the plugin in the
+// FROM clause. Returns an ordered mapping of column name to type
+// name. Types of example values are added to type_map if it is not
+// nil.
+func (self *VQL) TypeCheck(
+	scope types.Scope, type_map *types.TypeMap) *ordereddict.Dict {
+	result := ordereddict.NewDict()
+	if self.Query == nil {
+		return result
+	}
+
+	checker := &typeChecker{
+		scope:    scope,
+		type_map: type_map,
+	}
+	checker.checkSelect(self.Query, result)
+	return result
+}
+
+type typeChecker struct {
+	scope    types.Scope
+	type_map *types.TypeMap
+
+	// The example row emitted by the plugin in the FROM clause.
+	row_type types.Any
+}
+
+func (self *typeChecker) checkSelect(query *_Select, result *ordereddict.Dict) {
+	if query.SelectExpression == nil {
+		return
+	}
+
+	self.row_type = self.getRowType(query)
+
+	if query.SelectExpression.All {
+		self.addWildcard(query, result)
+	}
+
+	for _, expr := range query.SelectExpression.Expressions {
+		name := expr.GetName(self.scope)
+		if name == "*" {
+			self.addWildcard(query, result)
+			continue
+		}
+		result.Set(name, self.aliasedType(expr))
+	}
+}
+
+func (self *typeChecker) addWildcard(query *_Select, result *ordereddict.Dict) {
+	// Selecting from a stored query - use its types.
+	if query.From != nil {
+		symbol, pres := self.scope.Resolve(query.From.Plugin.Name)
+		if pres {
+			stored_query, ok := symbol.(*_StoredQuery)
+			if ok && stored_query.query != nil {
+				checker := &typeChecker{
+					scope:    self.scope,
+					type_map: self.type_map,
+				}
+				checker.checkSelect(stored_query.query, result)
+				return
+			}
+		}
+	}
+
+	if utils.IsNil(self.row_type) {
+		result.Set("*", UnknownType)
+		return
+	}
+
+	for _, member := range self.scope.GetMembers(self.row_type) {
+		value, _ := self.scope.Associative(self.row_type, member)
+		result.Set(member, self.typeName(value))
+	}
+}
+
+func (self *typeChecker) getRowType(query *_Select) types.Any {
+	if query.From == nil {
+		return nil
+	}
+
+	plugin, pres := self.scope.GetPlugin(query.From.Plugin.Name)
+	if !pres {
+		return nil
+	}
+
+	info := plugin.Info(self.scope, types.NewTypeMap())
+	if info == nil {
+		return nil
+	}
+	return info.RowType
+}
+
+// Returns the name of the example value's type.
+func (self *typeChecker) typeName(value types.Any) string {
+	if utils.IsNil(value) {
+		return UnknownType
+	}
+
+	switch value.(type) {
+	case types.Null, *types.Null:
+		return UnknownType
+	}
+
+	if self.type_map != nil {
+		return self.type_map.AddType(self.scope, value)
+	}
+
+	return strings.TrimLeft(reflect.TypeOf(value).String(), "*[]")
+}
+
+func (self *typeChecker) aliasedType(expr *_AliasedExpression) string {
+	if expr.SubSelect != nil {
+		return "[]Row"
+	}
+
+	if expr.Expression != nil {
+		return self.andType(expr.Expression)
+	}
+	return UnknownType
+}
+
+func (self *typeChecker) andType(expr *_AndExpression) string {
+	if len(expr.Right) > 0 {
+		return "bool"
+	}
+	return self.orType(expr.Left)
+}
+
+func (self *typeChecker) orType(expr *_OrExpression) string {
+	if len(expr.Right) > 0 {
+		return "bool"
+	}
+	return self.conditionType(expr.Left)
+}
+
+func (self *typeChecker) conditionType(expr *_ConditionOperand) string {
+	if expr.Not != nil || expr.Right != nil {
+		return "bool"
+	}
+	return self.additionType(expr.Left)
+}
+
+func (self *typeChecker) additionType(expr *_AdditionExpression) string {
+	result := self.multiplicationType(expr.Left)
+	for _, term := range expr.Right {
+		rhs := self.multiplicationType(term.Term)
+		if term.Operator == "+" && result == "string" && rhs == "string" {
+			continue
+		}
+		result = numericType(result, rhs)
+	}
+	return result
+}
+
+func (self *typeChecker) multiplicationType(expr *_MultiplicationExpression) string {
+	result := self.memberType(expr.Left)
+	for _, term := range expr.Right {
+		// Division always produces a float.
+		if term.Operator == "/" {
+			result = "float64"
+			continue
+		}
+		result = numericType(result, self.valueType(term.Factor))
+	}
+	return result
+}
+
+func (self *typeChecker) memberType(expr *_MemberExpression) string {
+	if len(expr.Right) > 0 {
+		return UnknownType
+	}
+	return self.valueType(expr.Left)
+}
+
+func (self *typeChecker) valueType(value *_Value) string {
+	value.mu.Lock()
+	value.maybeParseStrNumber(self.scope)
+	value.mu.Unlock()
+
+	switch {
+	case value.Subexpression != nil:
+		if len(value.Subexpression.Right) > 0 {
+			return UnknownType
+		}
+		return self.andType(value.Subexpression.Left)
+
+	case value.SymbolRef != nil:
+		return self.symbolType(value.SymbolRef)
+
+	case value.String != nil:
+		return "string"
+
+	case value.Int != nil:
+		return "int64"
+
+	case value.Float != nil:
+		return "float64"
+
+	case value.Duration != nil:
+		return "time.Duration"
+
+	case value.Boolean != nil:
+		return "bool"
+	}
+
+	return UnknownType
+}
+
+func (self *typeChecker) symbolType(symbol *_SymbolRef) string {
+	if symbol.Called {
+		function, pres := self.scope.GetFunction(symbol.Symbol)
+		if !pres {
+			return UnknownType
+		}
+
+		info := function.Info(self.scope, types.NewTypeMap())
+		if info == nil {
+			return UnknownType
+		}
+		return self.typeName(info.ReturnType)
+	}
+
+	// A reference to a column of the plugin's row.
+	if !utils.IsNil(self.row_type) {
+		value, pres := self.scope.Associative(self.row_type, symbol.Symbol)
+		if pres {
+			return self.typeName(value)
+		}
+	}
+
+	return UnknownType
+}
+
+func isIntType(name string) bool {
+	switch name {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64":
+		return true
+	}
+	return false
+}
+
+// Arithmetic on numbers produces a float if any side is a float.
+func numericType(a, b string) string {
+	switch {
+	case isIntType(a) && isIntType(b):
+		return "int64"
+	case (isIntType(a) || a == "float64") &&
+		(isIntType(b) || b == "float64"):
+		return "float64"
+	}
+	return UnknownType
+}
@@ -0,0 +1,82 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+var typeCheckTests = []struct {
+	query string
+	types *ordereddict.Dict
+}{
+	{"SELECT 1 AS A, 1.5 AS B, 'x' AS C, TRUE AS D, 1 / 2 AS E FROM scope()",
+		ordereddict.NewDict().
+			Set("A", "int64").
+			Set("B", "float64").
+			Set("C", "string").
+			Set("D", "bool").
+			Set("E", "float64")},
+	{"SELECT *, Size + 1 AS Next, Size * 1.5 AS Scaled, Name + 'x' AS Ext, " +
+		"Size > 2 AS Big, Missing FROM info()",
+		ordereddict.NewDict().
+			Set("Name", "string").
+			Set("Size", "int").
+			Set("Next", "int64").
+			Set("Scaled", "float64").
+			Set("Ext", "string").
+			Set("Big", "bool").
+			Set("Missing", UnknownType)},
+	{"SELECT upper(string=Name) AS Upper, foo() AS Foo FROM info()",
+		ordereddict.NewDict().
+			Set("Upper", "string").
+			Set("Foo", UnknownType)},
+	{"SELECT * FROM test()",
+		ordereddict.NewDict().Set("*", UnknownType)},
+	{"SELECT * FROM Stored",
+		ordereddict.NewDict().
+			Set("Name", "string").
+			Set("Size", "int").
+			Set("Total", "int")},
+}
+
+func TestTypeCheck(t *testing.T) {
+	scope := makeTestScope().AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "info",
+		RowType: ordereddict.NewDict().
+			Set("Name", "").
+			Set("Size", 0),
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			return nil
+		},
+	}).AppendFunctions(functions.GenericFunction{
+		FunctionName: "upper",
+		ReturnType:   "",
+		Function: func(
+			ctx context.Context,
+			scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			return nil
+		},
+	})
+	defer scope.Close()
+
+	stored, err := Parse("LET Stored = SELECT *, Size AS Total FROM info()")
+	assert.NoError(t, err)
+	for range stored.Eval(context.Background(), scope) {
+	}
+
+	for _, test := range typeCheckTests {
+		vql, err := Parse(test.query)
+		assert.NoError(t, err)
+		assert.Equal(t, test.types, vql.TypeCheck(scope, nil), test.query)
+	}
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// Returned when materializing rows uses more memory than the
+	// scope's memory quota.
+	ErrMemoryQuotaExceeded = errors.New("Memory quota exceeded")
+
+	// Returned in strict mode when a query references an undefined
+	// symbol.
+	ErrUndefinedSymbol = errors.New("Undefined symbol")
+
+	// Returned in strict mode when dividing by zero.
+	ErrDivisionByZero = errors.New("Division by zero")
+
+	// Returned in strict mode when an operator can not be applied
+	// to its operands.
+	ErrTypeMismatch = errors.New("Type mismatch")
+
+	// Returned when a LET function recurses deeper than the scope's
+	// recursion limit.
+	ErrRecursionDepthExceeded = errors.New("Recursion depth exceeded")
+
+	// Reported when scopes are nested deeper than the scope's
+	// MaxScopeDepth.
+	ErrScopeDepthExceeded = errors.New("Scope depth exceeded")
+
+	// Returned when a query reads more rows than the scope's
+	// MaxRows.
+	ErrRowLimitExceeded = errors.New("Row limit exceeded")
+
+	// Returned when subqueries are nested deeper than the scope's
+	// MaxSubqueryDepth.
+	ErrSubqueryDepthExceeded = errors.New("Subquery depth exceeded")
+)
+
+type queryAbortKeyType int
+
+const queryAbortKey queryAbortKeyType = 0
+
+type queryAbort struct {
+	mu     sync.Mutex
+	cancel func()
+	err    error
+}
+
+// Make the query abortable from any component which receives its
+// context (e.g. materializers and sorters).
+func WithQueryAbort(ctx context.Context) (context.Context, func()) {
+	sub_ctx, cancel := context.WithCancel(ctx)
+	return context.WithValue(sub_ctx, queryAbortKey, &queryAbort{
+		cancel: cancel,
+	}), cancel
+}
+
+// Abort the query with the error. Only the first error is kept.
+func AbortQuery(ctx context.Context, err error) {
+	abort, ok := ctx.Value(queryAbortKey).(*queryAbort)
+	if !ok {
+		return
+	}
+
+	FailQuery(ctx, err)
+	abort.cancel()
+}
+
+// Record the error the query fails with without cancelling it. The
+// caller stops producing rows so the query ends once the rows
+// already sent are delivered. Only the first error is kept.
+func FailQuery(ctx context.Context, err error) {
+	abort, ok := ctx.Value(queryAbortKey).(*queryAbort)
+	if !ok {
+		return
+	}
+
+	abort.mu.Lock()
+	if abort.err == nil {
+		abort.err = err
+	}
+	abort.mu.Unlock()
+}
+
+// The error the query was aborted with, if any.
+func QueryAbortError(ctx context.Context) error {
+	abort, ok := ctx.Value(queryAbortKey).(*queryAbort)
+	if !ok {
+		return nil
+	}
+
+	abort.mu.Lock()
+	defer abort.mu.Unlock()
+
+	return abort.err
+}
@@ -0,0 +1,40 @@
+package types
+
+import "strings"
+
+// A frame in the VQL call chain. Each query, foreach() body and
+// stored query pushes a frame on its scope so errors can be traced
+// back to the part of the query which produced them.
+type CallFrame struct {
+	Description string
+	Parent      *CallFrame
+}
+
+func (self *CallFrame) Push(description string) *CallFrame {
+	return &CallFrame{
+		Description: description,
+		Parent:      self,
+	}
+}
+
+// Render the chain from the outermost frame.
+func (self *CallFrame) String() string {
+	var frames []string
+	for frame := self; frame != nil; frame = frame.Parent {
+		frames = append(frames, frame.Description)
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return strings.Join(frames, " → ")
+}
+
+// Shorten long descriptions (e.g. query text) for the call chain.
+func CallFrameDescription(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 80 {
+		return text[:77] + "..."
+	}
+	return text
+}
@@ -0,0 +1,41 @@
+package types
+
+import "context"
+
+type scopeKeyType int
+
+const scopeKey scopeKeyType = 0
+
+// A context carrying the scope the query is evaluated in. Values not
+// found in the scope's Go context values are looked up in the parent
+// context. Queries attach it once (see VQL.Eval) so lookups do not
+// walk a wrapper for each nested query.
+type scopeContext struct {
+	context.Context
+	scope Scope
+}
+
+func (self scopeContext) Value(key interface{}) interface{} {
+	if key == scopeKey {
+		return self.scope
+	}
+
+	value, pres := self.scope.GetGoContextValue(key)
+	if pres {
+		return value
+	}
+
+	return self.Context.Value(key)
+}
+
+// Attach the scope to the context so plugins and functions can
+// retrieve it and the values set with scope.SetGoContextValue().
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return scopeContext{Context: ctx, scope: scope}
+}
+
+// Get the scope the query is evaluated in.
+func GetScope(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey).(Scope)
+	return scope, ok
+}
@@ -0,0 +1,16 @@
+package types
+
+// Describes where a LET symbol was defined.
+type Definition struct {
+	Name       string
+	Operator   string
+	Parameters []string
+
+	// The original text of the LET statement.
+	Source string
+	Line   int
+	Column int
+
+	// The id of the query which defined the symbol.
+	QueryId string
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// A query referenced a plugin which does not exist. This is an
+	// undefined symbol so errors.Is(err, ErrUndefinedSymbol) also
+	// matches it.
+	ErrPluginNotFound = fmt.Errorf("%w: Plugin not found", ErrUndefinedSymbol)
+
+	// A plugin or function was called with invalid args.
+	ErrArgParse = errors.New("Invalid args")
+
+	// A function or column expression panicked.
+	ErrPanic = errors.New("Panic")
+
+	// A query hides an existing variable. This is only a warning
+	// and does not stop the query (see ShadowingWarning).
+	ErrShadowing = errors.New("Shadowing")
+)
+
+// Runtime problems are logged as strings. Callers who need to detect
+// them programmatically can install an ErrorCollector on the scope
+// which receives a *QueryError for each problem. Use errors.Is() to
+// test for the kind of problem (e.g. ErrPluginNotFound). The
+// collector may be called from many goroutines.
+type ErrorCollector interface {
+	CollectError(err *QueryError)
+}
+
+type QueryError struct {
+	Err error
+
+	// The VQL call chain where the error occurred.
+	CallChain string
+}
+
+func (self *QueryError) Error() string {
+	if self.CallChain == "" {
+		return self.Err.Error()
+	}
+	return fmt.Sprintf("%v (VQL call chain: %v)", self.Err, self.CallChain)
+}
+
+func (self *QueryError) Unwrap() error {
+	return self.Err
+}
+
+// Reported when a query hides an existing variable. Use errors.As()
+// to get the details.
+type ShadowingWarning struct {
+	// What hides the variable: "LET", "Alias" or "Column".
+	Kind string
+	Name string
+
+	// The plugin emitting the column for Kind "Column".
+	Plugin string
+}
+
+func (self *ShadowingWarning) Error() string {
+	if self.Plugin != "" {
+		return fmt.Sprintf("%v: %v %v from plugin %v shadows an existing variable",
+			ErrShadowing, self.Kind, self.Name, self.Plugin)
+	}
+	return fmt.Sprintf("%v: %v %v shadows an existing variable",
+		ErrShadowing, self.Kind, self.Name)
+}
+
+func (self *ShadowingWarning) Unwrap() error {
+	return ErrShadowing
+}
@@ -20,6 +20,17 @@ type PluginGeneratorInterface interface {
 	Info(scope Scope, type_map *TypeMap) *PluginInfo
 }
 
+// Plugins which produce many rows may also send them to the query in
+// batches, saving a channel send for each row. Batches should have
+// about scope.BatchSize() rows and must not be changed after they
+// are sent. CallBatches() is only used when batching is enabled in
+// the scope, so the plugin must still implement Call().
+type BatchPluginGeneratorInterface interface {
+	PluginGeneratorInterface
+	CallBatches(ctx context.Context, scope Scope,
+		args *ordereddict.Dict) <-chan []Row
+}
+
 // Describes the specific plugin.
 type PluginInfo struct {
 	// The name of the plugin.
@@ -36,6 +47,29 @@ type PluginInfo struct {
 
 	// Arbitrary metadata attched to the plugin info
 	Metadata *ordereddict.Dict
+
+	// An optional example of the rows this plugin emits. This is
+	// used to resolve the columns of SELECT * queries before
+	// running them. It may be a struct or a dict.
+	RowType Any
+
+	// The plugin may emit rows forever (e.g. an event source).
+	// Queries selecting from it without a WHERE or LIMIT clause
+	// never terminate.
+	Unbounded bool
+
+	// A relative hint of how expensive the plugin is to run. The
+	// planner evaluates cheap conditions first. A zero cost means
+	// unknown.
+	Cost int
+
+	// The capabilities the plugin requires (e.g. FILESYSTEM). These
+	// are checked by the scope's SecurityPolicy.
+	Capabilities []string
+
+	// The plugin always returns the same rows for the same args so
+	// its results may be reused from the scope's QueryCache.
+	Cacheable bool
 }
 
 // Describe functions.
@@ -56,6 +90,19 @@ type FunctionInfo struct {
 
 	// Arbitrary metadata attched to the function info
 	Metadata *ordereddict.Dict
+
+	// An optional example of the value this function returns. This
+	// is used to infer column types before running the query.
+	ReturnType Any
+
+	// A relative hint of how expensive the function is to call. The
+	// planner evaluates cheap conditions first. A zero cost means
+	// unknown.
+	Cost int
+
+	// The capabilities the function requires. These are checked by
+	// the scope's SecurityPolicy.
+	Capabilities []string
 }
 
 // Describe a type. This is meant for human consumption so it does not
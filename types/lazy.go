@@ -45,3 +45,13 @@ type LazyExpr interface {
 type StoredExpression interface {
 	Reduce(ctx context.Context, scope Scope) Any
 }
+
+// A lambda expression (e.g. "x => x.Name") which plugins may call
+// with their own parameters, for example with each row.
+type Lambda interface {
+	Reduce(ctx context.Context, scope Scope, parameters []Any) Any
+}
+
+// Parses lambda expressions. This is set by the vfilter package so
+// plugins which can not import it may still accept lambdas.
+var ParseLambda func(expression string) (Lambda, error)
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type queryMemoryKeyType int
+
+const queryMemoryKey queryMemoryKeyType = 0
+
+type queryMemory struct {
+	used int64
+}
+
+// Account for the memory materialized by the query separately from
+// other queries. The memory is released when the query ends and its
+// context is discarded.
+func WithQueryMemory(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryMemoryKey, &queryMemory{})
+}
+
+// Add size to the memory used by the query and return the total
+// used so far. Outside a query only the size itself is returned.
+func ChargeQueryMemory(ctx context.Context, size int64) int64 {
+	memory, ok := ctx.Value(queryMemoryKey).(*queryMemory)
+	if !ok {
+		return size
+	}
+	return atomic.AddInt64(&memory.used, size)
+}
@@ -0,0 +1,10 @@
+package types
+
+// A PluginNamespace resolves the plugins under a dotted prefix (see
+// Scope.AliasPlugin()). This allows large families of plugins
+// (e.g. Artifact.Linux.Sys) to be resolved on demand instead of
+// registering every dotted name. The name passed is relative to the
+// prefix.
+type PluginNamespace interface {
+	GetPlugin(scope Scope, name string) (PluginGeneratorInterface, bool)
+}
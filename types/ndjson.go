@@ -0,0 +1,21 @@
+package types
+
+import (
+	"bufio"
+	"io"
+)
+
+// A stream of NDJSON (newline delimited JSON) rows. Wrapping a reader
+// declares that it should be queried as rows - other readers in the
+// scope (e.g. open files passed to functions) are left alone:
+//
+//	scope.AppendVars(ordereddict.NewDict().
+//	    Set("Stream", types.NewNDJSONSource(reader)))
+//	SELECT * FROM Stream
+type NDJSONSource struct {
+	Scanner *bufio.Scanner
+}
+
+func NewNDJSONSource(reader io.Reader) *NDJSONSource {
+	return &NDJSONSource{Scanner: bufio.NewScanner(reader)}
+}
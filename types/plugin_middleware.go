@@ -0,0 +1,18 @@
+package types
+
+import (
+	"context"
+
+	"github.com/Velocidex/ordereddict"
+)
+
+// Calls the next middleware in the chain, or the plugin itself.
+type PluginCallNext func(ctx context.Context, scope Scope,
+	args *ordereddict.Dict) <-chan Row
+
+// A PluginMiddleware wraps every plugin call made through the
+// scope. It may inspect or rewrite the arguments, filter the rows or
+// refuse the call by returning a closed channel instead of calling
+// next().
+type PluginMiddleware func(ctx context.Context, scope Scope,
+	name string, args *ordereddict.Dict, next PluginCallNext) <-chan Row
@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// A profiler can be installed into the scope to accumulate the time
+// spent evaluating each AST node during the query.
+type Profiler interface {
+	// Record a single evaluation of the AST node.
+	Record(ast_node interface{}, duration time.Duration)
+}
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// A QueryCache stores the materialized rows of plugin calls and
+// stored queries declared cacheable. Keys are built from the
+// normalized query and its args. Implementations must be safe to use
+// from many goroutines. The rows are shared so neither the caller of
+// Set() nor the caller of Get() may modify them - the query engine
+// copies each row before passing it on.
+type QueryCache interface {
+	Get(key string) ([]Row, bool)
+	Set(key string, rows []Row, ttl time.Duration)
+}
@@ -0,0 +1,50 @@
+package types
+
+import "fmt"
+
+// A lazy range of integers from Start (inclusive) to End (exclusive)
+// in increments of Step. Ranges are iterated on demand so they do not
+// need to be expanded in memory.
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func NewRange(start, end, step int64) *Range {
+	if step == 0 {
+		step = 1
+	}
+	return &Range{Start: start, End: end, Step: step}
+}
+
+// Does the range produce this value?
+func (self *Range) Contains(value int64) bool {
+	if self.Step > 0 {
+		return value >= self.Start && value < self.End &&
+			(value-self.Start)%self.Step == 0
+	}
+
+	return value <= self.Start && value > self.End &&
+		(self.Start-value)%(-self.Step) == 0
+}
+
+// The number of values produced.
+func (self *Range) Len() int64 {
+	if self.Step > 0 {
+		if self.End <= self.Start {
+			return 0
+		}
+		return (self.End - self.Start + self.Step - 1) / self.Step
+	}
+
+	if self.End >= self.Start {
+		return 0
+	}
+	return (self.Start - self.End - self.Step - 1) / -self.Step
+}
+
+func (self *Range) String() string {
+	return fmt.Sprintf("range(start=%v, end=%v, step=%v)",
+		self.Start, self.End, self.Step)
+}
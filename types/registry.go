@@ -0,0 +1,47 @@
+package types
+
+import "sync"
+
+// A Registry collects functions and plugins, usually from the init()
+// functions of the packages implementing them, so they can be added
+// to a scope in one go with scope.ImportRegistry(). It is safe to use
+// from many goroutines.
+type Registry struct {
+	mu        sync.Mutex
+	functions []FunctionInterface
+	plugins   []PluginGeneratorInterface
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (self *Registry) RegisterFunction(functions ...FunctionInterface) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.functions = append(self.functions, functions...)
+}
+
+func (self *Registry) RegisterPlugin(plugins ...PluginGeneratorInterface) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.plugins = append(self.plugins, plugins...)
+}
+
+// The registered functions in the order they were registered. Later
+// functions replace earlier ones of the same name when imported.
+func (self *Registry) Functions() []FunctionInterface {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return append([]FunctionInterface{}, self.functions...)
+}
+
+func (self *Registry) Plugins() []PluginGeneratorInterface {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return append([]PluginGeneratorInterface{}, self.plugins...)
+}
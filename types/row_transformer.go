@@ -0,0 +1,5 @@
+package types
+
+// Applied to every row emitted by a top level query, e.g. to add
+// constant columns or redact fields. Returning nil drops the row.
+type RowTransformer func(scope Scope, row Row) Row
@@ -3,7 +3,9 @@ package types
 import (
 	"context"
 	"log"
+	"regexp"
 	"runtime"
+	"time"
 
 	"github.com/Velocidex/ordereddict"
 )
@@ -29,6 +31,10 @@ type Scope interface {
 	// Copy the scope and create a subscope child.
 	Copy() Scope
 
+	// Copy the scope with its own copy of the variables so a
+	// running query is not affected by later changes to them.
+	Snapshot() Scope
+
 	// The scope context is a global k/v store
 	GetContext(name string) (Any, bool)
 	SetContext(name string, value Any)
@@ -37,6 +43,18 @@ type Scope interface {
 	SetContextDict(context *ordereddict.Dict)
 	ClearContext()
 
+	// Values provided by the embedding application. They are
+	// visible to plugins and functions through ctx.Value(key)
+	// while the query runs.
+	SetGoContextValue(key, value interface{})
+	GetGoContextValue(key interface{}) (interface{}, bool)
+
+	// Subqueries named with FROM ... AS name may be cancelled
+	// individually while the query runs.
+	RegisterSubquery(name string, cancel func()) func()
+	CancelSubquery(name string) bool
+	RunningSubqueries() []string
+
 	// Extract debug string about the current scope state.
 	PrintVars() string
 
@@ -56,16 +74,35 @@ type Scope interface {
 		name string, query StoredQuery) StoredQuery
 
 	Match(a Any, b Any) bool
+	Glob(a Any, b Any) bool
 	Iterate(ctx context.Context, a Any) <-chan Row
 
+	// Push a frame on the VQL call chain of this scope. Child
+	// scopes inherit the call chain.
+	PushCallFrame(description string)
+	CallChain() string
+
+	// Keep track of LET definitions. Redefining a symbol replaces
+	// its definition.
+	AddDefinition(definition *Definition)
+	GetDefinitions() []*Definition
+
 	// The scope's top level variable. Scopes search backward
 	// through their parents to resolve names from these vars.
 	AppendVars(row Row) Scope
 	Resolve(field string) (interface{}, bool)
 
-	// Program a custom sorter
+	// Program a custom sorter and grouper for ORDER BY and GROUP
+	// BY. Sort() and Group() delegate to the current ones. A custom
+	// implementation may get the previous one with Sorter() or
+	// Grouper() to handle the cases it does not specialize in.
 	SetSorter(sorter Sorter)
 	SetGrouper(grouper Grouper)
+	Sorter() Sorter
+	Grouper() Grouper
+	Sort(ctx context.Context, scope Scope, input <-chan Row,
+		key string, desc bool) <-chan Row
+	Group(ctx context.Context, scope Scope, actor GroupbyActor) <-chan Row
 	SetMaterializer(materializer ScopeMaterializer)
 	SetExplainer(explainer Explainer)
 
@@ -73,10 +110,21 @@ type Scope interface {
 	EnableExplain()
 	Explainer() Explainer
 
+	// Install a profiler to time AST node evaluation. Profiler()
+	// returns nil when profiling is not enabled.
+	SetProfiler(profiler Profiler)
+	Profiler() Profiler
+
 	// We can program the scope's protocols
 	AddProtocolImpl(implementations ...Any) Scope
 	AppendFunctions(functions ...FunctionInterface) Scope
+	OverloadFunctions(functions ...FunctionInterface) Scope
 	AppendPlugins(plugins ...PluginGeneratorInterface) Scope
+	ImportRegistry(registry *Registry) Scope
+
+	// Make a plugin available under another name. The target may
+	// be a plugin name, a plugin or a PluginNamespace.
+	AliasPlugin(alias string, target Any) error
 
 	// Logging and performance monitoring.
 	SetLogger(logger *log.Logger)
@@ -85,12 +133,51 @@ type Scope interface {
 	GetStats() *Stats
 
 	// Log levels
+	// ERROR messages include the VQL call chain of the scope.
 	Log(format string, a ...interface{})
 	Error(format string, a ...interface{})
 	Warn(format string, a ...interface{})
 	Debug(format string, a ...interface{})
 	Trace(format string, a ...interface{})
 
+	// Report runtime errors to the scope's ErrorCollector (if
+	// any). Errors should also be logged as usual.
+	SetErrorCollector(collector ErrorCollector)
+	ReportError(err error)
+
+	// Called when a query references a plugin or function which
+	// is not registered.
+	SetUnknownPluginHandler(handler UnknownPluginHandler)
+	UnknownPluginHandler() UnknownPluginHandler
+
+	// Applied to every row emitted by a top level query.
+	AddRowTransformer(transformer RowTransformer)
+	TransformRow(row Row) Row
+
+	// Middleware wraps every plugin call.
+	AddPluginMiddleware(middleware PluginMiddleware)
+	CallPlugin(ctx context.Context, name string,
+		plugin PluginGeneratorInterface, args *ordereddict.Dict) <-chan Row
+
+	// Calls the plugin's CallBatches() if batching is enabled and
+	// the plugin supports it. Otherwise (or when there is
+	// middleware, which sees the rows one at a time) each row is
+	// sent in its own batch.
+	CallPluginBatches(ctx context.Context, name string,
+		plugin PluginGeneratorInterface, args *ordereddict.Dict) <-chan []Row
+
+	// Restricts the plugins and functions queries may call.
+	SetSecurityPolicy(policy SecurityPolicy)
+	SecurityPolicy() SecurityPolicy
+
+	// Reuse the results of cacheable plugins.
+	SetQueryCache(cache QueryCache, ttl time.Duration)
+	QueryCache() (QueryCache, time.Duration)
+
+	// Signs the hash of query results.
+	SetResultSigner(signer ResultSigner)
+	ResultSigner() ResultSigner
+
 	// Introspection
 	GetFunction(name string) (FunctionInterface, bool)
 	GetPlugin(name string) (PluginGeneratorInterface, bool)
@@ -98,6 +185,102 @@ type Scope interface {
 	Describe(type_map *TypeMap) *ScopeInformation
 	CheckForOverflow() bool
 
+	// Queries running longer than this are terminated. A zero
+	// duration means no limit.
+	SetMaxDuration(max_duration time.Duration)
+	MaxDuration() time.Duration
+
+	// Guards against pathological (e.g. self referencing)
+	// queries. Scopes nested deeper than MaxScopeDepth (default
+	// 1000) are not evaluated and report ErrScopeDepthExceeded. A
+	// query reading more than MaxRows rows from its plugins
+	// (including subqueries) aborts with ErrRowLimitExceeded and
+	// subqueries nested deeper than MaxSubqueryDepth abort the
+	// query with ErrSubqueryDepthExceeded. Zero means no limit.
+	SetMaxScopeDepth(depth int)
+	MaxScopeDepth() int
+	SetMaxRows(rows int64)
+	MaxRows() int64
+	SetMaxSubqueryDepth(depth int)
+	MaxSubqueryDepth() int
+
+	// Limit the memory each query may use to materialize rows
+	// (LET <=, ORDER BY and GROUP BY). A zero quota means no
+	// limit. ChargeMemory() adds to the memory used by the query
+	// in ctx and returns ErrMemoryQuotaExceeded when the quota is
+	// exceeded. The memory is released when the query ends.
+	SetMemoryQuota(quota int64)
+	ChargeMemory(ctx context.Context, size int) error
+
+	// Share the memory of repeated strings in result rows and
+	// materialized rows. A size of 0 disables interning.
+	SetStringInterning(size int)
+	InternRow(row Row) Row
+
+	// Regexes for the =~ operator are compiled once and kept in an
+	// LRU cache shared by the scope and its children.
+	CompileRegex(pattern string) (*regexp.Regexp, error)
+	SetRegexCacheSize(size int)
+
+	// Floats (and ints compared to floats) within epsilon of each
+	// other are considered equal by the Eq protocol. The default
+	// of 0 compares exactly.
+	SetFloatEpsilon(epsilon float64)
+	FloatEpsilon() float64
+
+	// By default the WHERE clause sees the SELECT aliases on top
+	// of the source row's columns. When disabled WHERE only sees
+	// the source columns.
+	SetWhereAliases(enabled bool)
+	WhereAliases() bool
+
+	// In strict mode referencing an undefined symbol, function or
+	// plugin aborts the query with ErrUndefinedSymbol (or
+	// ErrPluginNotFound which wraps it) instead of producing Null. Likewise arithmetic aborts with
+	// ErrDivisionByZero or ErrTypeMismatch.
+	SetStrictMode(strict bool)
+	StrictMode() bool
+
+	// With snapshot isolation each top level query runs in a
+	// Snapshot() of the scope so it is not affected by LETs and
+	// changes to the variables made by other sessions while it
+	// runs. Note the query also does not see changes it makes to
+	// the variables itself (e.g. through functions which update
+	// a dict in the scope).
+	SetSnapshotIsolation(enabled bool)
+	SnapshotIsolation() bool
+
+	// Run the subqueries of foreach(workers=...) and
+	// chain(async=TRUE) one at a time, in order, so these two
+	// plugins produce the same rows in the same order each time they
+	// run, which is useful for tests. Other plugins which run
+	// goroutines are not affected. There is no seed for the
+	// scheduling: the order is the one written in the query.
+	// Defaults to false.
+	SetDeterministic(enabled bool)
+	Deterministic() bool
+
+	// Warn when a LET, an alias or a column from a plugin hides an
+	// existing variable. Each warning is logged once per query and
+	// reported to the ErrorCollector as a *ShadowingWarning.
+	// Defaults to false.
+	SetShadowingWarnings(enabled bool)
+	ShadowingWarnings() bool
+
+	// Plugins which support it send their rows to queries in
+	// batches of about size rows (see
+	// BatchPluginGeneratorInterface). Zero (the default) sends the
+	// rows one at a time.
+	SetBatchSize(size int)
+	BatchSize() int
+
+	// Limit how many times a LET function may call itself
+	// recursively (e.g. to walk a tree). Exceeding the limit aborts
+	// the query with ErrRecursionDepthExceeded. Zero means no limit
+	// apart from the scope's stack depth.
+	SetMaxRecursionDepth(depth int)
+	MaxRecursionDepth() int
+
 	// Charge an op to the throttler.
 	ChargeOp()
 	SetThrottler(t Throttler)
@@ -105,6 +288,10 @@ type Scope interface {
 	// Destructors are called when the scope is Close(). If the
 	// scope is already closed adding the destructor may fail.
 	AddDestructor(fn func()) error
+
+	// The destructor's context expires when the scope stops
+	// waiting for it.
+	AddDestructorWithContext(fn func(ctx context.Context)) error
 	IsClosed() bool
 	Close()
 }
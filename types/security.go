@@ -0,0 +1,43 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Common capabilities plugins and functions may declare in their
+// Info().
+const (
+	CapabilityFilesystem = "FILESYSTEM"
+	CapabilityNetwork    = "NETWORK"
+	CapabilityProcess    = "PROCESS"
+)
+
+// A query called a plugin or function which the scope's
+// SecurityPolicy does not allow.
+var ErrAccessDenied = errors.New("Access denied")
+
+type AccessDeniedError struct {
+	// "plugin" or "function"
+	Kind   string
+	Name   string
+	Reason string
+}
+
+func (self *AccessDeniedError) Error() string {
+	return fmt.Sprintf("%v: %v %v: %v",
+		ErrAccessDenied, self.Kind, self.Name, self.Reason)
+}
+
+func (self *AccessDeniedError) Unwrap() error {
+	return ErrAccessDenied
+}
+
+// A SecurityPolicy decides which plugins and functions a query may
+// call. The name is the name used in the query which may be an
+// alias. A non-nil error (normally an *AccessDeniedError) refuses the
+// call and aborts the query.
+type SecurityPolicy interface {
+	CheckPlugin(scope Scope, name string, info *PluginInfo) error
+	CheckFunction(scope Scope, name string, info *FunctionInfo) error
+}
@@ -0,0 +1,7 @@
+package types
+
+// Signs the digest of a query's output so consumers can verify where
+// the result came from.
+type ResultSigner interface {
+	Sign(digest []byte) ([]byte, error)
+}
@@ -3,6 +3,8 @@ package types
 import "context"
 
 // A Sorter is a pluggable way for VQL to sort an incoming set of rows.
+// Sort() must return without reading the input since the rows are
+// only sent after it returns.
 type Sorter interface {
 	Sort(ctx context.Context,
 		scope Scope,
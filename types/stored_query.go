@@ -2,6 +2,8 @@ package types
 
 import (
 	"context"
+
+	"www.velocidex.com/golang/vfilter/utils"
 )
 
 // A plugin like object which takes no arguments but may be inserted
@@ -20,7 +22,16 @@ func Materialize(ctx context.Context, scope Scope, stored_query StoredQuery) []R
 	defer new_scope.Close()
 
 	for item := range stored_query.Eval(ctx, new_scope) {
-		result = append(result, item)
+		err := scope.ChargeMemory(ctx, utils.EstimateSize(item))
+		if err != nil {
+			// Do not format the stored query here since that
+			// would materialize it again.
+			scope.Log("ERROR:During Materialize of StoredQuery: %v", err)
+			AbortQuery(ctx, err)
+			break
+		}
+
+		result = append(result, scope.InternRow(item))
 
 		if !warned && len(result) > 10000 {
 			scope.Log("WARN:During Materialize of StoredQuery %s: Expand larger than 10,000 rows!",
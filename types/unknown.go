@@ -0,0 +1,11 @@
+package types
+
+// Called when a query references a plugin or function which is not
+// registered. The handler may register it on demand (e.g. with
+// scope.AppendPlugins()) and return true, in which case the lookup
+// is retried. Otherwise the handler may return suggestions which
+// are included in the error message instead of the default ones.
+type UnknownPluginHandler interface {
+	UnknownPlugin(scope Scope, name string) (bool, []string)
+	UnknownFunction(scope Scope, name string) (bool, []string)
+}
@@ -0,0 +1,71 @@
+package vfilter
+
+import (
+	"fmt"
+
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Detect queries which will never terminate because they select
+// from an unbounded plugin (see PluginInfo.Unbounded) with no WHERE
+// or LIMIT clause. Returns a description of the problem or an empty
+// string if the query is bounded.
+func (self *VQL) CheckUnbounded(scope types.Scope) string {
+	query := self.Query
+	if query == nil {
+		query = self.StoredQuery
+	}
+	if query == nil {
+		return ""
+	}
+
+	source := unboundedSource(scope, query, 0)
+	if source == "" {
+		return ""
+	}
+	return fmt.Sprintf("Query selects from unbounded source %v "+
+		"without a WHERE or LIMIT clause", source)
+}
+
+// Add a LIMIT clause to the query if it is unbounded. This is
+// useful in interactive sessions where a query which never
+// terminates is usually a mistake. Returns true if the query was
+// changed.
+func (self *VQL) LimitUnbounded(scope types.Scope, limit int64) bool {
+	if self.Query == nil || self.CheckUnbounded(scope) == "" {
+		return false
+	}
+
+	self.Query.Limit = &limit
+	return true
+}
+
+// Returns the name of the unbounded plugin the query selects from.
+func unboundedSource(scope types.Scope, query *_Select, depth int) string {
+	if query.Limit != nil || query.Where != nil || query.From == nil ||
+		depth > 10 {
+		return ""
+	}
+
+	name := query.From.Plugin.Name
+
+	// Selecting from a stored query which is itself unbounded.
+	symbol, pres := scope.Resolve(name)
+	if pres {
+		stored_query, ok := symbol.(*_StoredQuery)
+		if ok && stored_query.query != nil {
+			return unboundedSource(scope, stored_query.query, depth+1)
+		}
+	}
+
+	plugin, pres := scope.GetPlugin(name)
+	if !pres {
+		return ""
+	}
+
+	info := plugin.Info(scope, types.NewTypeMap())
+	if info != nil && info.Unbounded {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnboundedQueries(t *testing.T) {
+	scope := makeTestScope().AppendPlugins(infinitePlugin{})
+	defer scope.Close()
+
+	multi_vql, err := MultiParse(`
+LET X = SELECT * FROM infinite()
+SELECT * FROM infinite()
+SELECT * FROM infinite() LIMIT 5
+SELECT * FROM infinite() WHERE Count < 5
+SELECT * FROM X
+SELECT * FROM scope()
+`)
+	assert.NoError(t, err)
+
+	var results []string
+	for _, vql := range multi_vql {
+		results = append(results, vql.CheckUnbounded(scope))
+	}
+
+	assert.NotEqual(t, "", results[0])
+	assert.Contains(t, results[1], "unbounded source infinite")
+	assert.Equal(t, "", results[2])
+	assert.Equal(t, "", results[3])
+	assert.Equal(t, "", results[4], "X is not defined yet")
+	assert.Equal(t, "", results[5])
+
+	// Once X is defined, selecting from it is also unbounded.
+	for _ = range multi_vql[0].Eval(context.Background(), scope) {
+	}
+	assert.NotEqual(t, "", multi_vql[4].CheckUnbounded(scope))
+
+	// Inject a default LIMIT so the query terminates.
+	assert.True(t, multi_vql[4].LimitUnbounded(scope, 3))
+	assert.False(t, multi_vql[2].LimitUnbounded(scope, 3))
+
+	rows := 0
+	for _ = range multi_vql[4].Eval(context.Background(), scope) {
+		rows++
+	}
+	assert.Equal(t, 3, rows)
+}
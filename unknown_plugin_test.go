@@ -0,0 +1,100 @@
+package vfilter
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+	"www.velocidex.com/golang/vfilter/functions"
+	"www.velocidex.com/golang/vfilter/plugins"
+	"www.velocidex.com/golang/vfilter/types"
+)
+
+// Loads the "lazy" plugin and function on demand and suggests names
+// for anything else.
+type lazyLoader struct {
+	calls int
+}
+
+func (self *lazyLoader) UnknownPlugin(
+	scope types.Scope, name string) (bool, []string) {
+	self.calls++
+	if name != "lazy" {
+		return false, []string{"lazy"}
+	}
+
+	scope.AppendPlugins(plugins.GenericListPlugin{
+		PluginName: "lazy",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) []Row {
+			return []Row{ordereddict.NewDict().Set("Loaded", true)}
+		},
+	})
+	return true, nil
+}
+
+func (self *lazyLoader) UnknownFunction(
+	scope types.Scope, name string) (bool, []string) {
+	self.calls++
+	if name != "lazy_func" {
+		return false, []string{"lazy_func"}
+	}
+
+	scope.AppendFunctions(functions.GenericFunction{
+		FunctionName: "lazy_func",
+		Function: func(ctx context.Context, scope types.Scope,
+			args *ordereddict.Dict) types.Any {
+			return "loaded"
+		},
+	})
+	return true, nil
+}
+
+func TestUnknownPluginHandler(t *testing.T) {
+	scope := makeTestScope()
+	defer scope.Close()
+
+	logger := &logWriter{Writer: os.Stdout}
+	scope.SetLogger(log.New(logger, "Log: ", log.Ldate|log.Ltime|log.Lshortfile))
+
+	loader := &lazyLoader{}
+	scope.SetUnknownPluginHandler(loader)
+
+	run := func(query string) []Row {
+		vql, err := Parse(query)
+		assert.NoError(t, err)
+
+		var result []Row
+		for row := range vql.Eval(context.Background(), scope) {
+			result = append(result, row)
+		}
+		return result
+	}
+
+	// Plugins and functions are loaded on demand.
+	rows := run("SELECT *, lazy_func() AS Func FROM lazy()")
+	assert.Equal(t, 1, len(rows))
+	value, _ := scope.Associative(rows[0], "Func")
+	assert.Equal(t, "loaded", value)
+	logger.NotContains(t, "not found")
+	assert.Equal(t, 2, loader.calls)
+
+	// Once loaded the handler is not called again.
+	run("SELECT *, lazy_func() AS Func FROM lazy()")
+	assert.Equal(t, 2, loader.calls)
+
+	// Registered plugins and LET queries are not unknown.
+	run("LET X = SELECT * FROM scope()")
+	run("SELECT * FROM X")
+	assert.Equal(t, 2, loader.calls)
+
+	// Otherwise the suggestions are used in the error.
+	run("SELECT * FROM lazzy()")
+	logger.Contains(t, "Plugin lazzy not found. Did you mean lazy?")
+
+	run("SELECT lazzy_func() FROM scope()")
+	logger.Contains(t, "Symbol lazzy_func not found. Did you mean lazy_func?")
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)(ns|us|ms|[smhd])`)
+
+	durationUnits = map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+	}
+)
+
+// Parse a duration literal like 5m, 2h30m or 7d. This is similar to
+// time.ParseDuration() but also accepts days.
+func ParseDuration(value string) (time.Duration, error) {
+	var result time.Duration
+
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+	}
+	value = strings.TrimLeft(value, "+-")
+
+	matches := durationRegex.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("Invalid duration %v", value)
+	}
+
+	end := 0
+	for _, match := range matches {
+		if match[0] != end {
+			return 0, fmt.Errorf("Invalid duration %v", value)
+		}
+		end = match[1]
+
+		number, err := strconv.ParseFloat(value[match[2]:match[3]], 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration %v", value)
+		}
+
+		unit := durationUnits[value[match[4]:match[5]]]
+		result += time.Duration(number * float64(unit))
+	}
+
+	if end != len(value) {
+		return 0, fmt.Errorf("Invalid duration %v", value)
+	}
+
+	return sign * result, nil
+}
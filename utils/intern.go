@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/Velocidex/ordereddict"
+)
+
+// Deduplicates equal strings so they all share the same memory. Large
+// result sets often repeat the same values (user names, paths) in
+// many rows. To bound memory use, at most size distinct strings are
+// remembered - other strings are returned as they are.
+type StringInterner struct {
+	mu      sync.Mutex
+	size    int
+	strings map[string]string
+}
+
+func NewStringInterner(size int) *StringInterner {
+	return &StringInterner{
+		size:    size,
+		strings: make(map[string]string),
+	}
+}
+
+func (self *StringInterner) Intern(s string) string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	interned, pres := self.strings[s]
+	if pres {
+		return interned
+	}
+
+	if len(self.strings) < self.size {
+		self.strings[s] = s
+	}
+	return s
+}
+
+// Number of distinct strings remembered.
+func (self *StringInterner) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	return len(self.strings)
+}
+
+// Intern all the strings in the value. Dicts and slices are updated
+// in place. Other types are returned as they are.
+func (self *StringInterner) InternValue(a interface{}) interface{} {
+	switch t := a.(type) {
+	case string:
+		return self.Intern(t)
+
+	case *ordereddict.Dict:
+		if t == nil {
+			return t
+		}
+		for _, key := range t.Keys() {
+			value, _ := t.Get(key)
+			switch value.(type) {
+			case string, *ordereddict.Dict, []interface{}, []string:
+				t.Update(key, self.InternValue(value))
+			}
+		}
+		return t
+
+	case []interface{}:
+		for idx, item := range t {
+			t[idx] = self.InternValue(item)
+		}
+		return t
+
+	case []string:
+		for idx, item := range t {
+			t[idx] = self.Intern(item)
+		}
+		return t
+	}
+
+	return a
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringInterner(t *testing.T) {
+	interner := NewStringInterner(2)
+
+	interner.Intern("a")
+	interner.Intern("b")
+	interner.Intern("a")
+	assert.Equal(t, 2, interner.Len())
+
+	// The table is full so new strings are not remembered.
+	assert.Equal(t, "c", interner.Intern("c"))
+	assert.Equal(t, 2, interner.Len())
+
+	row := ordereddict.NewDict().
+		Set("Name", "a").
+		Set("Count", 1).
+		Set("Nested", ordereddict.NewDict().Set("Name", "b")).
+		Set("List", []interface{}{"a", 2})
+	assert.Equal(t, row, interner.InternValue(row))
+
+	// Column order is preserved.
+	assert.Equal(t, []string{"Name", "Count", "Nested", "List"}, row.Keys())
+	assert.Equal(t, 2, interner.Len())
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Velocidex/ordereddict"
+)
+
+// Estimate the memory used by the value in bytes. This is not meant
+// to be accurate, only to track the rough cost of holding rows in
+// memory.
+func EstimateSize(a interface{}) int {
+	switch t := a.(type) {
+	case nil:
+		return 0
+
+	case string:
+		return 16 + len(t)
+
+	case []byte:
+		return 24 + len(t)
+
+	case *ordereddict.Dict:
+		if t == nil {
+			return 0
+		}
+		size := 64
+		for _, key := range t.Keys() {
+			value, _ := t.Get(key)
+			size += EstimateSize(key) + EstimateSize(value)
+		}
+		return size
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(a))
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		size := 24
+		for i := 0; i < value.Len(); i++ {
+			size += EstimateSize(value.Index(i).Interface())
+		}
+		return size
+
+	case reflect.Map:
+		size := 48
+		iter := value.MapRange()
+		for iter.Next() {
+			size += EstimateSize(iter.Key().Interface()) +
+				EstimateSize(iter.Value().Interface())
+		}
+		return size
+
+	case reflect.Invalid:
+		return 0
+	}
+
+	return int(value.Type().Size())
+}
+
+var (
+	byteSizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+	// kB, MB etc are powers of 1000 and KiB, MiB etc are powers of
+	// 1024 - the same units as humanize_bytes().
+	byteSizeUnits = map[string]float64{
+		"":    1,
+		"b":   1,
+		"k":   1e3,
+		"kb":  1e3,
+		"m":   1e6,
+		"mb":  1e6,
+		"g":   1e9,
+		"gb":  1e9,
+		"t":   1e12,
+		"tb":  1e12,
+		"ki":  1 << 10,
+		"kib": 1 << 10,
+		"mi":  1 << 20,
+		"mib": 1 << 20,
+		"gi":  1 << 30,
+		"gib": 1 << 30,
+		"ti":  1 << 40,
+		"tib": 1 << 40,
+	}
+)
+
+// Parse a byte size like 10MB, 1.5GiB or 512.
+func ParseByteSize(value string) (uint64, error) {
+	match := byteSizeRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("Invalid size %v", value)
+	}
+
+	unit, pres := byteSizeUnits[strings.ToLower(match[2])]
+	if !pres {
+		return 0, fmt.Errorf("Invalid size unit %v", match[2])
+	}
+
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid size %v", value)
+	}
+
+	return uint64(number * unit), nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Layouts tried in order when no format is given.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Parse a time string with the format (a Go time layout) or by
+// trying common layouts. Numeric strings are taken as epoch times.
+func ParseTimeString(
+	value, format string, location *time.Location) (time.Time, error) {
+	if format != "" {
+		return time.ParseInLocation(format, value, location)
+	}
+
+	// Numeric strings are epoch times.
+	number, err := strconv.ParseFloat(value, 64)
+	if err == nil {
+		result, ok := EpochToTime(number)
+		if ok {
+			return result.In(location), nil
+		}
+	}
+
+	for _, layout := range timeLayouts {
+		result, err := time.ParseInLocation(layout, value, location)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unable to parse %v as a time", value)
+}
+
+// Epoch values are autodetected as seconds, milliseconds,
+// microseconds or nanoseconds by their magnitude.
+func EpochToTime(value interface{}) (time.Time, bool) {
+	var epoch float64
+
+	switch t := value.(type) {
+	case string:
+		number, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		epoch = number
+
+	case float64:
+		epoch = t
+
+	default:
+		number, ok := ToInt64(value)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		// Integers are converted exactly.
+		switch {
+		case number > 1e17 || number < -1e17:
+			return time.Unix(0, number), true
+		case number > 1e14 || number < -1e14:
+			return time.Unix(0, number*1000), true
+		case number > 1e11 || number < -1e11:
+			return time.Unix(0, number*1000000), true
+		}
+		return time.Unix(number, 0), true
+	}
+
+	abs := math.Abs(epoch)
+	switch {
+	case abs > 1e17:
+		return time.Unix(0, int64(epoch)), true
+	case abs > 1e14:
+		return time.Unix(0, int64(epoch*1e3)), true
+	case abs > 1e11:
+		return time.Unix(0, int64(epoch*1e6)), true
+	}
+
+	sec, frac := math.Modf(epoch)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
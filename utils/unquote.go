@@ -106,6 +106,34 @@ outer:
 	return string(out[:j])
 }
 
+// Quote the string as a " delimited VQL string which Unquote()
+// decodes back to the same bytes. Printable bytes are kept as they
+// are (including utf8 sequences) and others are escaped.
+func Quote(s string) string {
+	out := make([]byte, 0, len(s)+2)
+	out = append(out, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			out = append(out, '\\', c)
+		case c == '\n':
+			out = append(out, '\\', 'n')
+		case c == '\r':
+			out = append(out, '\\', 'r')
+		case c == '\t':
+			out = append(out, '\\', 't')
+		case c < 0x20 || c == 0x7f:
+			out = append(out, '\\', 'x', hex_digits[c>>4], hex_digits[c&0xf])
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(append(out, '"'))
+}
+
+const hex_digits = "0123456789abcdef"
+
 // Unquote a ` delimited string.
 func Unquote_ident(s string) string {
 	if s == "" {
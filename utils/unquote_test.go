@@ -67,3 +67,15 @@ func TestSplitIdent(t *testing.T) {
 	)
 	g.AssertJson(t, "TestSplitIdent", res)
 }
+
+func TestQuote(t *testing.T) {
+	for _, value := range []string{
+		"Hello world", "", "ends with a quote'", "'''", `"\'`,
+		"Multi\r\nLine\t", "\x00\x01\xf0\xf1\x7f", "utf8 ✓",
+	} {
+		quoted := Quote(value)
+		if Unquote(quoted) != value {
+			t.Fatalf("Quote(%q) = %v does not unquote", value, quoted)
+		}
+	}
+}
@@ -81,6 +81,15 @@ func IsArray(a interface{}) bool {
 	return rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array
 }
 
+// Is this a channel we can receive from?
+func IsChannel(a interface{}) bool {
+	rt := reflect.TypeOf(a)
+	if rt == nil {
+		return false
+	}
+	return rt.Kind() == reflect.Chan && rt.ChanDir()&reflect.RecvDir != 0
+}
+
 // Try very hard to convert to a string
 func ToString(x interface{}) (string, bool) {
 	switch t := x.(type) {
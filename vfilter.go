@@ -124,6 +124,14 @@ query:
 
 scope := NewScope().AddProtocolImpl(FooAdder{})
 
+If Applicable() only depends on the types of its args (Associative
+implementations may also depend on the member name) the
+implementation should also have a TypeOnly() method. This allows
+the scope to remember which implementation applied to each pair of
+types instead of asking all of them each time:
+
+  func (self FooAdder) TypeOnly() {}
+
 
 */
 package vfilter
@@ -132,9 +140,13 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"regexp"
+	"runtime/debug"
+	"runtime/pprof"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Velocidex/ordereddict"
 	"github.com/alecthomas/participle"
@@ -142,7 +154,6 @@ import (
 	errors "github.com/pkg/errors"
 	"www.velocidex.com/golang/vfilter/functions"
 	"www.velocidex.com/golang/vfilter/scope"
-	scope_module "www.velocidex.com/golang/vfilter/scope"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils"
 )
@@ -174,6 +185,7 @@ var (
 			"|(?P<Ident>[a-zA-Z_][a-zA-Z0-9_]*|`[^`]+`)" +
 			`|''(?P<MultilineString>'.*?')''` +
 			`|(?P<String>'([^'\\]*(\\.[^'\\]*)*)'|"([^"\\]*(\\.[^"\\]*)*)")` +
+			`|(?P<Duration>[-+]?(\d+(\.\d+)?(ns|us|ms|[smhd]))+\b)` +
 			`|(?P<Number>[-+]?(0x[0-9a-f]+|\d*\.?\d+([eE][-+]?\d+)?))` +
 			`|(?P<Operators><>|!=|<=|>=|=>|=~|[-:+*/%,.()=<>{}\[\]])`,
 	))
@@ -181,7 +193,7 @@ var (
 	vqlParser = participle.MustBuild(
 		&VQL{},
 		participle.Lexer(vqlLexer),
-		participle.Upper("IN", "DESC"),
+		participle.Upper("IN"),
 		participle.Elide("Comment", "MLineComment", "VQLComment"),
 	// Need to solve left recursion detection first, if possible.
 	// participle.UseLookahead(),
@@ -190,14 +202,14 @@ var (
 	multiVQLParser = participle.MustBuild(
 		&MultiVQL{},
 		participle.Lexer(vqlLexer),
-		participle.Upper("IN", "DESC"),
+		participle.Upper("IN"),
 		participle.Elide("Comment", "MLineComment", "VQLComment"),
 	)
 
 	multiVQLParserWithComments = participle.MustBuild(
 		&MultiVQL{},
 		participle.Lexer(vqlLexer),
-		participle.Upper("IN", "DESC"),
+		participle.Upper("IN"),
 	)
 )
 
@@ -238,10 +250,34 @@ func Parse(expression string) (*VQL, error) {
 	case *lexer.Error:
 		return vql, reportError(err, t, expression)
 	default:
+		setSource([]*VQL{vql}, expression)
 		return vql, err
 	}
 }
 
+// Remember the original text of each statement.
+func setSource(statements []*VQL, expression string) {
+	for _, vql := range statements {
+		start := vql.Pos.Offset
+		end := vql.EndPos.Offset
+		if end <= start || end > len(expression) {
+			end = len(expression)
+		}
+		if start >= 0 && start < end {
+			vql.source = strings.TrimSpace(expression[start:end])
+		}
+	}
+}
+
+// Returns the original text of the statement, or a formatted version
+// if the original text is not known.
+func (self *VQL) Source(scope types.Scope) string {
+	if self.source != "" {
+		return self.source
+	}
+	return FormatToString(scope, self)
+}
+
 // Parse a string into multiple VQL statements.
 func MultiParse(expression string) ([]*VQL, error) {
 	vql := &MultiVQL{}
@@ -251,7 +287,9 @@ func MultiParse(expression string) ([]*VQL, error) {
 		return nil, reportError(err, t, expression)
 
 	default:
-		return vql.GetStatements(), err
+		statements := vql.GetStatements()
+		setSource(statements, expression)
+		return statements, err
 	}
 }
 
@@ -264,7 +302,9 @@ func MultiParseWithComments(expression string) ([]*VQL, error) {
 		return nil, reportError(err, t, expression)
 
 	default:
-		return vql.GetStatements(), err
+		statements := vql.GetStatements()
+		setSource(statements, expression)
+		return statements, err
 	}
 }
 
@@ -304,9 +344,17 @@ type VQL struct {
 	Parameters  *_ParameterList `{ "(" @@ ")" }`
 	LetOperator string          ` ( @"=" | @"<=" ) `
 	StoredQuery *_Select        ` ( @@ |  `
-	Expression  *_AndExpression ` @@ ) |`
+	Expression  *_AndExpression ` @@ `
+	Cache       *int64          ` [ ( "CACHE" | "cache" ) @Number ] ) |`
 	Query       *_Select        ` @@  `
 	Comments    []*_Comment
+
+	// The position of the statement in the parsed text.
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	// The original text of the statement if known.
+	source string
 }
 
 type _ParameterList struct {
@@ -341,21 +389,35 @@ func (self *VQL) Type() string {
 // rows.
 func (self *VQL) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 	output_chan := make(chan Row)
+	ctx = withShadowingWarnings(withQueryLabel(ctx))
+	ctx, report_panics := withQueryPanics(ctx)
+
+	// The scope is attached to the context once per query. Queries
+	// evaluated while running another query (e.g. by a plugin) keep
+	// the outer query's scope.
+	_, has_scope := types.GetScope(ctx)
 
 	// If this is a Let expression we need to create a stored
 	// query and assign to the scope.
 	if len(self.Let) > 0 {
-		if self.Parameters != nil && self.LetOperator == "<=" {
-			scope.Log("WARN:Expression %v takes parameters but is "+
-				"materialized! Did you mean to use '='? ", self.Let)
+		defer report_panics(scope)
+
+		if !has_scope {
+			ctx = types.WithScope(ctx, scope)
 		}
 
+		// Materializing a LET is subject to the query limits.
+		ctx, cancel := withQueryLimits(ctx, scope)
+		defer cancel()
+
 		_, pres := scope.GetFunction(self.Let)
 		if pres {
 			scope.Log("WARN:LET expression is masking a built in function %v", self.Let)
 		}
 
 		name := utils.Unquote_ident(self.Let)
+		warnShadowedLet(ctx, scope, name)
+		self.addDefinition(ctx, scope, name)
 
 		// Let assigning an expression.
 		if self.Expression != nil {
@@ -368,6 +430,20 @@ func (self *VQL) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 				expr.parameters = self.getParameters()
 			}
 
+			if self.Cache != nil && *self.Cache > 0 {
+				expr.cache = newExpressionCache(*self.Cache)
+			}
+
+			// LET f(x) <= ... memoizes the function.
+			if self.isMemoized() {
+				if expr.cache == nil {
+					expr.cache = newExpressionCache(defaultMemoizeSize)
+				}
+				scope.AppendVars(ordereddict.NewDict().Set(name, expr))
+				close(output_chan)
+				return output_chan
+			}
+
 			switch self.LetOperator {
 			// Store the expression in the scope for later.
 			case "=":
@@ -393,15 +469,21 @@ func (self *VQL) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 		}
 
 		// LET is for stored query: LET X = SELECT ...
-		switch self.LetOperator {
-		case "=":
+		switch {
+		case self.LetOperator == "=" || self.isMemoized():
 			stored_query := NewStoredQuery(self.StoredQuery, name)
 			if self.Parameters != nil {
 				stored_query.parameters = self.getParameters()
 			}
 
+			// LET f(x) <= SELECT ... materializes the query
+			// once for each set of args.
+			if self.isMemoized() {
+				stored_query.cache = newExpressionCache(defaultMemoizeSize)
+			}
+
 			scope.AppendVars(ordereddict.NewDict().Set(name, stored_query))
-		case "<=":
+		case self.LetOperator == "<=":
 			// Delegate to the scope's materializer to actually
 			// materialize this query.
 			scope.AppendVars(ordereddict.NewDict().Set(
@@ -412,24 +494,50 @@ func (self *VQL) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 		return output_chan
 
 	} else {
-		subscope := scope.Copy()
+		// With snapshot isolation the query sees the variables
+		// as they were when it started, even if they are
+		// changed (e.g. by another session) while it runs.
+		var subscope types.Scope
+		query_scope := scope
+		if scope.SnapshotIsolation() {
+			subscope = scope.Snapshot()
+			query_scope = subscope
+		} else {
+			subscope = scope.Copy()
+		}
+
+		if !has_scope {
+			ctx = types.WithScope(ctx, query_scope)
+		}
 		subscope.AppendVars(
 			ordereddict.NewDict().Set("$Query", FormatToString(scope, self)))
+		subscope.PushCallFrame(types.CallFrameDescription(self.Source(scope)))
+
+		ctx, cancel := withQueryLimits(ctx, scope)
 
 		go func() {
 			defer close(output_chan)
 			defer subscope.Close()
+			defer cancel()
+			defer report_panics(scope)
 
 			row_chan := self.Query.Eval(ctx, subscope)
 			for {
 				select {
 				case <-ctx.Done():
+					checkQueryLimits(ctx, scope)
 					return
 
 				case row, ok := <-row_chan:
 					if !ok {
 						return
 					}
+
+					row = subscope.TransformRow(row)
+					if utils.IsNil(row) {
+						continue
+					}
+					row = subscope.InternRow(row)
 					output_chan <- row
 				}
 			}
@@ -439,6 +547,12 @@ func (self *VQL) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 	}
 }
 
+// A materialized LET with parameters is a memoized function: its
+// result is computed once for each distinct set of args.
+func (self *VQL) isMemoized() bool {
+	return self.Parameters != nil && self.LetOperator == "<="
+}
+
 // Walk the parameters list and collect all the parameter names.
 func visitor(parameters *_ParameterList, result *[]string) {
 	*result = append(*result, parameters.Left)
@@ -447,6 +561,22 @@ func visitor(parameters *_ParameterList, result *[]string) {
 	}
 }
 
+// Record where the LET symbol was defined so callers can find it
+// later.
+func (self *VQL) addDefinition(
+	ctx context.Context, scope types.Scope, name string) {
+	query_id, _ := pprof.Label(ctx, queryLabel)
+	scope.AddDefinition(&types.Definition{
+		Name:       name,
+		Operator:   self.LetOperator,
+		Parameters: self.getParameters(),
+		Source:     self.Source(scope),
+		Line:       self.Pos.Line,
+		Column:     self.Pos.Column,
+		QueryId:    query_id,
+	})
+}
+
 func (self *VQL) getParameters() []string {
 	result := []string{}
 
@@ -523,7 +653,7 @@ func (self *_Select) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 
 		// Sort the output groups
 		sorter_input_chan := make(chan Row)
-		sorted_chan := scope.(*scope_module.Scope).Sort(
+		sorted_chan := scope.Sort(
 			ctx, scope, sorter_input_chan,
 			utils.Unquote_ident(*self.OrderBy), desc)
 
@@ -537,37 +667,50 @@ func (self *_Select) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 			self_copy.OrderBy = nil
 
 			for row := range self_copy.Eval(ctx, scope) {
-				sorter_input_chan <- row
+				select {
+				case <-ctx.Done():
+					return
+				case sorter_input_chan <- row:
+				}
 			}
 		}()
 
 		return sorted_chan
 	}
 
+	symbol, ok := self.countOnly(scope)
+	if ok {
+		go func() {
+			defer close(output_chan)
+			self.evalCountOnly(ctx, scope, symbol, output_chan)
+		}()
+
+		return output_chan
+	}
+
 	// Gets a row from the FROM clause, then transforms it
 	// according to the SelectExpression. After transformation,
 	// apply the WHERE clause to the row to determine if it should
 	// be relayed. NOTE: We need to transform the row first in
 	// order to assign aliases.
 	go func() {
-		from_chan := self.From.Eval(ctx, scope)
-
 		defer close(output_chan)
+
+		rows := self.From.Rows(ctx, scope)
+		first_row := true
+
 		for {
-			select {
-			// Are we cancelled?
-			case <-ctx.Done():
+			row, ok := rows.Next()
+			if !ok {
 				return
+			}
 
-				// Get a row
-			case row, ok := <-from_chan:
-				if !ok {
-					return
-				}
-				scope.Explainer().PluginOutput(
-					&self.From.Plugin, row)
-				self.processSingleRow(ctx, scope, row, output_chan)
+			scope.Explainer().PluginOutput(&self.From.Plugin, row)
+			if first_row {
+				self.warnShadowing(ctx, scope, row)
+				first_row = false
 			}
+			self.processSingleRow(ctx, scope, row, output_chan)
 		}
 	}()
 
@@ -576,6 +719,10 @@ func (self *_Select) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 
 func (self *_Select) processSingleRow(
 	ctx context.Context, scope types.Scope, row Row, output_chan chan Row) {
+	// Member chains resolved while processing this row are cached
+	// until the row is emitted.
+	ctx = withMemberCache(ctx)
+
 	subscope := scope.Copy()
 	defer subscope.Close()
 
@@ -583,6 +730,8 @@ func (self *_Select) processSingleRow(
 		ctx, subscope, row)
 	defer closer()
 
+	self.SelectExpression.evalEagerColumns(subscope, transformed_row)
+
 	if self.Where == nil {
 		materialized_row := MaterializedLazyRow(
 			ctx, transformed_row, subscope)
@@ -602,11 +751,16 @@ func (self *_Select) processSingleRow(
 		// Filters can access both the untransformed row and
 		// the transformed row. This allows WHERE clause to
 		// refer to both the raw plugin output as well as
-		// aliases of transformations on the row.
+		// aliases of transformations on the row. Unless
+		// disabled by scope.SetWhereAliases(false).
 		new_scope.AppendVars(row)
-		new_scope.AppendVars(transformed_row)
+		if scope.WhereAliases() {
+			new_scope.AppendVars(transformed_row)
+		}
 
+		done := profileNode(scope, self.Where)
 		expression := self.Where.Reduce(ctx, new_scope)
+		done()
 
 		// If the filtered expression returns a bool true,
 		// then pass the row to the output.
@@ -628,6 +782,9 @@ func (self *_Select) processSingleRow(
 
 type _From struct {
 	Plugin Plugin ` @@ `
+
+	// Named subqueries may be cancelled with scope.CancelSubquery()
+	Name *string `[ AS @Ident ]`
 }
 
 type Plugin struct {
@@ -642,7 +799,7 @@ type Plugin struct {
 
 type _Args struct {
 	Comments        []*_Comment       `[ @@ ] `
-	Left            string            `@Ident "=" `
+	Left            string            `( @Ident | @DESC ) "=" `
 	SubSelect       *_Select          `( "{" @@ "}" | `
 	ArrayOpenBrace  string            ` @"[" `
 	Array           *_CommaExpression ` @@? `
@@ -665,6 +822,7 @@ type _AliasedExpression struct {
 
 	mu                 sync.Mutex
 	cache, column_name *string
+	is_eager           *bool
 }
 
 // Cache the column name since each row needs it
@@ -692,6 +850,26 @@ func (self *_AliasedExpression) GetName(scope types.Scope) string {
 	return *column_name
 }
 
+// Columns wrapped in eager() are evaluated for each row even when the
+// row is rejected by the WHERE clause.
+func (self *_AliasedExpression) IsEager() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.is_eager != nil {
+		return *self.is_eager
+	}
+
+	is_eager := false
+	if self.Expression != nil {
+		symbol := self.Expression.getFunctionCall()
+		is_eager = symbol != nil && symbol.Symbol == "eager"
+	}
+	self.is_eager = &is_eager
+
+	return is_eager
+}
+
 func (self *_AliasedExpression) IsAggregate(scope types.Scope) bool {
 	if self.SubSelect != nil {
 		return true
@@ -705,6 +883,8 @@ func (self *_AliasedExpression) IsAggregate(scope types.Scope) bool {
 }
 
 func (self *_AliasedExpression) Reduce(ctx context.Context, scope types.Scope) Any {
+	defer profileNode(scope, self)()
+
 	if self.Expression != nil {
 		return self.Expression.Reduce(ctx, scope)
 	}
@@ -812,6 +992,10 @@ type _AndExpression struct {
 	Comments []*_Comment    ` [ @@ ] `
 	Left     *_OrExpression `( @@ `
 	Right    []*_OpAndTerm  `{ @@ })`
+
+	// The terms with their costs, used by getPlan().
+	mu    sync.Mutex
+	terms []*termInfo
 }
 
 type _OpAndTerm struct {
@@ -840,8 +1024,19 @@ type _ConditionOperand struct {
 }
 
 type _OpComparison struct {
-	Operator string               `@( "<>" | "<=" | ">=" | "=" | "<" | ">" | "!=" | IN | "=~")`
+	Operator string               `@( "<>" | "<=" | ">=" | "=" | "<" | ">" | "!=" | IN | "=~" | "GLOB" | "glob")`
 	Right    *_AdditionExpression `@@`
+
+	// Options for =~ and GLOB, e.g. WITH (case, multiline)
+	Flags []string ` [ ( "WITH" | "with" ) "(" @Ident { "," @Ident } ")" ] `
+
+	// Set by Compile() for =~ and GLOB with a constant pattern.
+	regex *regexp.Regexp
+
+	// Set by Compile() when Right is a constant. Compares the left
+	// operand to it, returning false when the left operand is not
+	// of the constant's type.
+	bound func(lhs Any) (result Any, ok bool)
 }
 
 type _Term struct {
@@ -861,6 +1056,9 @@ type _SymbolRef struct {
 	mu           sync.Mutex
 	function     FunctionInterface
 	split_symbol []string
+
+	// The function resolved by Compile().
+	compiled FunctionInterface
 }
 
 type _Value struct {
@@ -876,6 +1074,10 @@ type _Value struct {
 	Float     *float64
 	Int       *int64
 
+	// Duration literals like 5m or 2h30m.
+	StrDuration *string ` | @Duration`
+	Duration    *time.Duration
+
 	Boolean *string ` | @BOOL `
 	Null    bool    ` | @NULL)`
 
@@ -904,6 +1106,17 @@ func (self *_SelectExpression) mergeStarRow(
 	}
 }
 
+// Evaluate all the eager columns in the transformed row. Other columns
+// remain lazy and are only evaluated when accessed.
+func (self *_SelectExpression) evalEagerColumns(
+	scope types.Scope, transformed_row types.LazyRow) {
+	for _, expr := range self.Expressions {
+		if expr.IsEager() {
+			transformed_row.Get(expr.GetName(scope))
+		}
+	}
+}
+
 // Receives a row from the FROM clause (i.e. the plugin) and
 // transforms it according to the select expression to produce a new
 // row. The transformation results in a lazy row - The column
@@ -962,7 +1175,9 @@ func (self *_SelectExpression) Transform(
 			// Use the new scope rather than the callers scope since
 			// the lazy row may be accessed in any scope but needs to
 			// resolve members in the scope it was created from.
-			func(ctx context.Context, scope types.Scope) Any {
+			func(ctx context.Context, scope types.Scope) (result Any) {
+				defer recoverColumn(ctx, new_scope, name, &result)
+
 				item := expr.Reduce(ctx, new_scope)
 				switch t := item.(type) {
 
@@ -982,18 +1197,59 @@ func (self *_SelectExpression) Transform(
 	return new_row, new_scope.Close
 }
 
+// A panic while evaluating a column only loses that column: the
+// cell is set to NULL and the rest of the row is still emitted.
+func recoverColumn(ctx context.Context,
+	scope types.Scope, name string, result *Any) {
+	r := recover()
+	if r != nil {
+		message := fmt.Sprintf("WARN:Column %v set to NULL after PANIC", name)
+		if firstPanic(ctx, message) {
+			scope.Warn("Column %v set to NULL after PANIC: %v\n%s",
+				name, r, debug.Stack())
+		}
+		scope.ReportError(fmt.Errorf("%w in column %v: %v",
+			types.ErrPanic, name, r))
+		*result = types.Null{}
+	}
+}
+
 // The From expression runs the Plugin and then filters each row
 // according to the Where clause.
 func (self *_From) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 	output_chan := make(chan Row)
 
+	ctx, ok := enterSubquery(ctx, scope)
+	if !ok {
+		close(output_chan)
+		return output_chan
+	}
+
+	// Cancelling a named subquery only stops this plugin - the
+	// enclosing query sees it as finished.
+	done := func() {}
+	if self.Name != nil {
+		sub_ctx, cancel := context.WithCancel(ctx)
+		unregister := scope.RegisterSubquery(*self.Name, cancel)
+		done = func() {
+			unregister()
+			cancel()
+		}
+		ctx = sub_ctx
+	}
+
 	input_chan := self.Plugin.Eval(ctx, scope)
 	go func() {
 		defer close(output_chan)
+		defer done()
 		for row := range input_chan {
 			scope.GetStats().IncRowsScanned()
 			scope.ChargeOp()
 
+			if !chargeQueryRow(ctx, scope) {
+				return
+			}
+
 			select {
 			case <-ctx.Done():
 				return
@@ -1020,6 +1276,14 @@ func (self *Plugin) resolveSymbol(
 		}
 	}
 
+	// Dotted names may be registered (or aliased) plugins.
+	if len(components) > 1 && self.Call {
+		_plugin, pres := scope.GetPlugin(strings.Join(components, "."))
+		if pres {
+			return _plugin, pres
+		}
+	}
+
 	// Plugins with "." resolve themselves recursively.
 	var result Any = scope
 	for idx, component := range components {
@@ -1048,6 +1312,35 @@ func (self *Plugin) resolveSymbol(
 	return result, true
 }
 
+// Give the scope's UnknownPluginHandler a chance to register a
+// missing plugin before we resolve it. Returns the handler's
+// suggestions if the plugin is still missing.
+func (self *Plugin) loadUnknownPlugin(
+	scope types.Scope, components []string) []string {
+	if len(components) != 1 || !self.Call {
+		return nil
+	}
+
+	handler := scope.UnknownPluginHandler()
+	if handler == nil {
+		return nil
+	}
+
+	name := components[0]
+	_, pres := scope.GetPlugin(name)
+	if pres {
+		return nil
+	}
+
+	_, pres = scope.Resolve(name)
+	if pres {
+		return nil
+	}
+
+	_, suggestions := handler.UnknownPlugin(scope, name)
+	return suggestions
+}
+
 func (self *Plugin) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 
 	self.mu.Lock()
@@ -1058,10 +1351,15 @@ func (self *Plugin) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 	}
 	self.mu.Unlock()
 
+	suggestions := self.loadUnknownPlugin(scope, components)
+
 	symbol, pres := self.resolveSymbol(ctx, scope, components)
 	// Symbol not found! alert the caller.
 	if !pres {
-		options := scope.GetSimilarPlugins(self.Name)
+		options := suggestions
+		if len(options) == 0 {
+			options = scope.GetSimilarPlugins(self.Name)
+		}
 		message := fmt.Sprintf("Plugin %v not found. ", self.Name)
 		if len(options) > 0 {
 			message += fmt.Sprintf(
@@ -1078,6 +1376,13 @@ func (self *Plugin) Eval(ctx context.Context, scope types.Scope) <-chan Row {
 		}
 
 		scope.Log("ERROR:%v", message)
+		scope.ReportError(fmt.Errorf("%w: %v",
+			types.ErrPluginNotFound, self.Name))
+		if scope.StrictMode() {
+			types.AbortQuery(ctx, fmt.Errorf("%w: %v",
+				types.ErrPluginNotFound, self.Name))
+		}
+
 		output_chan := make(chan Row)
 		close(output_chan)
 		return output_chan
@@ -1117,9 +1422,18 @@ func (self *Plugin) evalSymbol(
 
 			// A plugin like item
 		case PluginGeneratorInterface:
+			if !checkPluginAccess(ctx, scope, name, t) {
+				close(output_chan)
+				return output_chan
+			}
+
 			scope.GetStats().IncPluginsCalled()
 
-			return t.Call(ctx, scope, args)
+			var result <-chan Row
+			withPluginLabel(ctx, name, func(ctx context.Context) {
+				result = callPluginCached(ctx, scope, name, t, args)
+			})
+			return result
 
 		default:
 			scope.Log("ERROR:Symbol %v is not callable", name)
@@ -1137,6 +1451,14 @@ func (self *Plugin) evalSymbol(
 		case StoredQuery:
 			return t.Eval(ctx, scope)
 
+			// Streams of NDJSON rows.
+		case *types.NDJSONSource:
+			return scope.Iterate(ctx, t)
+		}
+
+		// Go channels feeding rows into the query.
+		if utils.IsChannel(symbol) {
+			return scope.Iterate(ctx, symbol)
 		}
 	}
 
@@ -1254,6 +1576,40 @@ func (self *_CommaExpression) Reduce(ctx context.Context, scope types.Scope) Any
 	return result
 }
 
+// If the expression consists only of a single function call, return
+// the symbol of the call.
+func (self *_AndExpression) getFunctionCall() *_SymbolRef {
+	if len(self.Right) > 0 || self.Left == nil ||
+		len(self.Left.Right) > 0 {
+		return nil
+	}
+
+	condition := self.Left.Left
+	if condition == nil || condition.Not != nil ||
+		condition.Right != nil || condition.Left == nil ||
+		len(condition.Left.Right) > 0 {
+		return nil
+	}
+
+	multiplication := condition.Left.Left
+	if multiplication == nil || len(multiplication.Right) > 0 {
+		return nil
+	}
+
+	member := multiplication.Left
+	if member == nil || len(member.Right) > 0 ||
+		member.Left == nil || member.Left.Negated {
+		return nil
+	}
+
+	symbol := member.Left.SymbolRef
+	if symbol == nil || !symbol.Called {
+		return nil
+	}
+
+	return symbol
+}
+
 func (self *_AndExpression) IsAggregate(scope types.Scope) bool {
 	if self.Left.IsAggregate(scope) {
 		return true
@@ -1269,17 +1625,12 @@ func (self *_AndExpression) IsAggregate(scope types.Scope) bool {
 }
 
 func (self *_AndExpression) Reduce(ctx context.Context, scope types.Scope) Any {
-	result := self.Left.Reduce(ctx, scope)
 	if self.Right == nil {
-		return result
-	}
-
-	if scope.Bool(result) == false {
-		return false
+		return self.Left.Reduce(ctx, scope)
 	}
 
-	for _, term := range self.Right {
-		if scope.Bool(term.Term.Reduce(ctx, scope)) == false {
+	for _, term := range self.getPlan(scope) {
+		if scope.Bool(term.Reduce(ctx, scope)) == false {
 			return false
 		}
 	}
@@ -1344,9 +1695,11 @@ func (self *_AdditionExpression) Reduce(ctx context.Context, scope types.Scope)
 		term_value := term.Term.Reduce(ctx, scope)
 		switch term.Operator {
 		case "+":
-			result = scope.Add(result, term_value)
+			result = checkArithmetic(ctx, scope, term.Operator,
+				result, term_value, scope.Add(result, term_value))
 		case "-":
-			result = scope.Sub(result, term_value)
+			result = checkArithmetic(ctx, scope, term.Operator,
+				result, term_value, scope.Sub(result, term_value))
 		}
 	}
 
@@ -1372,6 +1725,12 @@ func (self _ConditionOperand) IsAggregate(scope types.Scope) bool {
 }
 
 func (self *_ConditionOperand) Reduce(ctx context.Context, scope types.Scope) Any {
+	// Only operators are timed - bare operands are timed by their
+	// parents.
+	if self.Right != nil {
+		defer profileNode(scope, self)()
+	}
+
 	if self.Not != nil {
 		value := self.Not.Reduce(ctx, scope)
 		return !scope.Bool(value)
@@ -1382,6 +1741,15 @@ func (self *_ConditionOperand) Reduce(ctx context.Context, scope types.Scope) An
 		return lhs
 	}
 
+	if self.Right.bound != nil {
+		result, ok := self.Right.bound(lhs)
+		if ok {
+			scope.Trace("Operation %v %v (bound) gave %v",
+				lhs, self.Right.Operator, result)
+			return result
+		}
+	}
+
 	rhs := self.Right.Right.Reduce(ctx, scope)
 
 	var result Any = false
@@ -1401,8 +1769,8 @@ func (self *_ConditionOperand) Reduce(ctx context.Context, scope types.Scope) An
 		result = scope.Gt(lhs, rhs)
 	case ">=":
 		result = scope.Gt(lhs, rhs) || scope.Eq(lhs, rhs)
-	case "=~":
-		result = scope.Match(rhs, lhs)
+	case "=~", "GLOB", "glob":
+		result = self.Right.match(scope, lhs, rhs)
 	}
 
 	scope.Trace("Operation %v %v %v gave %v", lhs, self.Right.Operator, rhs, result)
@@ -1429,9 +1797,11 @@ func (self *_MultiplicationExpression) Reduce(ctx context.Context, scope types.S
 		term_value := term.Factor.Reduce(ctx, scope)
 		switch term.Operator {
 		case "*":
-			result = scope.Mul(result, term_value)
+			result = checkArithmetic(ctx, scope, term.Operator,
+				result, term_value, scope.Mul(result, term_value))
 		case "/":
-			result = scope.Div(result, term_value)
+			result = checkArithmetic(ctx, scope, term.Operator,
+				result, term_value, scope.Div(result, term_value))
 		}
 	}
 
@@ -1451,7 +1821,20 @@ func (self _Value) IsAggregate(scope types.Scope) bool {
 }
 
 func (self *_Value) maybeParseStrNumber(scope types.Scope) {
-	if self.Int != nil || self.Float != nil {
+	if self.Int != nil || self.Float != nil || self.Duration != nil {
+		return
+	}
+
+	if self.StrDuration != nil {
+		duration, err := utils.ParseDuration(*self.StrDuration)
+		if err != nil {
+			scope.Log("ERROR:%v", err)
+			return
+		}
+		if self.Negated {
+			duration = -duration
+		}
+		self.Duration = &duration
 		return
 	}
 
@@ -1505,6 +1888,12 @@ func (self *_Value) Reduce(ctx context.Context, scope types.Scope) Any {
 		return res
 	}
 
+	if self.Duration != nil {
+		res := *self.Duration
+		self.mu.Unlock()
+		return res
+	}
+
 	// The following are static constants and can be cached.
 	if self.cache != nil {
 		res := self.cache
@@ -1546,7 +1935,24 @@ func (self *_SymbolRef) IsAggregate(scope types.Scope) bool {
 	return value.Info(scope, types.NewTypeMap()).IsAggregate
 }
 
-func (self *_SymbolRef) getFunction(scope types.Scope) (types.Any, bool) {
+// Ask the scope's UnknownPluginHandler about a function which is not
+// registered. LET functions are variables so they are not unknown.
+func loadUnknownFunction(scope types.Scope, name string) (bool, []string) {
+	handler := scope.UnknownPluginHandler()
+	if handler == nil {
+		return false, nil
+	}
+
+	_, pres := scope.Resolve(name)
+	if pres {
+		return false, nil
+	}
+
+	return handler.UnknownFunction(scope, name)
+}
+
+func (self *_SymbolRef) getFunction(
+	ctx context.Context, scope types.Scope) (types.Any, bool) {
 
 	self.mu.Lock()
 	components := self.split_symbol
@@ -1554,18 +1960,37 @@ func (self *_SymbolRef) getFunction(scope types.Scope) (types.Any, bool) {
 		components = utils.SplitIdent(self.Symbol)
 		self.split_symbol = components
 	}
+	compiled := self.compiled
 	self.mu.Unlock()
 
+	if compiled != nil {
+		return compiled, true
+	}
+
 	// Single item reference and called - call built in function.
+	var suggestions []string
 	if len(components) == 1 && self.Called {
 		res, pres := scope.GetFunction(self.Symbol)
 		if pres {
 			return res, pres
 		}
+
+		// The function may be loaded on demand.
+		var loaded bool
+		loaded, suggestions = loadUnknownFunction(scope, self.Symbol)
+		if loaded {
+			res, pres := scope.GetFunction(self.Symbol)
+			if pres {
+				return res, pres
+			}
+		}
 	}
 
 	// Plugins with "." resolve themselves recursively.
 	var result Any = scope
+	var root Any
+	var cache *memberCache
+
 	for idx, component := range components {
 		subcomponent, pres := scope.Associative(result, component)
 		if !pres {
@@ -1577,16 +2002,43 @@ func (self *_SymbolRef) getFunction(scope types.Scope) (types.Any, bool) {
 				if len(components) > 1 {
 					scope.Log("ERROR:While resolving %v Symbol %v not found. Current Scope is %s",
 						self.Symbol, components[0], scope.PrintVars())
+				} else if len(suggestions) > 0 {
+					scope.Log("ERROR:Symbol %v not found. Did you mean %v?",
+						self.Symbol, strings.Join(suggestions, " "))
 				} else {
 					scope.Log("ERROR:Symbol %v not found. Current Scope is %s",
 						self.Symbol, scope.PrintVars())
 				}
+
+				err := fmt.Errorf("%w: %v",
+					types.ErrUndefinedSymbol, components[0])
+				scope.ReportError(err)
+				if scope.StrictMode() {
+					types.AbortQuery(ctx, err)
+				}
 			}
 
 			return nil, false
 		}
 
 		result = subcomponent
+
+		// The rest of the chain may have already been resolved
+		// while processing this row.
+		if idx == 0 && len(components) > 1 && isCacheableRoot(result) {
+			cache = getMemberCache(ctx)
+			if cache != nil {
+				root = result
+				cached, pres := cache.Get(root, self.Symbol)
+				if pres {
+					return cached, true
+				}
+			}
+		}
+	}
+
+	if cache != nil {
+		cache.Set(root, self.Symbol, result)
 	}
 
 	return result, true
@@ -1597,7 +2049,7 @@ func (self *_SymbolRef) Reduce(ctx context.Context, scope types.Scope) Any {
 	// The symbol is just a constant in the scope. It may be a
 	// stored expression, a function or a stored query or just a
 	// plain value.
-	value, pres := self.getFunction(scope)
+	value, pres := self.getFunction(ctx, scope)
 	if value != nil && pres {
 		switch t := value.(type) {
 		case FunctionInterface:
@@ -1616,15 +2068,15 @@ func (self *_SymbolRef) Reduce(ctx context.Context, scope types.Scope) Any {
 			subscope := scope.Copy()
 			defer subscope.Close()
 
-			if subscope.CheckForOverflow() {
+			if subscope.CheckForOverflow() ||
+				!enterLetFunction(ctx, subscope, t.name) {
 				return &Null{}
 			}
 
-			subscope.AppendVars(self.buildArgsFromParameters(
-				ctx, scope))
+			vars := self.buildArgsFromParameters(ctx, scope)
 
 			scope.GetStats().IncFunctionsCalled()
-			return t.Reduce(ctx, subscope)
+			return t.reduceWithArgs(ctx, subscope, vars)
 
 		case StoredQuery:
 			// If the call site specifies parameters then
@@ -1641,11 +2093,19 @@ func (self *_SymbolRef) Reduce(ctx context.Context, scope types.Scope) Any {
 				subscope.ClearContext()
 				defer subscope.Close()
 
-				if subscope.CheckForOverflow() {
+				if subscope.CheckForOverflow() ||
+					!enterLetFunction(ctx, subscope, self.Symbol) {
 					return &Null{}
 				}
 
 				vars := self.buildArgsFromParameters(ctx, scope)
+
+				// Memoized queries are looked up by the
+				// values of their args.
+				stored_query, ok := t.(*_StoredQuery)
+				if ok && stored_query.cache != nil {
+					vars = materializeArgs(ctx, subscope, vars)
+				}
 				subscope.AppendVars(vars)
 
 				scope.GetStats().IncFunctionsCalled()
@@ -1654,6 +2114,7 @@ func (self *_SymbolRef) Reduce(ctx context.Context, scope types.Scope) Any {
 				return &StoredQueryCallSite{
 					query: t,
 					scope: subscope,
+					vars:  vars,
 				}
 			}
 		}
@@ -1723,13 +2184,37 @@ func buildArgsFromParameters(
 // Call into a built in VQL function.
 func (self *_SymbolRef) callFunction(
 	ctx context.Context, scope types.Scope,
-	func_obj FunctionInterface) Any {
+	func_obj FunctionInterface) (result Any) {
+
+	// A panicking function should not bring down the whole
+	// program. Log the expression which caused it instead.
+	defer func() {
+		r := recover()
+		if r != nil {
+			description := types.CallFrameDescription(
+				FormatToString(scope, self))
+			message := fmt.Sprintf("ERROR:PANIC in %v", description)
+			if firstPanic(ctx, message) {
+				scope.Log("ERROR:PANIC in %v: %v\n%s",
+					description, r, debug.Stack())
+			}
+			scope.ReportError(fmt.Errorf("%w in %v: %v",
+				types.ErrPanic, description, r))
+			result = &Null{}
+		}
+	}()
 
 	self.mu.Lock()
 	parameters := self.Parameters
 	function := self.function
 	self.mu.Unlock()
 
+	defer profileNode(scope, self)()
+
+	if !checkFunctionAccess(ctx, scope, self.Symbol, func_obj) {
+		return &Null{}
+	}
+
 	// Build up the args to pass to the function.
 	args := ordereddict.NewDict()
 	for _, arg := range parameters {
@@ -1754,7 +2239,7 @@ func (self *_SymbolRef) callFunction(
 	// same function copy to ensure it may store internal state.
 	if function != nil {
 		scope.GetStats().IncFunctionsCalled()
-		result := function.Call(ctx, scope, args)
+		result = function.Call(ctx, scope, args)
 		if result == nil {
 			return &Null{}
 		}
@@ -1773,7 +2258,7 @@ func (self *_SymbolRef) callFunction(
 	// Call the function now.
 	scope.GetStats().IncFunctionsCalled()
 
-	result := func_obj.Call(ctx, scope, args)
+	result = func_obj.Call(ctx, scope, args)
 
 	// Do not allow nil in VQL since it is not compatible with
 	// reflect package. The VQL plugin might accidentally pass nil
@@ -1794,19 +2279,5 @@ func GetIntScope(scope_int types.Scope) *scope.Scope {
 }
 
 func CopyFunction(in types.Any) types.FunctionInterface {
-	copier, ok := in.(types.FunctionCopier)
-	if ok {
-		return copier.Copy()
-	}
-
-	in_value := reflect.Indirect(reflect.ValueOf(in))
-	result := reflect.New(in_value.Type()).Interface()
-
-	// Handle aggregate functions specifically.
-	aggregate_func, ok := result.(functions.AggregatorInterface)
-	if ok {
-		aggregate_func.SetNewAggregator()
-	}
-
-	return result.(types.FunctionInterface)
+	return functions.CopyFunction(in)
 }
@@ -5,15 +5,15 @@ import (
 	"io"
 
 	"github.com/Velocidex/ordereddict"
-	scope_module "www.velocidex.com/golang/vfilter/scope"
 	"www.velocidex.com/golang/vfilter/types"
 	"www.velocidex.com/golang/vfilter/utils"
 )
 
 type GroupbyActor struct {
 	delegate   *_Select
-	row_source <-chan types.Row
+	row_source *rowReader
 	scope      types.Scope
+	warned     bool
 }
 
 func (self *GroupbyActor) Transform(ctx context.Context,
@@ -25,7 +25,17 @@ func (self *GroupbyActor) Transform(ctx context.Context,
 func (self *GroupbyActor) GetNextRow(ctx context.Context, scope types.Scope) (
 	types.LazyRow, types.Row, string, types.Scope, error) {
 
-	for row := range self.row_source {
+	for {
+		row, ok := self.row_source.Next()
+		if !ok {
+			break
+		}
+
+		if !self.warned {
+			self.delegate.warnShadowing(ctx, self.scope, row)
+			self.warned = true
+		}
+
 		// Create a new scope over which we can evaluate the filter
 		// clause.
 		new_scope := self.scope.Copy()
@@ -35,13 +45,27 @@ func (self *GroupbyActor) GetNextRow(ctx context.Context, scope types.Scope) (
 			ctx, new_scope, row)
 		defer closer()
 
+		self.delegate.SelectExpression.evalEagerColumns(
+			new_scope, transformed_row)
+
 		// Order matters - transformed row (from column specifiers)
 		// may mask original row (from plugin).
 		new_scope.AppendVars(row)
 		new_scope.AppendVars(transformed_row)
 
 		if self.delegate.Where != nil {
-			expression := self.delegate.Where.Reduce(ctx, new_scope)
+			// The WHERE clause may be restricted to the source
+			// row.
+			where_scope := new_scope
+			if !scope.WhereAliases() {
+				where_scope = self.scope.Copy()
+				defer where_scope.Close()
+				where_scope.AppendVars(row)
+			}
+
+			done := profileNode(scope, self.delegate.Where)
+			expression := self.delegate.Where.Reduce(ctx, where_scope)
+			done()
 
 			// If the filtered expression returns a bool false, then
 			// skip the row.
@@ -70,10 +94,14 @@ func (self *GroupbyActor) MaterializeRow(ctx context.Context,
 
 func (self *_Select) EvalGroupBy(ctx context.Context, scope types.Scope) <-chan Row {
 	// Build an actor to send to the grouper.
-	actor := &GroupbyActor{self, self.From.Eval(ctx, scope), scope}
+	actor := &GroupbyActor{
+		delegate:   self,
+		row_source: self.From.Rows(ctx, scope),
+		scope:      scope,
+	}
 
 	// Get a grouper implementation
-	grouper_output_chan := GetIntScope(scope).Group(ctx, scope, actor)
+	grouper_output_chan := scope.Group(ctx, scope, actor)
 
 	// Do we need to sort it as well?
 	if self.OrderBy == nil {
@@ -87,7 +115,7 @@ func (self *_Select) EvalGroupBy(ctx context.Context, scope types.Scope) <-chan
 
 	// Sort the output groups
 	sorter_input_chan := make(chan Row)
-	sorted_chan := scope.(*scope_module.Scope).Sort(
+	sorted_chan := scope.Sort(
 		ctx, scope, sorter_input_chan,
 		utils.Unquote_ident(*self.OrderBy), desc)
 
@@ -29,8 +29,14 @@ type execTest struct {
 	result Any
 }
 
-var compareOptions = cmpopts.IgnoreUnexported(
-	_Value{}, Plugin{}, _SymbolRef{}, _AliasedExpression{})
+var compareOptions = cmp.Options{
+	cmpopts.IgnoreUnexported(
+		_Value{}, Plugin{}, _SymbolRef{}, _AliasedExpression{}, _AndExpression{},
+		_OpComparison{}, VQL{}),
+
+	// Positions change when the query is reformatted.
+	cmpopts.IgnoreFields(VQL{}, "Pos", "EndPos"),
+}
 
 var execTestsSerialization = []execTest{
 	{"1 or sleep(a=100)", true},
@@ -90,8 +96,9 @@ var execTestsSerialization = []execTest{
 	{"10 / 0", Null{}},
 
 	// Arithmetic on incompatible types silently trapped.
-	{"1 + 'foo'", Null{}},
 	{"'foo' - 'bar'", Null{}},
+	{"'foo' + dict(a=1)", Null{}},
+	{"'foo' + NULL", Null{}},
 
 	// Logical operators
 	{"1 and 2 and 3 and 4", true},
@@ -105,6 +112,17 @@ var execTestsSerialization = []execTest{
 	{"'foo' + 'bar' = 'foobar'", true},
 	{"5 * func_foo()", 5},
 
+	// Scalars are stringified when added to strings.
+	{"1 + 'foo'", "1foo"},
+	{"'foo' + 1", "foo1"},
+	{"'foo' + 1.5", "foo1.5"},
+	{"'foo' + TRUE", "footrue"},
+
+	// String repetition
+	{"'ab' * 3", "ababab"},
+	{"3 * 'ab'", "ababab"},
+	{"'ab' * -1", ""},
+
 	// Equality
 	{"const_foo = 1", true},
 	{"const_foo != 2", true},
@@ -267,6 +285,34 @@ var execTestsSerialization = []execTest{
 
 	// For now dicts are not regexable
 	{"dict(x='Hello', y='World') =~ 'he'", false},
+
+	// Regex flags
+	{"'Hello' =~ '^he' WITH (case)", false},
+	{"'Hello' =~ '^He' WITH (case)", true},
+	{"'Hello' =~ '^he' with (nocase)", true},
+	{"'a\nb' =~ '^b$'", false},
+	{"'a\nb' =~ '^b$' WITH (multiline)", true},
+	{"'a\nb' =~ 'a.b' WITH (dotall, case)", true},
+	{"'Hello' =~ 'he' WITH (unknown)", false},
+
+	// Glob operator
+	{"'notepad.exe' GLOB '*.EXE'", true},
+	{"'notepad.exe' glob '*.EXE' WITH (case)", false},
+	{"'notepad.exe' GLOB 'note?ad.*'", true},
+	{"'notepad.exe' GLOB 'notepad'", false},
+	{"'notepad.exe' GLOB '[mn]otepad.[!d]*'", true},
+	{`'C:\\Windows\\notepad.exe' GLOB 'C:\\Windows\\*'`, true},
+	{"'/usr/bin/ls' GLOB '/usr/*/ls'", true},
+	{"('a.txt', 'b.exe') GLOB '*.exe'", true},
+	{"('a.txt', 'b.exe') GLOB '*.dll'", false},
+
+	// Duration literals compare with each other and with seconds.
+	{"5m = 300", true},
+	{"2h30m > 90m", true},
+	{"7d = 604800", true},
+	{"1.5s < 2s", true},
+	{"500ms < 1", true},
+	{"-5m < 0", true},
 }
 
 // These tests are excluded from serialization tests.
@@ -716,6 +762,67 @@ b={
 
 	{"Whitespace in the query",
 		"SELECT * FROM\ntest()"},
+
+	{"Repeated member chains in the same row",
+		"SELECT foo.bar.baz, foo.bar.baz + 1 AS X FROM scope() WHERE foo.bar.baz = 5"},
+
+	{"Foreach with workers in order",
+		"SELECT * FROM foreach(row={SELECT * FROM range(start=1, end=8)}, " +
+			"query={SELECT value, value * 2 AS Double FROM scope()}, " +
+			"workers=4, ordered=TRUE)"},
+
+	{"Foreach binding rows to a variable",
+		"SELECT * FROM foreach(row=[dict(X=1, Y=2), dict(X=3, Y=4)], " +
+			"var='Item', query={SELECT Item.X AS X, Item AS Row, Y FROM scope()})"},
+
+	{"Join two queries on a column",
+		"SELECT * FROM join(" +
+			"lhs={SELECT * FROM foreach(row=[dict(Id=1, A='a1'), dict(Id=2, A='a2'), dict(Id=3, A='a3')])}, " +
+			"rhs={SELECT * FROM foreach(row=[dict(Key=2, B='b2'), dict(Key=1, B='b1'), dict(Key=1, B='b1.1')])}, " +
+			"lhs_on='Id', rhs_on='Key') ORDER BY B"},
+	{"Zip two queries row by row", "SELECT * FROM zip(lhs={SELECT _value AS A FROM foreach(row=[1, 2, 3])}, rhs={SELECT _value AS B FROM foreach(row=[10, 11])})"},
+	{"Zip two queries until the longest", "SELECT * FROM zip(lhs={SELECT _value AS A FROM foreach(row=[1, 2, 3])}, rhs={SELECT _value AS B FROM foreach(row=[10, 11])}, longest=TRUE)"},
+	{"Chain queries asynchronously", "SELECT * FROM chain(a={SELECT _value AS A FROM foreach(row=[1, 2])}, b={SELECT _value AS A FROM foreach(row=[3, 4])}, async=TRUE) ORDER BY A"},
+	{"Sample every second row", "SELECT * FROM sample(query={SELECT * FROM foreach(row=[1, 2, 3, 4, 5])}, n=2)"},
+	{"Head of a query", "SELECT * FROM head(query={SELECT * FROM foreach(row=[1, 2, 3, 4, 5])}, count=2)"},
+	{"Tail of a query", "SELECT * FROM tail(query={SELECT * FROM foreach(row=[1, 2, 3, 4, 5])}, count=2)"},
+	{"Tail of a short query", "SELECT * FROM tail(query={SELECT * FROM foreach(row=[1, 2])}, count=5)"},
+	{"Sort by a column", "SELECT * FROM sort(query={SELECT * FROM foreach(row=[dict(A=1, B=3), dict(A=2, B=1), dict(A=3, B=2)])}, key='B')"},
+	{"Sort descending inside foreach", "SELECT * FROM foreach(row=[dict(X=10)], query={SELECT * FROM sort(query={SELECT _value + X AS Value FROM foreach(row=[1, 3, 2])}, key='Value', desc=TRUE)})"},
+	{"Uniq drops consecutive duplicates", "SELECT * FROM uniq(query={SELECT * FROM foreach(row=[1, 1, 2, 2, 1, 3])})"},
+	{"Uniq drops all duplicates on a key", "SELECT * FROM uniq(query={SELECT * FROM foreach(row=[dict(A=1, B=1), dict(A=1, B=2), dict(A=2, B=3), dict(A=1, B=4)])}, key='A', all=TRUE)"},
+	{"Stats over columns", "SELECT * FROM stats(query={SELECT * FROM foreach(row=[dict(A=2, B='x'), dict(A=4), dict(A=4), dict(A=4), dict(A=5), dict(A=5), dict(A=7), dict(A=9)])}, columns=['A', 'B'])"},
+	{"Pivot values into columns", "SELECT * FROM pivot(query={SELECT * FROM foreach(row=[dict(Host='a', Metric='cpu', V=1), dict(Host='a', Metric='mem', V=2), dict(Host='b', Metric='cpu', V=3)])}, key='Host', column='Metric', value='V')"},
+	{"Unpivot columns into rows", "SELECT * FROM unpivot(query={SELECT * FROM foreach(row=[dict(Host='a', cpu=1, mem=2), dict(Host='b', cpu=3)])}, columns=['cpu', 'mem'])"},
+	{"Rollup subtotals", "SELECT * FROM rollup(query={SELECT * FROM foreach(row=[dict(Dir='a', Ext='exe', Size=1), dict(Dir='a', Ext='txt', Size=2), dict(Dir='a', Ext='exe', Size=3), dict(Dir='b', Ext='exe', Size=4)])}, keys=['Dir', 'Ext'], sum='Size')"},
+	{"Iterate over a range", "SELECT * FROM foreach(row=range(start=1, end=10, step=3))"},
+	{"Iterate over a descending range", "SELECT _value FROM foreach(row=range(start=3, end=0, step=-1))"},
+	{"Range membership", "SELECT 4 in range(end=10, step=2) AS Even, 5 in range(end=10, step=2) AS Odd, 10 in range(end=10) AS End, 2.5 in range(end=10) AS Float FROM scope()"},
+	{"Timestamp epoch autodetection", "SELECT timestamp(epoch=1600000000, tz='UTC') AS Sec, timestamp(epoch=1600000000123, tz='UTC') AS Ms, timestamp(epoch=1600000000123456, tz='UTC') AS Us, timestamp(epoch=1600000000123456789, tz='UTC') AS Ns, timestamp(epoch=1600000000.5, tz='UTC') AS Float FROM scope()"},
+	{"Timestamp string parsing", "SELECT timestamp(string='2020-09-13T12:26:40Z') AS RFC3339, timestamp(string='2020-09-13 12:26:40') AS Plain, timestamp(string='1600000000') AS Numeric, timestamp(string='13/09/2020 12:26', format='02/01/2006 15:04') AS Custom, timestamp(string='not a time') AS Invalid FROM scope()"},
+	{"Coalesce returns the first non NULL", "SELECT coalesce(a=NULL, b=no_such_var, c=2, d=3) AS A, firstof(a=NULL, b='x') AS B, coalesce(a=NULL) AS C, coalesce(a=1, b=panic()) AS D FROM scope()"},
+	{"NULL propagation in arithmetic", "SELECT 1 + NULL AS Add, 'a' + NULL AS AddString, 1 - NULL AS Sub, NULL * 2 AS Mul, 2 / NULL AS Div, 0 / 2.0 AS DivZero, coalesce(a=1 + NULL, b=0) AS Default FROM scope()"},
+	{"Prefix suffix and substring tests", "SELECT starts_with(string='C:\\Windows\\System32', prefix='C:\\Windows') AS Prefix, starts_with(string='hello.exe', prefix=['a', 'HE'], nocase=TRUE) AS AnyPrefix, ends_with(string='hello.exe', suffix=['.dll', '.exe']) AS Suffix, ends_with(string='hello.EXE', suffix='.exe') AS CaseSuffix, contains(string='a.b*c', substring='.b*') AS Contains, contains(string='abc', substring='x') AS Missing FROM scope()"},
+	{"Truncate strings", "SELECT truncate(str='hello world', len=8) AS Default, truncate(str='hello world', len=8, suffix='...') AS Dots, truncate(str='hello', len=8) AS Short, truncate(str='hello', len=2, suffix='...') AS NoRoom, truncate(str='héllo wörld', len=6) AS Chars, truncate(str='héllo wörld', len=6, bytes=TRUE, suffix='') AS Bytes, truncate(str='日本語', len=4, bytes=TRUE, suffix='') AS Boundary FROM scope()"},
+	{"Elide the middle of strings", "SELECT elide_middle(str='/usr/local/share/doc/vfilter/README', len=20) AS Path, elide_middle(str='abcdefghij', len=6, sep='..') AS Dots, elide_middle(str='abc', len=6) AS Short, elide_middle(str='日本語日本語', len=10, bytes=TRUE) AS Bytes FROM scope()"},
+	{"Humanize sizes", "SELECT humanize_bytes(bytes=13982347234) AS Binary, humanize_bytes(bytes=13982347234, si=TRUE) AS SI, humanize_bytes(bytes=512) AS Small, humanize_bytes(bytes=-2048) AS Negative FROM scope()"},
+	{"Humanize durations", "SELECT humanize_duration(duration=93784) AS Seconds, humanize_duration(duration=2h30m) AS Literal, humanize_duration(duration=0.25) AS Fraction, humanize_duration(duration=-90) AS Negative FROM scope()"},
+	{"Humanize numbers", "SELECT humanize_number(number=13982347234) AS Int, humanize_number(number=-1234567.891) AS Float, humanize_number(number=1234567.891, precision=1, sep=' ') AS Precision, humanize_number(number=999) AS Small, humanize_number(number='x') AS Invalid FROM scope()"},
+	{"Parse formatted numbers", "SELECT parse_number(string='1,234.56') AS En, parse_number(string='1.234,56') AS De, parse_number(string='1.234.567') AS Grouped, parse_number(string='12,5') AS Comma, parse_number(string='1,234', decimal=',') AS Hint, parse_number(string=\"1'234'567\") AS Swiss, parse_number(string='-2 500') AS Spaces, parse_number(string='1,234') + 1 AS Sum, parse_number(string='abc') AS Invalid FROM scope()"},
+	{"Parse integers in other bases", "SELECT parse_int(string='ff', base=16) AS Hex, parse_int(string='0x1F', base=16) AS Prefixed, parse_int(string='0o17', base=0) AS Detected, parse_int(string='1010', base=2) AS Binary, parse_int(string='42') AS Decimal, parse_int(string='0xFFFFFFFFFFFFFFFF', base=16) AS Unsigned, parse_int(string='zz', base=10) AS Invalid FROM scope()"},
+	{"Format integers in other bases", "SELECT format_int(value=255) AS Hex, format_int(value=5, base=2, pad=8) AS Binary, format_int(value=8, base=8) AS Octal, format_int(value=-255, pad=4) AS Negative, format_int(value=parse_int(string='0xFFFFFFFFFFFFFFFF', base=16)) AS Unsigned, format_int(value=1, base=99) AS BadBase FROM scope()"},
+	{"Humanize out of range durations", "SELECT humanize_duration(duration=100000000000.0) AS Large, humanize_duration(duration=-100000000000.0) AS NegativeLarge, humanize_duration(duration=-9223372036.854776) AS Min, humanize_duration(duration=9223372036.8) AS Max FROM scope()"},
+	{"Tail with an absurd count", "SELECT * FROM tail(query={SELECT * FROM foreach(row=[1, 2, 3])}, count=1000000000000)"},
+	{"Tail with a large count", "SELECT * FROM tail(query={SELECT * FROM foreach(row=[1, 2, 3])}, count=100000000)"},
+	{"Tail with a negative count", "SELECT * FROM tail(query={SELECT * FROM foreach(row=[1, 2, 3])}, count=-1)"},
+	{"Format integers with a large pad", "SELECT len(list=format_int(value=1, pad=1000)) AS Padded, format_int(value=1, pad=100000000000) AS TooLarge FROM scope()"},
+	{"Join on keys of different types",
+		"SELECT * FROM join(" +
+			"lhs={SELECT * FROM foreach(row=[dict(Id=1, A='a1'), dict(Id='2', A='a2'), dict(Id=3.5, A='a3')])}, " +
+			"rhs={SELECT * FROM foreach(row=[dict(Key=1.0, B='b1'), dict(Key=2, B='b2'), dict(Key=3.5, B='b3')])}, " +
+			"lhs_on='Id', rhs_on='Key') ORDER BY B"},
+	{"Sort by a lambda", "SELECT * FROM sort(query={SELECT * FROM foreach(row=['ccc', 'a', 'bb'])}, key='x => len(list=x._value)', desc=TRUE)"},
+	{"Timestamps default to UTC", "SELECT timestamp(epoch=0) AS Zero, timestamp(epoch=1600000000) AS Epoch, timestamp(winfiletime=132444736000000000) AS WinFileTime, timestamp(string='2020-09-13 12:26:40') AS String FROM scope()"},
 }
 
 var multiVQLTest = []vqlTest{
@@ -1229,6 +1336,21 @@ SELECT * FROM flatten(query={
    SELECT *, SQ
    FROM foreach(row=[dict(A=1)])
 })`},
+	{"Eager columns are evaluated for filtered rows", `
+LET X <= SELECT eager(item=set_env(column="Eager", value=TRUE)) AS E,
+       set_env(column="Lazy", value=TRUE) AS L
+FROM scope() WHERE FALSE
+
+-- Eager should be set but Lazy should not
+SELECT RootEnv.Eager AS Eager, RootEnv.Lazy AS Lazy FROM scope()
+`},
+	{"Definitions record where LET symbols were defined", `
+LET X = SELECT * FROM range(end=2)
+LET Y(A) = A + 1
+LET X <= 5
+
+SELECT Name, Operator, Parameters, Line, Column, Source FROM definitions()
+`},
 }
 
 type _RangeArgs struct {
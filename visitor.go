@@ -197,6 +197,9 @@ func (self *Visitor) Visit(node interface{}) {
 
 	case *_From:
 		self.visitPlugin(&t.Plugin)
+		if t.Name != nil {
+			self.push(" AS ", *t.Name)
+		}
 
 	case *Plugin:
 		self.visitPlugin(t)
@@ -439,6 +442,9 @@ func (self *Visitor) visitConditionOperand(node *_ConditionOperand) {
 	if node.Right != nil {
 		self.push(" ", node.Right.Operator, " ")
 		self.Visit(node.Right.Right)
+		if len(node.Right.Flags) > 0 {
+			self.push(" WITH (", strings.Join(node.Right.Flags, ", "), ")")
+		}
 	}
 }
 
@@ -521,6 +527,15 @@ func (self *Visitor) visitValue(node *_Value) {
 		return
 	}
 
+	if node.StrDuration != nil {
+		if node.Negated {
+			self.push("-")
+		}
+		self.push(*node.StrDuration)
+		node.mu.Unlock()
+		return
+	}
+
 	if node.Boolean != nil {
 		self.push(*node.Boolean)
 		node.mu.Unlock()
@@ -832,6 +847,9 @@ func (self *Visitor) visitVQL(node *VQL) {
 
 		if node.Expression != nil {
 			self.Visit(node.Expression)
+			if node.Cache != nil {
+				self.push(" CACHE ", fmt.Sprintf("%v", *node.Cache))
+			}
 			return
 		}
 
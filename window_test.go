@@ -0,0 +1,48 @@
+package vfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Velocidex/ordereddict"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowPlugin(t *testing.T) {
+	scope := makeTestScope().AppendPlugins(infinitePlugin{})
+	defer scope.Close()
+
+	// A finite query is emitted as a single partial window.
+	vql, err := Parse(`SELECT Key, Count FROM window(
+   query={ SELECT * FROM foreach(row=[dict(Key='a'), dict(Key='b'), dict(Key='a')]) },
+   period=60, key='Key')`)
+	assert.NoError(t, err)
+
+	var rows []*ordereddict.Dict
+	ctx := context.Background()
+	for row := range vql.Eval(ctx, scope) {
+		rows = append(rows, row.(*ordereddict.Dict))
+	}
+
+	assert.Equal(t, 2, len(rows))
+	key, _ := rows[0].GetString("Key")
+	assert.Equal(t, "a", key)
+	count, _ := rows[0].GetInt64("Count")
+	assert.Equal(t, int64(2), count)
+
+	// An infinite query emits a row for each window.
+	vql, err = Parse(`SELECT Count FROM window(
+   query={ SELECT * FROM infinite() }, period=0.05) LIMIT 2`)
+	assert.NoError(t, err)
+
+	rows = nil
+	for row := range vql.Eval(ctx, scope) {
+		rows = append(rows, row.(*ordereddict.Dict))
+	}
+
+	assert.Equal(t, 2, len(rows))
+	for _, row := range rows {
+		count, _ := row.GetInt64("Count")
+		assert.True(t, count > 0)
+	}
+}